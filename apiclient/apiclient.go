@@ -49,6 +49,11 @@ type Client struct {
 	client    *http.Client // HTTP client used to communicate with the API.
 	BaseURL   *url.URL     // Base URL for API requests
 	UserAgent string       // User agent used when communicating with the  API.
+
+	Timeout time.Duration // Timeout for each attempt of a request. Zero means no timeout.
+	Retry   RetryPolicy   // How to retry requests that are safe to repeat.
+
+	sleep func(time.Duration) // Waits between retries. Replaced in tests.
 }
 
 var (
@@ -61,8 +66,42 @@ var (
 	// ErrForbidden means the given user doesn't have access to the given resource, for example
 	// the requester key isn't a member of a requested team.
 	ErrForbidden = fmt.Errorf("Forbidden")
+
+	// ErrNotModified means the resource hasn't changed since the copy described by the given
+	// CacheValidators was fetched.
+	ErrNotModified = fmt.Errorf("Not modified")
+
+	// ErrRequestsToLeaveNotSupported means the API doesn't have the requests-to-leave endpoints,
+	// so members have to ask an admin to remove them instead.
+	ErrRequestsToLeaveNotSupported = fmt.Errorf("requests to leave teams aren't supported")
 )
 
+// CacheValidators are the ETag and Last-Modified headers returned with a resource. Sending
+// them back with a later request lets the server reply "not modified" instead of sending
+// the whole resource again.
+type CacheValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// RequestToLeaveTeamRequest is the body of a request to leave a team. ArmoredSignedJSON is made
+// by team.MakeRequestToLeaveSignedJSON.
+type RequestToLeaveTeamRequest struct {
+	ArmoredSignedJSON string `json:"armoredSignedJson"`
+}
+
+// ListRequestsToLeaveTeamResponse is the response listing a team's requests to leave.
+type ListRequestsToLeaveTeamResponse struct {
+	Requests []RequestToLeaveTeam `json:"requests"`
+}
+
+// RequestToLeaveTeam is a single request to leave a team, as sent by the API.
+type RequestToLeaveTeam struct {
+	UUID              string `json:"uuid"`
+	Fingerprint       string `json:"fingerprint"`
+	ArmoredSignedJSON string `json:"armoredSignedJson"`
+}
+
 // New returns a new Fluidkeys Server API client.
 func New(fluidkeysVersion string) *Client {
 	apiURL, got := os.LookupEnv("FLUIDKEYS_API_URL") // e.g. http://localhost:4747/v1/
@@ -79,6 +118,9 @@ func New(fluidkeysVersion string) *Client {
 		client:    http.DefaultClient,
 		BaseURL:   parsedURL,
 		UserAgent: userAgent + "-" + fluidkeysVersion,
+		Timeout:   defaultTimeout,
+		Retry:     defaultRetryPolicy,
+		sleep:     time.Sleep,
 	}
 }
 
@@ -103,53 +145,79 @@ func (c *Client) GetPublicKey(email string) (string, error) {
 
 // GetPublicKeyByFingerprint attempts to get a single armored public key.
 func (c *Client) GetPublicKeyByFingerprint(fingerprint fpr.Fingerprint) (*pgpkey.PgpKey, error) {
+	key, _, err := c.GetPublicKeyByFingerprintIfModified(fingerprint, CacheValidators{})
+	return key, err
+}
+
+// GetPublicKeyByFingerprintIfModified gets a single armored public key, unless it hasn't changed
+// since the copy described by validators was fetched, in which case it returns ErrNotModified.
+// It also returns the validators for the key it got back, to send with the next request.
+func (c *Client) GetPublicKeyByFingerprintIfModified(
+	fingerprint fpr.Fingerprint, validators CacheValidators) (
+	*pgpkey.PgpKey, CacheValidators, error) {
+
 	path := fmt.Sprintf("key/%s.asc", fingerprint.Hex())
 	request, err := c.newRequest("GET", path, nil)
 	if err != nil {
-		return nil, err
+		return nil, CacheValidators{}, err
+	}
+	if validators.ETag != "" {
+		request.Header.Add("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		request.Header.Add("If-Modified-Since", validators.LastModified)
 	}
 
-	response, err := c.client.Do(request)
+	response, err := c.send(request, true)
 	if err != nil {
-		return nil, err
+		return nil, CacheValidators{}, err
+	}
+
+	if response.StatusCode == http.StatusNotModified {
+		return nil, validators, ErrNotModified
 	}
-	defer response.Body.Close()
 
 	if !isSuccess(response.StatusCode) {
 		if response != nil && response.StatusCode == http.StatusNotFound {
-			return nil, ErrPublicKeyNotFound
+			return nil, CacheValidators{}, ErrPublicKeyNotFound
 		}
-		return nil, makeErrorForAPIResponse(response)
+		return nil, CacheValidators{}, makeErrorForAPIResponse(response)
 	}
 
 	if response.Body == nil {
-		return nil, fmt.Errorf("got http %d, but with missing body", response.StatusCode)
+		return nil, CacheValidators{}, fmt.Errorf(
+			"got http %d, but with missing body", response.StatusCode)
 	}
 
 	bodyData, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		return nil, CacheValidators{}, fmt.Errorf("error reading response body: %v", err)
 	}
 	if len(bodyData) == 0 {
-		return nil, fmt.Errorf("got http %d, but with empty body", response.StatusCode)
+		return nil, CacheValidators{}, fmt.Errorf(
+			"got http %d, but with empty body", response.StatusCode)
 	}
 
 	retrievedKey, err := pgpkey.LoadFromArmoredPublicKey(string(bodyData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to load armored key: %v", err)
+		return nil, CacheValidators{}, fmt.Errorf("failed to load armored key: %v", err)
 	}
 
 	if retrievedKey.Fingerprint() != fingerprint {
 		log.Printf("danger: requested key %s from API but got back key %s\n",
 			fingerprint, retrievedKey.Fingerprint())
 
-		return nil, fmt.Errorf(
+		return nil, CacheValidators{}, fmt.Errorf(
 			"requested key %s but got back %s",
 			fingerprint, retrievedKey.Fingerprint(),
 		)
 	}
 
-	return retrievedKey, nil
+	newValidators := CacheValidators{
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+	}
+	return retrievedKey, newValidators, nil
 }
 
 // CreateSecret creates a secret for the given recipient
@@ -182,7 +250,7 @@ func (c *Client) UpsertTeam(roster string, rosterSignature string,
 	}
 	request.Header.Add("authorization", authorization(signerFingerprint))
 
-	_, err = c.do(request, nil)
+	_, err = c.doUpsert(request, nil)
 	return err
 }
 
@@ -231,7 +299,7 @@ func (c *Client) UpsertPublicKey(armoredPublicKey string, privateKey *pgpkey.Pgp
 		return fmt.Errorf("Failed to upload key: %s", err)
 	}
 	decodedUpsertResponse := new(v1structs.UpsertPublicKeyResponse)
-	_, err = c.do(request, &decodedUpsertResponse)
+	_, err = c.doUpsert(request, &decodedUpsertResponse)
 	return err
 }
 
@@ -302,7 +370,7 @@ func (c *Client) RequestToJoinTeam(
 
 	response, err := c.do(request, nil)
 	if err != nil {
-		if response.StatusCode == http.StatusConflict {
+		if response != nil && response.StatusCode == http.StatusConflict {
 			return fmt.Errorf("already got request to join team for %s", email)
 		}
 		return err
@@ -359,6 +427,113 @@ func (c *Client) DeleteRequestToJoinTeam(teamUUID uuid.UUID, requestUUID uuid.UU
 	return err
 }
 
+// RequestToLeaveTeam posts a request to remove the given key from the team identified by the
+// UUID. The request is signed by privateKey, which must be unlocked, so the team's admins can
+// check it came from the member.
+func (c *Client) RequestToLeaveTeam(teamUUID uuid.UUID, privateKey *pgpkey.PgpKey) (err error) {
+	armoredSignedJSON, err := team.MakeRequestToLeaveSignedJSON(teamUUID, privateKey, time.Now())
+	if err != nil {
+		return fmt.Errorf("Failed to create ArmoredSignedJSON: %s", err)
+	}
+
+	path := fmt.Sprintf("team/%s/requests-to-leave", teamUUID)
+	request, err := c.newRequest(
+		"POST", path, RequestToLeaveTeamRequest{ArmoredSignedJSON: armoredSignedJSON})
+	if err != nil {
+		return err
+	}
+	request.Header.Add("authorization", authorization(privateKey.Fingerprint()))
+
+	response, err := c.do(request, nil)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusConflict {
+			return fmt.Errorf("already got request to leave team for %s", privateKey.Fingerprint())
+		}
+		if isUnsupported(response) {
+			return ErrRequestsToLeaveNotSupported
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ListRequestsToLeaveTeam for the team with the given UUID. The requests aren't verified: the
+// caller should check each one with team.RequestToLeaveTeam.Verify before acting on it.
+func (c *Client) ListRequestsToLeaveTeam(teamUUID uuid.UUID, fingerprint fpr.Fingerprint) (
+	requestsToLeaveTeam []team.RequestToLeaveTeam, err error) {
+
+	path := fmt.Sprintf("team/%s/requests-to-leave", teamUUID)
+	request, err := c.newRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Add("authorization", authorization(fingerprint))
+	decodedJSON := new(ListRequestsToLeaveTeamResponse)
+	response, err := c.do(request, &decodedJSON)
+	if err != nil {
+		if isUnsupported(response) {
+			return nil, ErrRequestsToLeaveNotSupported
+		}
+		return nil, err
+	}
+	return parseRequestsToLeaveTeam(teamUUID, decodedJSON.Requests), nil
+}
+
+// isUnsupported returns whether the response means the API doesn't have the endpoint at all.
+func isUnsupported(response *http.Response) bool {
+	if response == nil {
+		return false
+	}
+	switch response.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// DeleteRequestToLeaveTeam deletes a request to leave a team
+func (c *Client) DeleteRequestToLeaveTeam(teamUUID uuid.UUID, requestUUID uuid.UUID) error {
+	path := fmt.Sprintf("team/%s/requests-to-leave/%s", teamUUID, requestUUID)
+	request, err := c.newRequest("DELETE", path, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(request, nil)
+	return err
+}
+
+// parseRequestsToLeaveTeam converts requests from the API, dropping any with an invalid UUID
+// or fingerprint.
+func parseRequestsToLeaveTeam(teamUUID uuid.UUID, jsonRequests []RequestToLeaveTeam) (
+	requestsToLeaveTeam []team.RequestToLeaveTeam) {
+
+	for _, jsonRequest := range jsonRequests {
+		requestUUID, err := uuid.FromString(jsonRequest.UUID)
+		if err != nil {
+			continue
+		}
+		requestFingerprint, err := fpr.Parse(jsonRequest.Fingerprint)
+		if err != nil {
+			continue
+		}
+
+		requestsToLeaveTeam = append(requestsToLeaveTeam, team.RequestToLeaveTeam{
+			UUID:              requestUUID,
+			TeamUUID:          teamUUID,
+			Fingerprint:       requestFingerprint,
+			ArmoredSignedJSON: jsonRequest.ArmoredSignedJSON,
+		})
+	}
+	return requestsToLeaveTeam
+}
+
+// VerifiesEmails returns true: the API only returns keys for email addresses that have been
+// verified by sending an email to them.
+func (c *Client) VerifiesEmails() bool {
+	return true
+}
+
 // Log sends an event to the API. The event is sent in a goroutine so it doesn't block the
 // main thread.
 func (c *Client) Log(event Event) error {
@@ -455,15 +630,12 @@ func signText(bytesToSign []byte, key *pgpkey.PgpKey) (armoredSigned string, err
 }
 
 func makeErrorForAPIResponse(response *http.Response) error {
-	if response.StatusCode == http.StatusUnauthorized {
-		return fmt.Errorf("Couldn't sign in to API")
-	}
+	apiError := &APIError{StatusCode: response.StatusCode}
 
-	apiErrorResponseDetail := decodeErrorResponse(response)
-	if apiErrorResponseDetail != "" {
-		return fmt.Errorf("API error: %d %s", response.StatusCode, apiErrorResponseDetail)
+	if response.StatusCode != http.StatusUnauthorized {
+		apiError.Detail = decodeErrorResponse(response)
 	}
-	return fmt.Errorf("API error: %d", response.StatusCode)
+	return apiError
 }
 
 func decodeErrorResponse(response *http.Response) string {
@@ -516,12 +688,26 @@ func (c *Client) newRequest(method, relativePath string, requestData interface{}
 
 // do sends an API request and decodes the JSON response, storing it in the
 // value pointed to by responseData. If an API error occurs, it returns error.
+// Requests with idempotent methods (GET, DELETE etc) are retried on temporary failures.
 func (c *Client) do(req *http.Request, responseData interface{}) (response *http.Response, err error) {
-	response, err = c.client.Do(req)
+	return c.doWithRetries(req, responseData, isIdempotent(req.Method))
+}
+
+// doUpsert is like do, but always retries on temporary failures. Use it for POST requests which
+// are safe to repeat, such as upserts.
+func (c *Client) doUpsert(req *http.Request, responseData interface{}) (
+	response *http.Response, err error) {
+
+	return c.doWithRetries(req, responseData, true)
+}
+
+func (c *Client) doWithRetries(req *http.Request, responseData interface{}, retryable bool) (
+	response *http.Response, err error) {
+
+	response, err = c.send(req, retryable)
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
 
 	if isSuccess(response.StatusCode) {
 		if responseData != nil && isJSON(response) && response.Body != nil {
@@ -182,7 +182,7 @@ func TestGetPublicKeyByFingerprint(t *testing.T) {
 		_, err := client.GetPublicKeyByFingerprint(exampledata.ExampleFingerprint4)
 
 		assert.GotError(t, err)
-		assert.Equal(t, fmt.Errorf("API error: 500"), err)
+		assert.Equal(t, &APIError{StatusCode: 500}, err)
 	})
 
 	t.Run("responds with junk", func(t *testing.T) {
@@ -207,6 +207,51 @@ func TestGetPublicKeyByFingerprint(t *testing.T) {
 	})
 }
 
+func TestGetPublicKeyByFingerprintIfModified(t *testing.T) {
+	const etag = `"abc123"`
+	const lastModified = "Sat, 15 Jun 2019 16:35:14 GMT"
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, exampledata.ExamplePublicKey4)
+	}
+
+	t.Run("returns the key and validators when there are no validators", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+		mux.HandleFunc("/key/"+exampledata.ExampleFingerprint4.Hex()+".asc", handler)
+
+		key, validators, err := client.GetPublicKeyByFingerprintIfModified(
+			exampledata.ExampleFingerprint4, CacheValidators{},
+		)
+
+		assert.NoError(t, err)
+		assert.Equal(t, exampledata.ExampleFingerprint4, key.Fingerprint())
+		assert.Equal(t, CacheValidators{ETag: etag, LastModified: lastModified}, validators)
+	})
+
+	t.Run("returns ErrNotModified when the etag matches", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+		mux.HandleFunc("/key/"+exampledata.ExampleFingerprint4.Hex()+".asc", handler)
+
+		given := CacheValidators{ETag: etag, LastModified: lastModified}
+		key, validators, err := client.GetPublicKeyByFingerprintIfModified(
+			exampledata.ExampleFingerprint4, given,
+		)
+
+		assert.Equal(t, ErrNotModified, err)
+		assert.Equal(t, true, key == nil)
+		assert.Equal(t, given, validators)
+	})
+}
+
 func TestCreateSecret(t *testing.T) {
 	client, mux, _, teardown := setup()
 	defer teardown()
@@ -321,7 +366,7 @@ func TestUpsertTeam(t *testing.T) {
 			fingerprint,
 		)
 
-		assert.Equal(t, fmt.Errorf("API error: 500 signing key not in roster"), err)
+		assert.Equal(t, &APIError{StatusCode: 500, Detail: "signing key not in roster"}, err)
 	})
 }
 
@@ -395,7 +440,7 @@ func TestGetTeamName(t *testing.T) {
 		_, err := client.GetTeamName(teamUUID)
 
 		assert.GotError(t, err)
-		assert.Equal(t, fmt.Errorf("API error: 500"), err)
+		assert.Equal(t, &APIError{StatusCode: 500}, err)
 	})
 }
 
@@ -489,7 +534,7 @@ func TestGetTeamRoster(t *testing.T) {
 		_, _, err := client.GetTeamRoster(errorUUID, requesterKey.Fingerprint())
 
 		assert.GotError(t, err)
-		assert.Equal(t, fmt.Errorf("API error: 500"), err)
+		assert.Equal(t, &APIError{StatusCode: 500}, err)
 	})
 }
 
@@ -572,7 +617,7 @@ func TestRequestToJoinTeam(t *testing.T) {
 			fingerprint,
 			"jane@example.com",
 		)
-		assert.Equal(t, fmt.Errorf("API error: 500 can't write to database"), err)
+		assert.Equal(t, &APIError{StatusCode: 500, Detail: "can't write to database"}, err)
 	})
 }
 
@@ -781,8 +826,133 @@ func TestDeleteRequestToJoinTeam(t *testing.T) {
 
 		err := client.DeleteRequestToJoinTeam(teamUUID, unknownRequestUUID)
 
-		assert.Equal(t, fmt.Errorf("API error: 404"), err)
+		assert.Equal(t, &APIError{StatusCode: 404}, err)
+	})
+}
+
+func TestRequestToLeaveTeam(t *testing.T) {
+	key, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey2, "test2")
+	if err != nil {
+		t.Fatalf("failed to load example key: %v", err)
+	}
+	mockTeamUUID := uuid.Must(uuid.NewV4())
+
+	t.Run("sends a request signed by the leaving key", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+
+		mockResponseHandler := func(w http.ResponseWriter, r *http.Request) {
+			assertClientSentVerb(t, "POST", r.Method)
+			assertClientSentValidAuthHeader(t, key.Fingerprint(), r.Header)
+
+			gotRequest := new(RequestToLeaveTeamRequest)
+			json.NewDecoder(r.Body).Decode(gotRequest)
+
+			request := team.RequestToLeaveTeam{
+				TeamUUID:          mockTeamUUID,
+				Fingerprint:       key.Fingerprint(),
+				ArmoredSignedJSON: gotRequest.ArmoredSignedJSON,
+			}
+			_, err := request.Verify(key, time.Now())
+			assert.NoError(t, err)
+			w.WriteHeader(http.StatusCreated)
+		}
+		mux.HandleFunc(
+			fmt.Sprintf("/team/%s/requests-to-leave", mockTeamUUID),
+			mockResponseHandler,
+		)
+
+		assert.NoError(t, client.RequestToLeaveTeam(mockTeamUUID, key))
 	})
+
+	t.Run("with a conflicting response status", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+
+		mux.HandleFunc(
+			fmt.Sprintf("/team/%s/requests-to-leave", mockTeamUUID),
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusConflict)
+			},
+		)
+
+		err := client.RequestToLeaveTeam(mockTeamUUID, key)
+		assert.Equal(t,
+			fmt.Errorf("already got request to leave team for %s", key.Fingerprint()), err)
+	})
+
+	t.Run("when the API doesn't support requests to leave", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+
+		mux.HandleFunc(
+			fmt.Sprintf("/team/%s/requests-to-leave", mockTeamUUID),
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+		)
+
+		err := client.RequestToLeaveTeam(mockTeamUUID, key)
+		assert.Equal(t, ErrRequestsToLeaveNotSupported, err)
+	})
+}
+
+func TestListRequestsToLeaveTeam(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	authFingerprint := fpr.MustParse("ABABABABABABABABABABABABABABABABABABABAB")
+	teamUUID := uuid.Must(uuid.NewV4())
+
+	mux.HandleFunc(
+		fmt.Sprintf("/team/%s/requests-to-leave", teamUUID),
+		func(w http.ResponseWriter, r *http.Request) {
+			assertClientSentVerb(t, "GET", r.Method)
+			assertClientSentValidAuthHeader(t, authFingerprint, r.Header)
+			w.Header().Add("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(ListRequestsToLeaveTeamResponse{
+				Requests: []RequestToLeaveTeam{
+					{
+						UUID:              "8e26e4df0d474f7f9a07a37b2aa92104",
+						Fingerprint:       "OPENPGP4FPR:AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA",
+						ArmoredSignedJSON: "signed",
+					},
+					{
+						UUID:        "invalid-uuid",
+						Fingerprint: "OPENPGP4FPR:CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD",
+					},
+				},
+			})
+		},
+	)
+
+	got, err := client.ListRequestsToLeaveTeam(teamUUID, authFingerprint)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []team.RequestToLeaveTeam{
+		{
+			UUID:              uuid.Must(uuid.FromString("8e26e4df0d474f7f9a07a37b2aa92104")),
+			TeamUUID:          teamUUID,
+			Fingerprint:       fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+			ArmoredSignedJSON: "signed",
+		},
+	}, got)
+}
+
+func TestListRequestsToLeaveTeamNotSupported(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	teamUUID := uuid.Must(uuid.NewV4())
+	mux.HandleFunc(
+		fmt.Sprintf("/team/%s/requests-to-leave", teamUUID),
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+	)
+
+	_, err := client.ListRequestsToLeaveTeam(teamUUID, exampledata.ExampleFingerprint2)
+	assert.Equal(t, ErrRequestsToLeaveNotSupported, err)
 }
 
 func TestLog(t *testing.T) {
@@ -930,6 +1100,7 @@ func setup() (client *Client, mux *http.ServeMux, serverURL string, teardown fun
 	client = New("vtest")
 	url, _ := url.Parse(server.URL + "/")
 	client.BaseURL = url
+	client.sleep = func(time.Duration) {} // don't wait between retries in tests
 
 	return client, mux, server.URL, server.Close
 }
@@ -0,0 +1,62 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package apiclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// NetworkError means the request never got a response from the API, for example because of a
+// timeout, a DNS failure or a dropped connection. Retrying later may succeed.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("network error talking to Fluidkeys: %v", e.Err)
+}
+
+// APIError means the API responded but rejected the request with a non-2xx status code.
+type APIError struct {
+	StatusCode int
+	Detail     string // from the API's JSON error response, if any
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == http.StatusUnauthorized {
+		return "Couldn't sign in to API"
+	}
+	if e.Detail != "" {
+		return fmt.Sprintf("API error: %d %s", e.StatusCode, e.Detail)
+	}
+	return fmt.Sprintf("API error: %d", e.StatusCode)
+}
+
+// IsNetworkError returns true if the error means the API couldn't be reached at all, as opposed
+// to the API rejecting the request.
+func IsNetworkError(err error) bool {
+	_, ok := err.(*NetworkError)
+	return ok
+}
+
+// IsAPIError returns true if the API responded to the request with an error status code.
+func IsAPIError(err error) bool {
+	_, ok := err.(*APIError)
+	return ok
+}
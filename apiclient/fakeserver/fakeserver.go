@@ -0,0 +1,688 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package fakeserver is an in-memory implementation of the Fluidkeys v1 API for use in tests.
+// It stores keys, secrets, teams and requests to join or leave teams, and makes the same signature
+// checks as the real server, so the client can be tested end-to-end without the network.
+package fakeserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluidkeys/api/v1structs"
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/clearsign"
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/keylookup"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/gofrs/uuid"
+)
+
+// Server is a running fake Fluidkeys API. Use BaseURL to point a client at it, and Close when
+// finished.
+type Server struct {
+	httpServer *httptest.Server
+	mutex      sync.Mutex
+
+	keys           map[fpr.Fingerprint]*pgpkey.PgpKey
+	singleUseUUIDs map[string]bool
+	secrets        map[fpr.Fingerprint][]storedSecret
+	teams          map[uuid.UUID]storedTeam
+	requests       map[uuid.UUID][]v1structs.RequestToJoinTeam
+	leaveRequests  map[uuid.UUID][]apiclient.RequestToLeaveTeam
+	events         []v1structs.CreateEventRequest
+}
+
+type storedSecret struct {
+	uuid   uuid.UUID
+	secret v1structs.Secret
+}
+
+type storedTeam struct {
+	team      *team.Team
+	roster    string
+	signature string
+}
+
+// New starts a fake server. The caller must call Close when finished with it.
+func New() *Server {
+	s := &Server{
+		keys:           map[fpr.Fingerprint]*pgpkey.PgpKey{},
+		singleUseUUIDs: map[string]bool{},
+		secrets:        map[fpr.Fingerprint][]storedSecret{},
+		teams:          map[uuid.UUID]storedTeam{},
+		requests:       map[uuid.UUID][]v1structs.RequestToJoinTeam{},
+		leaveRequests:  map[uuid.UUID][]apiclient.RequestToLeaveTeam{},
+	}
+	s.httpServer = httptest.NewServer(s)
+	return s
+}
+
+// BaseURL returns the URL that apiclient.Client.BaseURL should be set to.
+func (s *Server) BaseURL() *url.URL {
+	baseURL, err := url.Parse(s.httpServer.URL + "/")
+	if err != nil {
+		panic(err)
+	}
+	return baseURL
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// AddPublicKey stores a public key as if it had been uploaded, without needing the private key.
+func (s *Server) AddPublicKey(key *pgpkey.PgpKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[key.Fingerprint()] = key
+}
+
+// Events returns the events logged to the server, oldest first.
+func (s *Server) Events() []v1structs.CreateEventRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]v1structs.CreateEventRequest{}, s.events...)
+}
+
+// ServeHTTP routes requests to the handler for each API endpoint.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	route := r.Method + " " + routePattern(parts)
+
+	switch route {
+	case "GET email/*/key":
+		s.getPublicKeyByEmail(w, parts[1])
+
+	case "GET key/*":
+		s.getPublicKeyByFingerprint(w, r, parts[1])
+
+	case "POST keys":
+		s.upsertPublicKey(w, r)
+
+	case "POST secrets":
+		s.createSecret(w, r)
+
+	case "GET secrets":
+		s.listSecrets(w, r)
+
+	case "DELETE secrets/*":
+		s.deleteSecret(w, r, parts[1])
+
+	case "POST teams":
+		s.upsertTeam(w, r)
+
+	case "GET team/*":
+		s.withTeam(w, parts[1], s.getTeam)
+
+	case "GET team/*/roster":
+		s.withTeam(w, parts[1], func(w http.ResponseWriter, t storedTeam) {
+			s.getTeamRoster(w, r, t)
+		})
+
+	case "POST team/*/requests-to-join":
+		s.withTeam(w, parts[1], func(w http.ResponseWriter, t storedTeam) {
+			s.createRequestToJoinTeam(w, r, t)
+		})
+
+	case "GET team/*/requests-to-join":
+		s.withTeam(w, parts[1], func(w http.ResponseWriter, t storedTeam) {
+			s.listRequestsToJoinTeam(w, r, t)
+		})
+
+	case "DELETE team/*/requests-to-join/*":
+		s.withTeam(w, parts[1], func(w http.ResponseWriter, t storedTeam) {
+			s.deleteRequestToJoinTeam(w, t, parts[3])
+		})
+
+	case "POST team/*/requests-to-leave":
+		s.withTeam(w, parts[1], func(w http.ResponseWriter, t storedTeam) {
+			s.createRequestToLeaveTeam(w, r, t)
+		})
+
+	case "GET team/*/requests-to-leave":
+		s.withTeam(w, parts[1], func(w http.ResponseWriter, t storedTeam) {
+			s.listRequestsToLeaveTeam(w, r, t)
+		})
+
+	case "DELETE team/*/requests-to-leave/*":
+		s.withTeam(w, parts[1], func(w http.ResponseWriter, t storedTeam) {
+			s.deleteRequestToLeaveTeam(w, t, parts[3])
+		})
+
+	case "POST events":
+		s.createEvent(w, r)
+
+	default:
+		writeError(w, http.StatusNotFound, "no such endpoint: "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// routePattern replaces the variable parts of the path (emails, fingerprints, UUIDs) with `*`
+func routePattern(parts []string) string {
+	pattern := make([]string, len(parts))
+	for i, part := range parts {
+		if i%2 == 1 { // e.g. team/<uuid>/requests-to-join/<uuid>
+			pattern[i] = "*"
+		} else {
+			pattern[i] = part
+		}
+	}
+	return strings.Join(pattern, "/")
+}
+
+func (s *Server) getPublicKeyByEmail(w http.ResponseWriter, email string) {
+	for _, key := range s.keys {
+		for _, keyEmail := range key.Emails(true) {
+			if strings.EqualFold(keyEmail, email) {
+				s.writeArmoredKey(w, key, true)
+				return
+			}
+		}
+	}
+	writeError(w, http.StatusNotFound, "no key found for "+email)
+}
+
+// getPublicKeyByFingerprint serves the armored key with an ETag, and honours If-None-Match so
+// clients can revalidate their cached copy.
+func (s *Server) getPublicKeyByFingerprint(w http.ResponseWriter, r *http.Request, filename string) {
+	fingerprint, err := fpr.Parse(strings.TrimSuffix(filename, ".asc"))
+	if err != nil || !strings.HasSuffix(filename, ".asc") {
+		writeError(w, http.StatusBadRequest, "invalid fingerprint")
+		return
+	}
+
+	key, ok := s.keys[fingerprint]
+	if !ok {
+		writeError(w, http.StatusNotFound, "no key found for "+fingerprint.Hex())
+		return
+	}
+
+	armoredKey, err := key.Armor()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(armoredKey)))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	s.writeArmoredKey(w, key, false)
+}
+
+func (s *Server) writeArmoredKey(w http.ResponseWriter, key *pgpkey.PgpKey, asJSON bool) {
+	armoredKey, err := key.Armor()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, v1structs.GetPublicKeyResponse{ArmoredPublicKey: armoredKey})
+		return
+	}
+	w.Header().Set("Content-Type", "application/pgp-keys")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, armoredKey)
+}
+
+// upsertPublicKey checks the upload is clearsigned by the key being uploaded, that the signed
+// data matches the key and that the single use UUID hasn't been seen before.
+func (s *Server) upsertPublicKey(w http.ResponseWriter, r *http.Request) {
+	var request v1structs.UpsertPublicKeyRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	key, err := pgpkey.LoadFromArmoredPublicKey(request.ArmoredPublicKey)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid public key: "+err.Error())
+		return
+	}
+
+	block, _ := clearsign.Decode([]byte(request.ArmoredSignedJSON))
+	if block == nil {
+		writeError(w, http.StatusBadRequest, "armoredSignedJSON isn't clearsigned")
+		return
+	}
+
+	if _, err := openpgp.CheckDetachedSignature(
+		openpgp.EntityList{&key.Entity},
+		bytes.NewReader(block.Bytes),
+		block.ArmoredSignature.Body,
+	); err != nil {
+		writeError(w, http.StatusBadRequest, "bad signature on armoredSignedJSON: "+err.Error())
+		return
+	}
+
+	var signedData v1structs.UpsertPublicKeySignedData
+	if err := json.Unmarshal(block.Plaintext, &signedData); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid signed JSON: "+err.Error())
+		return
+	}
+
+	expectedHash := fmt.Sprintf("%X", sha256.Sum256([]byte(request.ArmoredPublicKey)))
+	if signedData.PublicKeySHA256 != expectedHash {
+		writeError(w, http.StatusBadRequest, "publicKeySha256 doesn't match public key")
+		return
+	}
+
+	if age := time.Since(signedData.Timestamp); age > time.Hour || age < -time.Hour {
+		writeError(w, http.StatusBadRequest, "timestamp too far from current time")
+		return
+	}
+
+	if s.singleUseUUIDs[signedData.SingleUseUUID] {
+		writeError(w, http.StatusBadRequest, "singleUseUuid has already been used")
+		return
+	}
+	s.singleUseUUIDs[signedData.SingleUseUUID] = true
+
+	s.keys[key.Fingerprint()] = key
+	writeJSON(w, http.StatusOK, v1structs.UpsertPublicKeyResponse{})
+}
+
+// createSecret stores the secret for the recipient, along with metadata (the secret's UUID)
+// encrypted to the recipient's key, as the real server does.
+func (s *Server) createSecret(w http.ResponseWriter, r *http.Request) {
+	var request v1structs.SendSecretRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	fingerprint, err := fpr.Parse(strings.TrimPrefix(request.RecipientFingerprint, "OPENPGP4FPR:"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid recipientFingerprint")
+		return
+	}
+
+	recipientKey, ok := s.keys[fingerprint]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "no key found for recipient")
+		return
+	}
+
+	secretUUID := uuid.Must(uuid.NewV4())
+	metadata, err := json.Marshal(v1structs.SecretMetadata{SecretUUID: secretUUID.String()})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	encryptedMetadata, err := recipientKey.EncryptArmored(metadata)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to encrypt metadata: "+err.Error())
+		return
+	}
+
+	s.secrets[fingerprint] = append(s.secrets[fingerprint], storedSecret{
+		uuid: secretUUID,
+		secret: v1structs.Secret{
+			EncryptedMetadata: encryptedMetadata,
+			EncryptedContent:  request.ArmoredEncryptedSecret,
+		},
+	})
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) listSecrets(w http.ResponseWriter, r *http.Request) {
+	fingerprint, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	response := v1structs.ListSecretsResponse{Secrets: []v1structs.Secret{}}
+	for _, stored := range s.secrets[fingerprint] {
+		response.Secrets = append(response.Secrets, stored.secret)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) deleteSecret(w http.ResponseWriter, r *http.Request, secretUUID string) {
+	fingerprint, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	remaining := []storedSecret{}
+	found := false
+	for _, stored := range s.secrets[fingerprint] {
+		if stored.uuid.String() == secretUUID {
+			found = true
+			continue
+		}
+		remaining = append(remaining, stored)
+	}
+
+	if !found {
+		writeError(w, http.StatusNotFound, "no such secret")
+		return
+	}
+	s.secrets[fingerprint] = remaining
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// upsertTeam creates or updates a team. The roster must be signed by the requester, who must be
+// an admin in the new roster and, for an existing team, in the current roster too.
+func (s *Server) upsertTeam(w http.ResponseWriter, r *http.Request) {
+	signer, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var request v1structs.UpsertTeamRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	newTeam, err := team.Load(request.TeamRoster, request.ArmoredDetachedSignature)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid roster: "+err.Error())
+		return
+	}
+
+	if !newTeam.IsAdmin(signer) {
+		writeError(w, http.StatusBadRequest, "signing key not in roster")
+		return
+	}
+
+	// co-signed rosters carry several signatures: the uploader must have made one of them
+	if err := team.VerifyRosterSignatures(request.TeamRoster, request.ArmoredDetachedSignature,
+		[]*pgpkey.PgpKey{s.keys[signer]}, 1); err != nil {
+
+		writeError(w, http.StatusBadRequest, "bad roster signature: "+err.Error())
+		return
+	}
+
+	if existing, exists := s.teams[newTeam.UUID]; exists {
+		if !existing.team.IsAdmin(signer) {
+			writeError(w, http.StatusForbidden, "signing key isn't an admin of the team")
+			return
+		}
+		if existing.roster != request.TeamRoster && newTeam.Version <= existing.team.Version {
+			writeError(w, http.StatusBadRequest, "roster version must increase")
+			return
+		}
+	}
+
+	s.teams[newTeam.UUID] = storedTeam{
+		team:      newTeam,
+		roster:    request.TeamRoster,
+		signature: request.ArmoredDetachedSignature,
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) withTeam(w http.ResponseWriter, teamUUID string,
+	handler func(http.ResponseWriter, storedTeam)) {
+
+	parsedUUID, err := uuid.FromString(teamUUID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid team UUID")
+		return
+	}
+
+	t, ok := s.teams[parsedUUID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "team not found")
+		return
+	}
+	handler(w, t)
+}
+
+func (s *Server) getTeam(w http.ResponseWriter, t storedTeam) {
+	writeJSON(w, http.StatusOK, v1structs.GetTeamResponse{Name: t.team.Name})
+}
+
+func (s *Server) getTeamRoster(w http.ResponseWriter, r *http.Request, t storedTeam) {
+	requester, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	if !t.team.Contains(requester) {
+		writeError(w, http.StatusForbidden, "requesting key isn't in the team")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, v1structs.GetTeamRosterResponse{
+		TeamRoster:               t.roster,
+		ArmoredDetachedSignature: t.signature,
+	})
+}
+
+func (s *Server) createRequestToJoinTeam(w http.ResponseWriter, r *http.Request, t storedTeam) {
+	requester, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var request v1structs.RequestToJoinTeamRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	requesterKeys := []*pgpkey.PgpKey{s.keys[requester]}
+	if len(keylookup.FilterByEmail(requesterKeys, request.TeamEmail)) == 0 {
+		writeError(w, http.StatusBadRequest, "key doesn't have email "+request.TeamEmail)
+		return
+	}
+
+	for _, existing := range s.requests[t.team.UUID] {
+		if existing.Fingerprint == requester.Uri() || existing.Email == request.TeamEmail {
+			writeError(w, http.StatusConflict, "already got request to join team")
+			return
+		}
+	}
+
+	s.requests[t.team.UUID] = append(s.requests[t.team.UUID], v1structs.RequestToJoinTeam{
+		UUID:        uuid.Must(uuid.NewV4()).String(),
+		Fingerprint: requester.Uri(),
+		Email:       request.TeamEmail,
+	})
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) listRequestsToJoinTeam(w http.ResponseWriter, r *http.Request, t storedTeam) {
+	requester, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	if !t.team.IsAdmin(requester) {
+		writeError(w, http.StatusForbidden, "requesting key isn't an admin of the team")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, v1structs.ListRequestsToJoinTeamResponse{
+		Requests: append([]v1structs.RequestToJoinTeam{}, s.requests[t.team.UUID]...),
+	})
+}
+
+func (s *Server) deleteRequestToJoinTeam(w http.ResponseWriter, t storedTeam, requestUUID string) {
+	remaining := []v1structs.RequestToJoinTeam{}
+	found := false
+
+	for _, request := range s.requests[t.team.UUID] {
+		if request.UUID == requestUUID {
+			found = true
+			continue
+		}
+		remaining = append(remaining, request)
+	}
+
+	if !found {
+		writeError(w, http.StatusNotFound, "no such request")
+		return
+	}
+	s.requests[t.team.UUID] = remaining
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// createRequestToLeaveTeam checks the request is signed by a member of the team asking to
+// leave it.
+func (s *Server) createRequestToLeaveTeam(w http.ResponseWriter, r *http.Request, t storedTeam) {
+	requester, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var request apiclient.RequestToLeaveTeamRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	if !t.team.Contains(requester) {
+		writeError(w, http.StatusForbidden, "requesting key isn't in the team")
+		return
+	}
+
+	leaveRequest := team.RequestToLeaveTeam{
+		TeamUUID:          t.team.UUID,
+		Fingerprint:       requester,
+		ArmoredSignedJSON: request.ArmoredSignedJSON,
+	}
+	if _, err := leaveRequest.Verify(s.keys[requester], time.Now()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, existing := range s.leaveRequests[t.team.UUID] {
+		if existing.Fingerprint == requester.Uri() {
+			writeError(w, http.StatusConflict, "already got request to leave team")
+			return
+		}
+	}
+
+	s.leaveRequests[t.team.UUID] = append(s.leaveRequests[t.team.UUID],
+		apiclient.RequestToLeaveTeam{
+			UUID:              uuid.Must(uuid.NewV4()).String(),
+			Fingerprint:       requester.Uri(),
+			ArmoredSignedJSON: request.ArmoredSignedJSON,
+		})
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) listRequestsToLeaveTeam(w http.ResponseWriter, r *http.Request, t storedTeam) {
+	requester, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	if !t.team.IsAdmin(requester) {
+		writeError(w, http.StatusForbidden, "requesting key isn't an admin of the team")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiclient.ListRequestsToLeaveTeamResponse{
+		Requests: append([]apiclient.RequestToLeaveTeam{}, s.leaveRequests[t.team.UUID]...),
+	})
+}
+
+func (s *Server) deleteRequestToLeaveTeam(w http.ResponseWriter, t storedTeam, requestUUID string) {
+	remaining := []apiclient.RequestToLeaveTeam{}
+	found := false
+
+	for _, request := range s.leaveRequests[t.team.UUID] {
+		if request.UUID == requestUUID {
+			found = true
+			continue
+		}
+		remaining = append(remaining, request)
+	}
+
+	if !found {
+		writeError(w, http.StatusNotFound, "no such request")
+		return
+	}
+	s.leaveRequests[t.team.UUID] = remaining
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) createEvent(w http.ResponseWriter, r *http.Request) {
+	var request v1structs.CreateEventRequest
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+	if request.Name == "" {
+		writeError(w, http.StatusBadRequest, "missing name")
+		return
+	}
+	s.events = append(s.events, request)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// authorize parses the requester's fingerprint from the authorization header, checking that
+// their key has been uploaded. It writes an error response and returns false if not.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) (fpr.Fingerprint, bool) {
+	header := r.Header.Get("authorization")
+	prefix := "tmpfingerprint: OPENPGP4FPR:"
+
+	if !strings.HasPrefix(header, prefix) {
+		writeError(w, http.StatusUnauthorized, "missing or invalid authorization header")
+		return fpr.Fingerprint{}, false
+	}
+
+	fingerprint, err := fpr.Parse(strings.TrimPrefix(header, prefix))
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid fingerprint in authorization header")
+		return fpr.Fingerprint{}, false
+	}
+
+	if _, ok := s.keys[fingerprint]; !ok {
+		writeError(w, http.StatusUnauthorized, "no key uploaded for "+fingerprint.Hex())
+		return fpr.Fingerprint{}, false
+	}
+	return fingerprint, true
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, requestData interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(requestData); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, responseData interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(responseData); err != nil {
+		panic(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, statusCode int, detail string) {
+	writeJSON(w, statusCode, v1structs.ErrorResponse{Detail: detail})
+}
@@ -0,0 +1,270 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fakeserver_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/api/v1structs"
+	"github.com/fluidkeys/crypto/openpgp/clearsign"
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/apiclient/fakeserver"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/policy"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/gofrs/uuid"
+)
+
+func TestEndToEnd(t *testing.T) {
+	server := fakeserver.New()
+	defer server.Close()
+
+	client := apiclient.New("test")
+	client.BaseURL = server.BaseURL()
+
+	admin := loadKey(t, exampledata.ExamplePrivateKey2, "test2")
+	member := loadKey(t, exampledata.ExamplePrivateKey3, "test3")
+	adminEmail, err := admin.Email()
+	assert.NoError(t, err)
+	memberEmail, err := member.Email()
+	assert.NoError(t, err)
+
+	t.Run("upload public keys", func(t *testing.T) {
+		for _, key := range []*pgpkey.PgpKey{admin, member} {
+			armored, err := key.Armor()
+			assert.NoError(t, err)
+			assert.NoError(t, client.UpsertPublicKey(armored, key))
+		}
+	})
+
+	t.Run("get public key by email and fingerprint", func(t *testing.T) {
+		armored, err := client.GetPublicKey(memberEmail)
+		assert.NoError(t, err)
+		gotKey, err := pgpkey.LoadFromArmoredPublicKey(armored)
+		assert.NoError(t, err)
+		assert.Equal(t, member.Fingerprint(), gotKey.Fingerprint())
+
+		gotKey, err = client.GetPublicKeyByFingerprint(admin.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, admin.Fingerprint(), gotKey.Fingerprint())
+
+		_, err = client.GetPublicKey("nobody@example.com")
+		assert.Equal(t, apiclient.ErrPublicKeyNotFound, err)
+	})
+
+	t.Run("revalidate a public key with its etag", func(t *testing.T) {
+		_, validators, err := client.GetPublicKeyByFingerprintIfModified(
+			admin.Fingerprint(), apiclient.CacheValidators{},
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, true, validators.ETag != "")
+
+		_, _, err = client.GetPublicKeyByFingerprintIfModified(admin.Fingerprint(), validators)
+		assert.Equal(t, apiclient.ErrNotModified, err)
+	})
+
+	theTeam := team.Team{
+		UUID:    uuid.Must(uuid.NewV4()),
+		Version: 1,
+		Name:    "Kiffix",
+		People: []team.Person{
+			{Email: adminEmail, Fingerprint: admin.Fingerprint(), IsAdmin: true},
+		},
+	}
+
+	t.Run("create team", func(t *testing.T) {
+		assert.NoError(t, theTeam.UpdateRoster(admin))
+		roster, signature := theTeam.Roster()
+		assert.NoError(t, client.UpsertTeam(roster, signature, admin.Fingerprint()))
+
+		name, err := client.GetTeamName(theTeam.UUID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Kiffix", name)
+	})
+
+	t.Run("request to join team and approve request", func(t *testing.T) {
+		assert.NoError(t, client.RequestToJoinTeam(theTeam.UUID, member.Fingerprint(), memberEmail))
+
+		_, _, err := client.GetTeamRoster(theTeam.UUID, member.Fingerprint())
+		assert.Equal(t, apiclient.ErrForbidden, err)
+
+		_, err = client.ListRequestsToJoinTeam(theTeam.UUID, member.Fingerprint())
+		assertStatusCode(t, http.StatusForbidden, err)
+
+		requests, err := client.ListRequestsToJoinTeam(theTeam.UUID, admin.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(requests))
+		assert.Equal(t, member.Fingerprint(), requests[0].Fingerprint)
+		assert.Equal(t, memberEmail, requests[0].Email)
+
+		theTeam.Version++
+		theTeam.UpsertPerson(team.Person{Email: memberEmail, Fingerprint: member.Fingerprint()})
+		assert.NoError(t, theTeam.UpdateRoster(admin))
+		roster, signature := theTeam.Roster()
+		assert.NoError(t, client.UpsertTeam(roster, signature, admin.Fingerprint()))
+		assert.NoError(t, client.DeleteRequestToJoinTeam(theTeam.UUID, requests[0].UUID))
+
+		gotRoster, gotSignature, err := client.GetTeamRoster(theTeam.UUID, member.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, roster, gotRoster)
+		assert.NoError(t, team.VerifyRoster(gotRoster, gotSignature, []*pgpkey.PgpKey{admin}))
+	})
+
+	t.Run("request to leave team", func(t *testing.T) {
+		assert.NoError(t, client.RequestToLeaveTeam(theTeam.UUID, member))
+		assert.GotError(t, client.RequestToLeaveTeam(theTeam.UUID, member))
+
+		_, err := client.ListRequestsToLeaveTeam(theTeam.UUID, member.Fingerprint())
+		assertStatusCode(t, http.StatusForbidden, err)
+
+		requests, err := client.ListRequestsToLeaveTeam(theTeam.UUID, admin.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(requests))
+		assert.Equal(t, member.Fingerprint(), requests[0].Fingerprint)
+		_, err = requests[0].Verify(member, time.Now())
+		assert.NoError(t, err)
+
+		assert.NoError(t, client.DeleteRequestToLeaveTeam(theTeam.UUID, requests[0].UUID))
+		requests, err = client.ListRequestsToLeaveTeam(theTeam.UUID, admin.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(requests))
+	})
+
+	t.Run("send, receive and delete a secret", func(t *testing.T) {
+		encrypted := encryptTo(t, member, "hello world")
+		assert.NoError(t, client.CreateSecret(member.Fingerprint(), encrypted))
+
+		secrets, err := client.ListSecrets(member.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(secrets))
+
+		content, _, err := member.DecryptArmoredToString(secrets[0].EncryptedContent)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello world", content)
+
+		metadataJSON, _, err := member.DecryptArmoredToString(secrets[0].EncryptedMetadata)
+		assert.NoError(t, err)
+		metadata := v1structs.SecretMetadata{}
+		assert.NoError(t, json.Unmarshal([]byte(metadataJSON), &metadata))
+		assert.NoError(t, client.DeleteSecret(member.Fingerprint(), metadata.SecretUUID))
+
+		secrets, err = client.ListSecrets(member.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(secrets))
+	})
+
+	t.Run("rejects roster signed by a non-admin", func(t *testing.T) {
+		forged := theTeam
+		forged.Version++
+		forged.People = []team.Person{
+			{Email: memberEmail, Fingerprint: member.Fingerprint(), IsAdmin: true},
+		}
+		assert.NoError(t, forged.UpdateRoster(member))
+		roster, signature := forged.Roster()
+
+		err := client.UpsertTeam(roster, signature, member.Fingerprint())
+		assertStatusCode(t, http.StatusForbidden, err)
+	})
+
+	t.Run("rejects roster with bad signature", func(t *testing.T) {
+		updated := theTeam
+		updated.Version++
+		assert.NoError(t, updated.UpdateRoster(admin))
+		_, signature := updated.Roster()
+		roster, err := updated.PreviewRoster()
+		assert.NoError(t, err)
+
+		err = client.UpsertTeam(roster+"\n# tampered", signature, admin.Fingerprint())
+		assertStatusCode(t, http.StatusBadRequest, err)
+	})
+
+	t.Run("rejects replayed key upload", func(t *testing.T) {
+		armored, err := admin.Armor()
+		assert.NoError(t, err)
+
+		signedData, err := json.Marshal(v1structs.UpsertPublicKeySignedData{
+			Timestamp:       time.Now(),
+			SingleUseUUID:   uuid.Must(uuid.NewV4()).String(),
+			PublicKeySHA256: fmt.Sprintf("%X", sha256.Sum256([]byte(armored))),
+		})
+		assert.NoError(t, err)
+
+		signed := bytes.NewBuffer(nil)
+		plaintext, err := clearsign.Encode(signed, admin.PrivateKey, nil)
+		assert.NoError(t, err)
+		_, err = plaintext.Write(signedData)
+		assert.NoError(t, err)
+		assert.NoError(t, plaintext.Close())
+
+		requestJSON, err := json.Marshal(v1structs.UpsertPublicKeyRequest{
+			ArmoredPublicKey:  armored,
+			ArmoredSignedJSON: signed.String(),
+		})
+		assert.NoError(t, err)
+
+		for _, expectedStatus := range []int{http.StatusOK, http.StatusBadRequest} {
+			response, err := http.Post(
+				server.BaseURL().String()+"keys", "application/json", bytes.NewReader(requestJSON),
+			)
+			assert.NoError(t, err)
+			response.Body.Close()
+			assert.Equal(t, expectedStatus, response.StatusCode)
+		}
+	})
+
+	t.Run("records events", func(t *testing.T) {
+		assert.NoError(t, client.Log(apiclient.Event{Name: "test_event"}))
+		assert.Equal(t, 1, len(server.Events()))
+		assert.Equal(t, "test_event", server.Events()[0].Name)
+	})
+}
+
+func loadKey(t *testing.T, armoredPrivateKey string, password string) *pgpkey.PgpKey {
+	t.Helper()
+	key, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(armoredPrivateKey, password)
+	assert.NoError(t, err)
+
+	// workaround as example private keys don't have hash prefs
+	err = key.SetPreferredHashAlgorithms(policy.AdvertiseHashPreferences, time.Now())
+	assert.NoError(t, err)
+	return key
+}
+
+func encryptTo(t *testing.T, key *pgpkey.PgpKey, plaintext string) string {
+	t.Helper()
+	encrypted, err := key.EncryptArmored([]byte(plaintext))
+	assert.NoError(t, err)
+	return encrypted
+}
+
+func assertStatusCode(t *testing.T, expected int, err error) {
+	t.Helper()
+	apiError, ok := err.(*apiclient.APIError)
+	if !ok {
+		t.Fatalf("expected *apiclient.APIError, got %T: %v", err, err)
+	}
+	assert.Equal(t, expected, apiError.StatusCode)
+}
@@ -0,0 +1,190 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how a Client retries requests that are safe to repeat.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. 1 disables retrying.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It doubles on each subsequent retry.
+	BaseDelay time.Duration
+
+	// MaxDelay caps any single delay, including one requested by a Retry-After header.
+	MaxDelay time.Duration
+}
+
+const (
+	defaultTimeout = 30 * time.Second
+)
+
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// send makes the request, retrying on network errors and temporary server errors if
+// `retryable` is true. It reads the whole response body so it can apply a timeout to each
+// attempt: the returned response's body can be read after send returns.
+// A non-2xx response is not an error: that's up to the caller.
+func (c *Client) send(req *http.Request, retryable bool) (*http.Response, error) {
+	attempts := 1
+	if retryable && c.Retry.MaxAttempts > 1 {
+		attempts = c.Retry.MaxAttempts
+	}
+
+	var response *http.Response
+	var err error
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			delay := c.Retry.backoff(attempt-1, response)
+			log.Printf("retrying %s %s in %s (attempt %d of %d)",
+				req.Method, req.URL, delay, attempt, attempts)
+			c.sleep(delay)
+		}
+
+		response, err = c.sendOnce(req)
+		if !shouldRetry(response, err) {
+			break
+		}
+	}
+	return response, err
+}
+
+// sendOnce makes a single attempt of the request, applying the client's timeout.
+func (c *Client) sendOnce(req *http.Request) (*http.Response, error) {
+	attemptRequest := req
+	if c.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), c.Timeout)
+		defer cancel()
+		attemptRequest = req.WithContext(ctx)
+	}
+
+	if req.GetBody != nil { // rewind the body in case this is a retry
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		attemptRequest.Body = body
+	}
+
+	response, err := c.client.Do(attemptRequest)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
+
+func shouldRetry(response *http.Response, err error) bool {
+	if err != nil {
+		return IsNetworkError(err)
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait before the given retry (starting at 1). It honours any
+// Retry-After header in a 429 or 503 response, otherwise it backs off exponentially from
+// BaseDelay, with jitter so lots of clients don't retry in lockstep.
+func (p RetryPolicy) backoff(retry int, response *http.Response) time.Duration {
+	if retryAfter, ok := parseRetryAfter(response, time.Now()); ok {
+		return p.capDelay(retryAfter)
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = p.capDelay(delay)
+
+	if delay <= 1 {
+		return delay
+	}
+	// "equal jitter": somewhere between half and all of the delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (p RetryPolicy) capDelay(delay time.Duration) time.Duration {
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// parseRetryAfter reads the Retry-After header from a 429 or 503 response. The header is
+// either a number of seconds or an HTTP date.
+func parseRetryAfter(response *http.Response, now time.Time) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+	if response.StatusCode != http.StatusTooManyRequests &&
+		response.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	header := response.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if date.Before(now) {
+			return 0, true
+		}
+		return date.Sub(now), true
+	}
+	return 0, false
+}
+
+// isIdempotent returns true for HTTP methods which can safely be repeated.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package apiclient
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/gofrs/uuid"
+)
+
+func TestRetries(t *testing.T) {
+	teamUUID := uuid.Must(uuid.FromString("74bb40b4-3510-11e9-968e-53c38df634be"))
+	path := fmt.Sprintf("/team/%s", teamUUID)
+
+	t.Run("GET is retried after a 502, then succeeds", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+
+		var delays []time.Duration
+		client.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+		calls := 0
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"name": "Kiffix"}`)
+		})
+
+		name, err := client.GetTeamName(teamUUID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Kiffix", name)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 1, len(delays))
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+
+		calls := 0
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+
+		_, err := client.GetTeamName(teamUUID)
+		assert.Equal(t, &APIError{StatusCode: 503}, err)
+		assert.Equal(t, client.Retry.MaxAttempts, calls)
+	})
+
+	t.Run("honours Retry-After on a 429", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+
+		var delays []time.Duration
+		client.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+		calls := 0
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Add("Retry-After", "7")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"name": "Kiffix"}`)
+		})
+
+		_, err := client.GetTeamName(teamUUID)
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Duration{7 * time.Second}, delays)
+	})
+
+	t.Run("doesn't retry a 500", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+
+		calls := 0
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		_, err := client.GetTeamName(teamUUID)
+		assert.Equal(t, true, IsAPIError(err))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("doesn't retry a POST that isn't an upsert", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+
+		calls := 0
+		mux.HandleFunc("/secrets", func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusBadGateway)
+		})
+
+		err := client.CreateSecret(exampledata.ExampleFingerprint4, "encrypted")
+		assert.Equal(t, &APIError{StatusCode: 502}, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retries an upsert, resending the body", func(t *testing.T) {
+		client, mux, _, teardown := setup()
+		defer teardown()
+
+		var bodies []int64
+		mux.HandleFunc("/teams", func(w http.ResponseWriter, r *http.Request) {
+			bodies = append(bodies, r.ContentLength)
+			if len(bodies) == 1 {
+				w.WriteHeader(http.StatusGatewayTimeout)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+
+		err := client.UpsertTeam("roster", "signature", exampledata.ExampleFingerprint4)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(bodies))
+		assert.Equal(t, bodies[0], bodies[1])
+	})
+
+	t.Run("classifies a connection failure as a network error", func(t *testing.T) {
+		client, _, _, teardown := setup()
+		teardown() // close the server so requests fail
+
+		calls := 0
+		client.sleep = func(time.Duration) { calls++ }
+
+		_, err := client.GetTeamName(teamUUID)
+		assert.Equal(t, true, IsNetworkError(err))
+		assert.Equal(t, client.Retry.MaxAttempts-1, calls)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   time.Second,
+		MaxDelay:    10 * time.Second,
+	}
+
+	t.Run("grows exponentially with jitter", func(t *testing.T) {
+		for retry, expectedMax := range map[int]time.Duration{
+			1: 1 * time.Second,
+			2: 2 * time.Second,
+			3: 4 * time.Second,
+		} {
+			delay := policy.backoff(retry, nil)
+			assert.Equal(t, true, expectedMax/2 <= delay && delay <= expectedMax)
+		}
+	})
+
+	t.Run("is capped at MaxDelay", func(t *testing.T) {
+		delay := policy.backoff(9, nil)
+		assert.Equal(t, true, delay <= policy.MaxDelay)
+	})
+
+	t.Run("caps Retry-After at MaxDelay", func(t *testing.T) {
+		response := &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"Retry-After": []string{"3600"}},
+		}
+		assert.Equal(t, policy.MaxDelay, policy.backoff(1, response))
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	makeResponse := func(statusCode int, retryAfter string) *http.Response {
+		return &http.Response{
+			StatusCode: statusCode,
+			Header:     http.Header{"Retry-After": []string{retryAfter}},
+		}
+	}
+
+	t.Run("parses seconds", func(t *testing.T) {
+		delay, ok := parseRetryAfter(makeResponse(429, "120"), now)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 2*time.Minute, delay)
+	})
+
+	t.Run("parses an HTTP date", func(t *testing.T) {
+		delay, ok := parseRetryAfter(makeResponse(503, "Sat, 01 Jun 2019 12:00:30 GMT"), now)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 30*time.Second, delay)
+	})
+
+	t.Run("ignores it for other status codes", func(t *testing.T) {
+		_, ok := parseRetryAfter(makeResponse(502, "120"), now)
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("ignores junk", func(t *testing.T) {
+		_, ok := parseRetryAfter(makeResponse(429, "soon"), now)
+		assert.Equal(t, false, ok)
+	})
+}
@@ -24,9 +24,11 @@ import (
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/BurntSushi/toml"
 	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/keyserver"
 	"github.com/natefinch/atomic"
 )
 
@@ -91,6 +93,20 @@ func (c *Config) RunFromCron() bool {
 	return c.parsedConfig.RunFromCron
 }
 
+// ManageGpgConfGroups returns whether Fluidkeys should add `group` lines for the user's teams to
+// gpg.conf. The default is true.
+func (c *Config) ManageGpgConfGroups() bool {
+	if !c.parsedMetadata.IsDefined("gpg_conf_groups") {
+		c.parsedConfig.GpgConfGroups = defaultGpgConfGroups
+		err := c.save()
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	return c.parsedConfig.GpgConfGroups
+}
+
 // ShouldStorePassword returns whether the given key's password should
 // be stored in the system keyring when successfully entered (avoiding future
 // password prompts).
@@ -130,6 +146,32 @@ func (c *Config) SetPublishToAPI(fingerprint fpr.Fingerprint, value bool) error
 	return c.setProperty(fingerprint, publishToAPI, value)
 }
 
+// Keyservers returns the public keyservers configured with `[[keyserver]]` sections, in the
+// order they appear in the config file.
+func (c *Config) Keyservers() []Keyserver {
+	return c.parsedConfig.Keyservers
+}
+
+// TeamKeyTrust returns how Fluidkeys should make GnuPG trust the keys of the user's teammates.
+// The default is TeamKeyTrustCertify.
+func (c *Config) TeamKeyTrust() TeamKeyTrust {
+	if c.parsedConfig.TeamKeyTrust == "" {
+		return TeamKeyTrustCertify
+	}
+	return c.parsedConfig.TeamKeyTrust
+}
+
+// KeyDirectory returns which key directory backend this profile uses, and for the filesystem
+// and git backends, the path of the directory. Use FLUIDKEYS_DIR to choose a different profile,
+// each of which has its own config file.
+// The default is the Fluidkeys server.
+func (c *Config) KeyDirectory() (backend KeyDirectoryBackend, path string) {
+	if c.parsedConfig.KeyDirectory == nil || c.parsedConfig.KeyDirectory.Backend == "" {
+		return KeyDirectoryFluidkeys, ""
+	}
+	return c.parsedConfig.KeyDirectory.Backend, c.parsedConfig.KeyDirectory.Path
+}
+
 func (c *Config) setProperty(fingerprint fpr.Fingerprint, property keyConfigProperty, value interface{}) error {
 	if c.parsedConfig.PgpKeys == nil { // initialize the map if empty
 		c.parsedConfig.PgpKeys = make(map[string]key)
@@ -209,6 +251,22 @@ func parse(r io.Reader) (*Config, error) {
 		}
 	}
 
+	for _, server := range parsedConfig.Keyservers {
+		if err := validateKeyserver(server); err != nil {
+			return nil, err
+		}
+	}
+
+	if parsedConfig.KeyDirectory != nil {
+		if err := validateKeyDirectory(*parsedConfig.KeyDirectory); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateTeamKeyTrust(parsedConfig.TeamKeyTrust); err != nil {
+		return nil, err
+	}
+
 	if len(metadata.Undecoded()) > 0 {
 		// found config variables that we don't know how to match to
 		// the tomlConfig structure
@@ -222,6 +280,49 @@ func parse(r io.Reader) (*Config, error) {
 	return &config, nil
 }
 
+func validateKeyserver(server Keyserver) error {
+	if _, err := keyserver.ParseAddress(server.Address); err != nil {
+		return err
+	}
+
+	switch keyserver.Protocol(server.Protocol) {
+	case keyserver.HKP, keyserver.VKS:
+		return nil
+	default:
+		return fmt.Errorf("invalid protocol for keyserver %s: '%s' (should be hkp or vks)",
+			server.Address, server.Protocol)
+	}
+}
+
+func validateKeyDirectory(directory keyDirectory) error {
+	switch directory.Backend {
+	case "", KeyDirectoryFluidkeys:
+		return nil
+
+	case KeyDirectoryFilesystem, KeyDirectoryGit:
+		if !filepath.IsAbs(directory.Path) {
+			return fmt.Errorf("key_directory path must be an absolute path, got '%s'",
+				directory.Path)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("invalid key_directory backend: '%s' (should be fluidkeys, "+
+			"filesystem or git)", directory.Backend)
+	}
+}
+
+func validateTeamKeyTrust(trust TeamKeyTrust) error {
+	switch trust {
+	case "", TeamKeyTrustCertify, TeamKeyTrustOwnertrust, TeamKeyTrustTofu:
+		return nil
+
+	default:
+		return fmt.Errorf("invalid team_key_trust: '%s' (should be certify, ownertrust or "+
+			"tofu)", trust)
+	}
+}
+
 func (c *Config) serialize(w io.Writer) error {
 	if _, err := io.WriteString(w, defaultConfigFile); err != nil {
 		return err
@@ -247,8 +348,56 @@ const (
 )
 
 type tomlConfig struct {
-	RunFromCron bool           `toml:"run_from_cron"`
-	PgpKeys     map[string]key `toml:"pgpkeys"`
+	RunFromCron   bool           `toml:"run_from_cron"`
+	GpgConfGroups bool           `toml:"gpg_conf_groups"`
+	TeamKeyTrust  TeamKeyTrust   `toml:"team_key_trust,omitempty"`
+	PgpKeys       map[string]key `toml:"pgpkeys"`
+	Keyservers    []Keyserver    `toml:"keyserver,omitempty"`
+
+	KeyDirectory *keyDirectory `toml:"key_directory,omitempty"`
+}
+
+type keyDirectory struct {
+	Backend KeyDirectoryBackend `toml:"backend"`
+	Path    string              `toml:"path"`
+}
+
+// KeyDirectoryBackend is where keys, secrets and team rosters are shared.
+type KeyDirectoryBackend string
+
+const (
+	// KeyDirectoryFluidkeys uses the Fluidkeys server (the default)
+	KeyDirectoryFluidkeys KeyDirectoryBackend = "fluidkeys"
+
+	// KeyDirectoryFilesystem uses a directory shared by the team, e.g. on a network drive
+	KeyDirectoryFilesystem KeyDirectoryBackend = "filesystem"
+
+	// KeyDirectoryGit uses a clone of a git repository shared by the team
+	KeyDirectoryGit KeyDirectoryBackend = "git"
+)
+
+// TeamKeyTrust is how Fluidkeys makes GnuPG treat teammates' keys as valid.
+type TeamKeyTrust string
+
+const (
+	// TeamKeyTrustCertify only certifies teammates' keys with the user's own key (the default)
+	TeamKeyTrustCertify TeamKeyTrust = "certify"
+
+	// TeamKeyTrustOwnertrust also gives team admins' keys full ownertrust, so keys they've
+	// certified are valid too
+	TeamKeyTrustOwnertrust TeamKeyTrust = "ownertrust"
+
+	// TeamKeyTrustTofu sets the TOFU policy of every teammate's key to "good"
+	TeamKeyTrustTofu TeamKeyTrust = "tofu"
+)
+
+// Keyserver is a public keyserver used to find other people's keys and/or publish the user's
+// own keys, alongside Fluidkeys.
+type Keyserver struct {
+	Address  string `toml:"address"`
+	Protocol string `toml:"protocol"`
+	Discover bool   `toml:"discover"`
+	Publish  bool   `toml:"publish"`
 }
 
 type key struct {
@@ -259,6 +408,8 @@ type key struct {
 
 const defaultRunFromCron = true
 
+const defaultGpgConfGroups = true
+
 const defaultConfigFile string = `# Fluidkeys configuration file for 'fk' command
 #
 # # run_from_cron allows Fluidkeys to add itself to your crontab in order to
@@ -268,6 +419,24 @@ const defaultConfigFile string = `# Fluidkeys configuration file for 'fk' comman
 #
 # run_from_cron = true
 #
+# # gpg_conf_groups allows Fluidkeys to add a 'group' line to gpg.conf for each of your
+# # teams, so you can encrypt to everyone with e.g. 'gpg --recipient kiffix'
+# # - the lines are updated every time Fluidkeys fetches your teams
+# # - set to false and run 'fk team fetch' to remove the lines from gpg.conf
+#
+# gpg_conf_groups = true
+#
+# # team_key_trust chooses how GnuPG comes to treat your teammates' keys as valid:
+# # - "certify" certifies their keys with your key (the default)
+# # - "ownertrust" also gives your team admins' keys full ownertrust, so keys
+# #   they've certified are valid too
+# # - "tofu" sets the TOFU policy of your teammates' keys to "good": this needs
+# #   'trust-model tofu+pgp' in gpg.conf
+# # - trust is taken away again when someone leaves the team, or when you change
+# #   this setting and run 'fk team fetch'
+#
+# team_key_trust = "ownertrust"
+#
 # [pgpkeys]
 #   [pgpkeys."AAAA1111AAAA1111AAAA1111AAAA1111AAAA1111"]
 #
@@ -287,6 +456,38 @@ const defaultConfigFile string = `# Fluidkeys configuration file for 'fk' comman
 #     # will be able to search for the key by email address
 #     publish_to_api = true
 #
+# [[keyserver]]
+#
+#     # address is the URL of a public keyserver, for example https://keys.openpgp.org
+#     # or hkps://keyserver.ubuntu.com
+#     address = "https://keys.openpgp.org"
+#
+#     # protocol is either "vks" (keys.openpgp.org) or "hkp" (most other keyservers)
+#     protocol = "vks"
+#
+#     # discover looks for other people's keys on this keyserver if they're not on
+#     # Fluidkeys
+#     discover = true
+#
+#     # publish uploads your keys to this keyserver whenever they're uploaded to
+#     # Fluidkeys
+#     publish = true
+#
+# [key_directory]
+#
+#     # backend chooses where keys, secrets and team rosters are shared:
+#     # - "fluidkeys" uses the Fluidkeys server (the default)
+#     # - "filesystem" uses a directory shared by your team, e.g. on a network drive
+#     # - "git" uses a clone of a git repository shared by your team, pulling and
+#     #   pushing changes automatically
+#     backend = "git"
+#
+#     # path is the shared directory, or your clone of the git repository
+#     path = "/home/jane/src/our-key-directory"
+#
+#     # to use a different backend for another profile, set FLUIDKEYS_DIR to a
+#     # different directory: each has its own config.toml
+#
 # THIS FILE IS OVERWRITTEN BY FLUIDKEYS.
 # Any comments you add will be lost.
 
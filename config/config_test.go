@@ -172,6 +172,116 @@ func TestParse(t *testing.T) {
 	})
 }
 
+func TestKeyservers(t *testing.T) {
+	t.Run("parses keyserver sections", func(t *testing.T) {
+		config, err := parse(strings.NewReader(`
+		[[keyserver]]
+		address = "https://keys.openpgp.org"
+		protocol = "vks"
+		discover = true
+		publish = true
+
+		[[keyserver]]
+		address = "hkps://keyserver.ubuntu.com"
+		protocol = "hkp"
+		discover = true
+		`))
+		assert.NoError(t, err)
+
+		assert.Equal(t, []Keyserver{
+			{Address: "https://keys.openpgp.org", Protocol: "vks", Discover: true, Publish: true},
+			{Address: "hkps://keyserver.ubuntu.com", Protocol: "hkp", Discover: true},
+		}, config.Keyservers())
+	})
+
+	t.Run("no keyservers by default", func(t *testing.T) {
+		config, err := parse(strings.NewReader(""))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(config.Keyservers()))
+	})
+
+	t.Run("return an error for an invalid protocol", func(t *testing.T) {
+		_, err := parse(strings.NewReader(`
+		[[keyserver]]
+		address = "https://keys.openpgp.org"
+		protocol = "ldap"
+		`))
+		assert.Equal(t, fmt.Errorf("invalid protocol for keyserver https://keys.openpgp.org: "+
+			"'ldap' (should be hkp or vks)"), err)
+	})
+
+	t.Run("return an error for an invalid address", func(t *testing.T) {
+		_, err := parse(strings.NewReader(`
+		[[keyserver]]
+		address = "keys.openpgp.org"
+		protocol = "vks"
+		`))
+		assert.GotError(t, err)
+	})
+}
+
+func TestKeyDirectory(t *testing.T) {
+	t.Run("defaults to fluidkeys", func(t *testing.T) {
+		config, err := parse(strings.NewReader(""))
+		assert.NoError(t, err)
+
+		backend, path := config.KeyDirectory()
+		assert.Equal(t, KeyDirectoryFluidkeys, backend)
+		assert.Equal(t, "", path)
+	})
+
+	t.Run("parses git backend", func(t *testing.T) {
+		config, err := parse(strings.NewReader(`
+		[key_directory]
+		backend = "git"
+		path = "/srv/keys"
+		`))
+		assert.NoError(t, err)
+
+		backend, path := config.KeyDirectory()
+		assert.Equal(t, KeyDirectoryGit, backend)
+		assert.Equal(t, "/srv/keys", path)
+	})
+
+	t.Run("return an error for a relative path", func(t *testing.T) {
+		_, err := parse(strings.NewReader(`
+		[key_directory]
+		backend = "filesystem"
+		path = "keys"
+		`))
+		assert.Equal(t,
+			fmt.Errorf("key_directory path must be an absolute path, got 'keys'"), err)
+	})
+
+	t.Run("return an error for an unknown backend", func(t *testing.T) {
+		_, err := parse(strings.NewReader(`
+		[key_directory]
+		backend = "ftp"
+		`))
+		assert.GotError(t, err)
+	})
+}
+
+func TestTeamKeyTrust(t *testing.T) {
+	t.Run("defaults to certify", func(t *testing.T) {
+		config, err := parse(strings.NewReader(""))
+		assert.NoError(t, err)
+		assert.Equal(t, TeamKeyTrustCertify, config.TeamKeyTrust())
+	})
+
+	t.Run("parses tofu", func(t *testing.T) {
+		config, err := parse(strings.NewReader(`team_key_trust = "tofu"`))
+		assert.NoError(t, err)
+		assert.Equal(t, TeamKeyTrustTofu, config.TeamKeyTrust())
+	})
+
+	t.Run("return an error for an unknown value", func(t *testing.T) {
+		_, err := parse(strings.NewReader(`team_key_trust = "always"`))
+		assert.Equal(t, fmt.Errorf("invalid team_key_trust: 'always' (should be certify, "+
+			"ownertrust or tofu)"), err)
+	})
+}
+
 func TestSerialize(t *testing.T) {
 	testFingerprint := fpr.MustParse("AAAA1111AAAA1111AAAA1111AAAA1111AAAA1111")
 
@@ -187,6 +297,7 @@ func TestSerialize(t *testing.T) {
 
 		expected := defaultConfigFile +
 			"run_from_cron = false\n" +
+			"gpg_conf_groups = false\n" +
 			"\n" +
 			"[pgpkeys]\n" +
 			"  [pgpkeys.AAAA1111AAAA1111AAAA1111AAAA1111AAAA1111]\n" +
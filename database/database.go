@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 	"time"
 
 	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
@@ -44,6 +45,10 @@ type Message struct {
 	KeysImportedIntoGnuPG []KeyImportedIntoGnuPGMessage
 	RequestsToJoinTeams   []RequestToJoinTeamMessage
 	EventTimes            map[string]time.Time
+	QueuedOperations      []QueuedOperationMessage
+	JoinDecisions         []JoinDecisionMessage
+	TeamKeyTrust          []TeamKeyTrustMessage
+	IssuedInvites         []IssuedInviteMessage
 }
 
 // KeyImportedIntoGnuPGMessage represents a key the user has imported into GnuPG from Fluidkeys
@@ -59,6 +64,67 @@ type RequestToJoinTeamMessage struct {
 	RequestedAt time.Time       `json: "RequestedAt"`
 }
 
+// QueuedOperationMessage records an API operation that failed because Fluidkeys couldn't be
+// reached, so it can be replayed later (by `fk sync`).
+type QueuedOperationMessage struct {
+	// Operation is what to do, for example "upsert_public_key"
+	Operation string
+
+	// Target identifies what the operation applies to, for example a fingerprint. Only the
+	// newest operation for any Operation and Target is kept.
+	Target string
+
+	// Arguments holds anything else needed to replay the operation.
+	Arguments map[string]string
+
+	QueuedAt time.Time
+}
+
+// JoinDecisionMessage records a decision to approve or reject a request to join a team, whether
+// made by an admin or automatically by the team's join rules.
+type JoinDecisionMessage struct {
+	TeamUUID    uuid.UUID
+	RequestUUID uuid.UUID
+	Email       string
+	Fingerprint fpr.Fingerprint
+	Approved    bool
+
+	// Automatic is true if the decision was made by the team's join rules, not by an admin.
+	Automatic bool
+
+	// Reason explains the decision, for example which join rule was broken.
+	Reason string
+
+	// Pending is true if the request was approved, but the roster adding them is waiting for
+	// other admins to co-sign it.
+	Pending bool
+
+	// DecidedBy is the admin key that approved or rejected the request.
+	DecidedBy fpr.Fingerprint
+	DecidedAt time.Time
+}
+
+// TeamKeyTrustMessage records that Fluidkeys made GnuPG trust a teammate's key, so the trust can
+// be taken away again when they leave the team.
+type TeamKeyTrustMessage struct {
+	Fingerprint fpr.Fingerprint
+
+	// Model is how the key was trusted, for example "ownertrust" or "tofu".
+	Model string
+
+	TrustedAt time.Time
+}
+
+// IssuedInviteMessage records an invite the user made as a team admin, so they can recognise the
+// invited person's request to join. Invites are used once: the record is deleted when the
+// request is approved.
+type IssuedInviteMessage struct {
+	TeamUUID  uuid.UUID
+	Email     string
+	Token     string
+	ExpiresAt time.Time
+}
+
 // New returns a database from the given fluidkeys directory
 func New(fluidkeysDirectory string) Database {
 	jsonFilename := filepath.Join(fluidkeysDirectory, "db.json")
@@ -108,6 +174,197 @@ func (db *Database) RecordRequestToJoinTeam(
 	return db.saveToFile(*message)
 }
 
+// QueueOperation records an operation to replay later. Any existing operation with the same
+// Operation and Target is replaced, and the new one goes to the back of the queue.
+func (db *Database) QueueOperation(operation QueuedOperationMessage) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	if operation.Operation == "" {
+		return fmt.Errorf("operation can't be empty")
+	}
+
+	message.QueuedOperations = append(
+		removeQueuedOperation(message.QueuedOperations, operation.Operation, operation.Target),
+		operation,
+	)
+
+	return db.saveToFile(*message)
+}
+
+// GetQueuedOperations returns the queued operations, oldest first.
+func (db *Database) GetQueuedOperations() (operations []QueuedOperationMessage, err error) {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return nil, err
+	}
+	return message.QueuedOperations, nil
+}
+
+// DeleteQueuedOperation removes the operation matching the given operation and target from the
+// queue.
+func (db *Database) DeleteQueuedOperation(operation string, target string) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	message.QueuedOperations = removeQueuedOperation(message.QueuedOperations, operation, target)
+	return db.saveToFile(*message)
+}
+
+func removeQueuedOperation(operations []QueuedOperationMessage, operation string, target string) (
+	remaining []QueuedOperationMessage) {
+
+	for _, op := range operations {
+		if op.Operation == operation && op.Target == target {
+			continue
+		}
+		remaining = append(remaining, op)
+	}
+	return remaining
+}
+
+// RecordJoinDecision adds the decision to the audit trail of decisions about requests to join
+// teams.
+func (db *Database) RecordJoinDecision(decision JoinDecisionMessage) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	message.JoinDecisions = append(message.JoinDecisions, decision)
+	return db.saveToFile(*message)
+}
+
+// GetJoinDecisions returns the decisions about requests to join the team with the given UUID,
+// oldest first.
+func (db *Database) GetJoinDecisions(teamUUID uuid.UUID) (decisions []JoinDecisionMessage,
+	err error) {
+
+	message, err := db.loadFromFile()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, decision := range message.JoinDecisions {
+		if decision.TeamUUID == teamUUID {
+			decisions = append(decisions, decision)
+		}
+	}
+	return decisions, nil
+}
+
+// RecordTeamKeyTrust records that Fluidkeys made GnuPG trust the given key, replacing any
+// earlier record for the same key.
+func (db *Database) RecordTeamKeyTrust(trust TeamKeyTrustMessage) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	message.TeamKeyTrust = append(
+		removeTeamKeyTrust(message.TeamKeyTrust, trust.Fingerprint),
+		trust,
+	)
+	return db.saveToFile(*message)
+}
+
+// GetTeamKeyTrust returns the keys Fluidkeys has made GnuPG trust.
+func (db *Database) GetTeamKeyTrust() (trusted []TeamKeyTrustMessage, err error) {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return nil, err
+	}
+	return message.TeamKeyTrust, nil
+}
+
+// DeleteTeamKeyTrust removes the record that Fluidkeys made GnuPG trust the given key.
+func (db *Database) DeleteTeamKeyTrust(fingerprint fpr.Fingerprint) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	message.TeamKeyTrust = removeTeamKeyTrust(message.TeamKeyTrust, fingerprint)
+	return db.saveToFile(*message)
+}
+
+// RecordIssuedInvite records an invite the user made, replacing any earlier invite for the same
+// team and email. Invites that have expired by now are forgotten.
+func (db *Database) RecordIssuedInvite(invite IssuedInviteMessage, now time.Time) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	others := removeIssuedInvite(message.IssuedInvites, invite.TeamUUID, invite.Email)
+	remaining := []IssuedInviteMessage{}
+	for _, existing := range others {
+		if now.Before(existing.ExpiresAt) {
+			remaining = append(remaining, existing)
+		}
+	}
+	message.IssuedInvites = append(remaining, invite)
+	return db.saveToFile(*message)
+}
+
+// GetIssuedInvite returns the invite the user made for the given team and email, or nil if
+// there isn't one.
+func (db *Database) GetIssuedInvite(teamUUID uuid.UUID, email string) (
+	*IssuedInviteMessage, error) {
+
+	message, err := db.loadFromFile()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, invite := range message.IssuedInvites {
+		if invite.TeamUUID == teamUUID && strings.EqualFold(invite.Email, email) {
+			return &invite, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteIssuedInvite forgets the invite the user made for the given team and email, so it can't
+// be used again.
+func (db *Database) DeleteIssuedInvite(teamUUID uuid.UUID, email string) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	message.IssuedInvites = removeIssuedInvite(message.IssuedInvites, teamUUID, email)
+	return db.saveToFile(*message)
+}
+
+func removeIssuedInvite(invites []IssuedInviteMessage, teamUUID uuid.UUID, email string) (
+	remaining []IssuedInviteMessage) {
+
+	for _, invite := range invites {
+		if invite.TeamUUID == teamUUID && strings.EqualFold(invite.Email, email) {
+			continue
+		}
+		remaining = append(remaining, invite)
+	}
+	return remaining
+}
+
+func removeTeamKeyTrust(trusted []TeamKeyTrustMessage, fingerprint fpr.Fingerprint) (
+	remaining []TeamKeyTrustMessage) {
+
+	for _, trust := range trusted {
+		if trust.Fingerprint == fingerprint {
+			continue
+		}
+		remaining = append(remaining, trust)
+	}
+	return remaining
+}
+
 // RecordLast takes a verb and item and records the action in the database, e.g verb "fetched",
 // item: key.
 func (db *Database) RecordLast(verb string, item interface{}, now time.Time) error {
@@ -145,6 +402,74 @@ func (db *Database) GetLast(verb string, item interface{}) (EventTimes time.Time
 	return message.EventTimes[mapKey], nil
 }
 
+// DeleteLast takes a verb and item and forgets when it was last recorded, so GetLast returns
+// the zero time.
+func (db *Database) DeleteLast(verb string, item interface{}) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	mapKey, err := makeMapKey(verb, item)
+	if err != nil {
+		return fmt.Errorf("don't know how to handle %v", item)
+	}
+
+	delete(message.EventTimes, mapKey)
+	return db.saveToFile(*message)
+}
+
+// ForgetOlderThan forgets every time recorded for the given verb more than `age` before now,
+// whatever the item. It stops verbs recorded per short-lived item from growing the database
+// forever.
+func (db *Database) ForgetOlderThan(verb string, age time.Duration, now time.Time) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	if verb == "" {
+		return fmt.Errorf("verb can't be empty")
+	}
+
+	for mapKey, t := range message.EventTimes {
+		if strings.HasPrefix(mapKey, verb+":") && now.Sub(t) > age {
+			delete(message.EventTimes, mapKey)
+		}
+	}
+	return db.saveToFile(*message)
+}
+
+// ForgetAllExcept forgets every time recorded for the given verb, except for the given items.
+// It's for verbs recorded per item in a list fetched elsewhere, so items that have gone from
+// the list can be forgotten.
+func (db *Database) ForgetAllExcept(verb string, keep ...interface{}) error {
+	message, err := db.loadFromFile()
+	if err != nil {
+		return err
+	}
+
+	if verb == "" {
+		return fmt.Errorf("verb can't be empty")
+	}
+
+	keepMapKeys := map[string]bool{}
+	for _, item := range keep {
+		mapKey, err := makeMapKey(verb, item)
+		if err != nil {
+			return fmt.Errorf("don't know how to handle %v", item)
+		}
+		keepMapKeys[mapKey] = true
+	}
+
+	for mapKey := range message.EventTimes {
+		if strings.HasPrefix(mapKey, verb+":") && !keepMapKeys[mapKey] {
+			delete(message.EventTimes, mapKey)
+		}
+	}
+	return db.saveToFile(*message)
+}
+
 func makeMapKey(verb string, item interface{}) (string, error) {
 	var itemKey string
 
@@ -317,6 +642,10 @@ func (db *Database) loadFromFile() (message *Message, err error) {
 		),
 		RequestsToJoinTeams: message.RequestsToJoinTeams,
 		EventTimes:          message.EventTimes,
+		QueuedOperations:    message.QueuedOperations,
+		JoinDecisions:       message.JoinDecisions,
+		TeamKeyTrust:        message.TeamKeyTrust,
+		IssuedInvites:       message.IssuedInvites,
 	}, nil
 }
 
@@ -550,6 +550,69 @@ func TestEventTimes(t *testing.T) {
 		assert.Equal(t, later, got)
 	})
 
+	t.Run("delete last", func(t *testing.T) {
+		database := New(testhelpers.Maketemp(t))
+		fingerprint := exampledata.ExampleFingerprint2
+
+		assert.NoError(t, database.RecordLast("fetch", fingerprint, now))
+		assert.NoError(t, database.RecordLast("certify", fingerprint, now))
+		assert.NoError(t, database.DeleteLast("fetch", fingerprint))
+
+		got, err := database.GetLast("fetch", fingerprint)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Time{}, got)
+
+		got, err = database.GetLast("certify", fingerprint)
+		assert.NoError(t, err)
+		assert.Equal(t, now, got)
+	})
+
+	t.Run("forget older than", func(t *testing.T) {
+		database := New(testhelpers.Maketemp(t))
+		fingerprint := exampledata.ExampleFingerprint2
+		otherFingerprint := exampledata.ExampleFingerprint3
+
+		assert.NoError(t, database.RecordLast("fetch", fingerprint, now))
+		assert.NoError(t, database.RecordLast("fetch", otherFingerprint, later))
+		assert.NoError(t, database.RecordLast("certify", fingerprint, now))
+		assert.NoError(t, database.ForgetOlderThan("fetch", time.Hour, later))
+
+		got, err := database.GetLast("fetch", fingerprint)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Time{}, got)
+
+		got, err = database.GetLast("fetch", otherFingerprint)
+		assert.NoError(t, err)
+		assert.Equal(t, later, got)
+
+		got, err = database.GetLast("certify", fingerprint)
+		assert.NoError(t, err)
+		assert.Equal(t, now, got)
+	})
+
+	t.Run("forget all except", func(t *testing.T) {
+		database := New(testhelpers.Maketemp(t))
+		fingerprint := exampledata.ExampleFingerprint2
+		otherFingerprint := exampledata.ExampleFingerprint3
+
+		assert.NoError(t, database.RecordLast("fetch", fingerprint, now))
+		assert.NoError(t, database.RecordLast("fetch", otherFingerprint, now))
+		assert.NoError(t, database.RecordLast("certify", fingerprint, now))
+		assert.NoError(t, database.ForgetAllExcept("fetch", otherFingerprint))
+
+		got, err := database.GetLast("fetch", fingerprint)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Time{}, got)
+
+		got, err = database.GetLast("fetch", otherFingerprint)
+		assert.NoError(t, err)
+		assert.Equal(t, now, got)
+
+		got, err = database.GetLast("certify", fingerprint)
+		assert.NoError(t, err)
+		assert.Equal(t, now, got)
+	})
+
 	t.Run("with a missing key in JSON", func(t *testing.T) {
 		tempDir := testhelpers.Maketemp(t)
 
@@ -573,6 +636,220 @@ func TestEventTimes(t *testing.T) {
 	})
 }
 
+func TestQueuedOperations(t *testing.T) {
+	database := New(testhelpers.Maketemp(t))
+
+	upsertA := QueuedOperationMessage{
+		Operation: "upsert_public_key",
+		Target:    exampleFingerprintA.Hex(),
+		QueuedAt:  now,
+	}
+	event := QueuedOperationMessage{
+		Operation: "log_event",
+		Target:    "some_event",
+		Arguments: map[string]string{"name": "some_event"},
+		QueuedAt:  now,
+	}
+	upsertALater := QueuedOperationMessage{
+		Operation: "upsert_public_key",
+		Target:    exampleFingerprintA.Hex(),
+		QueuedAt:  later,
+	}
+
+	t.Run("empty database has no queued operations", func(t *testing.T) {
+		operations, err := database.GetQueuedOperations()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(operations))
+	})
+
+	t.Run("returns operations in the order they were queued", func(t *testing.T) {
+		assert.NoError(t, database.QueueOperation(upsertA))
+		assert.NoError(t, database.QueueOperation(event))
+
+		operations, err := database.GetQueuedOperations()
+		assert.NoError(t, err)
+		assert.Equal(t, []QueuedOperationMessage{upsertA, event}, operations)
+	})
+
+	t.Run("replaces a duplicate operation, moving it to the back", func(t *testing.T) {
+		assert.NoError(t, database.QueueOperation(upsertALater))
+
+		operations, err := database.GetQueuedOperations()
+		assert.NoError(t, err)
+		assert.Equal(t, []QueuedOperationMessage{event, upsertALater}, operations)
+	})
+
+	t.Run("deletes an operation", func(t *testing.T) {
+		assert.NoError(t, database.DeleteQueuedOperation(event.Operation, event.Target))
+
+		operations, err := database.GetQueuedOperations()
+		assert.NoError(t, err)
+		assert.Equal(t, []QueuedOperationMessage{upsertALater}, operations)
+	})
+
+	t.Run("rejects an empty operation", func(t *testing.T) {
+		assert.GotError(t, database.QueueOperation(QueuedOperationMessage{}))
+	})
+}
+
+func TestJoinDecisions(t *testing.T) {
+	database := New(testhelpers.Maketemp(t))
+	teamUUID := uuid.Must(uuid.NewV4())
+	otherTeamUUID := uuid.Must(uuid.NewV4())
+
+	approved := JoinDecisionMessage{
+		TeamUUID:    teamUUID,
+		RequestUUID: uuid.Must(uuid.NewV4()),
+		Email:       "jane@example.com",
+		Fingerprint: exampleFingerprintA,
+		Approved:    true,
+		Automatic:   true,
+		Reason:      "key published with verified email",
+		DecidedBy:   exampleFingerprintB,
+		DecidedAt:   now,
+	}
+	rejected := JoinDecisionMessage{
+		TeamUUID:    teamUUID,
+		RequestUUID: uuid.Must(uuid.NewV4()),
+		Email:       "jane@gmail.com",
+		Fingerprint: exampleFingerprintA,
+		Reason:      "only email addresses at example.com can join the team",
+		DecidedBy:   exampleFingerprintB,
+		DecidedAt:   later,
+	}
+	otherTeam := JoinDecisionMessage{
+		TeamUUID:    otherTeamUUID,
+		Fingerprint: exampleFingerprintB,
+		DecidedBy:   exampleFingerprintA,
+		DecidedAt:   now,
+	}
+
+	assert.NoError(t, database.RecordJoinDecision(approved))
+	assert.NoError(t, database.RecordJoinDecision(otherTeam))
+	assert.NoError(t, database.RecordJoinDecision(rejected))
+
+	t.Run("returns the team's decisions, oldest first", func(t *testing.T) {
+		decisions, err := database.GetJoinDecisions(teamUUID)
+		assert.NoError(t, err)
+		assert.Equal(t, []JoinDecisionMessage{approved, rejected}, decisions)
+	})
+
+	t.Run("returns nothing for a team with no decisions", func(t *testing.T) {
+		decisions, err := database.GetJoinDecisions(uuid.Must(uuid.NewV4()))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(decisions))
+	})
+}
+
+func TestTeamKeyTrust(t *testing.T) {
+	database := New(testhelpers.Maketemp(t))
+
+	trustA := TeamKeyTrustMessage{
+		Fingerprint: exampleFingerprintA,
+		Model:       "ownertrust",
+		TrustedAt:   now,
+	}
+	trustB := TeamKeyTrustMessage{
+		Fingerprint: exampleFingerprintB,
+		Model:       "ownertrust",
+		TrustedAt:   now,
+	}
+	trustATofu := TeamKeyTrustMessage{
+		Fingerprint: exampleFingerprintA,
+		Model:       "tofu",
+		TrustedAt:   later,
+	}
+
+	t.Run("empty database has no trusted keys", func(t *testing.T) {
+		trusted, err := database.GetTeamKeyTrust()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(trusted))
+	})
+
+	t.Run("returns recorded keys", func(t *testing.T) {
+		assert.NoError(t, database.RecordTeamKeyTrust(trustA))
+		assert.NoError(t, database.RecordTeamKeyTrust(trustB))
+
+		trusted, err := database.GetTeamKeyTrust()
+		assert.NoError(t, err)
+		assert.Equal(t, []TeamKeyTrustMessage{trustA, trustB}, trusted)
+	})
+
+	t.Run("replaces the record for the same key", func(t *testing.T) {
+		assert.NoError(t, database.RecordTeamKeyTrust(trustATofu))
+
+		trusted, err := database.GetTeamKeyTrust()
+		assert.NoError(t, err)
+		assert.Equal(t, []TeamKeyTrustMessage{trustB, trustATofu}, trusted)
+	})
+
+	t.Run("deletes a record", func(t *testing.T) {
+		assert.NoError(t, database.DeleteTeamKeyTrust(exampleFingerprintB))
+
+		trusted, err := database.GetTeamKeyTrust()
+		assert.NoError(t, err)
+		assert.Equal(t, []TeamKeyTrustMessage{trustATofu}, trusted)
+	})
+}
+
+func TestIssuedInvites(t *testing.T) {
+	database := New(testhelpers.Maketemp(t))
+	teamUUID := uuid.Must(uuid.NewV4())
+
+	invite := IssuedInviteMessage{
+		TeamUUID:  teamUUID,
+		Email:     "jane@example.com",
+		Token:     "token-1",
+		ExpiresAt: later,
+	}
+
+	t.Run("returns nil if there's no invite", func(t *testing.T) {
+		got, err := database.GetIssuedInvite(teamUUID, "jane@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, (*IssuedInviteMessage)(nil), got)
+	})
+
+	t.Run("returns a recorded invite, ignoring the email's case", func(t *testing.T) {
+		assert.NoError(t, database.RecordIssuedInvite(invite, now))
+
+		got, err := database.GetIssuedInvite(teamUUID, "Jane@Example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, &invite, got)
+	})
+
+	t.Run("replaces an invite for the same team and email", func(t *testing.T) {
+		replacement := invite
+		replacement.Token = "token-2"
+		assert.NoError(t, database.RecordIssuedInvite(replacement, now))
+
+		got, err := database.GetIssuedInvite(teamUUID, "jane@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, &replacement, got)
+	})
+
+	t.Run("forgets expired invites", func(t *testing.T) {
+		other := IssuedInviteMessage{
+			TeamUUID:  teamUUID,
+			Email:     "joe@example.com",
+			Token:     "token-3",
+			ExpiresAt: later.Add(time.Hour),
+		}
+		assert.NoError(t, database.RecordIssuedInvite(other, later))
+
+		got, err := database.GetIssuedInvite(teamUUID, "jane@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, (*IssuedInviteMessage)(nil), got)
+	})
+
+	t.Run("deletes an invite", func(t *testing.T) {
+		assert.NoError(t, database.DeleteIssuedInvite(teamUUID, "joe@example.com"))
+
+		got, err := database.GetIssuedInvite(teamUUID, "joe@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, (*IssuedInviteMessage)(nil), got)
+	})
+}
+
 func TestDeduplicateKeyImportedIntoGnuPGMessages(t *testing.T) {
 
 	slice := []KeyImportedIntoGnuPGMessage{
@@ -0,0 +1,44 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+)
+
+func TestPGPWords(t *testing.T) {
+	t.Run("example from the PGP word list", func(t *testing.T) {
+		f := MustParse("E582 94F2 E9A2 2748 6E8B  061B 31CC 528F D7FA 3F19")
+		assert.Equal(t,
+			"topmost Istanbul Pluto vagabond treadmill Pacific brackish dictator goldfish "+
+				"Medusa afflict bravado chatter revolver Dupont midsummer stopwatch whimsical "+
+				"cowbell bottomless",
+			f.PGPWords())
+	})
+
+	t.Run("word lists have 512 different words", func(t *testing.T) {
+		seen := map[string]bool{}
+		for i := 0; i < 256; i++ {
+			seen[strings.ToLower(pgpWordsEven[i])] = true
+			seen[strings.ToLower(pgpWordsOdd[i])] = true
+		}
+		assert.Equal(t, 512, len(seen))
+	})
+}
+
+func TestSafetyCode(t *testing.T) {
+	f := MustParse("0000000001 0000000002 FFFFFFFFFF 0000000000")
+
+	t.Run("four groups of five digits", func(t *testing.T) {
+		// 0xFFFFFFFFFF = 1099511627775
+		assert.Equal(t, "00001 00002 27775 00000", f.SafetyCode())
+	})
+
+	t.Run("MatchesSafetyCode ignores spaces and dashes", func(t *testing.T) {
+		assert.Equal(t, true, f.MatchesSafetyCode("00001-00002-27775-00000"))
+		assert.Equal(t, true, f.MatchesSafetyCode(" 00001000022777500000 "))
+		assert.Equal(t, false, f.MatchesSafetyCode("00001 00002 27775 00001"))
+		assert.Equal(t, false, f.MatchesSafetyCode(""))
+	})
+}
@@ -0,0 +1,208 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	docopt "github.com/docopt/docopt-go"
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/armor"
+	"github.com/fluidkeys/crypto/openpgp/packet"
+	"github.com/fluidkeys/fluidkeys/colour"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/ui"
+	"github.com/natefinch/atomic"
+)
+
+func decryptSubcommand(args docopt.Opts) exitCode {
+	filename, err := args.String("<filename>")
+	if err != nil {
+		log.Panic(err)
+	}
+	return fileDecrypt(filename)
+}
+
+// fileDecrypt decrypts the given OpenPGP message (armored or binary) using whichever of the
+// user's keys it was encrypted to, writing the plaintext alongside it.
+func fileDecrypt(filename string) exitCode {
+	out.Print("\n")
+
+	encrypted, err := ioutil.ReadFile(filename)
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to read "+filename, nil, err))
+		return 1
+	}
+
+	message, err := readEncryptedMessage(encrypted)
+	if err != nil {
+		out.Print(ui.FormatFailure(filename+" isn't an encrypted OpenPGP message", nil, err))
+		return 1
+	}
+
+	keyIds, err := encryptedToKeyIds(message)
+	if err != nil {
+		out.Print(ui.FormatFailure(filename+" isn't an encrypted OpenPGP message", nil, err))
+		return 1
+	}
+
+	keys, err := loadPgpKeys()
+	if err != nil {
+		out.Print(ui.FormatFailure("Couldn't load PGP keys", nil, err))
+		return 1
+	}
+
+	key := findDecryptionKey(keys, keyIds)
+	if key == nil {
+		out.Print(ui.FormatFailure("None of your keys can decrypt "+filename, []string{
+			"The file wasn't encrypted to any of your Fluidkeys keys.",
+		}, nil))
+		return 1
+	}
+
+	privateKey, _, err := getDecryptedPrivateKeyAndPassword(key, &interactivePasswordPrompter{})
+	if err != nil {
+		out.Print(ui.FormatFailure("Error getting private key and password", nil, err))
+		return 1
+	}
+
+	decrypted, err := decryptMessage(encrypted, privateKey)
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to decrypt "+filename, nil, err))
+		return 1
+	}
+
+	outFilename := decryptedFilename(filename)
+	if _, err := os.Stat(outFilename); err == nil {
+		prompter := interactiveYesNoPrompter{}
+		if !prompter.promptYesNo(outFilename+" already exists. Overwrite it?", "n", nil) {
+			return 1
+		}
+	}
+
+	if err := atomic.WriteFile(outFilename, decrypted); err != nil {
+		out.Print(ui.FormatFailure("Failed to write "+outFilename, nil, err))
+		return 1
+	}
+
+	out.Print(ui.FormatSuccess("Decrypted "+filename, []string{
+		"Wrote " + colour.File(outFilename) + " using " + displayName(key),
+	}))
+	return 0
+}
+
+// decryptMessage decrypts the whole message into memory. Reading to the end means the message's
+// integrity check has passed before we write anything to disk.
+func decryptMessage(encrypted []byte, privateKey *pgpkey.PgpKey) (*bytes.Buffer, error) {
+	message, err := readEncryptedMessage(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, _, err := privateKey.Decrypt(message)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := bytes.NewBuffer(nil)
+	if _, err := io.Copy(decrypted, reader); err != nil {
+		return nil, fmt.Errorf("error reading decrypted data: %v", err)
+	}
+	return decrypted, nil
+}
+
+// readEncryptedMessage returns a reader for the binary OpenPGP message, removing the ASCII
+// armor if it's present.
+func readEncryptedMessage(encrypted []byte) (io.Reader, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(encrypted), []byte("-----BEGIN PGP MESSAGE-----")) {
+		return bytes.NewReader(encrypted), nil
+	}
+
+	block, err := armor.Decode(bytes.NewReader(encrypted))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding armor: %v", err)
+	}
+	return block.Body, nil
+}
+
+// encryptedToKeyIds reads the packets at the start of an encrypted message and returns the IDs
+// of the keys the message was encrypted to.
+func encryptedToKeyIds(message io.Reader) (keyIds []uint64, err error) {
+	packets := packet.NewReader(message)
+
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch p := p.(type) {
+		case *packet.EncryptedKey:
+			keyIds = append(keyIds, p.KeyId)
+
+		case *packet.SymmetricKeyEncrypted:
+			continue
+
+		case *packet.SymmetricallyEncrypted:
+			if len(keyIds) == 0 {
+				return nil, fmt.Errorf("message isn't encrypted to any keys")
+			}
+			return keyIds, nil
+
+		default:
+			return nil, fmt.Errorf("message isn't encrypted")
+		}
+	}
+}
+
+// findDecryptionKey returns the first key with a primary key or subkey matching any of the
+// given key IDs, or nil if none match.
+func findDecryptionKey(keys []pgpkey.PgpKey, keyIds []uint64) *pgpkey.PgpKey {
+	for i := range keys {
+		keyring := openpgp.EntityList{&keys[i].Entity}
+
+		for _, keyId := range keyIds {
+			if len(keyring.KeysById(keyId)) > 0 {
+				return &keys[i]
+			}
+		}
+	}
+	return nil
+}
+
+// decryptedFilename returns the filename to write the decrypted file to: the encrypted
+// filename without its .asc, .gpg or .pgp extension, or with .decrypted added if it had none.
+func decryptedFilename(encryptedFilename string) string {
+	extension := filepath.Ext(encryptedFilename)
+
+	switch strings.ToLower(extension) {
+	case ".asc", ".gpg", ".pgp":
+		if filepath.Base(encryptedFilename) != extension {
+			return strings.TrimSuffix(encryptedFilename, extension)
+		}
+	}
+	return encryptedFilename + ".decrypted"
+}
@@ -0,0 +1,283 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	docopt "github.com/docopt/docopt-go"
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/armor"
+	"github.com/fluidkeys/fluidkeys/colour"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/humanize"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/ui"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+	"github.com/gofrs/uuid"
+	"github.com/natefinch/atomic"
+)
+
+func encryptSubcommand(args docopt.Opts) exitCode {
+	filename, err := args.String("<filename>")
+	if err != nil {
+		log.Panic(err)
+	}
+	recipient, err := args.String("--to")
+	if err != nil {
+		log.Panic(err)
+	}
+	return fileEncrypt(filename, recipient)
+}
+
+// fileEncrypt encrypts the given file to everyone matching `recipient` (a team or an email
+// address), writing the result to <filename>.asc
+// It doesn't contact Fluidkeys: recipients come from the local (verified) team rosters and their
+// keys from GnuPG. The output is a standard ASCII armored OpenPGP message, so it can also be
+// decrypted with gpg.
+func fileEncrypt(filename string, recipient string) exitCode {
+	out.Print("\n")
+
+	memberships, err := loadVerifiedMemberships()
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to load team memberships", nil, err))
+		return 1
+	}
+
+	people, matchedTeams := findRecipients(recipient, teamsFromMemberships(memberships))
+	if len(people) == 0 {
+		out.Print(ui.FormatFailure("Couldn't find "+recipient+" in your teams", []string{
+			"Encrypt to the name of one of your teams, a group in one of your teams",
+			"(like @ops), or to the email address of someone in one of your teams.",
+		}, nil))
+		return 1
+	}
+
+	// always encrypt to our own key(s) in the matching teams, so we can decrypt it again
+	people = uniquePeople(append(people, myPeopleInTeams(memberships, matchedTeams)...))
+
+	recipientKeys, errorLines := loadRecipientKeys(people, time.Now())
+	if len(errorLines) > 0 {
+		out.Print(ui.FormatFailure("Couldn't get everyone's key from GnuPG", append(errorLines,
+			"",
+			"Fetch the latest keys for your team by running "+colour.Cmd("fk team fetch"),
+		), nil))
+		return 1
+	}
+
+	outFilename := filename + ".asc"
+	if _, err := os.Stat(outFilename); err == nil {
+		prompter := interactiveYesNoPrompter{}
+		if !prompter.promptYesNo(outFilename+" already exists. Overwrite it?", "n", nil) {
+			return 1
+		}
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to open "+filename, nil, err))
+		return 1
+	}
+	defer f.Close()
+
+	encrypted := bytes.NewBuffer(nil)
+	if err := encryptFile(f, encrypted, filepath.Base(filename), recipientKeys); err != nil {
+		out.Print(ui.FormatFailure("Failed to encrypt "+filename, nil, err))
+		return 1
+	}
+
+	if err := atomic.WriteFile(outFilename, encrypted); err != nil {
+		out.Print(ui.FormatFailure("Failed to write "+outFilename, nil, err))
+		return 1
+	}
+
+	var recipientLines []string
+	for _, person := range people {
+		recipientLines = append(recipientLines, "• "+person.Email)
+	}
+
+	out.Print(ui.FormatSuccess(
+		"Encrypted "+filename+" to "+humanize.Pluralize(len(people), "person", "people"),
+		append([]string{
+			"Wrote " + colour.File(outFilename) + " which can be decrypted by:",
+			"",
+		}, append(recipientLines,
+			"",
+			"To decrypt it, run "+colour.Cmd("fk decrypt "+outFilename),
+		)...),
+	))
+	return 0
+}
+
+// encryptFile reads plaintext from `in` and writes an ASCII armored OpenPGP message encrypted to
+// all the recipient keys to `encrypted`.
+func encryptFile(in io.Reader, encrypted io.Writer, filename string,
+	recipientKeys []*pgpkey.PgpKey) error {
+
+	if len(recipientKeys) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	var recipients []*openpgp.Entity
+	for _, key := range recipientKeys {
+		recipients = append(recipients, &key.Entity)
+	}
+
+	message, err := armor.Encode(encrypted, "PGP MESSAGE", nil)
+	if err != nil {
+		return err
+	}
+
+	pgpWriteCloser, err := openpgp.Encrypt(
+		message,
+		recipients,
+		nil,
+		&openpgp.FileHints{IsBinary: true, FileName: filename},
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(pgpWriteCloser, in); err != nil {
+		return err
+	}
+
+	if err := pgpWriteCloser.Close(); err != nil {
+		return fmt.Errorf("error closing encrypt writer: %v", err)
+	}
+	if err := message.Close(); err != nil {
+		return fmt.Errorf("error closing armorer: %v", err)
+	}
+	return nil
+}
+
+// findRecipients returns the people matching the given recipient, which can be the name or UUID
+// of a team (everyone in the team), a group in a team such as `@ops` (everyone in the group) or
+// an email address (that person, in any of the teams).
+// It also returns the UUIDs of the teams that matched.
+func findRecipients(recipient string, teams []team.Team) (
+	people []team.Person, matchedTeams []uuid.UUID) {
+
+	for _, t := range teams {
+		if team.IsGroupName(recipient) {
+			if groupPeople, err := t.GroupPeople(recipient); err == nil {
+				people = append(people, groupPeople...)
+				matchedTeams = append(matchedTeams, t.UUID)
+			}
+			continue
+		}
+
+		if strings.EqualFold(t.Name, recipient) || t.UUID.String() == strings.ToLower(recipient) {
+			people = append(people, t.People...)
+			matchedTeams = append(matchedTeams, t.UUID)
+			continue
+		}
+
+		for _, person := range t.People {
+			if strings.EqualFold(person.Email, recipient) {
+				people = append(people, person)
+				matchedTeams = append(matchedTeams, t.UUID)
+			}
+		}
+	}
+	return uniquePeople(people), matchedTeams
+}
+
+// myPeopleInTeams returns the roster entries for the user's own keys in the given teams.
+func myPeopleInTeams(memberships []userpackage.GroupedMembership, teamUUIDs []uuid.UUID) (
+	me []team.Person) {
+
+	for _, grouped := range memberships {
+		for _, teamUUID := range teamUUIDs {
+			if grouped.Team.UUID != teamUUID {
+				continue
+			}
+			for _, membership := range grouped.Memberships {
+				me = append(me, membership.Me)
+			}
+		}
+	}
+	return me
+}
+
+// uniquePeople removes any person with a fingerprint that's already appeared, preserving order.
+func uniquePeople(people []team.Person) (unique []team.Person) {
+	seen := map[fpr.Fingerprint]bool{}
+
+	for _, person := range people {
+		alreadySeen := false
+		for _, fingerprint := range person.Fingerprints() {
+			if seen[fingerprint] {
+				alreadySeen = true
+			}
+		}
+		if alreadySeen {
+			continue
+		}
+
+		for _, fingerprint := range person.Fingerprints() {
+			seen[fingerprint] = true
+		}
+		unique = append(unique, person)
+	}
+	return unique
+}
+
+func teamsFromMemberships(memberships []userpackage.GroupedMembership) (teams []team.Team) {
+	for _, membership := range memberships {
+		teams = append(teams, membership.Team)
+	}
+	return teams
+}
+
+// loadRecipientKeys loads the public keys for each of each person's fingerprints from GnuPG,
+// checking that they can be used for encryption. It returns a line describing each problem it
+// finds.
+func loadRecipientKeys(people []team.Person, now time.Time) (
+	keys []*pgpkey.PgpKey, errorLines []string) {
+
+	for _, person := range people {
+		for _, fingerprint := range person.Fingerprints() {
+			key, err := loadPublicKeyOffline(fingerprint)
+			if err != nil {
+				log.Printf("failed to load key %s: %v", fingerprint, err)
+				errorLines = append(errorLines,
+					person.Email+": key "+fingerprint.String()+" not found (try fk team fetch)")
+				continue
+			}
+
+			if key.EncryptionSubkey(now) == nil {
+				errorLines = append(errorLines,
+					person.Email+": key "+fingerprint.String()+" has no valid encryption subkey")
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, errorLines
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/policy"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/gofrs/uuid"
+)
+
+func TestEncryptFileAndDecryptMessage(t *testing.T) {
+	now := time.Now()
+
+	key2, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey2, "test2")
+	assert.NoError(t, err)
+	key3, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey3, "test3")
+	assert.NoError(t, err)
+
+	// workaround as example private keys don't have hash prefs
+	assert.NoError(t, key2.SetPreferredHashAlgorithms(policy.AdvertiseHashPreferences, now))
+	assert.NoError(t, key3.SetPreferredHashAlgorithms(policy.AdvertiseHashPreferences, now))
+
+	publicKey2 := publicKeyOnly(t, key2)
+	publicKey3 := publicKeyOnly(t, key3)
+
+	encrypted := bytes.NewBuffer(nil)
+	err = encryptFile(
+		strings.NewReader("hello world"), encrypted, "hello.txt",
+		[]*pgpkey.PgpKey{publicKey2, publicKey3},
+	)
+	assert.NoError(t, err)
+
+	t.Run("output is ASCII armored", func(t *testing.T) {
+		assert.Equal(t, true, strings.HasPrefix(encrypted.String(), "-----BEGIN PGP MESSAGE-----"))
+	})
+
+	t.Run("message is encrypted to both keys", func(t *testing.T) {
+		message, err := readEncryptedMessage(encrypted.Bytes())
+		assert.NoError(t, err)
+
+		keyIds, err := encryptedToKeyIds(message)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(keyIds))
+
+		keys := []pgpkey.PgpKey{*publicKey3}
+		assert.Equal(t, publicKey3.Fingerprint(), findDecryptionKey(keys, keyIds).Fingerprint())
+	})
+
+	t.Run("each recipient can decrypt it", func(t *testing.T) {
+		for _, key := range []*pgpkey.PgpKey{key2, key3} {
+			decrypted, err := decryptMessage(encrypted.Bytes(), key)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello world", decrypted.String())
+		}
+	})
+
+	t.Run("someone else can't decrypt it", func(t *testing.T) {
+		key, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(
+			exampledata.ExamplePrivateKey4, "test4")
+		assert.NoError(t, err)
+
+		_, err = decryptMessage(encrypted.Bytes(), key)
+		assert.GotError(t, err)
+	})
+}
+
+func publicKeyOnly(t *testing.T, key *pgpkey.PgpKey) *pgpkey.PgpKey {
+	t.Helper()
+	armored, err := key.Armor()
+	assert.NoError(t, err)
+	publicKey, err := pgpkey.LoadFromArmoredPublicKey(armored)
+	assert.NoError(t, err)
+	return publicKey
+}
+
+func TestFindRecipients(t *testing.T) {
+	alice := team.Person{Email: "alice@example.com", Fingerprint: exampledata.ExampleFingerprint2}
+	bob := team.Person{Email: "bob@example.com", Fingerprint: exampledata.ExampleFingerprint3}
+	carol := team.Person{Email: "carol@example.com", Fingerprint: exampledata.ExampleFingerprint4}
+
+	kiffix := team.Team{
+		UUID:   uuid.Must(uuid.FromString("74bb40b4-3510-11e9-968e-53c38df634be")),
+		Name:   "Kiffix",
+		People: []team.Person{alice, bob},
+	}
+	other := team.Team{
+		UUID:   uuid.Must(uuid.FromString("8f7d6f58-3510-11e9-92a4-9bb7b4b1b3d9")),
+		Name:   "Other",
+		People: []team.Person{bob, carol},
+		Groups: []team.Group{{Name: "ops", Members: []string{"carol@example.com"}}},
+	}
+	teams := []team.Team{kiffix, other}
+
+	t.Run("matches a team by name, ignoring case", func(t *testing.T) {
+		people, matchedTeams := findRecipients("kiffix", teams)
+		assert.Equal(t, []team.Person{alice, bob}, people)
+		assert.Equal(t, []uuid.UUID{kiffix.UUID}, matchedTeams)
+	})
+
+	t.Run("matches a team by UUID", func(t *testing.T) {
+		people, _ := findRecipients(other.UUID.String(), teams)
+		assert.Equal(t, []team.Person{bob, carol}, people)
+	})
+
+	t.Run("matches an email address once, even if they're in several teams", func(t *testing.T) {
+		people, matchedTeams := findRecipients("BOB@example.com", teams)
+		assert.Equal(t, []team.Person{bob}, people)
+		assert.Equal(t, []uuid.UUID{kiffix.UUID, other.UUID}, matchedTeams)
+	})
+
+	t.Run("matches a group with @", func(t *testing.T) {
+		people, matchedTeams := findRecipients("@ops", teams)
+		assert.Equal(t, []team.Person{carol}, people)
+		assert.Equal(t, []uuid.UUID{other.UUID}, matchedTeams)
+	})
+
+	t.Run("doesn't match a group without @", func(t *testing.T) {
+		people, _ := findRecipients("ops", teams)
+		assert.Equal(t, 0, len(people))
+	})
+
+	t.Run("treats someone's additional keys as the same person", func(t *testing.T) {
+		aliceOnLaptop := alice.WithFingerprint(alice.Fingerprint)
+		aliceOnLaptop.AdditionalFingerprints = []fpr.Fingerprint{
+			fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+		}
+		aliceOnToken := aliceOnLaptop.WithFingerprint(
+			fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"))
+
+		got := uniquePeople([]team.Person{aliceOnLaptop, bob, aliceOnToken})
+		assert.Equal(t, []team.Person{aliceOnLaptop, bob}, got)
+	})
+
+	t.Run("returns nothing for an unknown recipient", func(t *testing.T) {
+		people, _ := findRecipients("dave@example.com", teams)
+		assert.Equal(t, 0, len(people))
+	})
+}
+
+func TestDecryptedFilename(t *testing.T) {
+	var tests = []struct {
+		encrypted string
+		expected  string
+	}{
+		{"secrets.env.asc", "secrets.env"},
+		{"dir/secrets.env.gpg", "dir/secrets.env"},
+		{"secrets.PGP", "secrets"},
+		{"secrets.txt", "secrets.txt.decrypted"},
+		{".asc", ".asc.decrypted"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.encrypted, func(t *testing.T) {
+			assert.Equal(t, test.expected, decryptedFilename(test.encrypted))
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package fk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/apiclient/fakeserver"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/config"
+	"github.com/fluidkeys/fluidkeys/database"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/gpgwrapper"
+	"github.com/fluidkeys/fluidkeys/keycache"
+	"github.com/fluidkeys/fluidkeys/keylookup"
+	"github.com/fluidkeys/fluidkeys/keyring"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/testhelpers"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+	"github.com/gofrs/uuid"
+)
+
+func TestTeamEndToEnd(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	member := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+	teamUUID := uuid.Must(uuid.NewV4())
+
+	t.Run("admin creates a team", func(t *testing.T) {
+		admin.use(t)
+		newTeam := team.Team{
+			UUID: teamUUID,
+			Name: "Kiffix",
+			People: []team.Person{
+				{
+					Email:       admin.email,
+					Fingerprint: admin.fingerprint(),
+					IsAdmin:     true,
+					Role:        team.RoleOwner,
+				},
+			},
+		}
+		pending, err := signAndUploadRoster(newTeam, admin.key, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, false, pending)
+
+		teamName, err := api.GetTeamName(teamUUID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Kiffix", teamName)
+
+		myTeam, _ := admin.membership(t)
+		assert.Equal(t, teamUUID, myTeam.UUID)
+	})
+
+	t.Run("member applies to join with an invite", func(t *testing.T) {
+		admin.use(t)
+		myTeam, _ := admin.membership(t)
+		expiresAt := time.Now().Add(time.Hour)
+		token, err := team.MakeInviteToken(myTeam, member.email, admin.key, expiresAt)
+		assert.NoError(t, err)
+		assert.NoError(t, db.RecordIssuedInvite(database.IssuedInviteMessage{
+			TeamUUID: teamUUID, Email: member.email, Token: token, ExpiresAt: expiresAt,
+		}, time.Now()))
+
+		member.use(t)
+		assert.NoError(t, sendRequestToJoinTeam(
+			teamUUID, "Kiffix", member.fingerprint(), member.email))
+
+		_, _, err = api.GetTeamRoster(teamUUID, member.fingerprint())
+		assert.Equal(t, apiclient.ErrForbidden, err)
+	})
+
+	t.Run("admin authorizes the request", func(t *testing.T) {
+		admin.use(t)
+		myTeam, me := admin.membership(t)
+		assert.Equal(t, 0, doAuthorizeRequests(myTeam, me, &alwaysYesPrompter{}))
+
+		requests, err := api.ListRequestsToJoinTeam(teamUUID, admin.fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(requests))
+
+		updatedTeam, _ := admin.membership(t)
+		assert.Equal(t, true, updatedTeam.Contains(member.fingerprint()))
+
+		invite, err := db.GetIssuedInvite(teamUUID, member.email)
+		assert.NoError(t, err)
+		assert.Equal(t, (*database.IssuedInviteMessage)(nil), invite)
+	})
+
+	t.Run("member fetches the approved roster", func(t *testing.T) {
+		member.use(t)
+		assert.NoError(t, processRequestsToJoinTeam(true))
+
+		myTeam, me := member.membership(t)
+		assert.Equal(t, 2, len(myTeam.People))
+		assert.Equal(t, false, me.IsAdmin)
+
+		requests, err := db.GetRequestsToJoinTeams()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(requests))
+	})
+
+	t.Run("admin sends the member a secret", func(t *testing.T) {
+		admin.use(t)
+		keys, _, err := keylookup.FindByEmail(member.email, emailKeySources()...)
+		assert.NoError(t, err)
+		key, err := firstEncryptableKey(keys)
+		assert.NoError(t, err)
+
+		assert.NoError(t, sendSecret("the password is hunter2\n", "", key))
+	})
+
+	t.Run("member receives the secret", func(t *testing.T) {
+		member.use(t)
+		assert.NoError(t, Config.SetStorePassword(member.fingerprint(), true))
+
+		secrets, secretErrors, err := fetchSecretsForKey(
+			member.key, api, &fixedPasswordPrompter{password: member.password})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(secretErrors))
+		assert.Equal(t, 1, len(secrets))
+		assert.Equal(t, "the password is hunter2\n", secrets[0].decryptedContent)
+
+		assert.NoError(t, api.DeleteSecret(member.fingerprint(), secrets[0].UUID.String()))
+		remaining, err := api.ListSecrets(member.fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(remaining))
+	})
+}
+
+// testProfile is a Fluidkeys user with their own Fluidkeys directory and GnuPG home, so several
+// people can share a fake server in one test. Call use to switch the fk globals to them.
+type testProfile struct {
+	directory string
+	gpg       gpgwrapper.GnuPG
+	key       *pgpkey.PgpKey // unlocked
+	password  string
+	email     string
+}
+
+func newTestProfile(t *testing.T, armoredPrivateKey string, password string) *testProfile {
+	t.Helper()
+
+	key, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(armoredPrivateKey, password)
+	assert.NoError(t, err)
+	email, err := key.Email()
+	assert.NoError(t, err)
+
+	gpgPointer, err := gpgwrapper.LoadWithHomeDir(testhelpers.Maketemp(t))
+	assert.NoError(t, err)
+
+	p := &testProfile{
+		directory: testhelpers.Maketemp(t),
+		gpg:       *gpgPointer,
+		key:       key,
+		password:  password,
+		email:     email,
+	}
+
+	p.use(t)
+	assert.NoError(t, pushPrivateKeyBackToGpg(key, password, &gpg))
+	assert.NoError(t, db.RecordFingerprintImportedIntoGnuPG(key.Fingerprint()))
+	assert.NoError(t, Config.SetPublishToAPI(key.Fingerprint(), true))
+
+	armoredPublicKey, err := key.Armor()
+	assert.NoError(t, err)
+	assert.NoError(t, api.UpsertPublicKey(armoredPublicKey, key))
+	return p
+}
+
+// use points the fk globals at the profile's directory, database, config and GnuPG.
+func (p *testProfile) use(t *testing.T) {
+	t.Helper()
+
+	loadedConfig, err := config.Load(p.directory)
+	assert.NoError(t, err)
+
+	fluidkeysDirectory = p.directory
+	db = database.New(p.directory)
+	keyCache = keycache.New(p.directory)
+	Config = *loadedConfig
+	gpg = p.gpg
+	user = userpackage.New(p.directory, &db)
+	unlockedKeyCache = map[fpr.Fingerprint]*pgpkey.PgpKey{p.fingerprint(): p.key}
+}
+
+func (p *testProfile) fingerprint() fpr.Fingerprint {
+	return p.key.Fingerprint()
+}
+
+// membership returns the profile's only team, and them in it.
+func (p *testProfile) membership(t *testing.T) (team.Team, team.Person) {
+	t.Helper()
+
+	memberships, err := user.Memberships()
+	assert.NoError(t, err)
+	if len(memberships) != 1 {
+		t.Fatalf("expected %s to be in 1 team, got %d", p.email, len(memberships))
+	}
+	return memberships[0].Team, memberships[0].Me
+}
+
+// useFakeServer points api at a new fake Fluidkeys server. Call restore afterwards to shut it
+// down and put back the fk globals changed by it and by testProfile.use.
+func useFakeServer() (server *fakeserver.Server, restore func()) {
+	var (
+		savedAPI                = api
+		savedFluidkeysDirectory = fluidkeysDirectory
+		savedDB                 = db
+		savedKeyCache           = keyCache
+		savedConfig             = Config
+		savedGpg                = gpg
+		savedUser               = user
+		savedKeyring            = Keyring
+		savedUnlockedKeyCache   = unlockedKeyCache
+	)
+
+	server = fakeserver.New()
+	client := apiclient.New(Version)
+	client.BaseURL = server.BaseURL()
+	api = client
+	Keyring = keyring.Keyring{} // no backend, so passwords are never saved
+
+	return server, func() {
+		server.Close()
+		api = savedAPI
+		fluidkeysDirectory = savedFluidkeysDirectory
+		db = savedDB
+		keyCache = savedKeyCache
+		Config = savedConfig
+		gpg = savedGpg
+		user = savedUser
+		Keyring = savedKeyring
+		unlockedKeyCache = savedUnlockedKeyCache
+	}
+}
+
+// alwaysYesPrompter answers yes to every question.
+type alwaysYesPrompter struct{}
+
+func (p *alwaysYesPrompter) promptYesNo(string, string, *pgpkey.PgpKey) bool {
+	return true
+}
+
+// alwaysNoPrompter answers no to every question.
+type alwaysNoPrompter struct{}
+
+func (p *alwaysNoPrompter) promptYesNo(string, string, *pgpkey.PgpKey) bool {
+	return false
+}
+
+// fixedPasswordPrompter always gives the same password.
+type fixedPasswordPrompter struct {
+	password string
+}
+
+func (p *fixedPasswordPrompter) promptForPassword(*pgpkey.PgpKey) (string, error) {
+	return p.password, nil
+}
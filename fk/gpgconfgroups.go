@@ -0,0 +1,125 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/gpgwrapper"
+	"github.com/fluidkeys/fluidkeys/team"
+)
+
+// syncGpgConfGroups updates the group lines Fluidkeys manages in gpg.conf to match the teams
+// I'm in, so I can encrypt to a whole team (or a group in its roster) by name. If the config
+// turns this off, the lines are removed.
+func syncGpgConfGroups() error {
+	if !Config.ManageGpgConfGroups() {
+		removed, err := gpg.RemoveConfGroups()
+		if err != nil {
+			return fmt.Errorf("failed to remove team groups from gpg.conf: %v", err)
+		}
+		if removed {
+			log.Printf("removed team groups from gpg.conf")
+		}
+		return nil
+	}
+
+	groupedMemberships, err := user.GroupedMemberships()
+	if err != nil {
+		return err
+	}
+
+	teams := []team.Team{}
+	for _, membership := range groupedMemberships {
+		if err := verifyRosterOffline(membership.Team); err != nil {
+			log.Printf("not adding %s to gpg.conf: couldn't verify roster: %v",
+				membership.Team.Name, err)
+			continue
+		}
+		teams = append(teams, membership.Team)
+	}
+	groups := gpgConfGroupsForTeams(teams)
+
+	changed, err := gpg.SetConfGroups(groups)
+	if err != nil {
+		return fmt.Errorf("failed to update team groups in gpg.conf: %v", err)
+	}
+	if changed {
+		log.Printf("updated gpg.conf with %d team groups", len(groups))
+	}
+	return nil
+}
+
+// gpgConfGroupsForTeams returns the groups for all the teams. GnuPG merges group lines with the
+// same name, so encrypting to one would encrypt to both: any name used for more than one group,
+// e.g. team kiffix's `ops` group and a team called kiffix-ops, is left out with a warning.
+func gpgConfGroupsForTeams(teams []team.Team) []gpgwrapper.ConfGroup {
+	allGroups := []gpgwrapper.ConfGroup{}
+	for _, t := range teams {
+		allGroups = append(allGroups, gpgConfGroupsForTeam(t)...)
+	}
+
+	timesUsed := map[string]int{}
+	for _, group := range allGroups {
+		timesUsed[group.Name]++
+	}
+
+	groups := []gpgwrapper.ConfGroup{}
+	for _, group := range allGroups {
+		switch timesUsed[group.Name] {
+		case 0: // already warned about
+			continue
+
+		case 1:
+			groups = append(groups, group)
+
+		default:
+			printWarning(fmt.Sprintf("Not adding group %s to gpg.conf: %d teams or groups "+
+				"have that name", group.Name, timesUsed[group.Name]))
+			timesUsed[group.Name] = 0
+		}
+	}
+	return groups
+}
+
+// gpgConfGroupsForTeam returns a group with everyone's keys, named after the team, then a
+// group for each group in the roster, e.g. `kiffix` and `kiffix-ops`.
+func gpgConfGroupsForTeam(t team.Team) []gpgwrapper.ConfGroup {
+	groups := []gpgwrapper.ConfGroup{
+		{Name: t.Slug(), Fingerprints: t.Fingerprints()},
+	}
+
+	for _, group := range t.Groups {
+		people, err := t.GroupPeople(group.Name)
+		if err != nil {
+			log.Printf("not adding group %s to gpg.conf: %v", group.Name, err)
+			continue
+		}
+
+		groupName := t.Slug() + "-" + strings.ToLower(group.Name)
+		fingerprints := []fpr.Fingerprint{}
+		for _, person := range people {
+			fingerprints = append(fingerprints, person.Fingerprints()...)
+		}
+		groups = append(groups, gpgwrapper.ConfGroup{Name: groupName, Fingerprints: fingerprints})
+	}
+	return groups
+}
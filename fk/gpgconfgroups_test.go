@@ -0,0 +1,77 @@
+package fk
+
+import (
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/gpgwrapper"
+	"github.com/fluidkeys/fluidkeys/team"
+)
+
+func TestGpgConfGroupsForTeam(t *testing.T) {
+	kiffix := team.Team{
+		Name: "Kiffix & Co",
+		People: []team.Person{
+			{Email: "admin@example.com", Fingerprint: exampledata.ExampleFingerprint2, IsAdmin: true},
+			{
+				Email:                  "ops@example.com",
+				Fingerprint:            exampledata.ExampleFingerprint3,
+				AdditionalFingerprints: []fpr.Fingerprint{exampledata.ExampleFingerprint4},
+			},
+		},
+		Groups: []team.Group{{Name: "Ops", Members: []string{"ops@example.com"}}},
+	}
+
+	assert.Equal(t, []gpgwrapper.ConfGroup{
+		{
+			Name: "kiffix-and-co",
+			Fingerprints: []fpr.Fingerprint{
+				exampledata.ExampleFingerprint2,
+				exampledata.ExampleFingerprint3,
+				exampledata.ExampleFingerprint4,
+			},
+		},
+		{
+			Name: "kiffix-and-co-ops",
+			Fingerprints: []fpr.Fingerprint{
+				exampledata.ExampleFingerprint3,
+				exampledata.ExampleFingerprint4,
+			},
+		},
+	}, gpgConfGroupsForTeam(kiffix))
+}
+
+func TestGpgConfGroupsForTeams(t *testing.T) {
+	kiffix := team.Team{
+		Name: "Kiffix",
+		People: []team.Person{
+			{Email: "admin@example.com", Fingerprint: exampledata.ExampleFingerprint2, IsAdmin: true},
+			{Email: "ops@example.com", Fingerprint: exampledata.ExampleFingerprint3},
+		},
+		Groups: []team.Group{{Name: "ops", Members: []string{"ops@example.com"}}},
+	}
+	kiffixOps := team.Team{
+		Name: "Kiffix Ops",
+		People: []team.Person{
+			{Email: "other@example.com", Fingerprint: exampledata.ExampleFingerprint4, IsAdmin: true},
+		},
+	}
+
+	t.Run("leaves out names used by more than one group", func(t *testing.T) {
+		assert.Equal(t, []gpgwrapper.ConfGroup{
+			{
+				Name: "kiffix",
+				Fingerprints: []fpr.Fingerprint{
+					exampledata.ExampleFingerprint2,
+					exampledata.ExampleFingerprint3,
+				},
+			},
+		}, gpgConfGroupsForTeams([]team.Team{kiffix, kiffixOps}))
+	})
+
+	t.Run("keeps names that are only used once", func(t *testing.T) {
+		assert.Equal(t, gpgConfGroupsForTeam(kiffix), gpgConfGroupsForTeams([]team.Team{kiffix}))
+	})
+}
@@ -28,6 +28,8 @@ import (
 	"github.com/fluidkeys/fluidkeys/config"
 	"github.com/fluidkeys/fluidkeys/database"
 	"github.com/fluidkeys/fluidkeys/gpgwrapper"
+	"github.com/fluidkeys/fluidkeys/keycache"
+	"github.com/fluidkeys/fluidkeys/keydirectory"
 	"github.com/fluidkeys/fluidkeys/keyring"
 	"github.com/fluidkeys/fluidkeys/out"
 	userpackage "github.com/fluidkeys/fluidkeys/user"
@@ -40,6 +42,7 @@ func init() {
 	initConfig()
 	initKeyring()
 	initDatabase()
+	initKeyCache()
 	initGpgWrapper()
 	initAPIClient()
 	initUser()
@@ -78,6 +81,10 @@ func initDatabase() {
 	db = database.New(fluidkeysDirectory)
 }
 
+func initKeyCache() {
+	keyCache = keycache.New(fluidkeysDirectory)
+}
+
 func initGpgWrapper() {
 	gpgPointer, err := gpgwrapper.Load()
 	if err != nil {
@@ -95,7 +102,16 @@ func initOutput() {
 }
 
 func initAPIClient() {
-	api = apiclient.New(Version)
+	switch backend, path := Config.KeyDirectory(); backend {
+	case config.KeyDirectoryFilesystem:
+		api = keydirectory.NewFilesystem(path)
+
+	case config.KeyDirectoryGit:
+		api = keydirectory.NewGitRepository(path)
+
+	default:
+		api = apiclient.New(Version)
+	}
 }
 
 func initUser() {
@@ -547,7 +547,17 @@ func (a publishToAPI) String() string {
 }
 
 func (a publishToAPI) Enact(key *pgpkey.PgpKey, now time.Time, password *string) error {
-	return publishKeyToAPI(key)
+	if err := publishKeyToAPI(key); err == errQueuedOffline {
+		// keyservers are unreachable too: `fk sync` uploads to them when it replays the queue
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// keyservers are a best effort, so failing to reach them doesn't fail the action.
+	// don't request verification here: it would send an email every time keys are maintained
+	logKeyserverFailures(publishKeyToKeyservers(key, false))
+	return nil
 }
 
 func (a publishToAPI) SortOrder() int {
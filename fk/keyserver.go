@@ -0,0 +1,101 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/fluidkeys/fluidkeys/config"
+	"github.com/fluidkeys/fluidkeys/keyserver"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+)
+
+// discoveryKeyservers returns the keyservers the user has configured for finding other people's
+// keys.
+func discoveryKeyservers() []*keyserver.Client {
+	return configuredKeyservers(func(k config.Keyserver) bool { return k.Discover })
+}
+
+// publishKeyservers returns the keyservers the user has configured for publishing their own
+// keys.
+func publishKeyservers() []*keyserver.Client {
+	return configuredKeyservers(func(k config.Keyserver) bool { return k.Publish })
+}
+
+func configuredKeyservers(include func(config.Keyserver) bool) (clients []*keyserver.Client) {
+	for _, k := range Config.Keyservers() {
+		if !include(k) {
+			continue
+		}
+
+		client, err := keyserver.New(k.Address, keyserver.Protocol(k.Protocol))
+		if err != nil { // config was already validated on load, so this shouldn't happen
+			log.Printf("ignoring keyserver %s: %v", k.Address, err)
+			continue
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// publishKeyToKeyservers uploads the public key to every keyserver configured for publishing.
+// If requestVerification is true, keyservers which only publish verified email addresses are
+// asked to send verification emails.
+func publishKeyToKeyservers(key *pgpkey.PgpKey, requestVerification bool) (
+	results []keyserverUploadResult) {
+
+	armoredPublicKey, err := key.Armor()
+	if err != nil {
+		log.Printf("failed to armor key: %v", err)
+		return nil
+	}
+
+	for _, server := range publishKeyservers() {
+		verificationSentTo, err := server.Upload(armoredPublicKey, requestVerification)
+		results = append(results, keyserverUploadResult{
+			keyserverName:      server.Name(),
+			verificationSentTo: verificationSentTo,
+			err:                err,
+		})
+	}
+	return results
+}
+
+// logKeyserverFailures logs any failed uploads, for callers that don't treat reaching the
+// keyservers as essential.
+func logKeyserverFailures(results []keyserverUploadResult) {
+	for _, result := range results {
+		if err := result.Error(); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+}
+
+type keyserverUploadResult struct {
+	keyserverName      string
+	verificationSentTo []string
+	err                error
+}
+
+func (r keyserverUploadResult) Error() error {
+	if r.err == nil {
+		return nil
+	}
+	return fmt.Errorf("failed to upload key to %s: %v", r.keyserverName, r.err)
+}
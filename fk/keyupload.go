@@ -20,6 +20,8 @@ package fk
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/fluidkeys/fluidkeys/colour"
 	"github.com/fluidkeys/fluidkeys/out"
@@ -53,14 +55,33 @@ func keyUpload() exitCode {
 		}
 
 		err = publishKeyToAPI(unlockedKey)
-		if err != nil {
+		if err == errQueuedOffline {
+			printWarning("Couldn't reach Fluidkeys: the key will be uploaded by " +
+				colour.Cmd("fk sync") + "\n")
+		} else if err != nil {
 			printFailed("Error uploading key")
 			out.Print(colour.Error("     " + err.Error() + "\n\n"))
 			gotAnyErrors = true
 			continue
+		} else {
+			printSuccess("Uploaded public key to Fluidkeys\n")
 		}
 
-		printSuccess("Uploaded public key to Fluidkeys\n")
+		for _, result := range publishKeyToKeyservers(unlockedKey, true) {
+			if result.err != nil {
+				printFailed("Error uploading key to " + result.keyserverName)
+				out.Print(colour.Error("     " + result.err.Error() + "\n\n"))
+				gotAnyErrors = true
+				continue
+			}
+
+			printSuccess("Uploaded public key to " + result.keyserverName + "\n")
+			if len(result.verificationSentTo) > 0 {
+				printInfo("Click the link emailed to " +
+					strings.Join(result.verificationSentTo, ", ") +
+					" to publish it on " + result.keyserverName + "\n")
+			}
+		}
 	}
 
 	if gotAnyErrors {
@@ -94,6 +115,9 @@ func publishKeyToAPI(privateKey *pgpkey.PgpKey) error {
 		return fmt.Errorf("Couldn't load armored key: %s", err)
 	}
 	if err = api.UpsertPublicKey(armoredPublicKey, privateKey); err != nil {
+		if queueIfOffline(err, makeQueuedUpsertPublicKey(privateKey.Fingerprint(), time.Now())) {
+			return errQueuedOffline
+		}
 		return fmt.Errorf("Failed to upload public key: %s", err)
 
 	}
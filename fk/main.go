@@ -29,7 +29,6 @@ import (
 	"github.com/fluidkeys/fluidkeys/status"
 	"github.com/fluidkeys/fluidkeys/table"
 
-	"github.com/fluidkeys/fluidkeys/apiclient"
 	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
 
 	"github.com/docopt/docopt-go"
@@ -37,12 +36,15 @@ import (
 	"github.com/fluidkeys/fluidkeys/config"
 	"github.com/fluidkeys/fluidkeys/database"
 	"github.com/fluidkeys/fluidkeys/gpgwrapper"
+	"github.com/fluidkeys/fluidkeys/keycache"
+	"github.com/fluidkeys/fluidkeys/keydirectory"
 	"github.com/fluidkeys/fluidkeys/keyring"
 	"github.com/fluidkeys/fluidkeys/out"
 	"github.com/fluidkeys/fluidkeys/pgpkey"
 	"github.com/fluidkeys/fluidkeys/scheduler"
 	"github.com/fluidkeys/fluidkeys/ui"
 	userpackage "github.com/fluidkeys/fluidkeys/user"
+	"github.com/gofrs/uuid"
 )
 
 const Version = "1.1.1"
@@ -53,7 +55,8 @@ var (
 	db                 database.Database
 	Config             config.Config
 	Keyring            keyring.Keyring
-	api                *apiclient.Client
+	api                keydirectory.KeyDirectory
+	keyCache           *keycache.Cache
 	user               *userpackage.User
 )
 
@@ -70,14 +73,25 @@ Usage:
 	fk setup
 	fk setup <email>
 	fk team create
-	fk team apply <uuid>
-	fk team authorize
-	fk team fetch [--cron-output]
-	fk team edit
+	fk team apply (<uuid> | --token=<token>)
+	fk team invite <email> [--team=<team>]
+	fk team authorize [--team=<team>]
+	fk team fetch [--team=<team>] [--cron-output]
+	fk team fetch --accept-latest-roster --team=<team>
+	fk team edit [--team=<team>]
+	fk team remove <email> [--team=<team>]
+	fk team leave [--team=<team>]
+	fk team list
+	fk team show [<team>]
 	fk status
 	fk secret send <recipient-email>
-	fk secret send [<filename>] --to=<email>
-	fk secret receive
+	fk secret send [<filename>] --to=<email-or-group>
+	fk secret list
+	fk secret receive [<uuid>]
+	fk encrypt <filename> --to=<email-team-or-group>
+	fk decrypt <filename>
+	fk sign [--clearsign|--detach] <filename>
+	fk verify <filename> [<signature>]
 	fk key create
 	fk key from-gpg
 	fk key list
@@ -87,9 +101,15 @@ Usage:
 	fk sync [--cron-output]
 
 Options:
-	-h --help         Show this screen
-	   --dry-run      Don't change anything: only output what would happen
-	   --cron-output  Only print output on errors`, // TODO: Document `automatic`
+	-h --help           Show this screen
+	   --dry-run        Don't change anything: only output what would happen
+	   --cron-output    Only print output on errors
+	   --clearsign      Make a cleartext signed message containing the file
+	   --detach         Make a detached signature (the default)
+	   --team=<team>    The name or UUID of the team, if you're in more than one
+	   --token=<token>  An invite token from a team admin
+	   --accept-latest-roster  Accept the team's latest roster even though it can't be
+	                           checked against the one you have`, // TODO: Document `automatic`
 		Version,
 		Config.GetFilename(),
 		out.GetLogFilename(),
@@ -110,7 +130,9 @@ Options:
 	}
 	var code exitCode
 
-	switch getSubcommand(args, []string{"key", "secret", "team", "setup", "sync", "status"}) {
+	switch getSubcommand(args, []string{
+		"key", "secret", "team", "setup", "sync", "status", "encrypt", "decrypt", "sign", "verify",
+	}) {
 	case "key":
 		code = keySubcommand(args)
 
@@ -129,6 +151,18 @@ Options:
 	case "status":
 		code = statusSubcommand(args)
 
+	case "encrypt":
+		code = encryptSubcommand(args)
+
+	case "decrypt":
+		code = decryptSubcommand(args)
+
+	case "sign":
+		code = signSubcommand(args)
+
+	case "verify":
+		code = verifySubcommand(args)
+
 	default:
 		out.Print("unhandled subcommand")
 		code = 1
@@ -333,6 +367,18 @@ func loadPgpKey(fingerprint fpr.Fingerprint) (*pgpkey.PgpKey, error) {
 	return pgpKey, nil
 }
 
+// loadPublicKeyOffline loads someone's public key from GnuPG, or failing that from the
+// Fluidkeys key cache, without using the network.
+func loadPublicKeyOffline(fingerprint fpr.Fingerprint) (*pgpkey.PgpKey, error) {
+	key, err := loadPgpKey(fingerprint)
+	if err == nil {
+		return key, nil
+	}
+	log.Printf("failed to load key %s from GnuPG, trying key cache: %v", fingerprint, err)
+
+	return keyCache.Get(fingerprint)
+}
+
 func keyList() exitCode {
 	keys, err := loadPgpKeys()
 	if err != nil {
@@ -382,7 +428,7 @@ func promptForInput(prompt string) string {
 
 func secretSubcommand(args docopt.Opts) exitCode {
 	switch getSubcommand(args, []string{
-		"send", "receive",
+		"send", "list", "receive",
 	}) {
 	case "send":
 		emailAddress, err := args.String("<recipient-email>")
@@ -427,8 +473,19 @@ func secretSubcommand(args docopt.Opts) exitCode {
 			return secretSend(emailAddress, filename)
 		}
 
+	case "list":
+		return secretList()
+
 	case "receive":
-		return secretReceive()
+		if args["<uuid>"] == nil {
+			return secretReceive(nil)
+		}
+		secretUUID, err := uuid.FromString(args["<uuid>"].(string))
+		if err != nil {
+			out.Print(ui.FormatFailure("Invalid secret ID", nil, err))
+			return 1
+		}
+		return secretReceive(&secretUUID)
 	}
 	log.Panicf("secretSubcommand got unexpected arguments: %v", args)
 	panic(nil)
@@ -0,0 +1,257 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/colour"
+	"github.com/fluidkeys/fluidkeys/database"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/humanize"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/ui"
+	"github.com/gofrs/uuid"
+)
+
+// queueIfOffline records the operation in the database to be replayed by `fk sync` if err means
+// Fluidkeys couldn't be reached. It returns true if the operation was queued.
+func queueIfOffline(err error, operation database.QueuedOperationMessage) bool {
+	if !apiclient.IsNetworkError(err) {
+		return false
+	}
+
+	if queueErr := db.QueueOperation(operation); queueErr != nil {
+		log.Printf("failed to queue %s %s: %v", operation.Operation, operation.Target, queueErr)
+		return false
+	}
+	log.Printf("queued %s %s to retry later: %v", operation.Operation, operation.Target, err)
+	return true
+}
+
+// logEvent sends the event to Fluidkeys, queueing it to send later if Fluidkeys can't be reached.
+func logEvent(event apiclient.Event) {
+	if err := api.Log(event); err != nil {
+		if !queueIfOffline(err, makeQueuedLogEvent(event, time.Now())) {
+			log.Printf("failed to log event %s: %v", event.Name, err)
+		}
+	}
+}
+
+func makeQueuedUpsertPublicKey(fingerprint fpr.Fingerprint, now time.Time) database.QueuedOperationMessage {
+
+	return database.QueuedOperationMessage{
+		Operation: queuedUpsertPublicKey,
+		Target:    fingerprint.Hex(),
+		QueuedAt:  now,
+	}
+}
+
+func makeQueuedLogEvent(event apiclient.Event, now time.Time) database.QueuedOperationMessage {
+	arguments := map[string]string{"name": event.Name}
+
+	if event.Fingerprint != nil {
+		arguments["fingerprint"] = event.Fingerprint.Hex()
+	}
+	if event.TeamUUID != nil {
+		arguments["team_uuid"] = event.TeamUUID.String()
+	}
+	if event.Error != nil {
+		arguments["error"] = event.Error.Error()
+	}
+
+	return database.QueuedOperationMessage{
+		Operation: queuedLogEvent,
+		// identical events are deduplicated
+		Target: strings.Join([]string{
+			arguments["name"], arguments["fingerprint"], arguments["team_uuid"], arguments["error"],
+		}, "|"),
+		Arguments: arguments,
+		QueuedAt:  now,
+	}
+}
+
+func makeQueuedDeleteRequestToJoinTeam(teamUUID uuid.UUID, requestUUID uuid.UUID,
+	now time.Time) database.QueuedOperationMessage {
+
+	return database.QueuedOperationMessage{
+		Operation: queuedDeleteRequestToJoinTeam,
+		Target:    requestUUID.String(),
+		Arguments: map[string]string{"team_uuid": teamUUID.String()},
+		QueuedAt:  now,
+	}
+}
+
+// replayQueuedOperations retries each queued operation in order. It stops at the first network
+// error, since Fluidkeys still can't be reached. Operations that Fluidkeys rejects are removed
+// from the queue, as retrying them won't help. Operations that fail for other reasons (e.g. the
+// key couldn't be unlocked) stay queued.
+func replayQueuedOperations(unattended bool) (err error) {
+	operations, err := db.GetQueuedOperations()
+	if err != nil {
+		return err
+	}
+
+	if len(operations) == 0 {
+		return nil
+	}
+
+	out.Print("Sending " + humanize.Pluralize(len(operations), "queued operation",
+		"queued operations") + " to Fluidkeys:\n\n")
+
+	var returnError error
+
+	for _, operation := range operations {
+		description := describeQueuedOperation(operation)
+
+		replayErr := ui.RunWithCheckboxes(description, func() error {
+			return replayOperation(operation, unattended)
+		})
+
+		if apiclient.IsNetworkError(replayErr) {
+			out.Print("\n")
+			return replayErr // still offline: leave the rest in the queue
+		}
+
+		if replayErr != nil {
+			returnError = replayErr
+
+			if !apiclient.IsAPIError(replayErr) && replayErr != errUnknownQueuedOperation {
+				continue // leave it queued to try again next time
+			}
+			log.Printf("dropping queued %s %s: %v", operation.Operation, operation.Target, replayErr)
+		}
+
+		if err := db.DeleteQueuedOperation(operation.Operation, operation.Target); err != nil {
+			return err
+		}
+	}
+	out.Print("\n")
+	return returnError
+}
+
+func replayOperation(operation database.QueuedOperationMessage, unattended bool) error {
+	switch operation.Operation {
+	case queuedUpsertPublicKey:
+		fingerprint, err := fpr.Parse(operation.Target)
+		if err != nil {
+			return err
+		}
+		unlockedKey, err := getUnlockedKey(fingerprint, unattended)
+		if err != nil {
+			return err
+		}
+		armoredPublicKey, err := unlockedKey.Armor()
+		if err != nil {
+			return err
+		}
+		if err := api.UpsertPublicKey(armoredPublicKey, unlockedKey); err != nil {
+			return err
+		}
+		// the key wasn't uploaded to the keyservers either while we were offline
+		logKeyserverFailures(publishKeyToKeyservers(unlockedKey, false))
+		return nil
+
+	case queuedLogEvent:
+		return api.Log(eventFromArguments(operation.Arguments))
+
+	case queuedDeleteRequestToJoinTeam:
+		teamUUID, err := uuid.FromString(operation.Arguments["team_uuid"])
+		if err != nil {
+			return err
+		}
+		requestUUID, err := uuid.FromString(operation.Target)
+		if err != nil {
+			return err
+		}
+		return api.DeleteRequestToJoinTeam(teamUUID, requestUUID)
+
+	default:
+		log.Printf("unknown queued operation '%s'", operation.Operation)
+		return errUnknownQueuedOperation
+	}
+}
+
+func eventFromArguments(arguments map[string]string) apiclient.Event {
+	event := apiclient.Event{Name: arguments["name"]}
+
+	if fingerprint, err := fpr.Parse(arguments["fingerprint"]); err == nil {
+		event.Fingerprint = &fingerprint
+	}
+	if teamUUID, err := uuid.FromString(arguments["team_uuid"]); err == nil {
+		event.TeamUUID = &teamUUID
+	}
+	if arguments["error"] != "" {
+		event.Error = errors.New(arguments["error"])
+	}
+	return event
+}
+
+func describeQueuedOperation(operation database.QueuedOperationMessage) string {
+	switch operation.Operation {
+	case queuedUpsertPublicKey:
+		return "Upload key " + operation.Target
+
+	case queuedLogEvent:
+		return "Send event " + operation.Arguments["name"]
+
+	case queuedDeleteRequestToJoinTeam:
+		return "Delete request to join team"
+
+	default:
+		return operation.Operation
+	}
+}
+
+// errQueuedOffline means Fluidkeys couldn't be reached, so the operation was queued to be retried
+// by the next `fk sync`.
+var errQueuedOffline = errors.New("couldn't reach Fluidkeys, will retry during fk sync")
+
+var errUnknownQueuedOperation = errors.New("unknown queued operation")
+
+// errRosterNotUploaded means a team roster couldn't be uploaded, so the team hasn't changed.
+// Unlike keys, rosters aren't queued for `fk sync` to upload later: another admin could upload
+// a newer version of the roster in the meantime, and the queued one wouldn't chain from it.
+// The change has to be made again by hand.
+type errRosterNotUploaded struct {
+	originalError error
+}
+
+func (e errRosterNotUploaded) Error() string { return e.originalError.Error() }
+
+// formatRosterNotUploaded returns lines explaining that the roster hasn't changed if err is an
+// errRosterNotUploaded, followed by retryAdvice, or nil for any other error.
+func formatRosterNotUploaded(err error, retryAdvice string) []string {
+	if _, ok := err.(errRosterNotUploaded); !ok {
+		return nil
+	}
+	return []string{
+		"The roster wasn't uploaded, so the team hasn't changed. Changes to the roster",
+		"aren't retried by " + colour.Cmd("fk sync") + ": " + retryAdvice,
+	}
+}
+
+const (
+	queuedUpsertPublicKey         = "upsert_public_key"
+	queuedLogEvent                = "log_event"
+	queuedDeleteRequestToJoinTeam = "delete_request_to_join_team"
+)
@@ -0,0 +1,132 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/gofrs/uuid"
+)
+
+func TestQueuedLogEvent(t *testing.T) {
+	now := time.Date(2019, 6, 20, 16, 35, 0, 0, time.UTC)
+	fingerprint := exampledata.ExampleFingerprint4
+	teamUUID := uuid.Must(uuid.FromString("74bb40b4-3510-11e9-968e-53c38df634be"))
+
+	event := apiclient.Event{
+		Name:        "error_updating_team_unattended",
+		Fingerprint: &fingerprint,
+		TeamUUID:    &teamUUID,
+		Error:       fmt.Errorf("something went wrong"),
+	}
+
+	t.Run("round trips through the queue arguments", func(t *testing.T) {
+		queued := makeQueuedLogEvent(event, now)
+		assert.Equal(t, queuedLogEvent, queued.Operation)
+		assert.Equal(t, now, queued.QueuedAt)
+		assert.Equal(t, event, eventFromArguments(queued.Arguments))
+	})
+
+	t.Run("identical events have the same target", func(t *testing.T) {
+		assert.Equal(t,
+			makeQueuedLogEvent(event, now).Target,
+			makeQueuedLogEvent(event, now.Add(time.Hour)).Target,
+		)
+	})
+
+	t.Run("events without a fingerprint, team or error", func(t *testing.T) {
+		minimal := apiclient.Event{Name: "some_event"}
+		assert.Equal(t, minimal, eventFromArguments(makeQueuedLogEvent(minimal, now).Arguments))
+	})
+}
+
+func TestPublishToAPIWithUnreachableKeyservers(t *testing.T) {
+	server, restore := useFakeServer()
+	defer restore()
+
+	profile := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+
+	// nothing listens on port 1, so uploads to the keyserver fail straight away
+	configFile, err := os.OpenFile(
+		filepath.Join(profile.directory, "config.toml"), os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	_, err = configFile.WriteString(
+		"\n[[keyserver]]\naddress = \"http://127.0.0.1:1\"\nprotocol = \"hkp\"\npublish = true\n")
+	assert.NoError(t, err)
+	assert.NoError(t, configFile.Close())
+	profile.use(t)
+	assert.Equal(t, 1, len(publishKeyservers()))
+
+	t.Run("the action succeeds if only the keyservers fail", func(t *testing.T) {
+		assert.NoError(t, publishToAPI{}.Enact(profile.key, time.Now(), nil))
+	})
+
+	t.Run("the action succeeds when offline, and queues the upload", func(t *testing.T) {
+		server.Close()
+		assert.NoError(t, publishToAPI{}.Enact(profile.key, time.Now(), nil))
+
+		operations, err := db.GetQueuedOperations()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(operations))
+		assert.Equal(t, queuedUpsertPublicKey, operations[0].Operation)
+	})
+}
+
+func TestRosterUploadsAreNotQueued(t *testing.T) {
+	server, restore := useFakeServer()
+	defer restore()
+
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	admin.use(t)
+	server.Close()
+
+	newTeam := team.Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []team.Person{
+			{Email: admin.email, Fingerprint: admin.fingerprint(), IsAdmin: true},
+		},
+	}
+	_, err := signAndUploadRoster(newTeam, admin.key, 1)
+
+	t.Run("fails with errRosterNotUploaded", func(t *testing.T) {
+		_, isNotUploaded := err.(errRosterNotUploaded)
+		assert.Equal(t, true, isNotUploaded)
+		assert.Equal(t, 2, len(formatRosterNotUploaded(err, "try again.")))
+	})
+
+	t.Run("doesn't queue the roster", func(t *testing.T) {
+		operations, err := db.GetQueuedOperations()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(operations))
+	})
+
+	t.Run("doesn't save the roster", func(t *testing.T) {
+		teams, err := team.LoadTeams(admin.directory)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(teams))
+	})
+}
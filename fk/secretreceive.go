@@ -28,6 +28,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -41,10 +42,14 @@ import (
 	"github.com/fluidkeys/fluidkeys/humanize"
 	"github.com/fluidkeys/fluidkeys/out"
 	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/table"
+	"github.com/fluidkeys/fluidkeys/ui"
 	"github.com/gofrs/uuid"
 )
 
-func secretReceive() exitCode {
+// secretReceive downloads, decrypts and displays secrets for all the user's keys. If onlyUUID
+// is not nil, only the secret with that UUID is received.
+func secretReceive(onlyUUID *uuid.UUID) exitCode {
 	out.Print("\n")
 	keys, err := loadPgpKeys()
 	prompter := interactiveYesNoPrompter{}
@@ -57,37 +62,37 @@ func secretReceive() exitCode {
 	out.Print(colour.Info("Downloading secrets...") + "\n\n")
 
 	sawError := false
+	foundRequestedSecret := false
 	numSecretsDeleted := 0
 
 	secretLister := api
 
-	for _, key := range keys {
+	for i := range keys {
+		key := &keys[i]
+
 		if !Config.ShouldPublishToAPI(key.Fingerprint()) {
 			message := "Key not uploaded to Fluidkeys, can't receive secrets"
-			out.Print("⛔ " + displayName(&key) + ": " + colour.Warning(message) + "\n")
+			out.Print("⛔ " + displayName(key) + ": " + colour.Warning(message) + "\n")
 			continue
 		}
-		encryptedSecrets, err := downloadEncryptedSecrets(key.Fingerprint(), secretLister)
+		decryptedSecrets, secretErrors, err := fetchSecretsForKey(key, secretLister, &interactivePasswordPrompter{})
 		if err != nil {
-			switch err.(type) {
-			case errNoSecretsFound:
-				out.Print("📭 " + displayName(&key) + ": No secrets found\n")
-			default:
-				out.Print("📪 " + displayName(&key) + ": " + colour.Failure(err.Error()) + "\n")
-			}
+			out.Print(formatFetchSecretsError(key, err))
 			continue
 		}
 
-		privateKey, _, err := getDecryptedPrivateKeyAndPassword(&key, &interactivePasswordPrompter{})
-		if err != nil {
-			message := fmt.Sprintf("Error getting private key and password: %s", err)
-			out.Print("📪 " + displayName(&key) + ": " + colour.Failure(message) + "\n")
-			continue
+		if onlyUUID != nil {
+			decryptedSecrets = filterSecretsByUUID(decryptedSecrets, *onlyUUID)
+			if len(decryptedSecrets) == 0 && len(secretErrors) == 0 {
+				log.Printf("secret %s not found for key %s", onlyUUID, key.Fingerprint())
+				continue
+			}
+			foundRequestedSecret = foundRequestedSecret || len(decryptedSecrets) > 0
 		}
-		decryptedSecrets, secretErrors := decryptSecrets(encryptedSecrets, privateKey)
+
 		secretCount := len(decryptedSecrets)
 
-		out.Print("📬 " + displayName(&key) + ": " +
+		out.Print("📬 " + displayName(key) + ": " +
 			humanize.Pluralize(secretCount, "secret!", "secrets!") + "\n\n")
 
 		out.Print("💣 " + colour.Warning("Secrets self-destruct once viewed!\n\n"))
@@ -120,16 +125,13 @@ func secretReceive() exitCode {
 				printFailed(err.Error())
 			} else {
 				numSecretsDeleted++
+				forgetSecret(secret.UUID)
 			}
 
 		}
 
 		if len(secretErrors) > 0 {
-			output := humanize.Pluralize(len(secretErrors), "secret", "secrets") + " failed to download for " + displayName(&key) + ":\n"
-			out.Print(colour.Failure(colour.StripAllColourCodes(output)))
-			for _, error := range secretErrors {
-				printFailed(error.Error())
-			}
+			printSecretErrors(key, secretErrors)
 			sawError = true
 		}
 	}
@@ -140,12 +142,181 @@ func secretReceive() exitCode {
 		out.Print("💥 " + colour.Warning(deleteMessage) + "\n\n")
 	}
 
+	if onlyUUID != nil && !foundRequestedSecret {
+		out.Print(ui.FormatFailure("Couldn't find secret "+onlyUUID.String(), []string{
+			"List the secrets waiting for you by running " + colour.Cmd("fk secret list"),
+		}, nil))
+		return 1
+	}
+
 	if sawError {
 		return 1
 	}
 	return 0
 }
 
+// secretList downloads and decrypts secrets for all the user's keys, then lists them without
+// displaying their content. Secrets are left on the server so they can be received with
+// `fk secret receive <uuid>`.
+func secretList() exitCode {
+	out.Print("\n")
+	keys, err := loadPgpKeys()
+	if err != nil {
+		printFailed("Couldn't load PGP keys")
+		return 1
+	}
+
+	out.Print(colour.Info("Downloading secrets...") + "\n\n")
+
+	sawError := false
+	numSecretsListed := 0
+	listedAllKeys := true
+	var listedUUIDs []interface{}
+
+	for i := range keys {
+		key := &keys[i]
+
+		if !Config.ShouldPublishToAPI(key.Fingerprint()) {
+			message := "Key not uploaded to Fluidkeys, can't receive secrets"
+			out.Print("⛔ " + displayName(key) + ": " + colour.Warning(message) + "\n")
+			continue
+		}
+
+		// the secret metadata is encrypted, so listing secrets still requires the private key
+		decryptedSecrets, secretErrors, err := fetchSecretsForKey(key, api, &interactivePasswordPrompter{})
+		if err != nil {
+			if _, noSecrets := err.(errNoSecretsFound); !noSecrets {
+				listedAllKeys = false
+			}
+			out.Print(formatFetchSecretsError(key, err))
+			continue
+		}
+		for _, s := range decryptedSecrets {
+			listedUUIDs = append(listedUUIDs, s.UUID)
+		}
+
+		out.Print("📬 " + displayName(key) + ": " +
+			humanize.Pluralize(len(decryptedSecrets), "secret", "secrets") + "\n\n")
+
+		out.Print(table.FormatSecretTable(makeSecretRows(decryptedSecrets, time.Now())))
+		numSecretsListed += len(decryptedSecrets)
+
+		if len(secretErrors) > 0 {
+			printSecretErrors(key, secretErrors)
+			sawError = true
+		}
+	}
+
+	if listedAllKeys {
+		// secrets that are no longer on the server were received elsewhere, or have expired
+		if err := db.ForgetAllExcept("receive", listedUUIDs...); err != nil {
+			log.Printf("error calling db.ForgetAllExcept(\"receive\", ...): %v", err)
+		}
+	}
+
+	if numSecretsListed > 0 {
+		out.Print("Receive a secret by running " + colour.Cmd("fk secret receive <uuid>") + "\n\n")
+	}
+
+	if sawError {
+		return 1
+	}
+	return 0
+}
+
+// fetchSecretsForKey downloads the secrets waiting for the given key, unlocks the private key
+// and decrypts them.
+// It returns errNoSecretsFound if there are no secrets, or errDecryptPrivateKey if the private
+// key couldn't be unlocked.
+func fetchSecretsForKey(key *pgpkey.PgpKey, secretLister listSecretsInterface,
+	prompter promptForPasswordInterface) (secrets []secret, secretErrors []error, err error) {
+
+	encryptedSecrets, err := downloadEncryptedSecrets(key.Fingerprint(), secretLister)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, _, err := getDecryptedPrivateKeyAndPassword(key, prompter)
+	if err != nil {
+		return nil, nil, errDecryptPrivateKey{originalError: err}
+	}
+
+	secrets, secretErrors = decryptSecrets(encryptedSecrets, privateKey)
+	return secrets, secretErrors, nil
+}
+
+func formatFetchSecretsError(key *pgpkey.PgpKey, err error) string {
+	switch err.(type) {
+	case errNoSecretsFound:
+		return "📭 " + displayName(key) + ": No secrets found\n"
+
+	case errDecryptPrivateKey:
+		message := fmt.Sprintf("Error getting private key and password: %s", err)
+		return "📪 " + displayName(key) + ": " + colour.Failure(message) + "\n"
+
+	default:
+		return "📪 " + displayName(key) + ": " + colour.Failure(err.Error()) + "\n"
+	}
+}
+
+func printSecretErrors(key *pgpkey.PgpKey, secretErrors []error) {
+	output := humanize.Pluralize(len(secretErrors), "secret", "secrets") +
+		" failed to download for " + displayName(key) + ":\n"
+	out.Print(colour.Failure(colour.StripAllColourCodes(output)))
+	for _, error := range secretErrors {
+		printFailed(error.Error())
+	}
+}
+
+func filterSecretsByUUID(secrets []secret, secretUUID uuid.UUID) (matching []secret) {
+	for _, s := range secrets {
+		if s.UUID == secretUUID {
+			matching = append(matching, s)
+		}
+	}
+	return matching
+}
+
+// makeSecretRows returns a table row for each secret. The API doesn't record when a secret was
+// sent, so "received" is the first time this computer saw the secret.
+func makeSecretRows(secrets []secret, now time.Time) (rows []table.SecretRow) {
+	for _, s := range secrets {
+		rows = append(rows, table.SecretRow{
+			UUID:     s.UUID.String(),
+			Filename: s.originalFilename,
+			Size:     humanize.Pluralize(len(s.decryptedContent), "byte", "bytes"),
+			Received: humanize.RoughDuration(now.Sub(firstSeenSecret(s.UUID, now))) + " ago",
+		})
+	}
+	return rows
+}
+
+// firstSeenSecret returns the time the secret with the given UUID was first downloaded, recording
+// `now` in the database if it hasn't been seen before.
+func firstSeenSecret(secretUUID uuid.UUID, now time.Time) time.Time {
+	seen, err := db.GetLast("receive", secretUUID)
+	if err != nil {
+		log.Printf("error calling db.GetLast(\"receive\", %s): %v", secretUUID, err)
+		return now
+	}
+
+	if seen.IsZero() {
+		if err := db.RecordLast("receive", secretUUID, now); err != nil {
+			log.Printf("error calling db.RecordLast(\"receive\", %s): %v", secretUUID, err)
+		}
+		return now
+	}
+	return seen
+}
+
+// forgetSecret removes the time the secret with the given UUID was first seen from the
+// database, once the secret has been deleted from the server.
+func forgetSecret(secretUUID uuid.UUID) {
+	if err := db.DeleteLast("receive", secretUUID); err != nil {
+		log.Printf("error calling db.DeleteLast(\"receive\", %s): %v", secretUUID, err)
+	}
+}
+
 func promptAndWriteToDownloads(secret secret, prompter promptYesNoInterface) error {
 	downloadsDir, err := getDownloadsDir()
 	if err != nil {
@@ -21,6 +21,7 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fluidkeys/api/v1structs"
 	"github.com/fluidkeys/crypto/openpgp/packet"
@@ -362,3 +363,46 @@ func TestGenerateIncrementedFilenames(t *testing.T) {
 	}
 	assert.Equal(t, expected, gotFilenames)
 }
+
+func TestFilterSecretsByUUID(t *testing.T) {
+	uuid1 := uuid.Must(uuid.FromString("11111111-1111-1111-1111-111111111111"))
+	uuid2 := uuid.Must(uuid.FromString("22222222-2222-2222-2222-222222222222"))
+	uuid3 := uuid.Must(uuid.FromString("33333333-3333-3333-3333-333333333333"))
+
+	secrets := []secret{
+		{decryptedContent: "one", UUID: uuid1},
+		{decryptedContent: "two", UUID: uuid2},
+	}
+
+	t.Run("returns the secret with a matching UUID", func(t *testing.T) {
+		got := filterSecretsByUUID(secrets, uuid2)
+		assert.Equal(t, []secret{{decryptedContent: "two", UUID: uuid2}}, got)
+	})
+
+	t.Run("returns nothing if no secret matches", func(t *testing.T) {
+		got := filterSecretsByUUID(secrets, uuid3)
+		assert.Equal(t, 0, len(got))
+	})
+}
+
+func TestFirstSeenSecret(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+	secretUUID := uuid.Must(uuid.NewV4())
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("records the first time a secret is seen", func(t *testing.T) {
+		assert.Equal(t, now, firstSeenSecret(secretUUID, now))
+		assert.Equal(t, now, firstSeenSecret(secretUUID, now.Add(time.Hour)))
+	})
+
+	t.Run("forgets the secret once it's been deleted", func(t *testing.T) {
+		forgetSecret(secretUUID)
+
+		seen, err := db.GetLast("receive", secretUUID)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Time{}, seen)
+	})
+}
@@ -25,24 +25,31 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/fluidkeys/crypto/openpgp"
 	"github.com/fluidkeys/crypto/openpgp/armor"
-	"github.com/fluidkeys/fluidkeys/apiclient"
 	"github.com/fluidkeys/fluidkeys/colour"
+	"github.com/fluidkeys/fluidkeys/humanize"
+	"github.com/fluidkeys/fluidkeys/keylookup"
 	"github.com/fluidkeys/fluidkeys/out"
 	"github.com/fluidkeys/fluidkeys/pgpkey"
 	"github.com/fluidkeys/fluidkeys/policy"
 	"github.com/fluidkeys/fluidkeys/stringutils"
+	"github.com/fluidkeys/fluidkeys/team"
 )
 
 func secretSend(recipientEmail string, filename string) exitCode {
-	armoredPublicKey, err := api.GetPublicKey(recipientEmail)
+	if team.IsGroupName(recipientEmail) {
+		return secretSendToGroup(recipientEmail, filename)
+	}
+
+	keys, source, err := keylookup.FindByEmail(recipientEmail, emailKeySources()...)
 	if err != nil {
-		if err == apiclient.ErrPublicKeyNotFound {
+		if err == keylookup.ErrNotFound {
 			out.Print("\n")
-			out.Print("Couldn't find " + recipientEmail + " on Fluidkeys.\n\n")
+			out.Print("Couldn't find " + recipientEmail + " on Fluidkeys or in their Web Key Directory.\n\n")
 			out.Print("You can invite them to install Fluidkeys:\n")
 			out.Print("───\n")
 			out.Print(colour.Warning(`I'd like to send you an encrypted secret with Fluidkeys.
@@ -59,27 +66,61 @@ https://download.fluidkeys.com#` + recipientEmail + `
 		return 1
 	}
 
-	pgpKey, err := pgpkey.LoadFromArmoredPublicKey(armoredPublicKey)
+	pgpKey, err := firstEncryptableKey(keys)
 	if err != nil {
-		printFailed("Couldn't load the public key:")
+		printFailed("Couldn't encrypt to the key:")
 		out.Print("Error: " + err.Error() + "\n")
 		return 1
 	}
 
-	_, err = encryptSecret("dummy data to test encryption", "", pgpKey)
-	if err != nil {
-		printFailed("Couldn't encrypt to the key:")
+	_, onFluidkeys := source.(keylookup.FluidkeysDirectory)
+	if !onFluidkeys {
+		printInfo("Found key " + pgpKey.Fingerprint().String() + " for " + recipientEmail +
+			" using " + source.Name())
+		out.Print(colour.Info("They aren't on Fluidkeys, so you'll need to send them the " +
+			"encrypted secret yourself.\n\n"))
+	}
+
+	secret, basename, ok := readSecretToSend(filename, recipientEmail)
+	if !ok {
+		return 1
+	}
+
+	if !onFluidkeys {
+		encryptedSecret, err := encryptSecret(secret, basename, pgpKey)
+		if err != nil {
+			printFailed("Couldn't encrypt the secret:")
+			out.Print("Error: " + err.Error() + "\n")
+			return 1
+		}
+
+		out.Print("Send this encrypted message to " + recipientEmail + ", for example by email:\n\n")
+		out.Print(encryptedSecret + "\n")
+		return 0
+	}
+
+	if err := sendSecret(secret, basename, pgpKey); err != nil {
+		printFailed("Couldn't send the secret to " + recipientEmail)
 		out.Print("Error: " + err.Error() + "\n")
 		return 1
 	}
 
-	var secret string
-	var basename string
+	printSuccess("Sent. You should tell them to check Fluidkeys.\n")
+	return 0
+}
+
+// readSecretToSend reads the secret from the given file (showing a preview and asking for
+// confirmation) or, if filename is empty, from stdin. It prints any problems and returns ok false
+// if the secret shouldn't be sent.
+func readSecretToSend(filename string, recipient string) (
+	secret string, basename string, ok bool) {
+
+	var err error
 	if filename != "" {
 		secret, err = getSecretFromFile(filename, nil)
 		if err != nil {
 			printFailed("Error: " + err.Error())
-			return 1
+			return "", "", false
 		}
 
 		basename = filepath.Base(filename)
@@ -95,45 +136,128 @@ https://download.fluidkeys.com#` + recipientEmail + `
 		}
 		out.Print("\n")
 
-		out.Print(colour.Info("The file will be end-to-end encrypted to " + recipientEmail + "\n"))
+		out.Print(colour.Info("The file will be end-to-end encrypted to " + recipient + "\n"))
 		out.Print(colour.Info("so no-one else can read it 🕵️\n\n"))
 
 		prompter := interactiveYesNoPrompter{}
 
 		if !prompter.promptYesNo("Send "+basename+"?", "y", nil) {
-			return 1
+			return "", "", false
 		}
 	} else {
 		out.Print(colour.Info("Type or paste your secret, ending by typing Ctrl-D\n"))
-		out.Print(colour.Info("It will be end-to-end encrypted to " + recipientEmail + "\n"))
+		out.Print(colour.Info("It will be end-to-end encrypted to " + recipient + "\n"))
 		out.Print(colour.Info("so no-one else can read it 🕵️\n\n"))
 
 		secret, err = getSecretFromStdin(&stdinReader{})
 		if err != nil {
 			printFailed("Error: " + err.Error())
-			return 1
+			return "", "", false
 		}
 		basename = ""
 	}
+	return secret, basename, true
+}
 
-	encryptedSecret, err := encryptSecret(secret, basename, pgpKey)
+// secretSendToGroup encrypts and sends the secret separately to everyone in the given group
+// (e.g. @ops), using the fingerprints in the verified team rosters and keys from GnuPG.
+func secretSendToGroup(groupName string, filename string) exitCode {
+	memberships, err := loadVerifiedMemberships()
 	if err != nil {
-		printFailed("Couldn't encrypt the secret:")
+		printFailed("Failed to load team memberships")
 		out.Print("Error: " + err.Error() + "\n")
 		return 1
 	}
 
-	err = api.CreateSecret(pgpKey.Fingerprint(), encryptedSecret)
-	if err != nil {
-		printFailed("Couldn't send the secret to " + recipientEmail)
-		out.Print("Error: " + err.Error() + "\n")
+	people, matchedTeams := findRecipients(groupName, teamsFromMemberships(memberships))
+	people = withoutPeople(people, myPeopleInTeams(memberships, matchedTeams))
+	if len(people) == 0 {
+		printFailed("Couldn't find anyone else in " + groupName + " in your teams")
+		out.Print("Groups are listed in the team roster, which you can change with " +
+			colour.Cmd("fk team edit") + "\n\n")
+		return 1
+	}
+
+	_, errorLines := loadRecipientKeys(people, time.Now())
+	if len(errorLines) > 0 {
+		printFailed("Couldn't get everyone's key from GnuPG")
+		for _, line := range errorLines {
+			out.Print("     " + line + "\n")
+		}
+		out.Print("\n")
+		return 1
+	}
+
+	description := groupName + " (" + humanize.Pluralize(len(people), "person", "people") + ")"
+	secret, basename, ok := readSecretToSend(filename, description)
+	if !ok {
 		return 1
 	}
 
+	failed := false
+	for _, person := range people {
+		// send it to each of their keys, so they can read it on any of their devices
+		keys, _ := loadRecipientKeys([]team.Person{person}, time.Now())
+
+		sent := true
+		for _, key := range keys {
+			if err := sendSecret(secret, basename, key); err != nil {
+				printFailed("Couldn't send the secret to " + person.Email)
+				out.Print("Error: " + err.Error() + "\n")
+				failed = true
+				sent = false
+			}
+		}
+		if sent {
+			printSuccess("Sent to " + person.Email)
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	out.Print("\n")
 	printSuccess("Sent. You should tell them to check Fluidkeys.\n")
 	return 0
 }
 
+// sendSecret encrypts the secret to the key and sends it to Fluidkeys, where the key's owner can
+// receive it.
+func sendSecret(secret string, basename string, key *pgpkey.PgpKey) error {
+	encryptedSecret, err := encryptSecret(secret, basename, key)
+	if err != nil {
+		return err
+	}
+	return api.CreateSecret(key.Fingerprint(), encryptedSecret)
+}
+
+// withoutPeople returns the people whose fingerprint isn't one of the excluded people's keys.
+func withoutPeople(people []team.Person, excluded []team.Person) (remaining []team.Person) {
+	for _, person := range people {
+		isExcluded := false
+		for _, e := range excluded {
+			if e.HasFingerprint(person.Fingerprint) {
+				isExcluded = true
+			}
+		}
+		if !isExcluded {
+			remaining = append(remaining, person)
+		}
+	}
+	return remaining
+}
+
+// firstEncryptableKey returns the first of the keys which can be encrypted to. Keys found using
+// Web Key Directory may include old keys which can't.
+func firstEncryptableKey(keys []*pgpkey.PgpKey) (key *pgpkey.PgpKey, err error) {
+	for _, key := range keys {
+		if _, err = encryptSecret("dummy data to test encryption", "", key); err == nil {
+			return key, nil
+		}
+	}
+	return nil, err
+}
+
 func getSecretFromFile(filename string, fileReader ioutilReadFileInterface) (string, error) {
 	if fileReader == nil {
 		fileReader = &ioutilReadFilePassthrough{}
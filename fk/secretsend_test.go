@@ -13,6 +13,7 @@ import (
 	"github.com/fluidkeys/fluidkeys/colour"
 	"github.com/fluidkeys/fluidkeys/exampledata"
 	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
 )
 
 func TestEncryptSecret(t *testing.T) {
@@ -232,3 +233,19 @@ func assertMessageBodyMatchesSecretContent(unverifiedBody io.Reader, secret stri
 		t.Fatalf("recovered message incorrect got '%s', want '%s'", messageBuf.Bytes(), secret)
 	}
 }
+
+func TestWithoutPeople(t *testing.T) {
+	alice := team.Person{Email: "alice@example.com", Fingerprint: exampledata.ExampleFingerprint2}
+	bob := team.Person{Email: "bob@example.com", Fingerprint: exampledata.ExampleFingerprint3}
+	carol := team.Person{Email: "carol@example.com", Fingerprint: exampledata.ExampleFingerprint4}
+
+	t.Run("removes excluded people by fingerprint", func(t *testing.T) {
+		got := withoutPeople([]team.Person{alice, bob, carol}, []team.Person{bob})
+		assert.Equal(t, []team.Person{alice, carol}, got)
+	})
+
+	t.Run("returns nothing if everyone is excluded", func(t *testing.T) {
+		got := withoutPeople([]team.Person{alice}, []team.Person{alice})
+		assert.Equal(t, 0, len(got))
+	})
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	docopt "github.com/docopt/docopt-go"
+	"github.com/fluidkeys/fluidkeys/colour"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/ui"
+	"github.com/natefinch/atomic"
+)
+
+func signSubcommand(args docopt.Opts) exitCode {
+	filename, err := args.String("<filename>")
+	if err != nil {
+		log.Panic(err)
+	}
+	clearsign, err := args.Bool("--clearsign")
+	if err != nil {
+		log.Panic(err)
+	}
+	return fileSign(filename, clearsign)
+}
+
+// fileSign signs the given file and writes the signature to <filename>.asc
+// By default it makes a detached signature. If clearsign is true it instead writes a cleartext
+// signed message containing the file.
+func fileSign(filename string, clearsign bool) exitCode {
+	out.Print("\n")
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to read "+filename, nil, err))
+		return 1
+	}
+
+	key, code := getKeyForSigning()
+	if code != 0 {
+		return code
+	}
+
+	privateKey, _, err := getDecryptedPrivateKeyAndPassword(key, &interactivePasswordPrompter{})
+	if err != nil {
+		out.Print(ui.FormatFailure("Error getting private key and password", nil, err))
+		return 1
+	}
+
+	var signed string
+	if clearsign {
+		signed, err = privateKey.MakeArmoredClearSignature(data)
+	} else {
+		signed, err = privateKey.MakeArmoredDetachedSignature(data)
+	}
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to sign "+filename, nil, err))
+		return 1
+	}
+
+	outFilename := filename + ".asc"
+	if _, err := os.Stat(outFilename); err == nil {
+		prompter := interactiveYesNoPrompter{}
+		if !prompter.promptYesNo(outFilename+" already exists. Overwrite it?", "n", nil) {
+			return 1
+		}
+	}
+
+	if err := atomic.WriteFile(outFilename, strings.NewReader(signed)); err != nil {
+		out.Print(ui.FormatFailure("Failed to write "+outFilename, nil, err))
+		return 1
+	}
+
+	verifyCommand := "fk verify " + filename + " " + outFilename
+	if clearsign {
+		verifyCommand = "fk verify " + outFilename
+	}
+
+	out.Print(ui.FormatSuccess("Signed "+filename+" with "+displayName(key), []string{
+		"Wrote the signature to " + colour.File(outFilename),
+		"",
+		"Anyone in your team can check the signature by running",
+		colour.Cmd(verifyCommand),
+	}))
+	return 0
+}
+
+// getKeyForSigning returns the user's key, asking them to choose if they have more than one.
+func getKeyForSigning() (*pgpkey.PgpKey, exitCode) {
+	keys, err := loadPgpKeys()
+	if err != nil {
+		out.Print(ui.FormatFailure("Error loading pgp keys", nil, err))
+		return nil, 1
+	}
+
+	switch len(keys) {
+	case 0:
+		out.Print(ui.FormatFailure("You don't have a key to sign with", []string{
+			"Create a key by running " + colour.Cmd("fk setup"),
+		}, nil))
+		return nil, 1
+
+	case 1:
+		return &keys[0], 0
+
+	default:
+		printHeader("Which key do you want to sign with?")
+
+		if err := printEmailsWithNumbers(keys); err != nil {
+			return nil, 1 // no need to print as the function prints its own errors
+		}
+		return promptForKeyByNumber(keys, "Which key?"), 0
+	}
+}
@@ -18,6 +18,7 @@
 package fk
 
 import (
+	"log"
 	"time"
 
 	docopt "github.com/docopt/docopt-go"
@@ -70,6 +71,8 @@ func statusSubcommand(args docopt.Opts) exitCode {
 	}
 	allKeysWithWarnings = append(allKeysWithWarnings, orphanedKeysWithWarnings...)
 
+	printQueuedOperations()
+
 	if len(allKeysWithWarnings) == 0 {
 		out.Print(ui.FormatInfo("Get started with Fluidkeys", []string{
 			"If your team is already using Fluidkeys, ask your admin for the",
@@ -96,6 +99,26 @@ func statusSubcommand(args docopt.Opts) exitCode {
 	return 0
 }
 
+// printQueuedOperations warns about any operations waiting to be sent to Fluidkeys because it
+// couldn't be reached.
+func printQueuedOperations() {
+	operations, err := db.GetQueuedOperations()
+	if err != nil {
+		log.Printf("failed to get queued operations: %v", err)
+		return
+	}
+	if len(operations) == 0 {
+		return
+	}
+
+	out.Print(ui.FormatWarning(
+		humanize.Pluralize(len(operations), "operation is", "operations are")+
+			" queued to send to Fluidkeys", []string{
+			"Fluidkeys couldn't be reached when they were attempted. They'll be retried",
+			"next time " + colour.Cmd("fk sync") + " runs.",
+		}, nil))
+}
+
 func printMemberships(groupedMemberships []userpackage.GroupedMembership) (
 	membershipKeysWithWarnings []table.KeyWithWarnings, code exitCode) {
 
@@ -130,10 +153,7 @@ func printMemberships(groupedMemberships []userpackage.GroupedMembership) (
 
 		peopleRows := []table.PersonRow{}
 		for _, person := range groupedMembership.Team.People {
-			lastFetched, err := db.GetLast("fetch", person.Fingerprint)
-			if err != nil {
-				continue
-			}
+			lastFetched := lastFetchedKey(person.Fingerprint)
 			var roughDurationSinceLastFetched string
 			if lastFetched.IsZero() {
 				roughDurationSinceLastFetched = "-"
@@ -145,7 +165,7 @@ func printMemberships(groupedMemberships []userpackage.GroupedMembership) (
 			peopleRows = append(
 				peopleRows, table.PersonRow{
 					Email:              person.Email,
-					IsAdmin:            person.IsAdmin,
+					Role:               string(person.GetRole()),
 					TimeSinceLastFetch: roughDurationSinceLastFetched,
 				},
 			)
@@ -39,6 +39,11 @@ func sync() (code exitCode) {
 		}))
 
 	out.Print("\n")
+	if err := replayQueuedOperations(true); err != nil {
+		out.Print(ui.FormatWarning("Failed to send queued operations to Fluidkeys", nil, err))
+		code = 1
+	}
+
 	out.Print("-> " + colour.Cmd("fk key maintain automatic") + "\n")
 
 	if exitCode := keyMaintain(false, true); exitCode != 0 {
@@ -47,7 +52,11 @@ func sync() (code exitCode) {
 
 	out.Print("\n")
 	out.Print("-> " + colour.Cmd("fk team fetch") + "\n\n")
-	if exitCode := teamFetch(true); exitCode != 0 {
+	if exitCode := teamFetch(true, ""); exitCode != 0 {
+		code = exitCode
+	}
+
+	if exitCode := autoApproveRequestsToJoinTeams(); exitCode != 0 {
 		code = exitCode
 	}
 
@@ -27,11 +27,21 @@ import (
 )
 
 func teamSubcommand(args docopt.Opts) exitCode {
+	teamSelector := ""
+	if args["--team"] != nil {
+		teamSelector = args["--team"].(string)
+	}
+
 	switch getSubcommand(args, []string{
-		"authorize", "create", "apply", "fetch", "edit",
+		"authorize", "create", "apply", "invite", "fetch", "edit", "remove", "leave", "list",
+		"show",
 	}) {
 
 	case "apply":
+		if args["--token"] != nil {
+			return teamApplyWithInvite(args["--token"].(string))
+		}
+
 		id, err := args.String("<uuid>")
 		if err != nil {
 			log.Panic(err)
@@ -45,17 +55,46 @@ func teamSubcommand(args docopt.Opts) exitCode {
 
 		return teamApply(teamUUID)
 
+	case "invite":
+		email, err := args.String("<email>")
+		if err != nil {
+			log.Panic(err)
+		}
+		return teamInvite(email, teamSelector)
+
 	case "fetch":
-		return teamFetch(false)
+		if accept, _ := args.Bool("--accept-latest-roster"); accept {
+			return teamAcceptLatestRoster(teamSelector)
+		}
+		return teamFetch(false, teamSelector)
 
 	case "create":
 		return teamCreate()
 
 	case "authorize":
-		return teamAuthorize()
+		return teamAuthorize(teamSelector)
 
 	case "edit":
-		return teamEdit()
+		return teamEdit(teamSelector)
+
+	case "remove":
+		email, err := args.String("<email>")
+		if err != nil {
+			log.Panic(err)
+		}
+		return teamRemove(email, teamSelector)
+
+	case "leave":
+		return teamLeave(teamSelector)
+
+	case "list":
+		return teamList()
+
+	case "show":
+		if args["<team>"] != nil {
+			teamSelector = args["<team>"].(string)
+		}
+		return teamShow(teamSelector)
 	}
 	log.Panicf("secretSubcommand got unexpected arguments: %v", args)
 	panic(nil)
@@ -31,12 +31,39 @@ import (
 	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
 	"github.com/fluidkeys/fluidkeys/out"
 	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
 	"github.com/fluidkeys/fluidkeys/ui"
 	"github.com/gofrs/uuid"
 	spin "github.com/tj/go-spin"
 )
 
 func teamApply(teamUUID uuid.UUID) exitCode {
+	return doTeamApply(teamUUID, nil)
+}
+
+// teamApplyWithInvite applies to join the team using an admin's invite token. The token itself
+// isn't sent: the admin who made the invite recognises the request from their own record of
+// it, so they don't need to check our verification details.
+func teamApplyWithInvite(token string) exitCode {
+	invite, err := team.ParseInviteToken(token)
+	if err != nil {
+		out.Print(ui.FormatFailure("Invalid invite", []string{
+			"Check you've copied the whole command from the invitation.",
+		}, err))
+		return 1
+	}
+
+	if invite.HasExpired(time.Now()) {
+		out.Print(ui.FormatFailure("Your invite to join "+invite.TeamName+" has expired", []string{
+			"Ask your team admin to invite you again.",
+		}, nil))
+		return 1
+	}
+
+	return doTeamApply(invite.TeamUUID, invite)
+}
+
+func doTeamApply(teamUUID uuid.UUID, invite *team.Invite) exitCode {
 	if code := ensureUserCanJoinTeam(teamUUID); code != 0 {
 		return code
 	}
@@ -60,6 +87,14 @@ func teamApply(teamUUID uuid.UUID) exitCode {
 		return 1
 	}
 
+	if invite != nil && !invite.IsFor(email) {
+		out.Print(ui.FormatFailure("Your invite is for "+invite.Email, []string{
+			"You can only use it to apply with a key for " + invite.Email + ".",
+			"To make one, run " + colour.Cmd("fk key create"),
+		}, nil))
+		return 1
+	}
+
 	printHeader("Apply to join team")
 
 	alreadyInTeam, err := alreadyInTeam(teamUUID, pgpKey.Fingerprint())
@@ -76,20 +111,21 @@ func teamApply(teamUUID uuid.UUID) exitCode {
 			return 1
 		}
 
-		return teamFetch(false)
+		return teamFetch(false, "")
 	}
 
-	if err := api.RequestToJoinTeam(teamUUID, pgpKey.Fingerprint(), email); err != nil {
+	if err := sendRequestToJoinTeam(teamUUID, teamName, pgpKey.Fingerprint(), email); err != nil {
 		out.Print(ui.FormatFailure("Failed to apply to join "+teamName, nil, err))
 		return 1
 	}
 
-	// recording a request allows `team fetch` to periodically check if it's been authorized
-	if err := db.RecordRequestToJoinTeam(
-		teamUUID, teamName, pgpKey.Fingerprint(), time.Now()); err != nil {
-
-		out.Print(ui.FormatFailure("Failed to apply to join "+teamName, nil, err))
-		return 1
+	// invites only stand in for verification details if the directory has checked our email
+	if invite != nil && api.VerifiesEmails() {
+		out.Print(ui.FormatInfo("You were invited, so your team admin can add you straight away",
+			[]string{
+				"You don't need to send them your verification details.",
+			}))
+		return pollThenRunTeamFetch(teamUUID, pgpKey.Fingerprint())
 	}
 
 	out.Print(ui.FormatInfo("Reply to your team admin so they can add you to the team", []string{
@@ -118,6 +154,17 @@ func teamApply(teamUUID uuid.UUID) exitCode {
 	return pollThenRunTeamFetch(teamUUID, pgpKey.Fingerprint())
 }
 
+// sendRequestToJoinTeam sends the request to join the team to Fluidkeys, then records it so
+// `team fetch` can periodically check if it's been authorized.
+func sendRequestToJoinTeam(teamUUID uuid.UUID, teamName string, fingerprint fpr.Fingerprint,
+	email string) error {
+
+	if err := api.RequestToJoinTeam(teamUUID, fingerprint, email); err != nil {
+		return err
+	}
+	return db.RecordRequestToJoinTeam(teamUUID, teamName, fingerprint, time.Now())
+}
+
 // alreadyInTeam asks the API whether this fingerprint is listed in this team's roster and
 // returns the result, or error if something goes wrong.
 func alreadyInTeam(teamUUID uuid.UUID, fingerprint fp.Fingerprint) (bool, error) {
@@ -170,13 +217,25 @@ func pollThenRunTeamFetch(teamUUID uuid.UUID, fingerprint fp.Fingerprint) exitCo
 		}
 	}
 	out.Print("Running " + colour.Cmd("fk team fetch") + "\n\n")
-	return teamFetch(false)
+	return teamFetch(false, "")
 }
 
 func formatVerificationLines(fingerprint fpr.Fingerprint, email string) []string {
-	return []string{
+	return append([]string{
 		"» key:   " + fingerprint.String(),
 		"  email: " + email,
+		"  code:  " + fingerprint.SafetyCode(),
+	}, formatPGPWords(fingerprint)...)
+}
+
+// formatPGPWords returns the fingerprint's PGP words over two lines, so they fit on screen.
+func formatPGPWords(fingerprint fpr.Fingerprint) []string {
+	words := strings.Fields(fingerprint.PGPWords())
+	half := len(words) / 2
+
+	return []string{
+		"  words: " + strings.Join(words[:half], " "),
+		"         " + strings.Join(words[half:], " "),
 	}
 }
 
@@ -248,7 +307,7 @@ func getKeyForTeam() (*pgpkey.PgpKey, exitCode) {
 		}
 
 		out.Print(ifEmailNotListed)
-		pgpKey = promptForKeyByNumber(keys)
+		pgpKey = promptForKeyByNumber(keys, "Which is your team email?")
 	}
 	return pgpKey, 0
 }
@@ -276,7 +335,7 @@ func promptConfirmThisKey(key *pgpkey.PgpKey) bool {
 	return prompter.promptYesNo("Is this your team email?", "y", nil)
 }
 
-func promptForKeyByNumber(keys []pgpkey.PgpKey) *pgpkey.PgpKey {
+func promptForKeyByNumber(keys []pgpkey.PgpKey, question string) *pgpkey.PgpKey {
 	invalidEntry := fmt.Sprintf("Please select between 1 and %v.\n", len(keys))
 
 	inRange := func(selected int) bool {
@@ -285,7 +344,7 @@ func promptForKeyByNumber(keys []pgpkey.PgpKey) *pgpkey.PgpKey {
 
 	for {
 		rangePrompt := colour.Info(fmt.Sprintf("[1-%v]", len(keys)))
-		input := promptForInput(fmt.Sprintf("Which is your team email? " + rangePrompt + " "))
+		input := promptForInput(question + " " + rangePrompt + " ")
 		if integerSelected, err := strconv.Atoi(input); err != nil {
 			out.Print(invalidEntry)
 
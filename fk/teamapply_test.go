@@ -0,0 +1,20 @@
+package fk
+
+import (
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+)
+
+func TestFormatVerificationLines(t *testing.T) {
+	fingerprint := fpr.MustParse("E582 94F2 E9A2 2748 6E8B  061B 31CC 528F D7FA 3F19")
+
+	assert.Equal(t, []string{
+		"» key:   E582 94F2 E9A2 2748 6E8B  061B 31CC 528F D7FA 3F19",
+		"  email: jane@example.com",
+		"  code:  " + fingerprint.SafetyCode(),
+		"  words: topmost Istanbul Pluto vagabond treadmill Pacific brackish dictator goldfish Medusa",
+		"         afflict bravado chatter revolver Dupont midsummer stopwatch whimsical cowbell bottomless",
+	}, formatVerificationLines(fingerprint, "jane@example.com"))
+}
@@ -18,7 +18,10 @@
 package fk
 
 import (
+	"log"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fluidkeys/fluidkeys/colour"
 	"github.com/fluidkeys/fluidkeys/humanize"
@@ -28,67 +31,83 @@ import (
 	userpackage "github.com/fluidkeys/fluidkeys/user"
 )
 
-func teamAuthorize() exitCode {
-	allMemberships, err := user.Memberships()
-	if err != nil {
-		out.Print(ui.FormatFailure("Failed to list teams", nil, err))
+func teamAuthorize(teamSelector string) exitCode {
+	adminMembership := chooseAdminTeam(teamSelector)
+	if adminMembership == nil {
 		return 1
 	}
 
-	adminMemberships := filterByAdmin(allMemberships)
+	return doAuthorizeRequests(
+		adminMembership.Team, adminMembership.Memberships[0].Me, &interactiveYesNoPrompter{})
+}
+
+func doAuthorizeRequests(
+	myTeam team.Team, me team.Person, prompter promptYesNoInterface) exitCode {
 
-	switch len(adminMemberships) {
-	case 0:
-		out.Print(ui.FormatFailure("You aren't an admin of any teams", nil, nil))
+	printHeader("Authorize requests to join " + myTeam.Name)
+
+	requests, err := api.ListRequestsToJoinTeam(myTeam.UUID, me.Fingerprint)
+	if err != nil {
+		out.Print(ui.FormatFailure("Error getting requests", nil, err))
 		return 1
+	}
+	if len(requests) == 0 {
+		out.Print("No requests to join " + myTeam.Name + "\n\n")
+		return reviewRequestsToLeaveTeam(myTeam, me)
+	}
+
+	out.Print(ui.FormatInfo(
+		"Authorizing a key adds it to the team roster",
+		[]string{
+			"By authorizing a key, everyone in your team will fetch and trust that key.",
+			"",
+			"Your team should have sent you verification details.",
+			"Before authorizing a key, you'll need to type in the code from their verification",
+			"details, or check the words match.",
+		},
+	))
+
+	approvedRequests, deleteRequests := reviewRequests(requests, myTeam, prompter)
+
+	if len(approvedRequests) > 0 {
+		previousTeam := myTeam
+		myTeam.ChainFrom(previousTeam)
+
+		for _, request := range approvedRequests {
+			myTeam.UpsertPerson(
+				team.Person{
+					Email:       request.Email,
+					Fingerprint: request.Fingerprint,
+					IsAdmin:     false,
+				})
+		}
 
-	case 1:
-		myTeam := adminMemberships[0].Team
-		me := adminMemberships[0].Me
+		printHeader("Sign and upload team roster")
 
-		printHeader("Authorize requests to join " + myTeam.Name)
+		out.Print("The team roster is a signed file that defines who is in the team.\n\n")
 
-		requests, err := api.ListRequestsToJoinTeam(myTeam.UUID, me.Fingerprint)
+		pending, err := promptAndSignAndUploadRoster(myTeam, me.Fingerprint,
+			signaturesRequiredForUpdate(previousTeam, myTeam), prompter)
 		if err != nil {
-			out.Print(ui.FormatFailure("Error getting requests", nil, err))
+			out.Print(ui.FormatFailure("Failed to sign and upload roster",
+				formatRosterNotUploaded(err, "run "+colour.Cmd("fk team authorize")+" again."), err))
 			return 1
 		}
-		if len(requests) == 0 {
-			out.Print("No requests to join " + myTeam.Name + "\n")
-			return 0
-		}
-
-		out.Print(ui.FormatInfo(
-			"Authorizing a key adds it to the team roster",
-			[]string{
-				"By authorizing a key, everyone in your team will fetch and trust that key.",
-				"",
-				"Your team should have sent you verification details.",
-				"Check the key and email below match the verification details you've received.",
-			},
-		))
-
-		approvedRequests, deleteRequests := reviewRequests(requests, myTeam)
 
-		if len(approvedRequests) > 0 {
-			myTeam.Version++
+		for _, request := range approvedRequests {
+			recordJoinDecision(myTeam, joinDecision{
+				request: request, approved: true, reason: "approved by an admin", pending: pending,
+			}, false, me.Fingerprint)
+		}
 
+		if pending {
+			// keep the requests until the roster has enough signatures: until then they're
+			// not in the team
+			deleteRequests = withoutRequests(deleteRequests, approvedRequests)
+			approvedRequests = nil
+		} else {
 			for _, request := range approvedRequests {
-				myTeam.UpsertPerson(
-					team.Person{
-						Email:       request.Email,
-						Fingerprint: request.Fingerprint,
-						IsAdmin:     false,
-					})
-			}
-
-			printHeader("Sign and upload team roster")
-
-			out.Print("The team roster is a signed file that defines who is in the team.\n\n")
-
-			if err := promptAndSignAndUploadRoster(myTeam, me.Fingerprint); err != nil {
-				out.Print(ui.FormatFailure("Failed to sign and upload roster", nil, err))
-				return 1
+				forgetInvite(myTeam, request.Email)
 			}
 
 			if err := fetchAndCertifyTeamKeys(myTeam, me, false); err != nil {
@@ -96,31 +115,42 @@ func teamAuthorize() exitCode {
 				return 1
 			}
 		}
+	}
 
-		seenError := false
+	seenError := false
 
-		for _, request := range deleteRequests {
-			if err = api.DeleteRequestToJoinTeam(myTeam.UUID, request.UUID); err != nil {
-				out.Print(ui.FormatWarning(
-					"Failed to delete a request to join the team", nil, err,
-				))
-				seenError = true
-			}
+	for _, request := range deleteRequests {
+		if !containsRequest(approvedRequests, request) {
+			reason, automatic := rejectionReason(myTeam, request)
+			recordJoinDecision(myTeam, joinDecision{
+				request: request, approved: false, reason: reason,
+			}, automatic, me.Fingerprint)
 		}
 
-		if seenError {
-			return 1
-		}
+		if err = api.DeleteRequestToJoinTeam(myTeam.UUID, request.UUID); err != nil {
+			if queueIfOffline(err, makeQueuedDeleteRequestToJoinTeam(
+				myTeam.UUID, request.UUID, time.Now())) {
 
-		return 0
+				printWarning("Couldn't reach Fluidkeys: the request will be deleted by " +
+					colour.Cmd("fk sync"))
+				continue
+			}
+			out.Print(ui.FormatWarning(
+				"Failed to delete a request to join the team", nil, err,
+			))
+			seenError = true
+		}
+	}
 
-	default:
-		out.Print(ui.FormatFailure("Choosing from multiple teams not implemented", nil, nil))
+	if seenError {
 		return 1
 	}
+
+	return reviewRequestsToLeaveTeam(myTeam, me)
 }
 
-func reviewRequests(requests []team.RequestToJoinTeam, myTeam team.Team) (
+func reviewRequests(requests []team.RequestToJoinTeam, myTeam team.Team,
+	prompter promptYesNoInterface) (
 	approvedRequests []team.RequestToJoinTeam, deleteRequests []team.RequestToJoinTeam) {
 
 	out.Print(humanize.Pluralize(len(requests), "request", "requests") + " to join " +
@@ -131,11 +161,37 @@ func reviewRequests(requests []team.RequestToJoinTeam, myTeam team.Team) (
 	}
 	out.Print("\n")
 
-	prompter := interactiveYesNoPrompter{}
+	pendingTeam, err := loadPendingRoster(myTeam)
+	if err != nil {
+		log.Printf("failed to load pending roster for %s: %v", myTeam.Name, err)
+	}
+
 	for _, request := range requests {
 		out.Print("» key:   " + colour.Info(request.Fingerprint.String()) + "\n")
 		out.Print("  email: " + colour.Info(request.Email) + "\n")
 
+		if pendingTeam != nil && pendingTeam.Contains(request.Fingerprint) {
+			out.Print(ui.FormatInfo(
+				"This request has already been approved", []string{
+					"The roster adding them is waiting for other admins to co-sign it.",
+					"Skipping.",
+				},
+			))
+			continue
+		}
+
+		if err := myTeam.CheckJoinRules(request.Email); err != nil {
+			out.Print(ui.FormatWarning(
+				"This request breaks the team's join rules", []string{
+					"The roster says " + err.Error() + ".",
+					"Rejecting it.",
+				},
+				nil,
+			))
+			deleteRequests = append(deleteRequests, request)
+			continue
+		}
+
 		existingPerson, err := myTeam.GetUpsertPersonWarnings(team.Person{
 			Email:       request.Email,
 			Fingerprint: request.Fingerprint,
@@ -187,9 +243,32 @@ func reviewRequests(requests []team.RequestToJoinTeam, myTeam team.Team) (
 			out.Print("\n")
 		}
 
-		addToTeam := prompter.promptYesNo(
-			"Authorize "+request.Email+" now? (type n to decide later)", "", nil,
-		)
+		var invitedBy *team.Person
+		if err == nil { // invites only pre-authorize new people, not changes to existing ones
+			if invitedBy, err = verifyInvite(myTeam, request); err != nil {
+				out.Print(ui.FormatWarning(
+					"This request's invite isn't valid", []string{
+						"Check their verification details as you would without an invite.",
+					},
+					err,
+				))
+			}
+		}
+
+		var addToTeam bool
+		if invitedBy != nil {
+			addToTeam = prompter.promptYesNo(
+				"Add "+request.Email+" now? They were invited by "+invitedBy.Email, "y", nil,
+			)
+		} else {
+			addToTeam = prompter.promptYesNo(
+				"Authorize "+request.Email+" now? (type n to decide later)", "", nil,
+			)
+
+			if addToTeam && !confirmVerificationDetails(request, prompter) {
+				addToTeam = false
+			}
+		}
 
 		if addToTeam {
 			approvedRequests = append(approvedRequests, request)
@@ -214,6 +293,71 @@ func reviewRequests(requests []team.RequestToJoinTeam, myTeam team.Team) (
 	return approvedRequests, deleteRequests
 }
 
+// confirmVerificationDetails asks the admin to type the safety code from the verification details
+// the requester sent them, or (if they leave it blank) to confirm that the PGP words match.
+// It returns true if the details match.
+func confirmVerificationDetails(
+	request team.RequestToJoinTeam, prompter promptYesNoInterface) bool {
+
+	typed := promptForInput(
+		"Type the code from " + request.Email + "'s verification details, " +
+			"or press enter to compare words instead: ")
+
+	if strings.TrimSpace(typed) != "" {
+		if request.Fingerprint.MatchesSafetyCode(typed) {
+			printSuccess("The code matches")
+			return true
+		}
+		out.Print(ui.FormatFailure("That code doesn't match "+request.Email+"'s key", []string{
+			"Make sure you're reading the code from their verification details, sent to you",
+			"directly. If it still doesn't match, the request may not be from them.",
+		}, nil))
+		return false
+	}
+
+	out.Print("The words in their verification details should be:\n\n")
+	for _, line := range formatPGPWords(request.Fingerprint) {
+		out.Print(colour.Info(line) + "\n")
+	}
+	out.Print("\n")
+
+	return prompter.promptYesNo("Do all the words match?", "", nil)
+}
+
+// rejectionReason explains why the request was rejected, and whether it was rejected
+// automatically (by the join rules, or because they're already in the team) rather than by
+// the admin.
+func rejectionReason(t team.Team, request team.RequestToJoinTeam) (reason string, automatic bool) {
+	if err := t.CheckJoinRules(request.Email); err != nil {
+		return err.Error(), true
+	}
+	if t.Contains(request.Fingerprint) {
+		return "already in the team", true
+	}
+	return "rejected by an admin", false
+}
+
+// withoutRequests returns the requests that aren't in toRemove.
+func withoutRequests(requests []team.RequestToJoinTeam, toRemove []team.RequestToJoinTeam) (
+	remaining []team.RequestToJoinTeam) {
+
+	for _, request := range requests {
+		if !containsRequest(toRemove, request) {
+			remaining = append(remaining, request)
+		}
+	}
+	return remaining
+}
+
+func containsRequest(requests []team.RequestToJoinTeam, request team.RequestToJoinTeam) bool {
+	for _, r := range requests {
+		if r.UUID == request.UUID {
+			return true
+		}
+	}
+	return false
+}
+
 func filterByAdmin(memberships []userpackage.TeamMembership) (
 	adminMemberships []userpackage.TeamMembership) {
 
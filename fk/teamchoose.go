@@ -0,0 +1,175 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fluidkeys/fluidkeys/colour"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/ui"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+	"github.com/gofrs/uuid"
+)
+
+// chooseAdminTeam returns the team I'm an admin of that matches teamSelector, or asks which
+// team if teamSelector is empty and I'm an admin of several. It prints any problem and returns
+// nil if no team was chosen.
+func chooseAdminTeam(teamSelector string) *userpackage.GroupedMembership {
+	groupedMemberships, err := user.GroupedMemberships()
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to list teams", nil, err))
+		return nil
+	}
+
+	adminMemberships := filterGroupedByAdmin(groupedMemberships)
+	if len(adminMemberships) == 0 {
+		out.Print(ui.FormatFailure("You aren't an admin of any teams", nil, nil))
+		return nil
+	}
+
+	return chooseTeamOrPrintFailure(adminMemberships, teamSelector)
+}
+
+// chooseMemberTeam returns the team I'm in that matches teamSelector, or asks which team if
+// teamSelector is empty and I'm in several. It prints any problem and returns nil if no team
+// was chosen.
+func chooseMemberTeam(teamSelector string) *userpackage.GroupedMembership {
+	groupedMemberships, err := user.GroupedMemberships()
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to list teams", nil, err))
+		return nil
+	}
+
+	if len(groupedMemberships) == 0 {
+		out.Print(ui.FormatFailure("You aren't in any teams", nil, nil))
+		return nil
+	}
+
+	return chooseTeamOrPrintFailure(groupedMemberships, teamSelector)
+}
+
+func chooseTeamOrPrintFailure(groupedMemberships []userpackage.GroupedMembership,
+	teamSelector string) *userpackage.GroupedMembership {
+
+	chosen, err := chooseTeam(groupedMemberships, teamSelector, promptForTeam)
+	if err != nil {
+		out.Print(ui.FormatFailure(err.Error(), []string{
+			"Choose from " + formatTeamNames(groupedMemberships),
+		}, nil))
+		return nil
+	}
+	return chosen
+}
+
+// chooseTeam returns the team matching teamSelector, which can be the team's name or UUID.
+// If teamSelector is empty it returns the only team, or calls prompt to ask which team if
+// there are several.
+func chooseTeam(groupedMemberships []userpackage.GroupedMembership, teamSelector string,
+	prompt func([]userpackage.GroupedMembership) int) (*userpackage.GroupedMembership, error) {
+
+	if teamSelector == "" {
+		switch len(groupedMemberships) {
+		case 0:
+			return nil, fmt.Errorf("no teams to choose from")
+
+		case 1:
+			return &groupedMemberships[0], nil
+
+		default:
+			return &groupedMemberships[prompt(groupedMemberships)], nil
+		}
+	}
+
+	if teamUUID, err := uuid.FromString(teamSelector); err == nil {
+		for i := range groupedMemberships {
+			if groupedMemberships[i].Team.UUID == teamUUID {
+				return &groupedMemberships[i], nil
+			}
+		}
+		return nil, fmt.Errorf("no team with UUID %s", teamUUID)
+	}
+
+	var matching []*userpackage.GroupedMembership
+	for i := range groupedMemberships {
+		if strings.EqualFold(groupedMemberships[i].Team.Name, teamSelector) {
+			matching = append(matching, &groupedMemberships[i])
+		}
+	}
+
+	switch len(matching) {
+	case 0:
+		return nil, fmt.Errorf("no team called %s", teamSelector)
+
+	case 1:
+		return matching[0], nil
+
+	default:
+		return nil, fmt.Errorf("more than one team called %s: use the team's UUID", teamSelector)
+	}
+}
+
+// promptForTeam lists the teams and asks which one to use, returning its index.
+func promptForTeam(groupedMemberships []userpackage.GroupedMembership) int {
+	for index, membership := range groupedMemberships {
+		formattedListNumber := colour.Info(fmt.Sprintf("%-4s", strconv.Itoa(index+1)+"."))
+		out.Print(formattedListNumber + membership.Team.Name + "\n")
+		out.Print("    " + membership.Team.UUID.String() + "\n")
+	}
+	out.Print("\n")
+
+	invalidEntry := fmt.Sprintf("Please select between 1 and %v.\n", len(groupedMemberships))
+	for {
+		rangePrompt := colour.Info(fmt.Sprintf("[1-%v]", len(groupedMemberships)))
+		input := promptForInput("Which team? " + rangePrompt + " ")
+
+		if integerSelected, err := strconv.Atoi(input); err != nil {
+			out.Print(invalidEntry)
+		} else if integerSelected >= 1 && integerSelected <= len(groupedMemberships) {
+			return integerSelected - 1
+		} else {
+			out.Print(invalidEntry)
+		}
+	}
+}
+
+func formatTeamNames(groupedMemberships []userpackage.GroupedMembership) string {
+	names := []string{}
+	for _, membership := range groupedMemberships {
+		names = append(names, colour.Info(membership.Team.Name))
+	}
+	return strings.Join(names, ", ")
+}
+
+// filterGroupedByAdmin returns the teams I'm an admin of, keeping only my memberships that
+// are admins.
+func filterGroupedByAdmin(groupedMemberships []userpackage.GroupedMembership) (
+	adminMemberships []userpackage.GroupedMembership) {
+
+	for _, groupedMembership := range groupedMemberships {
+		if admins := filterByAdmin(groupedMembership.Memberships); len(admins) > 0 {
+			adminMemberships = append(adminMemberships, userpackage.GroupedMembership{
+				Team:        groupedMembership.Team,
+				Memberships: admins,
+			})
+		}
+	}
+	return adminMemberships
+}
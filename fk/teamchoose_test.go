@@ -0,0 +1,91 @@
+package fk
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/team"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+	"github.com/gofrs/uuid"
+)
+
+func TestChooseTeam(t *testing.T) {
+	kiffix := makeGroupedMembership("Kiffix", true)
+	acme := makeGroupedMembership("Acme", false)
+	otherAcme := makeGroupedMembership("acme", true)
+
+	neverPrompt := func([]userpackage.GroupedMembership) int {
+		t.Fatalf("unexpected prompt")
+		return 0
+	}
+
+	t.Run("returns the only team without prompting", func(t *testing.T) {
+		got, err := chooseTeam([]userpackage.GroupedMembership{kiffix}, "", neverPrompt)
+		assert.NoError(t, err)
+		assert.Equal(t, kiffix.Team.UUID, got.Team.UUID)
+	})
+
+	t.Run("prompts given several teams and no selector", func(t *testing.T) {
+		got, err := chooseTeam(
+			[]userpackage.GroupedMembership{kiffix, acme},
+			"",
+			func([]userpackage.GroupedMembership) int { return 1 },
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, acme.Team.UUID, got.Team.UUID)
+	})
+
+	t.Run("matches name ignoring case", func(t *testing.T) {
+		got, err := chooseTeam(
+			[]userpackage.GroupedMembership{kiffix, acme}, "KIFFIX", neverPrompt)
+		assert.NoError(t, err)
+		assert.Equal(t, kiffix.Team.UUID, got.Team.UUID)
+	})
+
+	t.Run("matches UUID", func(t *testing.T) {
+		got, err := chooseTeam(
+			[]userpackage.GroupedMembership{kiffix, acme, otherAcme},
+			otherAcme.Team.UUID.String(),
+			neverPrompt,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, otherAcme.Team.UUID, got.Team.UUID)
+	})
+
+	t.Run("errors if the name is ambiguous", func(t *testing.T) {
+		_, err := chooseTeam(
+			[]userpackage.GroupedMembership{kiffix, acme, otherAcme}, "Acme", neverPrompt)
+		assert.Equal(t, fmt.Errorf("more than one team called Acme: use the team's UUID"), err)
+	})
+
+	t.Run("errors if no team matches", func(t *testing.T) {
+		_, err := chooseTeam([]userpackage.GroupedMembership{kiffix}, "Acme", neverPrompt)
+		assert.Equal(t, fmt.Errorf("no team called Acme"), err)
+	})
+}
+
+func TestFilterGroupedByAdmin(t *testing.T) {
+	kiffix := makeGroupedMembership("Kiffix", true)
+	acme := makeGroupedMembership("Acme", false)
+
+	got := filterGroupedByAdmin([]userpackage.GroupedMembership{kiffix, acme})
+
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, kiffix.Team.UUID, got[0].Team.UUID)
+}
+
+func makeGroupedMembership(name string, isAdmin bool) userpackage.GroupedMembership {
+	me := team.Person{
+		Email:       "test2@example.com",
+		Fingerprint: exampledata.ExampleFingerprint2,
+		IsAdmin:     isAdmin,
+	}
+	t := team.Team{Name: name, UUID: uuid.Must(uuid.NewV4()), People: []team.Person{me}}
+
+	return userpackage.GroupedMembership{
+		Team:        t,
+		Memberships: []userpackage.TeamMembership{{Team: t, Me: me}},
+	}
+}
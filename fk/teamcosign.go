@@ -0,0 +1,158 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/fluidkeys/fluidkeys/colour"
+	fp "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/ui"
+)
+
+// signaturesRequiredForUpdate returns how many admins must sign the updated roster. It's the
+// larger of the current and new rosters' required_signatures, so a single admin can't lower the
+// requirement on their own.
+func signaturesRequiredForUpdate(before team.Team, after team.Team) int {
+	if before.SignaturesRequired() > after.SignaturesRequired() {
+		return before.SignaturesRequired()
+	}
+	return after.SignaturesRequired()
+}
+
+// coSignPendingRoster offers to add my signature to a roster that's been signed by other admins,
+// but not enough of them. It returns the roster's signature, including mine if I signed it.
+func coSignPendingRoster(
+	t team.Team, me team.Person, roster string, signature string, adminKeys []*pgpkey.PgpKey,
+	notEnough *team.ErrNotEnoughSignatures, unattended bool) (newSignature string, err error) {
+
+	pending, err := team.Load(roster, signature)
+	if err != nil {
+		return "", err
+	}
+	signers := pending.Signers(adminKeys)
+
+	if unattended || !t.IsAdmin(me.Fingerprint) || hasSigned(me, signers) {
+		out.Print(formatWaitingForSignatures(*pending, notEnough.Got, notEnough.Required))
+		return signature, nil
+	}
+
+	signedBy := []string{}
+	for _, signer := range signers {
+		if person, err := t.GetPersonForFingerprint(signer); err == nil {
+			signedBy = append(signedBy, "  "+person.Email)
+		}
+	}
+
+	out.Print(ui.FormatInfo(
+		"A new version of the roster for "+t.Name+" needs your signature",
+		append([]string{
+			fmt.Sprintf("Version %d of the roster has %d of the %d admin signatures it needs.",
+				pending.Version, notEnough.Got, notEnough.Required),
+			"It's been signed by:",
+		}, signedBy...),
+	))
+	out.Print(formatRosterPreview(roster))
+	if changes := team.Diff(t, *pending); len(changes) > 0 {
+		out.Print(formatRosterChanges(t.Name, changes))
+	}
+
+	prompter := interactiveYesNoPrompter{}
+	if !prompter.promptYesNo("Co-sign and upload the roster now?", "", nil) {
+		return signature, nil
+	}
+
+	privateKey, err := getUnlockedKey(me.Fingerprint, false)
+	if err != nil {
+		return "", fmt.Errorf("Failed to unlock private key to sign roster: %v", err)
+	}
+
+	err = ui.RunWithCheckboxes("Co-sign and upload team roster", func() error {
+		if newSignature, err = team.AddRosterSignature(roster, signature, privateKey); err != nil {
+			return err
+		}
+		return api.UpsertTeam(roster, newSignature, privateKey.Fingerprint())
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if notEnough.Got+1 < notEnough.Required {
+		teamSubdir, err := team.Directory(t, fluidkeysDirectory)
+		if err != nil {
+			return "", err
+		}
+		saver := team.RosterSaver{Directory: teamSubdir}
+		if err := saver.SavePending(roster, newSignature); err != nil {
+			log.Printf("failed to save pending roster: %v", err)
+		}
+		out.Print(formatWaitingForSignatures(*pending, notEnough.Got+1, notEnough.Required))
+	}
+	return newSignature, nil
+}
+
+// loadPendingRoster returns the newer version of the team's roster that's waiting for more admin
+// signatures, or nil if there isn't one.
+func loadPendingRoster(t team.Team) (*team.Team, error) {
+	teamSubdir, err := team.Directory(t, fluidkeysDirectory)
+	if err != nil {
+		return nil, err
+	}
+	saver := team.RosterSaver{Directory: teamSubdir}
+	roster, signature, err := saver.LoadPending()
+	if err != nil {
+		return nil, err
+	} else if roster == "" {
+		return nil, nil
+	}
+
+	pending, err := team.Load(roster, signature)
+	if err != nil {
+		return nil, err
+	} else if pending.Version <= t.Version {
+		return nil, nil // already superseded
+	}
+	return pending, nil
+}
+
+// hasSigned returns whether any of the person's keys are among the signers.
+func hasSigned(person team.Person, signers []fp.Fingerprint) bool {
+	for _, fingerprint := range person.Fingerprints() {
+		if fp.Contains(signers, fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatWaitingForSignatures(t team.Team, got int, required int) string {
+	return ui.FormatInfo(
+		"The new roster for "+t.Name+" is waiting for more admin signatures",
+		[]string{
+			fmt.Sprintf("Version %d of the roster has %d of the %d admin signatures it needs.",
+				t.Version, got, required),
+			"Until then, everyone will keep using the current roster.",
+			"",
+			"Other admins can co-sign it by running " + colour.Cmd("fk team fetch"),
+		},
+	)
+}
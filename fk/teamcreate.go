@@ -26,6 +26,7 @@ import (
 	"github.com/fluidkeys/fluidkeys/colour"
 	fp "github.com/fluidkeys/fluidkeys/fingerprint"
 	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
 	"github.com/fluidkeys/fluidkeys/stringutils"
 	"github.com/fluidkeys/fluidkeys/team"
 	"github.com/fluidkeys/fluidkeys/ui"
@@ -71,7 +72,9 @@ func teamCreate() exitCode {
 		return 1
 	}
 
-	teamMembers := []team.Person{{Email: email, Fingerprint: key.Fingerprint(), IsAdmin: true}}
+	teamMembers := []team.Person{
+		{Email: email, Fingerprint: key.Fingerprint(), IsAdmin: true, Role: team.RoleOwner},
+	}
 
 	printHeader("What's your team name?")
 
@@ -109,11 +112,16 @@ func teamCreate() exitCode {
 
 	out.Print("Create team roster with you in it:\n\n")
 
-	if err := promptAndSignAndUploadRoster(t, key.Fingerprint()); err != nil {
+	pending, err := promptAndSignAndUploadRoster(t, key.Fingerprint(), t.SignaturesRequired(),
+		&interactiveYesNoPrompter{})
+	if err != nil {
 		if err != errUserDeclinedToSign {
-			out.Print(ui.FormatFailure("Failed to sign and upload roster", nil, err))
+			out.Print(ui.FormatFailure("Failed to sign and upload roster",
+				formatRosterNotUploaded(err, "run "+colour.Cmd("fk team create")+" again."), err))
 		}
 		return 1
+	} else if pending {
+		return 0
 	}
 
 	out.Print("\n")
@@ -153,36 +161,49 @@ Join now:
 	return 0
 }
 
-func promptAndSignAndUploadRoster(t team.Team, adminFingerprint fp.Fingerprint) (err error) {
+// promptAndSignAndUploadRoster previews the roster, and if the user agrees, signs and uploads
+// it. If more than one admin signature is required, the roster is saved as pending until other
+// admins co-sign it, and pending is true: nobody will use the roster until then.
+func promptAndSignAndUploadRoster(t team.Team, adminFingerprint fp.Fingerprint,
+	signaturesRequired int, prompter promptYesNoInterface) (pending bool, err error) {
+
 	unsignedRoster, err := t.PreviewRoster()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	out.Print(formatRosterPreview(unsignedRoster))
 
-	prompter := interactiveYesNoPrompter{}
 	if !prompter.promptYesNo("Sign and upload the roster to Fluidkeys now?", "", nil) {
-		return errUserDeclinedToSign
+		return false, errUserDeclinedToSign
 	}
 
 	privateKey, err := getUnlockedKey(adminFingerprint, false)
 	if err != nil {
-		return fmt.Errorf("Failed to unlock private key to sign roster: %v", err)
+		return false, fmt.Errorf("Failed to unlock private key to sign roster: %v", err)
 	}
 
+	return signAndUploadRoster(t, privateKey, signaturesRequired)
+}
+
+// signAndUploadRoster signs the team's roster with the (unlocked) private key, uploads it and
+// saves it. If more than one signature is required, it's saved as pending until other admins
+// co-sign it, and pending is true: callers mustn't act as if the roster has been accepted.
+func signAndUploadRoster(
+	t team.Team, privateKey *pgpkey.PgpKey, signaturesRequired int) (pending bool, err error) {
+
 	const (
 		checkboxSign   = "Created signed team roster"
 		checkboxUpload = "Upload team roster to Fluidkeys"
 	)
 
-	failSign := func(err error) error {
+	failSign := func(err error) (bool, error) {
 		ui.PrintCheckboxFailure(checkboxSign, err)
-		return err
+		return false, err
 	}
-	failUpload := func(err error) error {
+	failUpload := func(err error) (bool, error) {
 		ui.PrintCheckboxFailure(checkboxUpload, err)
-		return err
+		return false, errRosterNotUploaded{originalError: err}
 	}
 
 	ui.PrintCheckboxPending(checkboxSign)
@@ -197,6 +218,22 @@ func promptAndSignAndUploadRoster(t team.Team, adminFingerprint fp.Fingerprint)
 	}
 
 	rosterSaver := team.RosterSaver{Directory: teamSubdirectory}
+
+	if signaturesRequired > 1 {
+		// nobody will accept the roster until other admins have co-signed it
+		if err := api.UpsertTeam(signedRoster, signature, privateKey.Fingerprint()); err != nil {
+			return failUpload(err)
+		}
+		if err := rosterSaver.SavePending(signedRoster, signature); err != nil {
+			return failSign(err)
+		}
+		ui.PrintCheckboxSuccess(checkboxSign)
+		ui.PrintCheckboxSuccess(checkboxUpload)
+		out.Print("\n")
+		out.Print(formatWaitingForSignatures(t, 1, signaturesRequired))
+		return true, nil
+	}
+
 	if err = rosterSaver.SaveDraft(signedRoster, signature); err != nil {
 		return failSign(err)
 	}
@@ -217,7 +254,7 @@ func promptAndSignAndUploadRoster(t team.Team, adminFingerprint fp.Fingerprint)
 	ui.PrintCheckboxSuccess(checkboxUpload)
 	out.Print("\n")
 
-	return nil
+	return false, nil
 }
 
 func formatRosterPreview(roster string) string {
@@ -13,27 +13,13 @@ import (
 	"github.com/fluidkeys/fluidkeys/ui"
 )
 
-func teamEdit() exitCode {
-	allMemberships, err := user.Memberships()
-	if err != nil {
-		out.Print(ui.FormatFailure("Failed to list teams", nil, err))
+func teamEdit(teamSelector string) exitCode {
+	adminMembership := chooseAdminTeam(teamSelector)
+	if adminMembership == nil {
 		return 1
 	}
 
-	adminMemberships := filterByAdmin(allMemberships)
-
-	switch len(adminMemberships) {
-	case 0:
-		out.Print(ui.FormatFailure("You aren't an admin of any teams", nil, nil))
-		return 1
-
-	case 1:
-		return doEditTeam(adminMemberships[0].Team, adminMemberships[0].Me)
-
-	default:
-		out.Print(ui.FormatFailure("Choosing from multiple teams not implemented", nil, nil))
-		return 1
-	}
+	return doEditTeam(adminMembership.Team, adminMembership.Memberships[0].Me)
 }
 
 func doEditTeam(myTeam team.Team, me team.Person) exitCode {
@@ -80,16 +66,26 @@ func doEditTeam(myTeam team.Team, me team.Person) exitCode {
 		return 1
 	}
 
-	updatedTeam.Version = myTeam.Version + 1
+	updatedTeam.ChainFrom(myTeam)
 
 	if err := team.ValidateUpdate(&myTeam, updatedTeam, &me); err != nil {
 		out.Print(ui.FormatFailure("Problem with new team roster", nil, err))
 		return 1
 	}
 
-	if err := promptAndSignAndUploadRoster(*updatedTeam, me.Fingerprint); err != nil {
+	if changes := team.Diff(myTeam, *updatedTeam); len(changes) > 0 {
+		out.Print(formatRosterChanges(updatedTeam.Name, changes))
+	} else {
+		out.Print(ui.FormatInfo("No changes to the people in "+updatedTeam.Name, nil))
+	}
+
+	signaturesRequired := signaturesRequiredForUpdate(myTeam, *updatedTeam)
+	if _, err := promptAndSignAndUploadRoster(*updatedTeam, me.Fingerprint,
+		signaturesRequired, &interactiveYesNoPrompter{}); err != nil {
+
 		if err != errUserDeclinedToSign {
-			out.Print(ui.FormatFailure("Failed to sign and upload roster", nil, err))
+			out.Print(ui.FormatFailure("Failed to sign and upload roster",
+				formatRosterNotUploaded(err, "run "+colour.Cmd("fk team edit")+" again."), err))
 		}
 		return 1
 	}
@@ -97,6 +93,32 @@ func doEditTeam(myTeam team.Team, me team.Person) exitCode {
 	return 0
 }
 
+// formatRosterChanges lists the changes to the people in the team, highlighting new keys.
+func formatRosterChanges(teamName string, changes []team.Change) string {
+	lines := []string{}
+	newKeys := false
+
+	for _, change := range changes {
+		if change.IsSecurityRelevant() {
+			newKeys = true
+			lines = append(lines, colour.Warning("! "+change.String()))
+		} else {
+			lines = append(lines, "• "+change.String())
+		}
+	}
+
+	if !newKeys {
+		return ui.FormatInfo("Changes to "+teamName, lines)
+	}
+
+	lines = append(lines,
+		"",
+		"Changes marked ! give someone a new key. Whoever has that key can read secrets",
+		"sent to them, so check with them that the key really is theirs.",
+	)
+	return ui.FormatWarning("Changes to "+teamName+", including new keys", lines, nil)
+}
+
 func writeRosterToTempfile(roster string) (tmpFilename string, err error) {
 	tmpfile, err := ioutil.TempFile("", "roster.toml_")
 	if err != nil {
@@ -26,24 +26,42 @@ import (
 	"github.com/fluidkeys/fluidkeys/colour"
 	fp "github.com/fluidkeys/fluidkeys/fingerprint"
 	"github.com/fluidkeys/fluidkeys/humanize"
+	"github.com/fluidkeys/fluidkeys/keycache"
+	"github.com/fluidkeys/fluidkeys/keylookup"
 	"github.com/fluidkeys/fluidkeys/out"
 	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/status"
 	"github.com/fluidkeys/fluidkeys/team"
 	"github.com/fluidkeys/fluidkeys/ui"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+	"github.com/fluidkeys/fluidkeys/wkd"
 )
 
-func teamFetch(unattended bool) exitCode {
+// teamFetch fetches updates to all my teams, or only the team matching teamSelector (a team
+// name or UUID) if it's given.
+func teamFetch(unattended bool, teamSelector string) exitCode {
 	sawError := false
 
-	if err := processRequestsToJoinTeam(unattended); err != nil {
-		// don't output anything: the function does that itself
-		sawError = true
-	}
+	var memberships []userpackage.TeamMembership
 
-	memberships, err := user.Memberships()
-	if err != nil {
-		out.Print(ui.FormatFailure("Failed to list teams", nil, err))
-		return 1
+	if teamSelector == "" {
+		if err := processRequestsToJoinTeam(unattended); err != nil {
+			// don't output anything: the function does that itself
+			sawError = true
+		}
+
+		allMemberships, err := user.Memberships()
+		if err != nil {
+			out.Print(ui.FormatFailure("Failed to list teams", nil, err))
+			return 1
+		}
+		memberships = allMemberships
+	} else {
+		groupedMembership := chooseMemberTeam(teamSelector)
+		if groupedMembership == nil {
+			return 1
+		}
+		memberships = groupedMembership.Memberships
 	}
 
 	for i := range memberships {
@@ -54,7 +72,7 @@ func teamFetch(unattended bool) exitCode {
 			sawError = true
 
 			if unattended {
-				api.Log(apiclient.Event{
+				logEvent(apiclient.Event{
 					Name:        eventErrorUpdatingTeamUnattended,
 					Fingerprint: &me.Fingerprint,
 					TeamUUID:    &t.UUID,
@@ -62,7 +80,7 @@ func teamFetch(unattended bool) exitCode {
 				})
 			}
 		} else if unattended {
-			api.Log(apiclient.Event{
+			logEvent(apiclient.Event{
 				Name:        eventSuccessUpdatingTeamUnattended,
 				Fingerprint: &me.Fingerprint,
 				TeamUUID:    &t.UUID,
@@ -70,6 +88,16 @@ func teamFetch(unattended bool) exitCode {
 		}
 	}
 
+	if err := syncGpgConfGroups(); err != nil {
+		out.Print(ui.FormatWarning("Failed to update team groups in gpg.conf", nil, err))
+		sawError = true
+	}
+
+	if err := syncTeamKeyTrust(); err != nil {
+		out.Print(ui.FormatWarning("Failed to update trust for team keys in GnuPG", nil, err))
+		sawError = true
+	}
+
 	if sawError {
 		out.Print("\n")
 		printFailed("Encountered errors while syncing.\n")
@@ -78,6 +106,25 @@ func teamFetch(unattended bool) exitCode {
 	return 0
 }
 
+// teamAcceptLatestRoster accepts the latest roster of the team matching teamSelector after I
+// confirm it, then fetches the team's keys as usual.
+func teamAcceptLatestRoster(teamSelector string) exitCode {
+	groupedMembership := chooseMemberTeam(teamSelector)
+	if groupedMembership == nil {
+		return 1
+	}
+	membership := groupedMembership.Memberships[0]
+
+	printHeader(membership.Team.Name)
+	if err := acceptLatestRoster(
+		membership.Team, membership.Me, &interactiveYesNoPrompter{}); err != nil {
+
+		out.Print(ui.FormatFailure("Failed to accept latest roster", nil, err))
+		return 1
+	}
+	return teamFetch(false, teamSelector)
+}
+
 func doUpdateTeam(myTeam *team.Team, me *team.Person, unattended bool) (err error) {
 	printHeader(myTeam.Name)
 
@@ -147,11 +194,74 @@ func fetchAndUpdateRoster(t team.Team, me team.Person, unattended bool) (
 		return nil, fmt.Errorf("error getting team admin public keys: %v", err)
 	}
 
-	if err := team.VerifyRoster(roster, signature, adminKeys); err != nil {
+	updatedTeam, err = team.Load(roster, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	skippedVersions := false
+	switch err := team.ValidateChain(&t, updatedTeam); err {
+	case nil:
+
+	case team.ErrRosterVersionsSkipped:
+		// the versions in between aren't available (the key directory only has the latest),
+		// so only accept it if it's signed by the admins of the roster we accepted, as if it
+		// were the next version
+		skippedVersions = true
+
+	default:
+		return nil, fmt.Errorf("rejected updated roster: %v", err)
+	}
+
+	signaturesRequired := signaturesRequiredForUpdate(t, *updatedTeam)
+	err = t.VerifyUpdateSignatures(roster, signature, adminKeys, signaturesRequired)
+
+	if notEnough, ok := err.(*team.ErrNotEnoughSignatures); ok {
+		// the new roster is waiting for other admins to co-sign it
+		if signature, err = coSignPendingRoster(
+			t, me, roster, signature, adminKeys, notEnough, unattended); err != nil {
+
+			return nil, err
+		}
+
+		if err := t.VerifyUpdateSignatures(
+			roster, signature, adminKeys, signaturesRequired); err != nil {
+
+			log.Printf("not accepting roster v%d yet: %v", updatedTeam.Version, err)
+			db.RecordLast("fetch", t, time.Now())
+			return &t, nil
+		}
+
+		if updatedTeam, err = team.Load(roster, signature); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		if skippedVersions {
+			return nil, errSkippedVersionsNotVerified(t, *updatedTeam, err)
+		}
 		return nil, fmt.Errorf("couldn't validate signature on updated roster: %v", err)
 	}
+
+	if err := t.VerifyRoleChangeSignatures(*updatedTeam, roster, signature, adminKeys); err != nil {
+		if skippedVersions {
+			return nil, errSkippedVersionsNotVerified(t, *updatedTeam, err)
+		}
+		return nil, fmt.Errorf("rejected updated roster: %v", err)
+	}
 	log.Printf("new roster verified OK")
 
+	if skippedVersions {
+		out.Print(ui.FormatWarning(
+			fmt.Sprintf("%s roster skipped from v%d to v%d", t.Name, t.Version,
+				updatedTeam.Version),
+			[]string{
+				"The versions in between weren't fetched, so they couldn't be checked.",
+				fmt.Sprintf("v%d is signed by the admins of v%d, so it's been accepted.",
+					updatedTeam.Version, t.Version),
+				"Check the changes below with a team admin.",
+			}, nil))
+	}
+
 	teamSubdir, err := team.Directory(t, fluidkeysDirectory)
 	if err != nil {
 		return nil, err
@@ -162,13 +272,80 @@ func fetchAndUpdateRoster(t team.Team, me team.Person, unattended bool) (
 		return nil, err
 	}
 
+	if changes := team.Diff(t, *updatedTeam); len(changes) > 0 {
+		out.Print(formatRosterChanges(updatedTeam.Name, changes))
+	}
+
 	db.RecordLast("fetch", t, time.Now())
+	return updatedTeam, nil
+}
 
-	updatedTeam, err = team.Load(roster, signature)
+// errSkippedVersionsNotVerified explains that a roster that skipped versions couldn't be
+// verified against the admins of the roster we accepted, and how to accept it anyway.
+func errSkippedVersionsNotVerified(t team.Team, updatedTeam team.Team, err error) error {
+	return fmt.Errorf("rejected updated roster: v%d skips versions since v%d and isn't signed "+
+		"by enough of its admins (%v). After checking the roster with a team admin, accept it "+
+		"by running: fk team fetch --accept-latest-roster --team=%s",
+		updatedTeam.Version, t.Version, err, t.UUID)
+}
+
+// acceptLatestRoster fetches the team's latest roster and, if I confirm, accepts it without
+// checking that it follows on from the roster I accepted last, as if I'd just joined the team.
+// It's for recovering when versions were skipped and the fetched roster isn't signed by the
+// admins of the roster I have, e.g. because the admins changed in the meantime.
+func acceptLatestRoster(t team.Team, me team.Person, prompter promptYesNoInterface) error {
+	roster, signature, err := api.GetTeamRoster(t.UUID, me.Fingerprint)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("error downloading team roster: %v", err)
 	}
-	return updatedTeam, nil
+
+	latestTeam, err := team.Load(roster, signature)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case latestTeam.UUID != t.UUID:
+		return fmt.Errorf("roster is for team %s, not %s", latestTeam.UUID, t.UUID)
+
+	case latestTeam.Version <= t.Version:
+		return fmt.Errorf("latest roster v%d isn't newer than v%d accepted previously",
+			latestTeam.Version, t.Version)
+
+	case !latestTeam.Contains(me.Fingerprint):
+		return fmt.Errorf("you're not in roster v%d", latestTeam.Version)
+	}
+
+	if err := fetchAdminKeysVerifyRoster(*latestTeam, roster, signature); err != nil {
+		return fmt.Errorf("failed to verify roster v%d: %v", latestTeam.Version, err)
+	}
+
+	if changes := team.Diff(t, *latestTeam); len(changes) > 0 {
+		out.Print(formatRosterChanges(latestTeam.Name, changes))
+	}
+
+	out.Print(ui.FormatWarning(
+		fmt.Sprintf("Roster v%d can't be checked against v%d", latestTeam.Version, t.Version),
+		[]string{
+			"It's signed by its own admins, but not by enough of the admins you trust.",
+			"Only accept it if a team admin has confirmed these changes with you.",
+		}, nil))
+
+	if !prompter.promptYesNo(fmt.Sprintf("Accept roster v%d?", latestTeam.Version), "n", nil) {
+		return fmt.Errorf("roster v%d not accepted", latestTeam.Version)
+	}
+
+	teamSubdir, err := team.Directory(t, fluidkeysDirectory)
+	if err != nil {
+		return err
+	}
+
+	saver := team.RosterSaver{Directory: teamSubdir}
+	if err := saver.Save(roster, signature); err != nil {
+		return err
+	}
+	db.RecordLast("fetch", t, time.Now())
+	return nil
 }
 
 // fetchAndCertifyTeamKeys fetches each key listed in the team and locally signs them in GnuPG
@@ -181,25 +358,22 @@ func fetchAndCertifyTeamKeys(
 
 	out.Print("Fetching and signing keys for other members of " + t.Name + ":\n\n")
 
-	for _, person := range t.People {
+	problems := []teammateKeyProblem{}
+
+	for _, person := range peopleByKey(t.People) {
 		if person.Fingerprint == me.Fingerprint {
 			continue
 		}
 
-		if !alwaysDownload {
-			if stale, err := db.IsOlderThan("fetch", person.Fingerprint,
-				time.Duration(24)*time.Hour, time.Now()); err != nil {
-				return err
-			} else if !stale {
-				ui.PrintCheckboxSkipped(person.Email + " skipped: fetched recently")
-				continue
-			}
+		if !alwaysDownload && fetchedRecently(person.Fingerprint, time.Now()) {
+			ui.PrintCheckboxSkipped(person.Email + " skipped: fetched recently")
+			continue
 		}
 
 		var theirKey *pgpkey.PgpKey
 
 		err = ui.RunWithCheckboxes(person.Email+": fetch key", func() error {
-			theirKey, err = api.GetPublicKeyByFingerprint(person.Fingerprint)
+			theirKey, err = keyCache.Refresh(api, person.Fingerprint, time.Now())
 
 			if err != nil && err == apiclient.ErrPublicKeyNotFound {
 				log.Print(err)
@@ -214,7 +388,16 @@ func fetchAndCertifyTeamKeys(
 			continue
 		}
 
+		problem := checkTeammateKey(person, theirKey, time.Now())
+		if problem != nil {
+			problems = append(problems, *problem)
+		}
+
 		err = ui.RunWithCheckboxes(person.Email+": sign key", func() error {
+			if problem != nil && status.IsUnusable(problem.warnings) {
+				log.Printf("not certifying unusable key %s", person.Fingerprint.Hex())
+				return ui.SkipThisAction
+			}
 
 			if !alreadyCertified(person.Email, person.Fingerprint, me.Fingerprint) {
 				unlockedKey, err := getUnlockedKey(me.Fingerprint, unattended)
@@ -247,18 +430,30 @@ func fetchAndCertifyTeamKeys(
 				return fmt.Errorf("failed to ASCII armor key")
 			}
 
+			alreadyInGnuPG, err := isKeyInGnuPG(theirKey.Fingerprint())
+			if err != nil {
+				log.Printf("failed to check whether %s is in GnuPG: %v",
+					theirKey.Fingerprint(), err)
+				alreadyInGnuPG = true // don't risk deleting it when leaving the team
+			}
+
 			err = gpg.ImportArmoredKey(armoredKey)
 			if err != nil {
 				log.Print(err)
 				return fmt.Errorf("Failed to import key into gpg")
 			}
-			db.RecordLast("fetch", theirKey.Fingerprint(), time.Now())
+			if !alreadyInGnuPG {
+				// record that Fluidkeys added the key, so only then it's deleted on leaving
+				db.RecordLast("import", theirKey.Fingerprint(), time.Now())
+			}
 
 			return nil
 		})
 		// keep trying subsequent keys even if we hit an error.
 	}
 	out.Print("\n")
+
+	reportTeammateKeyProblems(t, me, problems, unattended)
 	return err
 }
 
@@ -390,6 +585,13 @@ func processRequestsToJoinTeam(unattended bool) (returnError error) {
 			continue
 		}
 	}
+
+	if !unattended {
+		// requests from people I've invited are pre-authorized, so offer to add them now
+		if err := processInvitedRequestsToJoinTeams(); err != nil {
+			returnError = err
+		}
+	}
 	return returnError
 }
 
@@ -403,6 +605,33 @@ func printRequestHasntBeenApproved(request team.RequestToJoinTeam) {
 	)
 }
 
+// lastFetchedKey returns when the key was last fetched into the key cache, or the zero time if
+// it's never been fetched.
+func lastFetchedKey(fingerprint fp.Fingerprint) time.Time {
+	fetchedAt, err := keyCache.FetchedAt(fingerprint)
+	if err != nil && err != keycache.ErrNotCached {
+		log.Printf("failed to get last fetch time for %s: %v", fingerprint, err)
+	}
+	return fetchedAt
+}
+
+// fetchedRecently returns true if the key was fetched into the key cache in the last 24 hours
+// and is in GnuPG, so there's no need to fetch it again yet.
+func fetchedRecently(fingerprint fp.Fingerprint, now time.Time) bool {
+	fetchedAt := lastFetchedKey(fingerprint)
+	if fetchedAt.IsZero() || now.Sub(fetchedAt) > time.Duration(24)*time.Hour {
+		return false
+	}
+
+	// fetching may have succeeded but importing it failed, so check it's made it into GnuPG
+	inGnuPG, err := isKeyInGnuPG(fingerprint)
+	if err != nil {
+		log.Printf("failed to check whether %s is in GnuPG: %v", fingerprint, err)
+		return false
+	}
+	return inGnuPG
+}
+
 // unlockedKeyCache is used to store unlocked keys: don't unlock them more than once
 // TODO: there's a good case for a new package or file with all these (similar) kind of helpers,
 // they shouldn't all be living in these command files.
@@ -436,33 +665,93 @@ func getUnlockedKey(fingerprint fp.Fingerprint, unattended bool) (*pgpkey.PgpKey
 	return unlockedKey, nil
 }
 
+// peopleByKey returns a copy of each person for each of their keys, with that key as their
+// Fingerprint.
+func peopleByKey(people []team.Person) (byKey []team.Person) {
+	for _, person := range people {
+		for _, fingerprint := range person.Fingerprints() {
+			byKey = append(byKey, person.WithFingerprint(fingerprint))
+		}
+	}
+	return byKey
+}
+
 func fetchAdminPublicKeys(t team.Team) (adminKeys []*pgpkey.PgpKey, err error) {
 	for _, p := range t.Admins() {
-		key, err := discoverPublicKey(p.Fingerprint)
+		key, err := discoverPublicKey(p.Fingerprint, p.Email)
 		if err != nil {
 			return nil, err
 		}
 		adminKeys = append(adminKeys, key)
+
+		for _, fingerprint := range p.AdditionalFingerprints {
+			// an admin can sign with any of their keys, but only their main key is required
+			if key, err := discoverPublicKey(fingerprint, p.Email); err != nil {
+				log.Printf("failed to find additional key %s for %s: %v", fingerprint, p.Email, err)
+			} else {
+				adminKeys = append(adminKeys, key)
+			}
+		}
 	}
 	return adminKeys, nil
 }
 
-func discoverPublicKey(fingerprint fp.Fingerprint) (key *pgpkey.PgpKey, err error) {
+// discoverPublicKey looks for the key with the given fingerprint in GnuPG, then the API (via the
+// key cache), then the key cache alone in case we're offline, then any keyservers configured for
+// discovery, then by the owner's email address using WKD.
+func discoverPublicKey(fingerprint fp.Fingerprint, email string) (key *pgpkey.PgpKey, err error) {
 	if key, err := loadPgpKey(fingerprint); err != nil { // no error
 		log.Printf("failed to find key %s in GnuPG: %v", fingerprint, err)
 	} else {
 		return key, nil
 	}
 
-	if key, err = api.GetPublicKeyByFingerprint(fingerprint); err != nil {
+	if key, err = keyCache.Refresh(api, fingerprint, time.Now()); err != nil {
 		log.Printf("failed to find key %s in API: %v", fingerprint, err)
 	} else {
 		return key, nil
 	}
 
+	if key, err = keyCache.Get(fingerprint); err != nil {
+		log.Printf("failed to find key %s in key cache: %v", fingerprint, err)
+	} else {
+		return key, nil
+	}
+
+	for _, server := range discoveryKeyservers() {
+		if key, err = server.FindByFingerprint(fingerprint); err != nil {
+			log.Printf("failed to find key %s on %s: %v", fingerprint, server.Name(), err)
+		} else {
+			return key, nil
+		}
+	}
+
+	if key, err = keylookup.FindByFingerprintAndEmail(fingerprint, email, wkd.New()); err != nil {
+
+		log.Printf("failed to find key %s for %s: %v", fingerprint, email, err)
+	} else {
+		return key, nil
+	}
+
 	return nil, fmt.Errorf("failed multiple attempts to find get public key for %s", fingerprint)
 }
 
+// emailKeySources returns the places to look for someone's public key by their email address,
+// in order of preference.
+func emailKeySources() []keylookup.EmailLookup {
+	return append([]keylookup.EmailLookup{keylookup.FluidkeysDirectory{Client: api}},
+		otherKeySources()...)
+}
+
+// otherKeySources returns the sources of public keys other than the Fluidkeys directory.
+func otherKeySources() []keylookup.EmailLookup {
+	sources := []keylookup.EmailLookup{wkd.New()}
+	for _, server := range discoveryKeyservers() {
+		sources = append(sources, server)
+	}
+	return sources
+}
+
 // fetchAdminKeysVerifyRoster fetches the public keys of the admins in the team and verifies the roster
 // against them.
 func fetchAdminKeysVerifyRoster(t team.Team, roster string, signature string) error {
@@ -0,0 +1,294 @@
+package fk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/gofrs/uuid"
+)
+
+func TestFetchAndUpdateRosterQuorum(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	owner := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	ownerLaptop := newTestProfile(t, exampledata.ExamplePrivateKey3, "test3")
+	coAdmin := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+
+	current := uploadRoster(t, team.Team{
+		UUID:               uuid.Must(uuid.NewV4()),
+		Name:               "Kiffix",
+		RequiredSignatures: 2,
+		People: []team.Person{
+			{
+				Email:                  owner.email,
+				Fingerprint:            owner.fingerprint(),
+				AdditionalFingerprints: []fpr.Fingerprint{ownerLaptop.fingerprint()},
+				IsAdmin:                true,
+				Role:                   team.RoleOwner,
+			},
+			{Email: coAdmin.email, Fingerprint: coAdmin.fingerprint(), IsAdmin: true},
+		},
+	}, owner.key, coAdmin.key)
+
+	coAdmin.use(t)
+	saveRoster(t, *current)
+
+	updated := *current
+	updated.ChainFrom(*current)
+	updated.Name = "Kiffix Ltd"
+
+	t.Run("one admin signing with two of their keys isn't enough", func(t *testing.T) {
+		uploadRoster(t, updated, owner.key, ownerLaptop.key)
+
+		coAdmin.use(t)
+		myTeam, me := coAdmin.membership(t)
+		fetchedTeam, err := fetchAndUpdateRoster(myTeam, me, true)
+		assert.NoError(t, err)
+		assert.Equal(t, "Kiffix", fetchedTeam.Name)
+
+		savedTeam, _ := coAdmin.membership(t)
+		assert.Equal(t, current.Version, savedTeam.Version)
+	})
+
+	t.Run("two admins' signatures are enough", func(t *testing.T) {
+		uploadRoster(t, updated, owner.key, coAdmin.key)
+
+		coAdmin.use(t)
+		myTeam, me := coAdmin.membership(t)
+		assert.NoError(t, db.DeleteLast("fetch", myTeam)) // unattended fetches once a day
+		fetchedTeam, err := fetchAndUpdateRoster(myTeam, me, true)
+		assert.NoError(t, err)
+		assert.Equal(t, "Kiffix Ltd", fetchedTeam.Name)
+
+		savedTeam, _ := coAdmin.membership(t)
+		assert.Equal(t, updated.Version, savedTeam.Version)
+	})
+}
+
+func TestFetchAndUpdateRosterSkippedVersions(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	newAdmin := newTestProfile(t, exampledata.ExamplePrivateKey3, "test3")
+	member := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+
+	v1 := uploadRoster(t, team.Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []team.Person{
+			{Email: admin.email, Fingerprint: admin.fingerprint(), IsAdmin: true},
+			{Email: member.email, Fingerprint: member.fingerprint()},
+		},
+	}, admin.key)
+
+	member.use(t)
+	saveRoster(t, *v1)
+
+	t.Run("accepts a roster signed by the admins of the one accepted", func(t *testing.T) {
+		v2 := *v1
+		v2.ChainFrom(*v1)
+		v2.People = append(v1.People, team.Person{
+			Email:       "carol@example.com",
+			Fingerprint: fpr.MustParse("B79F0840DEF12EBBA72FF72D7327A44C2157A758"),
+		})
+		signedV2 := uploadRoster(t, v2, admin.key)
+
+		v3 := *signedV2
+		v3.ChainFrom(*signedV2)
+		v3.People = append(signedV2.People, team.Person{
+			Email:       "dave@example.com",
+			Fingerprint: fpr.MustParse("E63AF0E74EB5DE3FB72DC981C991709318ECBDE7"),
+		})
+		uploadRoster(t, v3, admin.key)
+
+		member.use(t)
+		myTeam, me := member.membership(t)
+		fetchedTeam, err := fetchAndUpdateRoster(myTeam, me, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(fetchedTeam.People))
+
+		savedTeam, _ := member.membership(t)
+		assert.Equal(t, v3.Version, savedTeam.Version)
+	})
+
+	member.use(t)
+	accepted, _ := member.membership(t)
+
+	v4 := accepted
+	v4.ChainFrom(accepted)
+	v4.People = append(accepted.People, team.Person{
+		Email: newAdmin.email, Fingerprint: newAdmin.fingerprint(), IsAdmin: true,
+	})
+	signedV4 := uploadRoster(t, v4, admin.key)
+
+	v5 := *signedV4
+	v5.ChainFrom(*signedV4)
+	v5.People = signedV4.People[1:] // without the admin who signed v4
+	uploadRoster(t, v5, newAdmin.key)
+
+	t.Run("rejects a roster signed by new admins", func(t *testing.T) {
+		member.use(t)
+		myTeam, me := member.membership(t)
+		_, err := fetchAndUpdateRoster(myTeam, me, false)
+		assert.GotError(t, err)
+
+		savedTeam, _ := member.membership(t)
+		assert.Equal(t, accepted.Version, savedTeam.Version)
+	})
+
+	t.Run("doesn't accept the latest roster without confirmation", func(t *testing.T) {
+		member.use(t)
+		myTeam, me := member.membership(t)
+		assert.GotError(t, acceptLatestRoster(myTeam, me, &alwaysNoPrompter{}))
+
+		savedTeam, _ := member.membership(t)
+		assert.Equal(t, accepted.Version, savedTeam.Version)
+	})
+
+	t.Run("accepts the latest roster once confirmed", func(t *testing.T) {
+		member.use(t)
+		myTeam, me := member.membership(t)
+		assert.NoError(t, acceptLatestRoster(myTeam, me, &alwaysYesPrompter{}))
+
+		savedTeam, _ := member.membership(t)
+		assert.Equal(t, v5.Version, savedTeam.Version)
+		assert.Equal(t, true, savedTeam.IsAdmin(newAdmin.fingerprint()))
+
+		myTeam, me = member.membership(t)
+		_, err := fetchAndUpdateRoster(myTeam, me, false)
+		assert.NoError(t, err)
+	})
+}
+
+func TestFetchAndUpdateRosterRequiresOwnerForRoleChanges(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	owner := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	member := newTestProfile(t, exampledata.ExamplePrivateKey3, "test3")
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+
+	v1 := uploadRoster(t, team.Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []team.Person{
+			{
+				Email:       owner.email,
+				Fingerprint: owner.fingerprint(),
+				IsAdmin:     true,
+				Role:        team.RoleOwner,
+			},
+			{Email: admin.email, Fingerprint: admin.fingerprint(), IsAdmin: true},
+			{Email: member.email, Fingerprint: member.fingerprint()},
+		},
+	}, owner.key)
+
+	member.use(t)
+	saveRoster(t, *v1)
+
+	v2 := *v1
+	v2.ChainFrom(*v1)
+	v2.People = []team.Person{v1.People[0], v1.People[1], v1.People[2]}
+	v2.People[2].IsAdmin = true
+	v2.People[2].Role = team.RoleAdmin
+
+	t.Run("a role change signed only by an admin is rejected", func(t *testing.T) {
+		uploadRoster(t, v2, admin.key)
+
+		member.use(t)
+		myTeam, me := member.membership(t)
+		_, err := fetchAndUpdateRoster(myTeam, me, false)
+		assert.GotError(t, err)
+
+		savedTeam, me := member.membership(t)
+		assert.Equal(t, v1.Version, savedTeam.Version)
+		assert.Equal(t, false, me.IsAdmin)
+	})
+
+	t.Run("a role change signed by an owner is accepted", func(t *testing.T) {
+		uploadRoster(t, v2, admin.key, owner.key)
+
+		member.use(t)
+		myTeam, me := member.membership(t)
+		_, err := fetchAndUpdateRoster(myTeam, me, false)
+		assert.NoError(t, err)
+
+		savedTeam, me := member.membership(t)
+		assert.Equal(t, v2.Version, savedTeam.Version)
+		assert.Equal(t, true, me.IsAdmin)
+	})
+}
+
+// uploadRoster signs the team's roster with each of the keys and uploads it to the fake server,
+// as the first key. It returns the team loaded from the signed roster.
+func uploadRoster(t *testing.T, newTeam team.Team, signingKeys ...*pgpkey.PgpKey) *team.Team {
+	t.Helper()
+
+	roster, err := newTeam.PreviewRoster()
+	assert.NoError(t, err)
+
+	signature := ""
+	for _, key := range signingKeys {
+		signature, err = team.AddRosterSignature(roster, signature, key)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, api.UpsertTeam(roster, signature, signingKeys[0].Fingerprint()))
+
+	signedTeam, err := team.Load(roster, signature)
+	assert.NoError(t, err)
+	return signedTeam
+}
+
+// saveRoster saves the team's roster as if it had been accepted by the current profile.
+func saveRoster(t *testing.T, signedTeam team.Team) {
+	t.Helper()
+
+	teamSubdir, err := team.Directory(signedTeam, fluidkeysDirectory)
+	assert.NoError(t, err)
+
+	roster, signature := signedTeam.Roster()
+	saver := team.RosterSaver{Directory: teamSubdir}
+	assert.NoError(t, saver.Save(roster, signature))
+}
+
+func TestFetchedRecently(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+	now := time.Now()
+
+	inGnuPG, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey4)
+	assert.NoError(t, err)
+	notInGnuPG, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey2)
+	assert.NoError(t, err)
+
+	t.Run("a key that's never been fetched", func(t *testing.T) {
+		assert.Equal(t, false, fetchedRecently(inGnuPG.Fingerprint(), now))
+	})
+
+	t.Run("a key fetched in the last day", func(t *testing.T) {
+		_, err := keyCache.Store(inGnuPG, apiclient.CacheValidators{}, now.Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, true, fetchedRecently(inGnuPG.Fingerprint(), now))
+	})
+
+	t.Run("a key fetched more than a day ago", func(t *testing.T) {
+		assert.Equal(t, false, fetchedRecently(inGnuPG.Fingerprint(), now.Add(24*time.Hour)))
+	})
+
+	t.Run("a key fetched recently that didn't make it into GnuPG", func(t *testing.T) {
+		_, err := keyCache.Store(notInGnuPG, apiclient.CacheValidators{}, now)
+		assert.NoError(t, err)
+		assert.Equal(t, false, fetchedRecently(notInGnuPG.Fingerprint(), now))
+	})
+}
@@ -0,0 +1,298 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/fluidkeys/fluidkeys/colour"
+	"github.com/fluidkeys/fluidkeys/database"
+	"github.com/fluidkeys/fluidkeys/humanize"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/ui"
+)
+
+// inviteValidFor is how long an invite to join a team can be used for.
+const inviteValidFor = time.Duration(7*24) * time.Hour
+
+func teamInvite(email string, teamSelector string) exitCode {
+	adminMembership := chooseAdminTeam(teamSelector)
+	if adminMembership == nil {
+		return 1
+	}
+	t := adminMembership.Team
+	me := adminMembership.Memberships[0].Me
+
+	printHeader("Invite " + email + " to join " + t.Name)
+
+	if !strings.Contains(email, "@") {
+		out.Print(ui.FormatFailure("Invalid email address: "+email, nil, nil))
+		return 1
+	}
+
+	for _, person := range t.People {
+		if strings.EqualFold(person.Email, email) {
+			out.Print(ui.FormatWarning(email+" is already in the team", nil, nil))
+			return 1
+		}
+	}
+
+	if err := t.CheckJoinRules(email); err != nil {
+		out.Print(ui.FormatFailure("Can't invite "+email, []string{
+			"The roster says " + err.Error() + ".",
+		}, nil))
+		return 1
+	}
+
+	privateKey, err := getUnlockedKey(me.Fingerprint, false)
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to unlock private key to sign invite", nil, err))
+		return 1
+	}
+
+	expiresAt := time.Now().Add(inviteValidFor)
+	token, err := team.MakeInviteToken(t, email, privateKey, expiresAt)
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to make invite", nil, err))
+		return 1
+	}
+
+	// the API doesn't pass the token on with their request to join, so remember the invite to
+	// recognise their request when it arrives
+	if err := db.RecordIssuedInvite(database.IssuedInviteMessage{
+		TeamUUID:  t.UUID,
+		Email:     email,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, time.Now()); err != nil {
+		out.Print(ui.FormatFailure("Failed to record invite", nil, err))
+		return 1
+	}
+
+	if api.VerifiesEmails() {
+		out.Print(ui.FormatInfo("Send this invitation to "+email, []string{
+			"It expires in 7 days, and can only be used once.",
+			"",
+			"When they apply, you can add them with " + colour.Cmd("fk team fetch") + " or " +
+				colour.Cmd("fk team authorize") + ",",
+			"without checking their verification details.",
+		}))
+	} else {
+		out.Print(ui.FormatInfo("Send this invitation to "+email, []string{
+			"It expires in 7 days.",
+			"",
+			"Your key directory doesn't verify email addresses, so when they apply you'll",
+			"still need to check their verification details with " +
+				colour.Cmd("fk team authorize") + ".",
+		}))
+	}
+
+	out.Print(formatFileDivider("Invitation to join "+t.Name, 80) + "\n")
+	invitation := "I've invited you to join " + t.Name + " on Fluidkeys.\n\n" +
+		"1. download Fluidkeys from https://download.fluidkeys.com\n\n" +
+		"2. apply to join the team by running:\n\n" +
+		"> fk team apply --token=" + token + "\n"
+	out.Print("\n" + invitation + "\n")
+	out.Print(formatFileDivider("", 80) + "\n\n")
+
+	prompter := interactiveYesNoPrompter{}
+	if prompter.promptYesNo("Copy this invitation to your clipboard now?", "y", nil) == true {
+		if err := clipboard.WriteAll(invitation); err != nil {
+			out.Print(ui.FormatFailure("Failed to copy invitation to clipboard", nil, err))
+			return 1
+		}
+	}
+	return 0
+}
+
+// verifyInvite checks whether I invited the person making the request, returning me (as the
+// admin who made the invite) if so. It returns nil and no error if there's no invite for their
+// email, or if the key directory doesn't verify emails: then anyone could apply with the email
+// of someone who's been invited, so their verification details still need checking.
+func verifyInvite(t team.Team, request team.RequestToJoinTeam) (invitedBy *team.Person, err error) {
+	if !api.VerifiesEmails() {
+		return nil, nil
+	}
+
+	issued, err := db.GetIssuedInvite(t.UUID, request.Email)
+	if err != nil {
+		return nil, err
+	} else if issued == nil {
+		return nil, nil
+	}
+
+	invite, err := team.ParseInviteToken(issued.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := t.GetPersonForFingerprint(invite.InvitedBy)
+	if err != nil {
+		return nil, fmt.Errorf("invite is from %s who isn't in the team", invite.InvitedBy)
+	}
+
+	adminKey, err := discoverPublicKey(invite.InvitedBy, admin.Email)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get key %s to check invite: %v", invite.InvitedBy, err)
+	}
+
+	if err := invite.Verify(t, adminKey, request.Email, time.Now()); err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// processInvitedRequestsToJoinTeams offers to add people who've applied to join a team I'm an
+// admin of using a valid invite. The invite means they're already authorized, so it only takes
+// one prompt.
+func processInvitedRequestsToJoinTeams() (returnError error) {
+	memberships, err := loadVerifiedMemberships()
+	if err != nil {
+		out.Print(ui.FormatWarning("Failed to load team memberships", nil, err))
+		return err
+	}
+
+	for _, grouped := range memberships {
+		adminMemberships := filterByAdmin(grouped.Memberships)
+		if len(adminMemberships) == 0 {
+			continue
+		}
+
+		if err := acceptInvitedRequests(
+			grouped.Team, adminMemberships[0].Me, &interactiveYesNoPrompter{}); err != nil {
+
+			out.Print(ui.FormatWarning(
+				"Failed to add invited people to "+grouped.Team.Name,
+				formatRosterNotUploaded(err, "the requests have been kept, so the next sync will "+
+					"add them again."), err))
+			returnError = err
+		}
+	}
+	return returnError
+}
+
+func acceptInvitedRequests(t team.Team, me team.Person, prompter promptYesNoInterface) error {
+	requests, err := api.ListRequestsToJoinTeam(t.UUID, me.Fingerprint)
+	if err != nil {
+		return err
+	}
+
+	pendingTeam, err := loadPendingRoster(t)
+	if err != nil {
+		log.Printf("failed to load pending roster for %s: %v", t.Name, err)
+	}
+
+	invited := []joinDecision{}
+	for _, request := range requests {
+		if pendingTeam != nil && pendingTeam.Contains(request.Fingerprint) {
+			continue // already approved, waiting for the roster to be co-signed
+		}
+		if t.CheckJoinRules(request.Email) != nil {
+			continue // leave it for `fk team authorize` to reject
+		}
+		if _, err := t.GetUpsertPersonWarnings(team.Person{
+			Email: request.Email, Fingerprint: request.Fingerprint}); err != nil {
+			continue // changes someone already in the team, so needs checking
+		}
+
+		invitedBy, err := verifyInvite(t, request)
+		if err != nil {
+			log.Printf("ignoring invite from %s: %v", request.Email, err)
+			continue
+		} else if invitedBy == nil {
+			continue
+		}
+
+		invited = append(invited, joinDecision{
+			request: request, approved: true, reason: "invited by " + invitedBy.Email,
+		})
+	}
+
+	if len(invited) == 0 {
+		return nil
+	}
+
+	out.Print(humanize.Pluralize(len(invited), "invited person has", "invited people have") +
+		" applied to join " + t.Name + ":\n\n")
+
+	for _, decision := range invited {
+		out.Print("» key:   " + colour.Info(decision.request.Fingerprint.String()) + "\n")
+		out.Print("  email: " + colour.Info(decision.request.Email) + "\n")
+		out.Print("  " + decision.reason + "\n\n")
+	}
+
+	if !prompter.promptYesNo("Add them to the team now?", "y", nil) {
+		out.Print("You can add them later with " + colour.Cmd("fk team authorize") + "\n\n")
+		return nil
+	}
+
+	previousTeam := t
+	t.ChainFrom(previousTeam)
+
+	for _, decision := range invited {
+		t.UpsertPerson(team.Person{
+			Email:       decision.request.Email,
+			Fingerprint: decision.request.Fingerprint,
+			IsAdmin:     false,
+		})
+	}
+
+	privateKey, err := getUnlockedKey(me.Fingerprint, false)
+	if err != nil {
+		return fmt.Errorf("failed to unlock private key to sign roster: %v", err)
+	}
+
+	pending, err := signAndUploadRoster(
+		t, privateKey, signaturesRequiredForUpdate(previousTeam, t))
+	if err != nil {
+		return err
+	}
+
+	if pending {
+		// keep the requests and invites until the roster has enough signatures to add them
+		for _, decision := range invited {
+			decision.pending = true
+			recordJoinDecision(t, decision, false, me.Fingerprint)
+		}
+		return nil
+	}
+
+	for _, decision := range invited {
+		if err := api.DeleteRequestToJoinTeam(t.UUID, decision.request.UUID); err != nil {
+			log.Printf("failed to delete request %s: %v", decision.request.UUID, err)
+		}
+		recordJoinDecision(t, decision, false, me.Fingerprint)
+		forgetInvite(t, decision.request.Email)
+		ui.PrintCheckboxSuccess("Added " + decision.request.Email)
+	}
+	out.Print("\n")
+	return nil
+}
+
+// forgetInvite deletes my record of inviting the email to the team, if there is one, so the
+// invite can only be used once.
+func forgetInvite(t team.Team, email string) {
+	if err := db.DeleteIssuedInvite(t.UUID, email); err != nil {
+		log.Printf("failed to forget invite for %s: %v", email, err)
+	}
+}
@@ -0,0 +1,155 @@
+package fk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/database"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/keydirectory"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/testhelpers"
+	"github.com/gofrs/uuid"
+)
+
+func TestVerifyInvite(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	admin.use(t)
+
+	kiffix := team.Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []team.Person{
+			{Email: admin.email, Fingerprint: admin.fingerprint(), IsAdmin: true},
+		},
+	}
+	pending, err := signAndUploadRoster(kiffix, admin.key, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, false, pending)
+
+	request := team.RequestToJoinTeam{
+		UUID:        uuid.Must(uuid.NewV4()),
+		TeamUUID:    kiffix.UUID,
+		Email:       "jane@kiffix.com",
+		Fingerprint: exampledata.ExampleFingerprint3,
+	}
+
+	t.Run("without an invite", func(t *testing.T) {
+		invitedBy, err := verifyInvite(kiffix, request)
+		assert.NoError(t, err)
+		assert.Equal(t, (*team.Person)(nil), invitedBy)
+	})
+
+	expiresAt := time.Now().Add(time.Hour)
+	token, err := team.MakeInviteToken(kiffix, request.Email, admin.key, expiresAt)
+	assert.NoError(t, err)
+	assert.NoError(t, db.RecordIssuedInvite(database.IssuedInviteMessage{
+		TeamUUID: kiffix.UUID, Email: request.Email, Token: token, ExpiresAt: expiresAt,
+	}, time.Now()))
+
+	t.Run("with an invite I made", func(t *testing.T) {
+		invitedBy, err := verifyInvite(kiffix, request)
+		assert.NoError(t, err)
+		assert.Equal(t, admin.email, invitedBy.Email)
+	})
+
+	t.Run("with a key directory that doesn't verify emails", func(t *testing.T) {
+		previousAPI := api
+		api = keydirectory.NewFilesystem(testhelpers.Maketemp(t))
+		defer func() { api = previousAPI }()
+
+		invitedBy, err := verifyInvite(kiffix, request)
+		assert.NoError(t, err)
+		assert.Equal(t, (*team.Person)(nil), invitedBy)
+	})
+
+	t.Run("after the invite has been used", func(t *testing.T) {
+		forgetInvite(kiffix, request.Email)
+
+		invitedBy, err := verifyInvite(kiffix, request)
+		assert.NoError(t, err)
+		assert.Equal(t, (*team.Person)(nil), invitedBy)
+	})
+}
+
+func TestAcceptInvitedRequestsWaitingForCoSignatures(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	member := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+
+	admin.use(t)
+	kiffix := team.Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []team.Person{
+			{Email: admin.email, Fingerprint: admin.fingerprint(), IsAdmin: true},
+			{
+				Email:       "bob@example.com",
+				Fingerprint: fpr.MustParse("B79F0840DEF12EBBA72FF72D7327A44C2157A758"),
+				IsAdmin:     true,
+			},
+		},
+		RequiredSignatures: 2,
+	}
+	pending, err := signAndUploadRoster(kiffix, admin.key, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, false, pending)
+
+	expiresAt := time.Now().Add(time.Hour)
+	token, err := team.MakeInviteToken(kiffix, member.email, admin.key, expiresAt)
+	assert.NoError(t, err)
+	assert.NoError(t, db.RecordIssuedInvite(database.IssuedInviteMessage{
+		TeamUUID: kiffix.UUID, Email: member.email, Token: token, ExpiresAt: expiresAt,
+	}, time.Now()))
+
+	member.use(t)
+	assert.NoError(t, sendRequestToJoinTeam(
+		kiffix.UUID, kiffix.Name, member.fingerprint(), member.email))
+
+	admin.use(t)
+	myTeam, me := admin.membership(t)
+	assert.NoError(t, acceptInvitedRequests(myTeam, me, &alwaysYesPrompter{}))
+
+	t.Run("keeps the request and the invite", func(t *testing.T) {
+		requests, err := api.ListRequestsToJoinTeam(kiffix.UUID, admin.fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(requests))
+
+		invite, err := db.GetIssuedInvite(kiffix.UUID, member.email)
+		assert.NoError(t, err)
+		assert.Equal(t, token, invite.Token)
+	})
+
+	t.Run("records the decision as pending", func(t *testing.T) {
+		decisions, err := db.GetJoinDecisions(kiffix.UUID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(decisions))
+		assert.Equal(t, true, decisions[0].Approved)
+		assert.Equal(t, true, decisions[0].Pending)
+	})
+
+	t.Run("doesn't add them to the accepted roster", func(t *testing.T) {
+		myTeam, _ := admin.membership(t)
+		assert.Equal(t, false, myTeam.Contains(member.fingerprint()))
+
+		pendingTeam, err := loadPendingRoster(myTeam)
+		assert.NoError(t, err)
+		assert.Equal(t, true, pendingTeam.Contains(member.fingerprint()))
+	})
+
+	t.Run("doesn't offer the request again while it's pending", func(t *testing.T) {
+		myTeam, me := admin.membership(t)
+		assert.NoError(t, acceptInvitedRequests(myTeam, me, &alwaysYesPrompter{}))
+
+		decisions, err := db.GetJoinDecisions(kiffix.UUID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(decisions))
+	})
+}
@@ -0,0 +1,253 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/config"
+	"github.com/fluidkeys/fluidkeys/database"
+	fp "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/ui"
+)
+
+// autoApproveRequestsToJoinTeams applies the join rules of each team I'm an admin of to its
+// requests to join: if the team restricts email domains, requests that break the rules are
+// rejected and, if the team allows it, requests from allowed email domains with a key
+// published in Fluidkeys with a verified email address are approved.
+// It's run unattended by `fk sync`. Anything else is left for an admin to review with
+// `fk team authorize`.
+func autoApproveRequestsToJoinTeams() (code exitCode) {
+	memberships, err := loadVerifiedMemberships()
+	if err != nil {
+		out.Print(ui.FormatWarning("Failed to load team memberships", nil, err))
+		return 1
+	}
+
+	for _, grouped := range memberships {
+		adminMemberships := filterByAdmin(grouped.Memberships)
+		if len(adminMemberships) == 0 || !grouped.Team.RestrictsEmailDomains() {
+			continue
+		}
+
+		if err := autoApproveRequests(grouped.Team, adminMemberships[0].Me); err != nil {
+			out.Print(ui.FormatWarning(
+				"Failed to approve requests to join "+grouped.Team.Name,
+				formatRosterNotUploaded(err, "the requests have been kept, so the next sync will "+
+					"approve them again."), err))
+			code = 1
+		}
+	}
+	return code
+}
+
+func autoApproveRequests(t team.Team, me team.Person) error {
+	requests, err := api.ListRequestsToJoinTeam(t.UUID, me.Fingerprint)
+	if err != nil {
+		return err
+	}
+
+	pendingTeam, err := loadPendingRoster(t)
+	if err != nil {
+		log.Printf("failed to load pending roster for %s: %v", t.Name, err)
+	}
+
+	decisions := []joinDecision{}
+	approved := []joinDecision{}
+
+	for _, request := range requests {
+		if pendingTeam != nil && pendingTeam.Contains(request.Fingerprint) {
+			log.Printf("request from %s is waiting for the roster to be co-signed", request.Email)
+			continue
+		}
+
+		decision := decideJoinRequest(t, request, isPublishedWithVerifiedEmail)
+		if decision == nil {
+			log.Printf("leaving request from %s for an admin to review", request.Email)
+			continue
+		}
+		decisions = append(decisions, *decision)
+		if decision.approved {
+			approved = append(approved, *decision)
+		}
+	}
+
+	if len(decisions) == 0 {
+		return nil
+	}
+
+	out.Print("Applying join rules for " + t.Name + ":\n\n")
+
+	pending := false
+	if len(approved) > 0 {
+		previousTeam := t
+		t.ChainFrom(previousTeam)
+
+		for _, decision := range approved {
+			t.UpsertPerson(team.Person{
+				Email:       decision.request.Email,
+				Fingerprint: decision.request.Fingerprint,
+				IsAdmin:     false,
+			})
+		}
+
+		privateKey, err := getUnlockedKey(me.Fingerprint, true)
+		if err != nil {
+			return fmt.Errorf("failed to unlock private key to sign roster: %v", err)
+		}
+
+		if pending, err = signAndUploadRoster(
+			t, privateKey, signaturesRequiredForUpdate(previousTeam, t)); err != nil {
+			return err
+		}
+	}
+
+	for _, decision := range decisions {
+		if decision.approved && pending {
+			// keep the request until the roster has enough signatures to add them
+			decision.pending = true
+			recordJoinDecision(t, decision, true, me.Fingerprint)
+			ui.PrintCheckboxSuccess("Approved " + decision.request.Email +
+				": waiting for other admins to co-sign the roster")
+			continue
+		}
+
+		if err := api.DeleteRequestToJoinTeam(t.UUID, decision.request.UUID); err != nil {
+			log.Printf("failed to delete request %s: %v", decision.request.UUID, err)
+		}
+		recordJoinDecision(t, decision, true, me.Fingerprint)
+
+		if decision.approved {
+			ui.PrintCheckboxSuccess("Approved " + decision.request.Email)
+		} else {
+			ui.PrintCheckboxSuccess("Rejected " + decision.request.Email + ": " + decision.reason)
+		}
+	}
+	out.Print("\n")
+	return nil
+}
+
+// joinDecision is a decision to approve or reject a request to join a team, and why.
+type joinDecision struct {
+	request  team.RequestToJoinTeam
+	approved bool
+	reason   string
+
+	// pending is true if the roster approving the request is waiting for co-signatures.
+	pending bool
+}
+
+// decideJoinRequest returns the decision the team's join rules make about the request, or nil
+// if an admin needs to decide.
+func decideJoinRequest(t team.Team, request team.RequestToJoinTeam,
+	isVerified func(team.RequestToJoinTeam) (bool, error)) *joinDecision {
+
+	if err := t.CheckJoinRules(request.Email); err != nil {
+		return &joinDecision{request: request, approved: false, reason: err.Error()}
+	}
+
+	_, err := t.GetUpsertPersonWarnings(team.Person{
+		Email:       request.Email,
+		Fingerprint: request.Fingerprint,
+	})
+	switch {
+	case err == team.ErrPersonWouldNotBeChanged:
+		return &joinDecision{request: request, approved: false, reason: "already in the team"}
+
+	case err != nil:
+		// e.g. it would replace someone's key: always ask an admin
+		return nil
+	}
+
+	if _, err := t.GetPersonForEmail(request.Email); err == nil || t.Contains(request.Fingerprint) {
+		// it would change someone already in the team
+		return nil
+	}
+
+	if !t.AutoApprovesRequests() {
+		return nil
+	}
+
+	verified, err := isVerified(request)
+	if err != nil {
+		log.Printf("failed to check key for %s: %v", request.Email, err)
+		return nil
+	}
+	if !verified {
+		return nil
+	}
+	return &joinDecision{
+		request:  request,
+		approved: true,
+		reason:   "key is published in Fluidkeys with a verified email address",
+	}
+}
+
+// isPublishedWithVerifiedEmail returns whether the key in the request is the one Fluidkeys
+// returns for the request's email address. Fluidkeys only does that once the key's owner has
+// verified the email address.
+// The filesystem and git key directories return any key with a matching user ID, which anyone
+// who can write to them could add, so it always returns false for those.
+func isPublishedWithVerifiedEmail(request team.RequestToJoinTeam) (bool, error) {
+	if backend, _ := Config.KeyDirectory(); backend != config.KeyDirectoryFluidkeys {
+		log.Printf("not auto approving %s: the %s key directory doesn't verify email addresses",
+			request.Email, backend)
+		return false, nil
+	}
+
+	armoredKey, err := api.GetPublicKey(request.Email)
+	if err == apiclient.ErrPublicKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	key, err := pgpkey.LoadFromArmoredPublicKey(armoredKey)
+	if err != nil {
+		return false, err
+	}
+	return key.Fingerprint() == request.Fingerprint, nil
+}
+
+// recordJoinDecision adds the decision to the audit trail in the database.
+func recordJoinDecision(
+	t team.Team, decision joinDecision, automatic bool, decidedBy fp.Fingerprint) {
+
+	err := db.RecordJoinDecision(database.JoinDecisionMessage{
+		TeamUUID:    t.UUID,
+		RequestUUID: decision.request.UUID,
+		Email:       decision.request.Email,
+		Fingerprint: decision.request.Fingerprint,
+		Approved:    decision.approved,
+		Automatic:   automatic,
+		Pending:     decision.pending,
+		Reason:      decision.reason,
+		DecidedBy:   decidedBy,
+		DecidedAt:   time.Now(),
+	})
+	if err != nil {
+		log.Printf("failed to record decision about request from %s: %v",
+			decision.request.Email, err)
+	}
+}
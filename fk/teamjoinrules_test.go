@@ -0,0 +1,163 @@
+package fk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/config"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/testhelpers"
+	"github.com/gofrs/uuid"
+)
+
+func TestDecideJoinRequest(t *testing.T) {
+	rules := team.JoinRules{AllowedEmailDomains: []string{"example.com"}, AutoApprove: true}
+	kiffix := team.Team{
+		Name: "Kiffix",
+		UUID: uuid.Must(uuid.NewV4()),
+		People: []team.Person{
+			{Email: "admin@example.com", Fingerprint: exampledata.ExampleFingerprint2, IsAdmin: true},
+			{Email: "member@example.com", Fingerprint: exampledata.ExampleFingerprint4},
+		},
+		JoinRules: &rules,
+	}
+
+	verified := func(team.RequestToJoinTeam) (bool, error) { return true, nil }
+	notVerified := func(team.RequestToJoinTeam) (bool, error) { return false, nil }
+	failsToVerify := func(team.RequestToJoinTeam) (bool, error) {
+		return false, fmt.Errorf("offline")
+	}
+
+	request := team.RequestToJoinTeam{
+		UUID:        uuid.Must(uuid.NewV4()),
+		Email:       "jane@example.com",
+		Fingerprint: exampledata.ExampleFingerprint3,
+	}
+
+	t.Run("rejects a request breaking the join rules", func(t *testing.T) {
+		gmail := request
+		gmail.Email = "jane@gmail.com"
+
+		got := decideJoinRequest(kiffix, gmail, verified)
+		assert.Equal(t, &joinDecision{
+			request:  gmail,
+			approved: false,
+			reason:   "only email addresses at example.com can join the team",
+		}, got)
+	})
+
+	t.Run("rejects a request from someone already in the team", func(t *testing.T) {
+		existing := request
+		existing.Email = "member@example.com"
+		existing.Fingerprint = exampledata.ExampleFingerprint4
+
+		got := decideJoinRequest(kiffix, existing, verified)
+		assert.Equal(t, &joinDecision{
+			request: existing, approved: false, reason: "already in the team",
+		}, got)
+	})
+
+	t.Run("approves a request with a verified key", func(t *testing.T) {
+		got := decideJoinRequest(kiffix, request, verified)
+		assert.Equal(t, true, got != nil && got.approved)
+	})
+
+	t.Run("leaves requests with unverified keys for an admin", func(t *testing.T) {
+		assert.Equal(t, (*joinDecision)(nil), decideJoinRequest(kiffix, request, notVerified))
+		assert.Equal(t, (*joinDecision)(nil), decideJoinRequest(kiffix, request, failsToVerify))
+	})
+
+	t.Run("leaves requests that would replace a key for an admin", func(t *testing.T) {
+		replacing := request
+		replacing.Email = "admin@example.com"
+
+		assert.Equal(t, (*joinDecision)(nil), decideJoinRequest(kiffix, replacing, verified))
+	})
+
+	t.Run("leaves requests for an admin if the team doesn't auto approve", func(t *testing.T) {
+		manual := kiffix
+		manual.JoinRules = &team.JoinRules{AllowedEmailDomains: []string{"example.com"}}
+
+		assert.Equal(t, (*joinDecision)(nil), decideJoinRequest(manual, request, verified))
+	})
+}
+
+func TestAutoApproveRequestsToJoinTeams(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	requester := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+
+	admin.use(t)
+	kiffix := team.Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []team.Person{
+			{Email: admin.email, Fingerprint: admin.fingerprint(), IsAdmin: true},
+		},
+		JoinRules: &team.JoinRules{AllowedEmailDomains: []string{"kiffix.com"}},
+	}
+	pending, err := signAndUploadRoster(kiffix, admin.key, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, false, pending)
+
+	requester.use(t)
+	assert.NoError(t, sendRequestToJoinTeam(
+		kiffix.UUID, kiffix.Name, requester.fingerprint(), requester.email))
+
+	t.Run("rejects requests breaking the rules of a team that doesn't auto approve",
+		func(t *testing.T) {
+			admin.use(t)
+			assert.Equal(t, 0, autoApproveRequestsToJoinTeams())
+
+			requests, err := api.ListRequestsToJoinTeam(kiffix.UUID, admin.fingerprint())
+			assert.NoError(t, err)
+			assert.Equal(t, 0, len(requests))
+
+			decisions, err := db.GetJoinDecisions(kiffix.UUID)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(decisions))
+			assert.Equal(t, requester.email, decisions[0].Email)
+			assert.Equal(t, false, decisions[0].Approved)
+			assert.Equal(t, true, decisions[0].Automatic)
+		})
+}
+
+func TestIsPublishedWithVerifiedEmail(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	requester := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+	request := team.RequestToJoinTeam{
+		UUID:        uuid.Must(uuid.NewV4()),
+		Email:       requester.email,
+		Fingerprint: requester.fingerprint(),
+	}
+
+	t.Run("with the Fluidkeys key directory", func(t *testing.T) {
+		verified, err := isPublishedWithVerifiedEmail(request)
+		assert.NoError(t, err)
+		assert.Equal(t, true, verified)
+	})
+
+	t.Run("with a filesystem key directory", func(t *testing.T) {
+		directory := testhelpers.Maketemp(t)
+		configToml := "[key_directory]\nbackend = \"filesystem\"\npath = \"" +
+			filepath.Join(directory, "keys") + "\"\n"
+		assert.NoError(t, ioutil.WriteFile(
+			filepath.Join(directory, "config.toml"), []byte(configToml), 0600))
+
+		loadedConfig, err := config.Load(directory)
+		assert.NoError(t, err)
+		Config = *loadedConfig
+
+		verified, err := isPublishedWithVerifiedEmail(request)
+		assert.NoError(t, err)
+		assert.Equal(t, false, verified)
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/colour"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/status"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/ui"
+)
+
+// teammateKeyProblem records the problems found with a teammate's key when fetching it.
+type teammateKeyProblem struct {
+	person   team.Person
+	key      *pgpkey.PgpKey
+	warnings []status.KeyWarning
+}
+
+// checkTeammateKey returns a teammateKeyProblem if the fetched key is revoked, expired, expiring
+// soon or has no valid encryption subkey, otherwise nil.
+func checkTeammateKey(person team.Person, key *pgpkey.PgpKey, now time.Time) *teammateKeyProblem {
+	warnings := status.GetPublicKeyWarnings(*key, now)
+	if len(warnings) == 0 {
+		return nil
+	}
+	return &teammateKeyProblem{person: person, key: key, warnings: warnings}
+}
+
+// reportTeammateKeyProblems prints the problems with teammates' keys. If I'm an admin of the
+// team and running interactively, it offers to remove people whose keys are unusable, and to
+// send a reminder to people whose keys are expiring soon.
+func reportTeammateKeyProblems(
+	t team.Team, me team.Person, problems []teammateKeyProblem, unattended bool) {
+
+	if len(problems) == 0 {
+		return
+	}
+
+	isAdmin := t.IsAdmin(me.Fingerprint)
+	out.Print(formatTeammateKeyProblems(t, problems, isAdmin))
+
+	if unattended || !isAdmin {
+		return
+	}
+
+	prompter := interactiveYesNoPrompter{}
+	toRemove := []team.Person{}
+
+	for _, problem := range problems {
+		if status.IsUnusable(problem.warnings) {
+			if prompter.promptYesNo(
+				"Remove "+problem.person.Email+" from "+t.Name+"?", "n", nil) {
+				toRemove = append(toRemove, problem.person)
+			}
+			continue
+		}
+
+		if prompter.promptYesNo(
+			"Send "+problem.person.Email+" a reminder to extend their key?", "y", nil) {
+			err := ui.RunWithCheckboxes("Send reminder to "+problem.person.Email, func() error {
+				return sendKeyReminder(t, problem)
+			})
+			if err != nil {
+				log.Printf("failed to send reminder to %s: %v", problem.person.Email, err)
+			}
+		}
+	}
+
+	if len(toRemove) > 0 {
+		if _, err := removePeopleFromTeam(t, me, toRemove); err != nil {
+			log.Printf("failed to remove people from %s: %v", t.Name, err)
+		}
+	}
+}
+
+func formatTeammateKeyProblems(t team.Team, problems []teammateKeyProblem, isAdmin bool) string {
+	lines := []string{}
+	for _, problem := range problems {
+		warningStrings := []string{}
+		for _, warning := range problem.warnings {
+			warningStrings = append(warningStrings, warning.String())
+		}
+		lines = append(lines, problem.person.Email+": "+strings.Join(warningStrings, ", "))
+	}
+
+	lines = append(lines, "")
+	if isAdmin {
+		lines = append(lines,
+			"Secrets can't be sent to people with revoked, expired or unusable keys. Ask them",
+			"to run "+colour.Cmd("fk key maintain")+" or remove them from the team.",
+		)
+	} else {
+		lines = append(lines,
+			"Secrets can't be sent to people with revoked, expired or unusable keys. Ask them",
+			"to run "+colour.Cmd("fk key maintain")+", or ask a team admin to remove them.",
+		)
+	}
+
+	return ui.FormatWarning("Some keys in "+t.Name+" need attention", lines, nil)
+}
+
+// sendKeyReminder sends the person a secret asking them to extend their key before it expires.
+func sendKeyReminder(t team.Team, problem teammateKeyProblem) error {
+	reminder := fmt.Sprintf(
+		"Your key %s in %s expires soon.\n\n"+
+			"Please run `fk key maintain` then `fk key upload` to extend it, or your team "+
+			"won't be able to send you secrets.\n",
+		problem.person.Fingerprint, t.Name,
+	)
+
+	encryptedReminder, err := encryptSecret(reminder, "", problem.key)
+	if err != nil {
+		return err
+	}
+	return api.CreateSecret(problem.person.Fingerprint, encryptedReminder)
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/colour"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/ui"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+)
+
+func teamLeave(teamSelector string) exitCode {
+	groupedMemberships, err := user.GroupedMemberships()
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to list teams", nil, err))
+		return 1
+	}
+
+	if len(groupedMemberships) == 0 {
+		out.Print(ui.FormatFailure("You aren't in any teams", nil, nil))
+		return 1
+	}
+
+	membership := chooseTeamOrPrintFailure(groupedMemberships, teamSelector)
+	if membership == nil {
+		return 1
+	}
+
+	otherTeams := []team.Team{}
+	for _, otherMembership := range groupedMemberships {
+		if otherMembership.Team.UUID != membership.Team.UUID {
+			otherTeams = append(otherTeams, otherMembership.Team)
+		}
+	}
+
+	return doLeaveTeam(*membership, otherTeams, &interactiveYesNoPrompter{})
+}
+
+func doLeaveTeam(membership userpackage.GroupedMembership, otherTeams []team.Team,
+	prompter promptYesNoInterface) exitCode {
+
+	myTeam := membership.Team
+
+	printHeader("Leave " + myTeam.Name)
+
+	if isOnlyAdmin(myTeam, membership.Memberships) {
+		out.Print(ui.FormatFailure("You're the only admin of "+myTeam.Name, []string{
+			"Make someone else an admin before leaving, by running " + colour.Cmd("fk team edit"),
+		}, nil))
+		return 1
+	}
+
+	out.Print(ui.FormatInfo("Leaving removes the team from this computer", []string{
+		"Fluidkeys will ask the team admins to remove you from the roster, then delete",
+		"the roster and the keys of teammates you aren't in another team with.",
+	}))
+
+	if !prompter.promptYesNo("Leave "+myTeam.Name+"?", "n", nil) {
+		return 1
+	}
+	out.Print("\n")
+
+	err := ui.RunWithCheckboxes("Ask team admins to remove you from the roster", func() error {
+		// ask to remove each of my keys in the team
+		for _, myMembership := range membership.Memberships {
+			unlockedKey, err := getUnlockedKey(myMembership.Me.Fingerprint, false)
+			if err != nil {
+				log.Print(err)
+				return fmt.Errorf("failed to unlock key to sign request")
+			}
+			if err := api.RequestToLeaveTeam(myTeam.UUID, unlockedKey); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sawError := false
+	if err != nil {
+		// carry on removing the team from this computer: an admin can still remove me
+		out.Print(ui.FormatWarning("Couldn't ask the admins of "+myTeam.Name+" to remove you",
+			[]string{
+				"Ask a team admin to remove you from the roster by running",
+				colour.Cmd("fk team remove " + membership.Memberships[0].Me.Email),
+			}, err))
+		sawError = err != apiclient.ErrRequestsToLeaveNotSupported
+	}
+
+	myFingerprints, err := db.GetFingerprintsImportedIntoGnuPG()
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to list your keys", nil, err))
+		return 1
+	}
+
+	for _, person := range teammatesToForget(myTeam, myFingerprints, otherTeams) {
+		if err := ui.RunWithCheckboxes(person.Email+": remove key", func() error {
+			return forgetTeammate(person, myFingerprints)
+		}); err != nil {
+			sawError = true
+		}
+	}
+
+	err = ui.RunWithCheckboxes("Delete team roster", func() error {
+		return forgetTeam(myTeam)
+	})
+	if err != nil {
+		sawError = true
+	}
+
+	if err := syncGpgConfGroups(); err != nil {
+		log.Printf("failed to remove %s from gpg.conf: %v", myTeam.Name, err)
+		sawError = true
+	}
+
+	if err := syncTeamKeyTrust(); err != nil {
+		log.Printf("failed to take away trust for %s keys: %v", myTeam.Name, err)
+		sawError = true
+	}
+	out.Print("\n")
+
+	if sawError {
+		printFailed("Encountered errors while leaving " + myTeam.Name + ".\n")
+		return 1
+	}
+
+	printSuccess("Left " + myTeam.Name)
+	out.Print("\n")
+	return 0
+}
+
+// isOnlyAdmin returns true if I'm an admin of the team and nobody else is.
+func isOnlyAdmin(t team.Team, myMemberships []userpackage.TeamMembership) bool {
+	myAdminMemberships := filterByAdmin(myMemberships)
+	if len(myAdminMemberships) == 0 {
+		return false
+	}
+
+	for _, admin := range t.Admins() {
+		isMe := false
+		for _, membership := range myAdminMemberships {
+			if admin.HasFingerprint(membership.Me.Fingerprint) {
+				isMe = true
+			}
+		}
+		if !isMe {
+			return false
+		}
+	}
+	return true
+}
+
+// teammatesToForget returns the people in the team who aren't me and aren't in any of my other
+// teams, so their keys are no longer needed. There's an entry for each key to forget, with that
+// key as the person's Fingerprint.
+func teammatesToForget(t team.Team, myFingerprints []fpr.Fingerprint, otherTeams []team.Team) (
+	toForget []team.Person) {
+
+	for _, person := range t.People {
+		if isMyPerson(person, myFingerprints) {
+			continue
+		}
+
+		for _, fingerprint := range person.Fingerprints() {
+			inOtherTeam := false
+			for _, otherTeam := range otherTeams {
+				if otherTeam.Contains(fingerprint) {
+					inOtherTeam = true
+					break
+				}
+			}
+			if !inOtherTeam {
+				toForget = append(toForget, person.WithFingerprint(fingerprint))
+			}
+		}
+	}
+	return toForget
+}
+
+// isMyPerson returns whether any of the person's keys are mine.
+func isMyPerson(person team.Person, myFingerprints []fpr.Fingerprint) bool {
+	for _, fingerprint := range person.Fingerprints() {
+		if fpr.Contains(myFingerprints, fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// forgetTeammate deletes the teammate's key from GnuPG (if it wasn't there until Fluidkeys
+// imported it) and from the key cache, and forgets that we fetched it and certified it with any
+// of my keys. A key that was already in GnuPG is left there, along with my certification.
+func forgetTeammate(person team.Person, myFingerprints []fpr.Fingerprint) error {
+	lastImported, err := db.GetLast("import", person.Fingerprint)
+	if err != nil {
+		return err
+	}
+
+	if !lastImported.IsZero() {
+		if err := gpg.DeletePublicKey(person.Fingerprint); err != nil {
+			log.Printf("failed to delete %s from GnuPG: %v", person.Fingerprint, err)
+			return fmt.Errorf("failed to delete key from GnuPG")
+		}
+	} else {
+		log.Printf("leaving %s in GnuPG: it was there before Fluidkeys imported it",
+			person.Fingerprint)
+	}
+
+	if err := keyCache.Delete(person.Fingerprint); err != nil {
+		return err
+	}
+
+	for _, verb := range []string{"fetch", "import"} {
+		if err := db.DeleteLast(verb, person.Fingerprint); err != nil {
+			return err
+		}
+	}
+
+	for _, myFingerprint := range myFingerprints {
+		if err := db.DeleteLast("certify", emailKeyAndCertifier{
+			email:     person.Email,
+			key:       person.Fingerprint,
+			certifier: myFingerprint,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forgetTeam deletes the team's subdirectory, including its roster history, and forgets when
+// the team was last fetched.
+func forgetTeam(t team.Team) error {
+	teamSubdirectory, err := team.Directory(t, fluidkeysDirectory)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(teamSubdirectory); err != nil {
+		return err
+	}
+	return db.DeleteLast("fetch", t)
+}
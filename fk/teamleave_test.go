@@ -0,0 +1,255 @@
+package fk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/keydirectory"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+	"github.com/gofrs/uuid"
+)
+
+func TestTeammatesToForget(t *testing.T) {
+	me := team.Person{Email: "me@example.com", Fingerprint: exampledata.ExampleFingerprint2}
+	shared := team.Person{Email: "shared@example.com", Fingerprint: exampledata.ExampleFingerprint3}
+	onlyHere := team.Person{Email: "only@example.com", Fingerprint: exampledata.ExampleFingerprint4}
+
+	leaving := team.Team{Name: "Leaving", People: []team.Person{me, shared, onlyHere}}
+	staying := team.Team{Name: "Staying", People: []team.Person{me, shared}}
+
+	t.Run("keeps people in my other teams", func(t *testing.T) {
+		got := teammatesToForget(
+			leaving, []fpr.Fingerprint{me.Fingerprint}, []team.Team{staying})
+
+		assert.Equal(t, []team.Person{onlyHere}, got)
+	})
+
+	t.Run("with no other teams", func(t *testing.T) {
+		got := teammatesToForget(leaving, []fpr.Fingerprint{me.Fingerprint}, nil)
+
+		assert.Equal(t, []team.Person{shared, onlyHere}, got)
+	})
+	t.Run("forgets each of a teammate's keys, but none of mine", func(t *testing.T) {
+		meWithToken := me
+		meWithToken.AdditionalFingerprints = []fpr.Fingerprint{
+			fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+		}
+		sharedWithToken := shared
+		sharedWithToken.AdditionalFingerprints = []fpr.Fingerprint{
+			fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+		}
+		withTokens := team.Team{Name: "Tokens", People: []team.Person{meWithToken, sharedWithToken}}
+
+		got := teammatesToForget(withTokens, []fpr.Fingerprint{me.Fingerprint}, nil)
+
+		assert.Equal(t, []team.Person{
+			sharedWithToken.WithFingerprint(shared.Fingerprint),
+			sharedWithToken.WithFingerprint(
+				fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD")),
+		}, got)
+	})
+}
+
+func TestForgetTeammate(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	newToGnuPG := newTestProfile(t, exampledata.ExamplePrivateKey3, "test3")
+	alreadyInGnuPG := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+
+	kiffix := team.Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []team.Person{
+			{Email: admin.email, Fingerprint: admin.fingerprint(), IsAdmin: true},
+			{Email: newToGnuPG.email, Fingerprint: newToGnuPG.fingerprint()},
+			{Email: alreadyInGnuPG.email, Fingerprint: alreadyInGnuPG.fingerprint()},
+		},
+	}
+
+	admin.use(t)
+	armoredKey, err := alreadyInGnuPG.key.Armor()
+	assert.NoError(t, err)
+	assert.NoError(t, gpg.ImportArmoredKey(armoredKey))
+
+	me := kiffix.People[0]
+	assert.NoError(t, fetchAndCertifyTeamKeys(kiffix, me, false))
+
+	myFingerprints := []fpr.Fingerprint{admin.fingerprint()}
+
+	t.Run("deletes a key Fluidkeys imported into GnuPG", func(t *testing.T) {
+		assert.NoError(t, forgetTeammate(kiffix.People[1], myFingerprints))
+
+		inGnuPG, err := isKeyInGnuPG(newToGnuPG.fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, false, inGnuPG)
+	})
+
+	t.Run("leaves a key that was already in GnuPG", func(t *testing.T) {
+		assert.NoError(t, forgetTeammate(kiffix.People[2], myFingerprints))
+
+		inGnuPG, err := isKeyInGnuPG(alreadyInGnuPG.fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, true, inGnuPG)
+	})
+}
+
+func TestLeaveTeamWithoutRequestsToLeave(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+	api = withoutRequestsToLeave{api}
+
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	member := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+
+	admin.use(t)
+	kiffix := team.Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []team.Person{
+			{Email: admin.email, Fingerprint: admin.fingerprint(), IsAdmin: true},
+			{Email: member.email, Fingerprint: member.fingerprint()},
+		},
+	}
+	pending, err := signAndUploadRoster(kiffix, admin.key, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, false, pending)
+
+	member.use(t)
+	saveRoster(t, *uploadRoster(t, kiffix, admin.key))
+
+	t.Run("authorize carries on without requests to leave", func(t *testing.T) {
+		admin.use(t)
+		myTeam, me := admin.membership(t)
+		assert.Equal(t, 0, reviewRequestsToLeaveTeam(myTeam, me))
+	})
+
+	t.Run("leave still removes the team from this computer", func(t *testing.T) {
+		member.use(t)
+		myTeam, me := member.membership(t)
+		membership := userpackage.GroupedMembership{
+			Team:        myTeam,
+			Memberships: []userpackage.TeamMembership{{Team: myTeam, Me: me}},
+		}
+		assert.Equal(t, 0, doLeaveTeam(membership, nil, &alwaysYesPrompter{}))
+
+		memberships, err := user.Memberships()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(memberships))
+	})
+}
+
+// withoutRequestsToLeave is a key directory like the Fluidkeys API, which doesn't have the
+// requests-to-leave endpoints.
+type withoutRequestsToLeave struct {
+	keydirectory.KeyDirectory
+}
+
+func (d withoutRequestsToLeave) RequestToLeaveTeam(uuid.UUID, *pgpkey.PgpKey) error {
+	return apiclient.ErrRequestsToLeaveNotSupported
+}
+
+func (d withoutRequestsToLeave) ListRequestsToLeaveTeam(uuid.UUID, fpr.Fingerprint) (
+	[]team.RequestToLeaveTeam, error) {
+
+	return nil, apiclient.ErrRequestsToLeaveNotSupported
+}
+
+func TestReviewRequestsToLeaveTeamRejectsStaleAndReplayedRequests(t *testing.T) {
+	_, restore := useFakeServer()
+	defer restore()
+
+	admin := newTestProfile(t, exampledata.ExamplePrivateKey2, "test2")
+	member := newTestProfile(t, exampledata.ExamplePrivateKey4, "test4")
+
+	admin.use(t)
+	kiffix := team.Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []team.Person{
+			{Email: admin.email, Fingerprint: admin.fingerprint(), IsAdmin: true},
+			{Email: member.email, Fingerprint: member.fingerprint()},
+		},
+	}
+	pending, err := signAndUploadRoster(kiffix, admin.key, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, false, pending)
+
+	makeRequest := func(t *testing.T, signedAt time.Time) team.RequestToLeaveTeam {
+		t.Helper()
+		signedJSON, err := team.MakeRequestToLeaveSignedJSON(kiffix.UUID, member.key, signedAt)
+		assert.NoError(t, err)
+		return team.RequestToLeaveTeam{
+			UUID:              uuid.Must(uuid.NewV4()),
+			TeamUUID:          kiffix.UUID,
+			Fingerprint:       member.fingerprint(),
+			ArmoredSignedJSON: signedJSON,
+		}
+	}
+
+	t.Run("a request signed too long ago is deleted", func(t *testing.T) {
+		request := makeRequest(t, time.Now().Add(-team.RequestToLeaveValidFor-time.Hour))
+		directory := &withRequestsToLeave{
+			KeyDirectory: api,
+			requests:     []team.RequestToLeaveTeam{request},
+		}
+		api = directory
+		defer func() { api = directory.KeyDirectory }()
+
+		myTeam, me := admin.membership(t)
+		assert.Equal(t, 0, reviewRequestsToLeaveTeam(myTeam, me))
+		assert.Equal(t, []uuid.UUID{request.UUID}, directory.deleted)
+
+		myTeam, _ = admin.membership(t)
+		assert.Equal(t, true, myTeam.Contains(member.fingerprint()))
+	})
+
+	t.Run("a request that was already acted on is deleted", func(t *testing.T) {
+		request := makeRequest(t, time.Now())
+		singleUseUUID, err := request.Verify(member.key, time.Now())
+		assert.NoError(t, err)
+		assert.NoError(t, db.RecordLast("leave-request", singleUseUUID, time.Now()))
+
+		// the same signed request, sent again so the server gave it a new UUID
+		request.UUID = uuid.Must(uuid.NewV4())
+		directory := &withRequestsToLeave{
+			KeyDirectory: api,
+			requests:     []team.RequestToLeaveTeam{request},
+		}
+		api = directory
+		defer func() { api = directory.KeyDirectory }()
+
+		myTeam, me := admin.membership(t)
+		assert.Equal(t, 0, reviewRequestsToLeaveTeam(myTeam, me))
+		assert.Equal(t, []uuid.UUID{request.UUID}, directory.deleted)
+
+		myTeam, _ = admin.membership(t)
+		assert.Equal(t, true, myTeam.Contains(member.fingerprint()))
+	})
+}
+
+// withRequestsToLeave is a key directory that returns the given requests to leave, and records
+// which ones were deleted.
+type withRequestsToLeave struct {
+	keydirectory.KeyDirectory
+	requests []team.RequestToLeaveTeam
+	deleted  []uuid.UUID
+}
+
+func (d *withRequestsToLeave) ListRequestsToLeaveTeam(uuid.UUID, fpr.Fingerprint) (
+	[]team.RequestToLeaveTeam, error) {
+
+	return d.requests, nil
+}
+
+func (d *withRequestsToLeave) DeleteRequestToLeaveTeam(_ uuid.UUID, requestUUID uuid.UUID) error {
+	d.deleted = append(d.deleted, requestUUID)
+	return nil
+}
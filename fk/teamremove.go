@@ -0,0 +1,253 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"log"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/colour"
+	"github.com/fluidkeys/fluidkeys/humanize"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/ui"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+	"github.com/gofrs/uuid"
+)
+
+func teamRemove(email string, teamSelector string) exitCode {
+	groupedMemberships, err := user.GroupedMemberships()
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to list teams", nil, err))
+		return 1
+	}
+
+	adminMemberships := filterGroupedByAdmin(groupedMemberships)
+	if len(adminMemberships) == 0 {
+		out.Print(ui.FormatFailure("You aren't an admin of any teams", nil, nil))
+		return 1
+	}
+
+	if teamSelector == "" {
+		// only choose between the teams the person is in
+		matchingMemberships := []userpackage.GroupedMembership{}
+		for _, membership := range adminMemberships {
+			if _, err := membership.Team.GetPersonForEmail(email); err == nil {
+				matchingMemberships = append(matchingMemberships, membership)
+			}
+		}
+
+		if len(matchingMemberships) == 0 {
+			out.Print(ui.FormatFailure(email+" isn't in any team you're an admin of", nil, nil))
+			return 1
+		}
+		adminMemberships = matchingMemberships
+	}
+
+	adminMembership := chooseTeamOrPrintFailure(adminMemberships, teamSelector)
+	if adminMembership == nil {
+		return 1
+	}
+
+	return doRemoveFromTeam(adminMembership.Team, adminMembership.Memberships[0].Me, email)
+}
+
+func doRemoveFromTeam(myTeam team.Team, me team.Person, email string) exitCode {
+	printHeader("Remove " + email + " from " + myTeam.Name)
+
+	person, err := myTeam.GetPersonForEmail(email)
+	if err != nil {
+		out.Print(ui.FormatFailure(email+" isn't in "+myTeam.Name, nil, err))
+		return 1
+	}
+
+	if person.HasFingerprint(me.Fingerprint) {
+		out.Print(ui.FormatFailure("You can't remove yourself from the team", []string{
+			"To leave the team, run " + colour.Cmd("fk team leave"),
+		}, nil))
+		return 1
+	}
+
+	existingRoster, signature := myTeam.Roster()
+	if err := fetchAdminKeysVerifyRoster(myTeam, existingRoster, signature); err != nil {
+		out.Print(ui.FormatFailure("Failed to verify team roster", []string{
+			"Before changing the team roster, Fluidkeys checked the signature of the team ",
+			"roster, but encountered a problem.",
+		}, err))
+		return 1
+	}
+
+	if _, err := removePeopleFromTeam(myTeam, me, []team.Person{*person}); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// removePeopleFromTeam makes a new version of the roster without the given people and prompts
+// me to sign and upload it. pending is true if the roster is waiting for other admins to co-sign
+// it, so the people haven't been removed yet.
+func removePeopleFromTeam(t team.Team, me team.Person, toRemove []team.Person) (
+	pending bool, err error) {
+
+	updatedTeam := t
+	updatedTeam.People = append([]team.Person{}, t.People...)
+	updatedTeam.ChainFrom(t)
+
+	for _, person := range toRemove {
+		if err := updatedTeam.RemovePerson(person.Fingerprint); err != nil {
+			return false, err
+		}
+	}
+
+	if err := team.ValidateUpdate(&t, &updatedTeam, &me); err != nil {
+		out.Print(ui.FormatFailure("Problem with new team roster", nil, err))
+		return false, err
+	}
+
+	pending, err = promptAndSignAndUploadRoster(updatedTeam, me.Fingerprint,
+		signaturesRequiredForUpdate(t, updatedTeam), &interactiveYesNoPrompter{})
+	if err != nil {
+		if err != errUserDeclinedToSign {
+			out.Print(ui.FormatFailure("Failed to sign and upload roster",
+				formatRosterNotUploaded(err, "remove them again."), err))
+		}
+		return false, err
+	}
+	return pending, nil
+}
+
+// reviewRequestsToLeaveTeam checks each request to leave the team was signed by the member
+// leaving, then offers to remove them from the roster. It loads the team from disk, since the
+// roster may have just been changed by approving requests to join.
+func reviewRequestsToLeaveTeam(teamToReview team.Team, me team.Person) exitCode {
+	_, myTeam, err := user.IsInTeam(teamToReview.UUID)
+	if err != nil || myTeam == nil {
+		out.Print(ui.FormatFailure("Failed to load team "+teamToReview.Name, nil, err))
+		return 1
+	}
+
+	requests, err := api.ListRequestsToLeaveTeam(myTeam.UUID, me.Fingerprint)
+	if err == apiclient.ErrRequestsToLeaveNotSupported {
+		log.Printf("not checking for requests to leave %s: %v", myTeam.Name, err)
+		return 0
+	} else if err != nil {
+		out.Print(ui.FormatFailure("Error getting requests to leave the team", nil, err))
+		return 1
+	}
+	if len(requests) == 0 {
+		return 0
+	}
+
+	out.Print(humanize.Pluralize(len(requests), "request", "requests") + " to leave " +
+		myTeam.Name + ":\n\n")
+
+	// requests are only valid for a while, so older records of acting on them aren't needed
+	if err := db.ForgetOlderThan(
+		"leave-request", team.RequestToLeaveValidFor, time.Now()); err != nil {
+		log.Printf("failed to forget old requests to leave: %v", err)
+	}
+
+	pendingTeam, err := loadPendingRoster(*myTeam)
+	if err != nil {
+		log.Printf("failed to load pending roster for %s: %v", myTeam.Name, err)
+	}
+
+	prompter := interactiveYesNoPrompter{}
+	toRemove := []team.Person{}
+	approvedRequests := []team.RequestToLeaveTeam{}
+	approvedUUIDs := []uuid.UUID{}
+	deleteRequests := []team.RequestToLeaveTeam{}
+
+	for _, request := range requests {
+		person, err := myTeam.GetPersonForFingerprint(request.Fingerprint)
+		if err != nil {
+			log.Printf("%s has already left the team, deleting request", request.Fingerprint)
+			deleteRequests = append(deleteRequests, request)
+			continue
+		}
+
+		if pendingTeam != nil && !pendingTeam.Contains(request.Fingerprint) {
+			log.Printf("roster removing %s is waiting to be co-signed", person.Email)
+			continue
+		}
+
+		key, err := discoverPublicKey(person.Fingerprint, person.Email)
+		if err != nil {
+			out.Print(ui.FormatWarning(
+				"Couldn't get the key for "+person.Email+" to check their request", nil, err))
+			continue
+		}
+
+		singleUseUUID, err := request.Verify(key, time.Now())
+		if err != nil {
+			out.Print(ui.FormatWarning(
+				"Ignoring request to leave from "+person.Email, []string{
+					"The request wasn't signed by their key recently, so it may not have come " +
+						"from them.",
+				}, err))
+			deleteRequests = append(deleteRequests, request)
+			continue
+		}
+
+		if processedAt, err := db.GetLast("leave-request", singleUseUUID); err != nil {
+			log.Printf("failed to check whether request to leave was seen before: %v", err)
+			continue
+		} else if !processedAt.IsZero() {
+			out.Print(ui.FormatWarning(
+				"Ignoring request to leave from "+person.Email, []string{
+					"The same request was already acted on at " + processedAt.Format(time.RFC822) +
+						", so it may have been sent again by someone else.",
+				}, nil))
+			deleteRequests = append(deleteRequests, request)
+			continue
+		}
+
+		out.Print("» key:   " + colour.Info(person.Fingerprint.String()) + "\n")
+		out.Print("  email: " + colour.Info(person.Email) + "\n\n")
+
+		if prompter.promptYesNo("Remove "+person.Email+" from the team?", "y", nil) {
+			toRemove = append(toRemove, *person)
+			approvedRequests = append(approvedRequests, request)
+			approvedUUIDs = append(approvedUUIDs, singleUseUUID)
+		}
+	}
+
+	exit := 0
+	if len(toRemove) > 0 {
+		if pending, err := removePeopleFromTeam(*myTeam, me, toRemove); err != nil {
+			exit = 1
+		} else if !pending {
+			// if it's pending, keep the requests until the roster has been co-signed
+			deleteRequests = append(deleteRequests, approvedRequests...)
+			for _, singleUseUUID := range approvedUUIDs {
+				if err := db.RecordLast("leave-request", singleUseUUID, time.Now()); err != nil {
+					log.Printf("failed to record request to leave: %v", err)
+				}
+			}
+		}
+	}
+
+	for _, request := range deleteRequests {
+		if err := api.DeleteRequestToLeaveTeam(myTeam.UUID, request.UUID); err != nil {
+			out.Print(ui.FormatWarning("Failed to delete a request to leave the team", nil, err))
+			exit = 1
+		}
+	}
+	return exit
+}
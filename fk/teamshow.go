@@ -0,0 +1,315 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fluidkeys/crypto/openpgp/errors"
+	"github.com/fluidkeys/fluidkeys/colour"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/humanize"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/ui"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+)
+
+func teamList() exitCode {
+	out.Print("\n")
+
+	rosters, err := team.LoadRosters(fluidkeysDirectory)
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to list teams", nil, err))
+		return 1
+	}
+	if len(rosters) == 0 {
+		out.Print(ui.FormatInfo("You aren't in any teams", []string{
+			"Join a team by running " + colour.Cmd("fk team apply <team id>"),
+		}))
+		return 0
+	}
+
+	myFingerprints := loadMyFingerprints()
+	code := 0
+
+	for _, loaded := range rosters {
+		if loaded.Err != nil {
+			printRosterFailedToLoad(loaded)
+			code = 1
+			continue
+		}
+
+		t := *loaded.Team
+		status := checkRosterSignature(t, loadAdminKeysOffline(t))
+
+		printHeader(t.Name)
+		out.Print("  id:           " + t.UUID.String() + "\n")
+		out.Print("  people:       " + humanize.Pluralize(len(t.People), "person", "people") + "\n")
+		out.Print("  my role:      " + formatMyRoles(t, myFingerprints) + "\n")
+		out.Print("  last fetched: " + formatLastFetched(t) + "\n")
+		out.Print("  signature:    " + status.String() + "\n\n")
+
+		if status.state == signatureInvalid {
+			code = 1
+		}
+	}
+
+	out.Print("To see everyone in a team, run " + colour.Cmd("fk team show <team>") + "\n\n")
+	return code
+}
+
+func teamShow(teamSelector string) exitCode {
+	out.Print("\n")
+
+	rosters, err := team.LoadRosters(fluidkeysDirectory)
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to list teams", nil, err))
+		return 1
+	}
+
+	loaded, err := chooseRoster(rosters, teamSelector)
+	if err != nil {
+		out.Print(ui.FormatFailure(err.Error(), []string{
+			"See your teams with " + colour.Cmd("fk team list"),
+		}, nil))
+		return 1
+	}
+	if loaded.Err != nil {
+		printRosterFailedToLoad(*loaded)
+		return 1
+	}
+
+	t := *loaded.Team
+	myFingerprints := loadMyFingerprints()
+
+	printHeader(t.Name)
+	out.Print("  id:           " + t.UUID.String() + "\n")
+	out.Print(fmt.Sprintf("  version:      %d\n", t.Version))
+	out.Print("  last fetched: " + formatLastFetched(t) + "\n\n")
+
+	for _, person := range t.People {
+		out.Print(formatPersonDetails(person, myFingerprints))
+	}
+
+	status := checkRosterSignature(t, loadAdminKeysOffline(t))
+	switch status.state {
+	case signatureValid:
+		out.Print(ui.FormatSuccess("The roster's signature is valid", []string{
+			"Signed by " + strings.Join(status.signers, ", ") + ".",
+		}))
+
+	case signatureUnchecked:
+		out.Print(ui.FormatWarning("Couldn't check the roster's signature", []string{
+			"The key of the admin who signed it isn't in GnuPG or the key cache.",
+			"To fetch them, run " + colour.Cmd("fk team fetch"),
+		}, nil))
+
+	case signatureIncomplete:
+		out.Print(ui.FormatWarning("The roster doesn't have enough signatures", []string{
+			"Signed by " + strings.Join(status.signers, ", ") + ".",
+		}, status.err))
+
+	case signatureInvalid:
+		out.Print(ui.FormatFailure("The roster's signature is invalid", []string{
+			"The roster or signature file in " + loaded.Directory,
+			"has been changed since a team admin signed it. Don't trust the keys in it.",
+			"",
+			"To download the roster again, run " + colour.Cmd("fk team fetch"),
+		}, status.err))
+		return 1
+	}
+	return 0
+}
+
+// chooseRoster returns the roster for the team with the given name or UUID, or asks which team
+// if teamSelector is empty. Rosters that failed to load can be chosen by UUID, so that
+// `fk team show` can explain what's wrong with them.
+func chooseRoster(rosters []team.LoadedRoster, teamSelector string) (*team.LoadedRoster, error) {
+	loaded := []userpackage.GroupedMembership{}
+	for _, roster := range rosters {
+		if roster.Err == nil {
+			loaded = append(loaded, userpackage.GroupedMembership{Team: *roster.Team})
+		}
+	}
+
+	chosen, err := chooseTeam(loaded, teamSelector, promptForTeam)
+	if err == nil {
+		for i := range rosters {
+			if rosters[i].Err == nil && rosters[i].Team.UUID == chosen.Team.UUID {
+				return &rosters[i], nil
+			}
+		}
+	}
+
+	if teamSelector != "" {
+		for i := range rosters {
+			if rosters[i].Err != nil && strings.HasSuffix(
+				filepath.Base(rosters[i].Directory), strings.ToLower(teamSelector)) {
+				return &rosters[i], nil
+			}
+		}
+	}
+	return nil, err
+}
+
+func printRosterFailedToLoad(loaded team.LoadedRoster) {
+	printHeader(filepath.Base(loaded.Directory))
+	out.Print(ui.FormatFailure("Couldn't load the team roster", []string{
+		"The roster or signature file in " + loaded.Directory,
+		"is missing or has been changed. Don't trust the keys in it.",
+		"",
+		"To download the roster again, run " + colour.Cmd("fk team fetch"),
+	}, loaded.Err))
+}
+
+func formatPersonDetails(person team.Person, myFingerprints []fpr.Fingerprint) string {
+	output := "» " + colour.Info(person.Email) + "  " + string(person.GetRole())
+	for _, fingerprint := range myFingerprints {
+		if person.HasFingerprint(fingerprint) {
+			output += " " + colour.Disabled("(you)")
+			break
+		}
+	}
+	output += "\n"
+
+	for _, fingerprint := range person.Fingerprints() {
+		output += "  key:          " + fingerprint.String() + "\n"
+	}
+
+	lastFetched := "-"
+	if t := lastFetchedKey(person.Fingerprint); !t.IsZero() {
+		lastFetched = humanize.RoughDuration(time.Since(t)) + " ago"
+	}
+	output += "  last fetched: " + lastFetched + "\n\n"
+	return output
+}
+
+func formatMyRoles(t team.Team, myFingerprints []fpr.Fingerprint) string {
+	roles := []string{}
+	for _, person := range t.People {
+		for _, fingerprint := range myFingerprints {
+			if person.HasFingerprint(fingerprint) {
+				roles = append(roles, string(person.GetRole())+" ("+person.Email+")")
+				break
+			}
+		}
+	}
+	if len(roles) == 0 {
+		return "-"
+	}
+	return strings.Join(roles, ", ")
+}
+
+func formatLastFetched(t team.Team) string {
+	lastFetched, err := db.GetLast("fetch", t)
+	if err != nil {
+		log.Printf("failed to get last fetch time for %s: %v", t.Name, err)
+		return "-"
+	}
+	if lastFetched.IsZero() {
+		return "never"
+	}
+	return humanize.RoughDuration(time.Since(lastFetched)) + " ago"
+}
+
+func loadMyFingerprints() []fpr.Fingerprint {
+	fingerprints, err := db.GetFingerprintsImportedIntoGnuPG()
+	if err != nil {
+		log.Printf("failed to get fingerprints imported into GnuPG: %v", err)
+	}
+	return fingerprints
+}
+
+type rosterSignatureState int
+
+const (
+	// signatureValid means enough admins have signed the roster
+	signatureValid rosterSignatureState = iota
+	// signatureUnchecked means the keys of the admins who signed the roster aren't available
+	// offline, in GnuPG or the key cache
+	signatureUnchecked
+	// signatureIncomplete means admins have signed the roster, but not enough of them
+	signatureIncomplete
+	// signatureInvalid means the roster or signature have changed since an admin signed it
+	signatureInvalid
+)
+
+type rosterSignatureStatus struct {
+	state   rosterSignatureState
+	signers []string // emails of the admins who signed the roster
+	err     error
+}
+
+// checkRosterSignature checks the team's roster signature against the given admin keys,
+// without contacting Fluidkeys.
+func checkRosterSignature(t team.Team, adminKeys []*pgpkey.PgpKey) rosterSignatureStatus {
+	if len(adminKeys) == 0 {
+		return rosterSignatureStatus{state: signatureUnchecked}
+	}
+
+	signers := []string{}
+	for _, fingerprint := range t.Signers(adminKeys) {
+		if person, err := t.GetPersonForFingerprint(fingerprint); err == nil {
+			signers = appendIfMissing(signers, person.Email)
+		}
+	}
+
+	roster, signature := t.Roster()
+	err := team.VerifyRoster(roster, signature, adminKeys)
+	switch err.(type) {
+	case nil:
+		return rosterSignatureStatus{state: signatureValid, signers: signers}
+
+	case *team.ErrNotEnoughSignatures:
+		return rosterSignatureStatus{state: signatureIncomplete, signers: signers, err: err}
+	}
+
+	if err == errors.ErrUnknownIssuer {
+		// signed by an admin whose key we don't have, so we can't tell if it's valid
+		return rosterSignatureStatus{state: signatureUnchecked, err: err}
+	}
+	return rosterSignatureStatus{state: signatureInvalid, err: err}
+}
+
+func (s rosterSignatureStatus) String() string {
+	switch s.state {
+	case signatureValid:
+		return colour.Success("valid, signed by " + strings.Join(s.signers, ", "))
+	case signatureUnchecked:
+		return colour.Warning("not checked: signer's key isn't in GnuPG or the key cache")
+	case signatureIncomplete:
+		return colour.Warning(s.err.Error())
+	default:
+		return colour.Failure("INVALID: the roster has been changed since it was signed")
+	}
+}
+
+func appendIfMissing(items []string, item string) []string {
+	for _, existing := range items {
+		if existing == item {
+			return items
+		}
+	}
+	return append(items, item)
+}
@@ -0,0 +1,68 @@
+package fk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/gofrs/uuid"
+)
+
+func TestCheckRosterSignature(t *testing.T) {
+	adminKey, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(
+		exampledata.ExamplePrivateKey2, "test2")
+	assert.NoError(t, err)
+	otherKey, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(
+		exampledata.ExamplePrivateKey3, "test3")
+	assert.NoError(t, err)
+
+	kiffix := team.Team{
+		Name: "Kiffix",
+		UUID: uuid.Must(uuid.NewV4()),
+		People: []team.Person{
+			{Email: "admin@example.com", Fingerprint: adminKey.Fingerprint(), IsAdmin: true},
+			{Email: "other@example.com", Fingerprint: otherKey.Fingerprint(), IsAdmin: true},
+		},
+	}
+	assert.NoError(t, kiffix.UpdateRoster(adminKey))
+	roster, signature := kiffix.Roster()
+
+	t.Run("valid signature", func(t *testing.T) {
+		got := checkRosterSignature(kiffix, []*pgpkey.PgpKey{adminKey, otherKey})
+		assert.Equal(t, signatureValid, got.state)
+		assert.Equal(t, []string{"admin@example.com"}, got.signers)
+	})
+
+	t.Run("no admin keys", func(t *testing.T) {
+		got := checkRosterSignature(kiffix, nil)
+		assert.Equal(t, signatureUnchecked, got.state)
+	})
+
+	t.Run("signed by an admin whose key isn't available", func(t *testing.T) {
+		got := checkRosterSignature(kiffix, []*pgpkey.PgpKey{otherKey})
+		assert.Equal(t, signatureUnchecked, got.state)
+	})
+
+	t.Run("not enough signatures", func(t *testing.T) {
+		twoRequired := kiffix
+		twoRequired.RequiredSignatures = 2
+		assert.NoError(t, twoRequired.UpdateRoster(adminKey))
+
+		got := checkRosterSignature(twoRequired, []*pgpkey.PgpKey{adminKey, otherKey})
+		assert.Equal(t, signatureIncomplete, got.state)
+		assert.Equal(t, []string{"admin@example.com"}, got.signers)
+	})
+
+	t.Run("roster changed after signing", func(t *testing.T) {
+		tampered, err := team.Load(
+			strings.Replace(roster, "other@example.com", "evil@example.com", 1), signature)
+		assert.NoError(t, err)
+
+		got := checkRosterSignature(*tampered, []*pgpkey.PgpKey{adminKey, otherKey})
+		assert.Equal(t, signatureInvalid, got.state)
+		assert.GotError(t, got.err)
+	})
+}
@@ -0,0 +1,246 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/config"
+	"github.com/fluidkeys/fluidkeys/database"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/gpgwrapper"
+	"github.com/fluidkeys/fluidkeys/team"
+)
+
+// syncTeamKeyTrust makes GnuPG trust my teammates' keys as the config's team_key_trust says:
+// either full ownertrust for team admins, or a "good" TOFU policy for everyone. Trust that
+// Fluidkeys gave earlier is taken away from anyone who's no longer in one of my teams.
+//
+// Only trust given by Fluidkeys is ever taken away: a key I've trusted myself is left alone.
+func syncTeamKeyTrust() error {
+	trustModel := Config.TeamKeyTrust()
+
+	groupedMemberships, err := user.GroupedMemberships()
+	if err != nil {
+		return err
+	}
+
+	teams := []team.Team{}
+	myFingerprints := loadMyFingerprints()
+	for _, membership := range groupedMemberships {
+		for _, m := range membership.Memberships {
+			myFingerprints = append(myFingerprints, m.Me.Fingerprints()...)
+		}
+
+		if err := verifyRosterOffline(membership.Team); err != nil {
+			log.Printf("not trusting keys in %s: couldn't verify roster: %v",
+				membership.Team.Name, err)
+			continue
+		}
+		teams = append(teams, membership.Team)
+	}
+
+	wanted := teamKeysToTrust(teams, trustModel, myFingerprints)
+
+	recorded, err := db.GetTeamKeyTrust()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, trust := range trustToTakeAway(recorded, wanted, trustModel) {
+		if err := takeAwayTeamKeyTrust(trust); err != nil {
+			log.Printf("failed to take away trust for %s: %v", trust.Fingerprint, err)
+			failed++
+			continue
+		}
+		if err := db.DeleteTeamKeyTrust(trust.Fingerprint); err != nil {
+			return err
+		}
+	}
+
+	for _, fingerprint := range wanted {
+		if isTrustRecorded(recorded, fingerprint, trustModel) {
+			continue
+		}
+
+		trusted, err := giveTeamKeyTrust(fingerprint, trustModel)
+		if err != nil {
+			log.Printf("failed to trust %s: %v", fingerprint, err)
+			failed++
+			continue
+		} else if !trusted {
+			continue
+		}
+
+		err = db.RecordTeamKeyTrust(database.TeamKeyTrustMessage{
+			Fingerprint: fingerprint,
+			Model:       string(trustModel),
+			TrustedAt:   time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to update trust for %d keys", failed)
+	}
+	return nil
+}
+
+// teamKeysToTrust returns the keys that should be trusted in GnuPG: the admins' keys for
+// ownertrust, or everyone's keys for TOFU. My own keys are never included, since they're
+// already trusted ultimately.
+func teamKeysToTrust(teams []team.Team, trustModel config.TeamKeyTrust,
+	myFingerprints []fpr.Fingerprint) (fingerprints []fpr.Fingerprint) {
+
+	var people []team.Person
+	for _, t := range teams {
+		switch trustModel {
+		case config.TeamKeyTrustOwnertrust:
+			people = append(people, t.Admins()...)
+
+		case config.TeamKeyTrustTofu:
+			people = append(people, t.People...)
+		}
+	}
+
+	for _, person := range people {
+		for _, fingerprint := range person.Fingerprints() {
+			if fpr.Contains(myFingerprints, fingerprint) ||
+				fpr.Contains(fingerprints, fingerprint) {
+				continue
+			}
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+	return fingerprints
+}
+
+// trustToTakeAway returns the recorded trust that's no longer wanted, either because the key
+// isn't in the wanted list, or because it was trusted using a different model.
+func trustToTakeAway(recorded []database.TeamKeyTrustMessage, wanted []fpr.Fingerprint,
+	trustModel config.TeamKeyTrust) (unwanted []database.TeamKeyTrustMessage) {
+
+	for _, trust := range recorded {
+		if trust.Model == string(trustModel) && fpr.Contains(wanted, trust.Fingerprint) {
+			continue
+		}
+		unwanted = append(unwanted, trust)
+	}
+	return unwanted
+}
+
+// giveTeamKeyTrust makes GnuPG trust the given key, returning false if it was left alone
+// because I'd already set its ownertrust myself.
+func giveTeamKeyTrust(fingerprint fpr.Fingerprint, trustModel config.TeamKeyTrust) (
+	trusted bool, err error) {
+
+	switch trustModel {
+	case config.TeamKeyTrustOwnertrust:
+		level, err := gpg.Ownertrust(fingerprint)
+		if err != nil {
+			return false, err
+		}
+		if level != gpgwrapper.OwnertrustUnknown && level != gpgwrapper.OwnertrustUndefined {
+			log.Printf("not changing ownertrust for %s: already set to %d", fingerprint, level)
+			return false, nil
+		}
+		if err := gpg.SetOwnertrust(fingerprint, gpgwrapper.OwnertrustFull); err != nil {
+			return false, err
+		}
+		log.Printf("set ownertrust for %s to full", fingerprint)
+		return true, nil
+
+	case config.TeamKeyTrustTofu:
+		if inGnuPG, err := isKeyInGnuPG(fingerprint); err != nil {
+			return false, err
+		} else if !inGnuPG {
+			log.Printf("not setting TOFU policy for %s: key isn't in GnuPG yet", fingerprint)
+			return false, nil
+		}
+		if err := gpg.SetTofuPolicy(fingerprint, gpgwrapper.TofuPolicyGood); err != nil {
+			return false, err
+		}
+		log.Printf("set TOFU policy for %s to good", fingerprint)
+		return true, nil
+
+	default:
+		return false, nil
+	}
+}
+
+// takeAwayTeamKeyTrust undoes giveTeamKeyTrust. If I've since changed the key's ownertrust
+// myself, it's left alone.
+func takeAwayTeamKeyTrust(trust database.TeamKeyTrustMessage) error {
+	switch config.TeamKeyTrust(trust.Model) {
+	case config.TeamKeyTrustOwnertrust:
+		level, err := gpg.Ownertrust(trust.Fingerprint)
+		if err != nil {
+			return err
+		}
+		if level != gpgwrapper.OwnertrustFull {
+			log.Printf("not resetting ownertrust for %s: changed to %d since Fluidkeys set it",
+				trust.Fingerprint, level)
+			return nil
+		}
+		if err := gpg.SetOwnertrust(
+			trust.Fingerprint, gpgwrapper.OwnertrustUndefined); err != nil {
+			return err
+		}
+		log.Printf("reset ownertrust for %s", trust.Fingerprint)
+		return nil
+
+	case config.TeamKeyTrustTofu:
+		if inGnuPG, err := isKeyInGnuPG(trust.Fingerprint); err != nil {
+			return err
+		} else if !inGnuPG {
+			return nil // nothing to reset until the key is imported again
+		}
+		if err := gpg.SetTofuPolicy(trust.Fingerprint, gpgwrapper.TofuPolicyAuto); err != nil {
+			return err
+		}
+		log.Printf("reset TOFU policy for %s to auto", trust.Fingerprint)
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+func isTrustRecorded(recorded []database.TeamKeyTrustMessage, fingerprint fpr.Fingerprint,
+	trustModel config.TeamKeyTrust) bool {
+
+	for _, trust := range recorded {
+		if trust.Fingerprint == fingerprint && trust.Model == string(trustModel) {
+			return true
+		}
+	}
+	return false
+}
+
+func isKeyInGnuPG(fingerprint fpr.Fingerprint) (bool, error) {
+	listings, err := gpg.ListPublicKeys(fingerprint.Hex())
+	if err != nil {
+		return false, err
+	}
+	return len(listings) > 0, nil
+}
@@ -0,0 +1,78 @@
+package fk
+
+import (
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/config"
+	"github.com/fluidkeys/fluidkeys/database"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/team"
+)
+
+func TestTeamKeysToTrust(t *testing.T) {
+	me := exampledata.ExampleFingerprint4
+	kiffix := team.Team{
+		Name: "Kiffix",
+		People: []team.Person{
+			{
+				Email:                  "admin@example.com",
+				Fingerprint:            exampledata.ExampleFingerprint2,
+				AdditionalFingerprints: []fpr.Fingerprint{exampledata.ExampleFingerprint3},
+				IsAdmin:                true,
+			},
+			{Email: "me@example.com", Fingerprint: me, IsAdmin: true},
+			{Email: "member@example.com", Fingerprint: fpr.MustParse(
+				"AAAA1111AAAA1111AAAA1111AAAA1111AAAA1111")},
+		},
+	}
+	myFingerprints := []fpr.Fingerprint{me}
+
+	t.Run("ownertrust trusts admins' keys, but not mine", func(t *testing.T) {
+		assert.Equal(t,
+			[]fpr.Fingerprint{exampledata.ExampleFingerprint2, exampledata.ExampleFingerprint3},
+			teamKeysToTrust([]team.Team{kiffix}, config.TeamKeyTrustOwnertrust, myFingerprints),
+		)
+	})
+
+	t.Run("tofu trusts everyone's keys, but not mine", func(t *testing.T) {
+		assert.Equal(t,
+			[]fpr.Fingerprint{
+				exampledata.ExampleFingerprint2,
+				exampledata.ExampleFingerprint3,
+				fpr.MustParse("AAAA1111AAAA1111AAAA1111AAAA1111AAAA1111"),
+			},
+			teamKeysToTrust([]team.Team{kiffix, kiffix}, config.TeamKeyTrustTofu, myFingerprints),
+		)
+	})
+
+	t.Run("certify trusts nobody", func(t *testing.T) {
+		assert.Equal(t, 0, len(
+			teamKeysToTrust([]team.Team{kiffix}, config.TeamKeyTrustCertify, myFingerprints)))
+	})
+}
+
+func TestTrustToTakeAway(t *testing.T) {
+	stillAdmin := database.TeamKeyTrustMessage{
+		Fingerprint: exampledata.ExampleFingerprint2,
+		Model:       "ownertrust",
+	}
+	leftTeam := database.TeamKeyTrustMessage{
+		Fingerprint: exampledata.ExampleFingerprint3,
+		Model:       "ownertrust",
+	}
+	recorded := []database.TeamKeyTrustMessage{stillAdmin, leftTeam}
+	wanted := []fpr.Fingerprint{exampledata.ExampleFingerprint2}
+
+	t.Run("takes away trust from keys no longer wanted", func(t *testing.T) {
+		assert.Equal(t,
+			[]database.TeamKeyTrustMessage{leftTeam},
+			trustToTakeAway(recorded, wanted, config.TeamKeyTrustOwnertrust),
+		)
+	})
+
+	t.Run("takes away trust given using a different model", func(t *testing.T) {
+		assert.Equal(t, recorded, trustToTakeAway(recorded, wanted, config.TeamKeyTrustTofu))
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/ui"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+)
+
+// loadVerifiedMemberships returns the user's team memberships, but only for teams whose roster
+// on disk has a valid signature from one of the team admins.
+// It only uses keys that are already in GnuPG, so it works without contacting Fluidkeys. A warning
+// is printed for any team that fails to verify.
+func loadVerifiedMemberships() (verified []userpackage.GroupedMembership, err error) {
+	groupedMemberships, err := user.GroupedMemberships()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, membership := range groupedMemberships {
+		if err := verifyRosterOffline(membership.Team); err != nil {
+			log.Printf("failed to verify roster for %s: %v", membership.Team.Name, err)
+			out.Print(ui.FormatWarning(
+				"Ignoring "+membership.Team.Name+": couldn't verify team roster", []string{
+					"The roster on disk doesn't have a valid signature from a team admin.",
+				}, err))
+			continue
+		}
+		verified = append(verified, membership)
+	}
+	return verified, nil
+}
+
+// verifyRosterOffline checks the team's roster against the admins' public keys in GnuPG.
+func verifyRosterOffline(t team.Team) error {
+	adminKeys := loadAdminKeysOffline(t)
+	if len(adminKeys) == 0 {
+		return fmt.Errorf("none of the team admins' keys are in GnuPG or the key cache")
+	}
+
+	roster, signature := t.Roster()
+	return team.VerifyRoster(roster, signature, adminKeys)
+}
+
+// loadAdminKeysOffline returns the public keys of the team's admins that are in GnuPG or the
+// key cache.
+func loadAdminKeysOffline(t team.Team) (adminKeys []*pgpkey.PgpKey) {
+	for _, admin := range t.Admins() {
+		for _, fingerprint := range admin.Fingerprints() {
+			key, err := loadPublicKeyOffline(fingerprint)
+			if err != nil {
+				log.Printf("failed to load admin key %s: %v", fingerprint, err)
+				continue
+			}
+			adminKeys = append(adminKeys, key)
+		}
+	}
+	return adminKeys
+}
@@ -0,0 +1,398 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	docopt "github.com/docopt/docopt-go"
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/armor"
+	"github.com/fluidkeys/crypto/openpgp/clearsign"
+	"github.com/fluidkeys/crypto/openpgp/errors"
+	"github.com/fluidkeys/crypto/openpgp/packet"
+	"github.com/fluidkeys/fluidkeys/colour"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/out"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/ui"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+)
+
+func verifySubcommand(args docopt.Opts) exitCode {
+	filename, err := args.String("<filename>")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	signatureFilename := ""
+	if args["<signature>"] != nil {
+		if signatureFilename, err = args.String("<signature>"); err != nil {
+			log.Panic(err)
+		}
+	}
+	return fileVerify(filename, signatureFilename)
+}
+
+// fileVerify checks the signature on a file against the keys of the people in the user's teams,
+// and reports who signed it.
+// If signatureFilename is empty, filename should either be a cleartext signed message, or a
+// detached signature (e.g. file.txt.asc) alongside the file it signs.
+func fileVerify(filename string, signatureFilename string) exitCode {
+	out.Print("\n")
+
+	data, signature, err := readSignedFiles(filename, signatureFilename)
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to read signature", nil, err))
+		return 1
+	}
+
+	memberships, err := loadVerifiedMemberships()
+	if err != nil {
+		out.Print(ui.FormatFailure("Failed to load team memberships", nil, err))
+		return 1
+	}
+
+	myKeys, err := loadPgpKeys()
+	if err != nil {
+		out.Print(ui.FormatFailure("Couldn't load PGP keys", nil, err))
+		return 1
+	}
+
+	keyring := makeVerifyKeyring(memberships, myKeys)
+
+	signer, err := verifySignature(keyring, data, signature)
+	if notValid, ok := err.(errSignerKeyNotValid); ok {
+		out.Print(ui.FormatFailure("Signed with a key that wasn't valid", []string{
+			"The signature was made by " + signerName(signer) + " (" +
+				fpr.FromBytes(signer.PrimaryKey.Fingerprint).String() + "),",
+			"but their key wasn't valid for signing when it was made, so it can't be trusted.",
+		}, notValid))
+		return 1
+	}
+
+	switch {
+	case err == errors.ErrUnknownIssuer:
+		out.Print(ui.FormatFailure("Signed by someone who isn't in your teams", []string{
+			"The signature was made by a key that isn't in any of your team rosters,",
+			"so Fluidkeys can't say who signed it.",
+		}, nil))
+		return 1
+
+	case err != nil:
+		out.Print(ui.FormatFailure("Bad signature", []string{
+			"The file may have been changed since it was signed.",
+		}, err))
+		return 1
+	}
+
+	signerFingerprint := fpr.FromBytes(signer.PrimaryKey.Fingerprint)
+	out.Print(ui.FormatSuccess(
+		"Good signature from "+signerName(signer),
+		append(
+			[]string{"Signed by " + signerFingerprint.String(), ""},
+			describeSigner(signerFingerprint, memberships, myKeys)...,
+		),
+	))
+	return 0
+}
+
+// readSignedFiles returns the signed data and its detached signature. If the data is a cleartext
+// signed message, signature is nil.
+func readSignedFiles(filename string, signatureFilename string) (
+	data []byte, signature []byte, err error) {
+
+	if signatureFilename == "" {
+		if data, err = ioutil.ReadFile(filename); err != nil {
+			return nil, nil, err
+		}
+		if block, _ := clearsign.Decode(data); block != nil {
+			return data, nil, nil
+		}
+
+		// treat it as a detached signature next to the file it signs, e.g. file.txt.asc
+		signedFilename := strings.TrimSuffix(filename, filepath.Ext(filename))
+		if _, err := os.Stat(signedFilename); signedFilename == filename || err != nil {
+			return nil, nil, fmt.Errorf(
+				"%s isn't a cleartext signed message, and there's no file it could be "+
+					"a detached signature for", filename)
+		}
+		filename, signatureFilename = signedFilename, filename
+	}
+
+	if data, err = ioutil.ReadFile(filename); err != nil {
+		return nil, nil, err
+	}
+	if signature, err = ioutil.ReadFile(signatureFilename); err != nil {
+		return nil, nil, err
+	}
+	return data, signature, nil
+}
+
+// verifySignature checks a detached signature (armored or binary) over data, or if signature is
+// nil, checks that data is a correctly signed cleartext message.
+// It returns the entity that made the signature. If the signature is good but the signer's key
+// was revoked or expired when it was made, it returns the signer along with an
+// errSignerKeyNotValid.
+func verifySignature(keyring openpgp.KeyRing, data []byte, signature []byte) (
+	*openpgp.Entity, error) {
+
+	signed, signature, err := splitSignature(data, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// openpgp skips revoked keys when looking for the issuer, which would make a signature from
+	// a revoked key look like one from a stranger. Check the signature against any key, then
+	// check the key was valid when the signature was made.
+	signer, err := openpgp.CheckDetachedSignature(
+		anyUsageKeyRing{keyring}, bytes.NewReader(signed), bytes.NewReader(signature),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	issuerKeyID, signedAt, err := readSignatureIssuer(signature)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range (openpgp.EntityList{signer}).KeysById(issuerKeyID) {
+		if err := checkSigningKeyAt(key, signedAt); err != nil {
+			return signer, err
+		}
+	}
+	return signer, nil
+}
+
+// splitSignature returns the signed data and the binary detached signature over it. If
+// signature is nil, data should be a cleartext signed message.
+func splitSignature(data []byte, signature []byte) (signed []byte, binarySignature []byte,
+	err error) {
+
+	if signature == nil {
+		block, _ := clearsign.Decode(data)
+		if block == nil {
+			return nil, nil, fmt.Errorf("no cleartext signed message found")
+		}
+		if binarySignature, err = ioutil.ReadAll(block.ArmoredSignature.Body); err != nil {
+			return nil, nil, err
+		}
+		return block.Bytes, binarySignature, nil
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN PGP SIGNATURE-----")) {
+		block, err := armor.Decode(bytes.NewReader(signature))
+		if err != nil {
+			return nil, nil, err
+		}
+		if block.Type != openpgp.SignatureType {
+			return nil, nil, fmt.Errorf("expected '%s', got '%s'", openpgp.SignatureType, block.Type)
+		}
+		if binarySignature, err = ioutil.ReadAll(block.Body); err != nil {
+			return nil, nil, err
+		}
+		return data, binarySignature, nil
+	}
+	return data, signature, nil
+}
+
+// readSignatureIssuer returns the key ID and creation time of the first signature packet in a
+// binary signature.
+func readSignatureIssuer(signature []byte) (issuerKeyID uint64, signedAt time.Time, err error) {
+	p, err := packet.NewReader(bytes.NewReader(signature)).Next()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	switch sig := p.(type) {
+	case *packet.Signature:
+		if sig.IssuerKeyId == nil {
+			return 0, time.Time{}, fmt.Errorf("signature doesn't have an issuer")
+		}
+		return *sig.IssuerKeyId, sig.CreationTime, nil
+	case *packet.SignatureV3:
+		return sig.IssuerKeyId, sig.CreationTime, nil
+	default:
+		return 0, time.Time{}, fmt.Errorf("non signature packet found")
+	}
+}
+
+// checkSigningKeyAt returns an errSignerKeyNotValid if key isn't allowed to make signatures, or
+// if it, or the primary key it belongs to, was revoked or had expired at signedAt.
+func checkSigningKeyAt(key openpgp.Key, signedAt time.Time) error {
+	entity := key.Entity
+
+	for _, revocation := range entity.Revocations {
+		if revokedAt(revocation, signedAt) {
+			return errSignerKeyNotValid{
+				problem: "revoked on " + revocation.CreationTime.Format("2 January 2006"),
+			}
+		}
+	}
+
+	for _, id := range entity.Identities {
+		if expired, expiry := expiredAt(entity.PrimaryKey.CreationTime,
+			id.SelfSignature.KeyLifetimeSecs, signedAt); expired {
+			return errSignerKeyNotValid{problem: "expired on " + expiry.Format("2 January 2006")}
+		}
+	}
+
+	if key.SelfSignature.FlagsValid && !key.SelfSignature.FlagSign {
+		return errSignerKeyNotValid{problem: "isn't allowed to make signatures"}
+	}
+
+	if key.PublicKey == entity.PrimaryKey {
+		return nil // the primary key's expiry has been checked above
+	}
+
+	if key.SelfSignature.SigType == packet.SigTypeSubkeyRevocation &&
+		revokedAt(key.SelfSignature, signedAt) {
+		return errSignerKeyNotValid{
+			problem: "signing subkey was revoked on " +
+				key.SelfSignature.CreationTime.Format("2 January 2006"),
+		}
+	}
+	if expired, expiry := expiredAt(key.PublicKey.CreationTime,
+		key.SelfSignature.KeyLifetimeSecs, signedAt); expired {
+		return errSignerKeyNotValid{
+			problem: "signing subkey expired on " + expiry.Format("2 January 2006"),
+		}
+	}
+	return nil
+}
+
+// revokedAt returns true if revocation means signatures made at signedAt can't be trusted.
+// A key that's been superseded or retired was fine until it was revoked, but for any other
+// reason (e.g. the key was compromised) every signature it's made is suspect.
+func revokedAt(revocation *packet.Signature, signedAt time.Time) bool {
+	if revocation.RevocationReason != nil {
+		switch *revocation.RevocationReason {
+		case revocationReasonSuperseded, revocationReasonRetired:
+			return !signedAt.Before(revocation.CreationTime)
+		}
+	}
+	return true
+}
+
+// expiredAt returns true and the expiry time if a key created at creationTime with the given
+// lifetime had expired at signedAt.
+func expiredAt(creationTime time.Time, lifetimeSecs *uint32, signedAt time.Time) (
+	bool, *time.Time) {
+
+	hasExpiry, expiry := pgpkey.CalculateExpiry(creationTime, lifetimeSecs)
+	if !hasExpiry || signedAt.Before(*expiry) {
+		return false, nil
+	}
+	return true, expiry
+}
+
+// Reasons for revocation, see https://tools.ietf.org/html/rfc4880#section-5.2.3.23
+const (
+	revocationReasonSuperseded uint8 = 1
+	revocationReasonRetired    uint8 = 3
+)
+
+// errSignerKeyNotValid means a signature checked out, but the key that made it wasn't valid for
+// signing when it was made, e.g. it had been revoked or had expired.
+type errSignerKeyNotValid struct {
+	problem string
+}
+
+func (e errSignerKeyNotValid) Error() string { return "the signer's key " + e.problem }
+
+// anyUsageKeyRing wraps a KeyRing so that looking up keys by usage returns every key with the
+// given ID, including revoked ones. Callers must check the key themselves.
+type anyUsageKeyRing struct {
+	openpgp.KeyRing
+}
+
+func (k anyUsageKeyRing) KeysByIdUsage(id uint64, requiredUsage byte) []openpgp.Key {
+	return k.KeysById(id)
+}
+
+// makeVerifyKeyring returns a keyring containing the keys of everyone in the given teams, and
+// the user's own keys. Keys are loaded from GnuPG, so anyone whose key hasn't been fetched is
+// left out.
+func makeVerifyKeyring(memberships []userpackage.GroupedMembership, myKeys []pgpkey.PgpKey) (
+	keyring openpgp.EntityList) {
+
+	seen := map[fpr.Fingerprint]bool{}
+
+	for i := range myKeys {
+		seen[myKeys[i].Fingerprint()] = true
+		keyring = append(keyring, &myKeys[i].Entity)
+	}
+
+	for _, membership := range memberships {
+		for _, fingerprint := range membership.Team.Fingerprints() {
+			if seen[fingerprint] {
+				continue
+			}
+			seen[fingerprint] = true
+
+			key, err := loadPgpKey(fingerprint)
+			if err != nil {
+				log.Printf("failed to load key %s from GnuPG: %v", fingerprint, err)
+				continue
+			}
+			keyring = append(keyring, &key.Entity)
+		}
+	}
+	return keyring
+}
+
+// describeSigner returns a line for each of the user's teams the signer is in (and whether
+// they're an admin), plus a line if the signer is one of the user's own keys.
+func describeSigner(signerFingerprint fpr.Fingerprint,
+	memberships []userpackage.GroupedMembership, myKeys []pgpkey.PgpKey) (lines []string) {
+
+	for _, key := range myKeys {
+		if key.Fingerprint() == signerFingerprint {
+			lines = append(lines, "This is your key.")
+		}
+	}
+
+	for _, membership := range memberships {
+		t := membership.Team
+		if !t.Contains(signerFingerprint) {
+			continue
+		}
+
+		person, err := t.GetPersonForFingerprint(signerFingerprint)
+		if err != nil {
+			log.Printf("error getting person for %s: %v", signerFingerprint, err)
+			continue
+		}
+
+		lines = append(lines,
+			person.Email+" is a team "+string(person.GetRole())+" in "+colour.Info(t.Name))
+	}
+	return lines
+}
+
+func signerName(signer *openpgp.Entity) string {
+	key := pgpkey.PgpKey{Entity: *signer}
+	return displayName(&key)
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package fk
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/errors"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	userpackage "github.com/fluidkeys/fluidkeys/user"
+	"github.com/gofrs/uuid"
+)
+
+func TestVerifySignature(t *testing.T) {
+	signingKey, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(
+		exampledata.ExamplePrivateKey4, "test4")
+	assert.NoError(t, err)
+
+	publicKey, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey4)
+	assert.NoError(t, err)
+	otherKey, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey3)
+	assert.NoError(t, err)
+
+	keyring := openpgp.EntityList{&publicKey.Entity}
+	data := []byte("hello world\n")
+
+	t.Run("with a detached signature", func(t *testing.T) {
+		signature, err := signingKey.MakeArmoredDetachedSignature(data)
+		assert.NoError(t, err)
+
+		signer, err := verifySignature(keyring, data, []byte(signature))
+		assert.NoError(t, err)
+		assert.Equal(t, exampledata.ExampleFingerprint4, fpr.FromBytes(signer.PrimaryKey.Fingerprint))
+
+		t.Run("rejects changed data", func(t *testing.T) {
+			_, err := verifySignature(keyring, []byte("goodbye world\n"), []byte(signature))
+			assert.GotError(t, err)
+		})
+
+		t.Run("returns ErrUnknownIssuer for keys not in the keyring", func(t *testing.T) {
+			_, err := verifySignature(
+				openpgp.EntityList{&otherKey.Entity}, data, []byte(signature))
+			assert.Equal(t, errors.ErrUnknownIssuer, err)
+		})
+	})
+
+	t.Run("with a cleartext signed message", func(t *testing.T) {
+		signed, err := signingKey.MakeArmoredClearSignature(data)
+		assert.NoError(t, err)
+
+		signer, err := verifySignature(keyring, []byte(signed), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, exampledata.ExampleFingerprint4, fpr.FromBytes(signer.PrimaryKey.Fingerprint))
+	})
+
+	t.Run("reports a signer whose key was revoked", func(t *testing.T) {
+		signature, err := signingKey.MakeArmoredDetachedSignature(data)
+		assert.NoError(t, err)
+
+		revokedKey, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey4)
+		assert.NoError(t, err)
+		revocation, err := signingKey.GetRevocationSignature(2, "compromised", time.Now())
+		assert.NoError(t, err)
+		revokedKey.Revocations = append(revokedKey.Revocations, revocation)
+
+		signer, err := verifySignature(
+			openpgp.EntityList{&revokedKey.Entity}, data, []byte(signature))
+		_, isNotValid := err.(errSignerKeyNotValid)
+		assert.Equal(t, true, isNotValid)
+		assert.Equal(t, exampledata.ExampleFingerprint4, fpr.FromBytes(signer.PrimaryKey.Fingerprint))
+	})
+
+	t.Run("accepts a signature made before the key was retired", func(t *testing.T) {
+		signature, err := signingKey.MakeArmoredDetachedSignature(data)
+		assert.NoError(t, err)
+
+		retiredKey, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey4)
+		assert.NoError(t, err)
+		revocation, err := signingKey.GetRevocationSignature(
+			3, "retired", time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		retiredKey.Revocations = append(retiredKey.Revocations, revocation)
+
+		_, err = verifySignature(openpgp.EntityList{&retiredKey.Entity}, data, []byte(signature))
+		assert.NoError(t, err)
+	})
+
+	t.Run("reports a signer whose key had expired when they signed", func(t *testing.T) {
+		signature, err := signingKey.MakeArmoredDetachedSignature(data)
+		assert.NoError(t, err)
+
+		expiredKey, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey4)
+		assert.NoError(t, err)
+		oneSecond := uint32(1)
+		for _, id := range expiredKey.Identities {
+			id.SelfSignature.KeyLifetimeSecs = &oneSecond
+		}
+
+		_, err = verifySignature(openpgp.EntityList{&expiredKey.Entity}, data, []byte(signature))
+		_, isNotValid := err.(errSignerKeyNotValid)
+		assert.Equal(t, true, isNotValid)
+	})
+
+	t.Run("errors if there's no signature", func(t *testing.T) {
+		_, err := verifySignature(keyring, data, nil)
+		assert.GotError(t, err)
+	})
+}
+
+func TestDescribeSigner(t *testing.T) {
+	memberships := []userpackage.GroupedMembership{
+		{
+			Team: team.Team{
+				UUID: uuid.Must(uuid.NewV4()),
+				Name: "Kiffix",
+				People: []team.Person{
+					{Email: "alice@example.com", Fingerprint: exampledata.ExampleFingerprint2, IsAdmin: true},
+					{Email: "bob@example.com", Fingerprint: exampledata.ExampleFingerprint3},
+				},
+			},
+		},
+	}
+
+	t.Run("describes a team admin", func(t *testing.T) {
+		lines := describeSigner(exampledata.ExampleFingerprint2, memberships, nil)
+		assert.Equal(t, 1, len(lines))
+		assert.Equal(t, true, strings.HasPrefix(lines[0], "alice@example.com is a team admin in"))
+	})
+
+	t.Run("returns nothing for a stranger", func(t *testing.T) {
+		lines := describeSigner(exampledata.ExampleFingerprint4, memberships, nil)
+		assert.Equal(t, 0, len(lines))
+	})
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package gpgwrapper
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/natefinch/atomic"
+)
+
+// ConfGroup is a `group` line in gpg.conf. Encrypting to the group's name encrypts to all of
+// its keys.
+type ConfGroup struct {
+	Name         string
+	Fingerprints []fpr.Fingerprint
+}
+
+// SetConfGroups replaces the group lines Fluidkeys manages in gpg.conf with the given groups,
+// leaving the rest of the file alone. If there are no groups, the lines are removed.
+func (g *GnuPG) SetConfGroups(groups []ConfGroup) (changed bool, err error) {
+	return g.updateConf(func(gpgConf string) string {
+		return addGroupLinesWithoutRepeating(gpgConf, groups)
+	})
+}
+
+// RemoveConfGroups removes the group lines Fluidkeys manages from gpg.conf, if present.
+func (g *GnuPG) RemoveConfGroups() (removed bool, err error) {
+	return g.updateConf(removeGroupLines)
+}
+
+func (g *GnuPG) updateConf(update func(string) string) (changed bool, err error) {
+	homeDir, err := g.HomeDir()
+	if err != nil {
+		return false, fmt.Errorf("couldn't get GnuPG home directory: %v", err)
+	}
+	filename := filepath.Join(homeDir, "gpg.conf")
+
+	current, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("error reading %s: %v", filename, err)
+	}
+
+	updated := update(string(current))
+	if updated == string(current) {
+		return false, nil
+	}
+
+	if err := atomic.WriteFile(filename, bytes.NewBufferString(updated)); err != nil {
+		return false, fmt.Errorf("error writing %s: %v", filename, err)
+	}
+	return true, nil
+}
+
+func addGroupLinesWithoutRepeating(gpgConf string, groups []ConfGroup) string {
+	removed := removeGroupLines(gpgConf)
+	if len(groups) == 0 {
+		return removed
+	}
+
+	if isEmpty(removed) {
+		return formatGroupLines(groups)
+	}
+
+	if !strings.HasSuffix(removed, "\n") {
+		removed += "\n"
+	}
+	return removed + "\n" + formatGroupLines(groups)
+}
+
+// removeGroupLines removes everything from the start marker to the end marker. If the end marker
+// is missing, gpg.conf is left alone, rather than risk removing the user's own lines.
+func removeGroupLines(gpgConf string) string {
+	start := strings.Index(gpgConf, groupLinesStart)
+	if start == -1 {
+		return gpgConf
+	}
+	end := strings.Index(gpgConf[start:], groupLinesEnd)
+	if end == -1 {
+		return gpgConf
+	}
+	end += start + len(groupLinesEnd)
+
+	result := gpgConf[:start] + strings.TrimPrefix(gpgConf[end:], "\n")
+	if isEmpty(result) {
+		return ""
+	}
+	return strings.TrimRight(result, "\n") + "\n"
+}
+
+func formatGroupLines(groups []ConfGroup) string {
+	lines := groupLinesStart + groupLinesComment
+	for _, group := range groups {
+		hexFingerprints := []string{}
+		for _, fingerprint := range group.Fingerprints {
+			hexFingerprints = append(hexFingerprints, fingerprint.Hex())
+		}
+		lines += "group " + group.Name + " = " + strings.Join(hexFingerprints, " ") + "\n"
+	}
+	return lines + groupLinesEnd + "\n"
+}
+
+func isEmpty(gpgConf string) bool {
+	return strings.Trim(gpgConf, "\n") == ""
+}
+
+const (
+	groupLinesStart = "# BEGIN Fluidkeys team groups\n"
+
+	groupLinesComment = "" +
+		"# Fluidkeys updates these lines when fetching your teams: don't edit them.\n" +
+		"# To remove them, set gpg_conf_groups = false in your Fluidkeys config file.\n"
+
+	groupLinesEnd = "# END Fluidkeys team groups"
+)
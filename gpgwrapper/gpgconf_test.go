@@ -0,0 +1,107 @@
+package gpgwrapper
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+)
+
+var exampleGroups = []ConfGroup{
+	{
+		Name: "kiffix",
+		Fingerprints: []fpr.Fingerprint{
+			exampledata.ExampleFingerprint2, exampledata.ExampleFingerprint3,
+		},
+	},
+	{
+		Name:         "kiffix-ops",
+		Fingerprints: []fpr.Fingerprint{exampledata.ExampleFingerprint3},
+	},
+}
+
+var exampleGroupLines = "# BEGIN Fluidkeys team groups\n" +
+	"# Fluidkeys updates these lines when fetching your teams: don't edit them.\n" +
+	"# To remove them, set gpg_conf_groups = false in your Fluidkeys config file.\n" +
+	"group kiffix = " + exampledata.ExampleFingerprint2.Hex() + " " +
+	exampledata.ExampleFingerprint3.Hex() + "\n" +
+	"group kiffix-ops = " + exampledata.ExampleFingerprint3.Hex() + "\n" +
+	"# END Fluidkeys team groups\n"
+
+func TestAddGroupLinesWithoutRepeating(t *testing.T) {
+	t.Run("to an empty gpg.conf", func(t *testing.T) {
+		assert.Equal(t, exampleGroupLines, addGroupLinesWithoutRepeating("", exampleGroups))
+	})
+
+	t.Run("after the user's own lines", func(t *testing.T) {
+		got := addGroupLinesWithoutRepeating("use-agent\nkeyserver foo", exampleGroups)
+		assert.Equal(t, "use-agent\nkeyserver foo\n\n"+exampleGroupLines, got)
+	})
+
+	t.Run("replaces existing group lines", func(t *testing.T) {
+		existing := "use-agent\n\n" + exampleGroupLines
+		got := addGroupLinesWithoutRepeating(existing, exampleGroups[:1])
+
+		assert.Equal(t, "use-agent\n\n"+formatGroupLines(exampleGroups[:1]), got)
+	})
+
+	t.Run("with no groups, removes existing group lines", func(t *testing.T) {
+		got := addGroupLinesWithoutRepeating("use-agent\n\n"+exampleGroupLines, nil)
+		assert.Equal(t, "use-agent\n", got)
+	})
+}
+
+func TestRemoveGroupLines(t *testing.T) {
+	t.Run("keeps lines before and after", func(t *testing.T) {
+		got := removeGroupLines("use-agent\n" + exampleGroupLines + "armor\n")
+		assert.Equal(t, "use-agent\narmor\n", got)
+	})
+
+	t.Run("leaves gpg.conf without group lines alone", func(t *testing.T) {
+		assert.Equal(t, "use-agent\n", removeGroupLines("use-agent\n"))
+	})
+
+	t.Run("leaves gpg.conf alone if the end marker is missing", func(t *testing.T) {
+		broken := "use-agent\n# BEGIN Fluidkeys team groups\ngroup mine = ABCD\n"
+		assert.Equal(t, broken, removeGroupLines(broken))
+	})
+
+	t.Run("returns empty string if nothing else is left", func(t *testing.T) {
+		assert.Equal(t, "", removeGroupLines(exampleGroupLines))
+	})
+}
+
+func TestSetConfGroups(t *testing.T) {
+	gpg := makeGpgWithTempHome(t)
+	gpgConf := filepath.Join(gpg.homeDir, "gpg.conf")
+	assert.NoError(t, ioutil.WriteFile(gpgConf, []byte("use-agent\n"), 0600))
+
+	t.Run("adds group lines", func(t *testing.T) {
+		changed, err := gpg.SetConfGroups(exampleGroups)
+		assert.NoError(t, err)
+		assert.Equal(t, true, changed)
+
+		contents, err := ioutil.ReadFile(gpgConf)
+		assert.NoError(t, err)
+		assert.Equal(t, "use-agent\n\n"+exampleGroupLines, string(contents))
+	})
+
+	t.Run("doesn't change an up to date gpg.conf", func(t *testing.T) {
+		changed, err := gpg.SetConfGroups(exampleGroups)
+		assert.NoError(t, err)
+		assert.Equal(t, false, changed)
+	})
+
+	t.Run("removes group lines", func(t *testing.T) {
+		removed, err := gpg.RemoveConfGroups()
+		assert.NoError(t, err)
+		assert.Equal(t, true, removed)
+
+		contents, err := ioutil.ReadFile(gpgConf)
+		assert.NoError(t, err)
+		assert.Equal(t, "use-agent\n", string(contents))
+	})
+}
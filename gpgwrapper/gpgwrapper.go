@@ -79,6 +79,17 @@ func Load() (*GnuPG, error) {
 	return &GnuPG{fullGpgPath: gpgBinary}, nil
 }
 
+// LoadWithHomeDir is like Load, but uses the given GnuPG home directory instead of the user's
+// default one.
+func LoadWithHomeDir(homeDir string) (*GnuPG, error) {
+	g, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	g.homeDir = homeDir
+	return g, nil
+}
+
 // Version returns the GnuPG version string, e.g. "1.2.3"
 func (g *GnuPG) Version() (string, error) {
 	outString, _, err := g.run("", "--version")
@@ -139,6 +150,19 @@ func (g *GnuPG) ImportArmoredKey(armoredKey string) error {
 	return nil
 }
 
+// DeletePublicKey removes the public key with the given fingerprint from the GPG key ring.
+// GnuPG refuses to delete a public key while the key ring has its secret key.
+func (g *GnuPG) DeletePublicKey(fingerprint fpr.Fingerprint) error {
+	_, stderr, err := g.run("", "--yes", "--delete-keys", fingerprint.Hex())
+	if err != nil {
+		if strings.Contains(stderr, noPublicKey) || strings.Contains(stderr, "not found") {
+			return fmt.Errorf("no such key %s", fingerprint.Hex())
+		}
+		return err
+	}
+	return nil
+}
+
 // ListSecretKeys lists the secret(private) keys in the users key ring.
 func (g *GnuPG) ListSecretKeys() ([]KeyListing, error) {
 	args := []string{
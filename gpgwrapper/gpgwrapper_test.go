@@ -180,6 +180,29 @@ func TestListPublicKeys(t *testing.T) {
 
 }
 
+func TestDeletePublicKey(t *testing.T) {
+	gpg := makeGpgWithTempHome(t)
+	gpg.ImportArmoredKey(exampledata.ExamplePublicKey2)
+	gpg.ImportArmoredKey(exampledata.ExamplePublicKey3)
+
+	t.Run("removes only the given key", func(t *testing.T) {
+		assert.NoError(t, gpg.DeletePublicKey(exampledata.ExampleFingerprint2))
+
+		publicKeys, err := gpg.ListPublicKeys("test2@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(publicKeys))
+
+		publicKeys, err = gpg.ListPublicKeys("test3@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(publicKeys))
+	})
+
+	t.Run("returns an error if the key isn't in the key ring", func(t *testing.T) {
+		err := gpg.DeletePublicKey(exampledata.ExampleFingerprint4)
+		assert.GotError(t, err)
+	})
+}
+
 func TestExportPublicKey(t *testing.T) {
 	gpg := makeGpgWithTempHome(t)
 	gpg.ImportArmoredKey(ExamplePublicKey)
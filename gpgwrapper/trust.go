@@ -2,6 +2,7 @@ package gpgwrapper
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
@@ -24,3 +25,96 @@ func (g *GnuPG) TrustUltimately(fingerprint fpr.Fingerprint) error {
 
 	return nil
 }
+
+// OwnertrustLevel is how far GnuPG trusts the owner of a key to certify other keys, as
+// exchanged with `gpg --export-ownertrust` and `gpg --import-ownertrust`.
+type OwnertrustLevel int
+
+const (
+	// OwnertrustUnknown means the key isn't in the trust database at all.
+	OwnertrustUnknown OwnertrustLevel = 0
+
+	// OwnertrustUndefined is "I don't know or won't say": certifications made by the key
+	// don't count towards validity.
+	OwnertrustUndefined OwnertrustLevel = 2
+
+	// OwnertrustNever means certifications made by the key are ignored.
+	OwnertrustNever OwnertrustLevel = 3
+
+	// OwnertrustMarginal means certifications made by the key count partially.
+	OwnertrustMarginal OwnertrustLevel = 4
+
+	// OwnertrustFull means a certification made by the key is enough to make another key
+	// valid.
+	OwnertrustFull OwnertrustLevel = 5
+
+	// OwnertrustUltimate is normally reserved for the user's own keys.
+	OwnertrustUltimate OwnertrustLevel = 6
+)
+
+// Ownertrust returns the ownertrust level GnuPG has for the given key, or OwnertrustUnknown
+// if it has none.
+func (g *GnuPG) Ownertrust(fingerprint fpr.Fingerprint) (OwnertrustLevel, error) {
+	stdout, _, err := g.run("", "--export-ownertrust")
+	if err != nil {
+		return OwnertrustUnknown, err
+	}
+	return parseOwnertrust(stdout, fingerprint), nil
+}
+
+// SetOwnertrust sets the ownertrust level of the given key. Setting it to OwnertrustUndefined
+// takes away trust given previously.
+func (g *GnuPG) SetOwnertrust(fingerprint fpr.Fingerprint, level OwnertrustLevel) error {
+	if level == OwnertrustUnknown {
+		// gpg ignores lines with level 0, so this would silently do nothing
+		return fmt.Errorf("can't set ownertrust to unknown, use undefined instead")
+	}
+
+	line := fmt.Sprintf("%s:%d:\n", fingerprint.Hex(), level)
+	_, _, err := g.run(line, "--import-ownertrust")
+	return err
+}
+
+// TofuPolicy is a trust-on-first-use policy, used when GnuPG's trust model is `tofu` or
+// `tofu+pgp`.
+type TofuPolicy string
+
+const (
+	// TofuPolicyGood means the key is treated as fully valid for its user IDs.
+	TofuPolicyGood TofuPolicy = "good"
+
+	// TofuPolicyAuto is GnuPG's default: validity is built up from how the key is used.
+	TofuPolicyAuto TofuPolicy = "auto"
+)
+
+// SetTofuPolicy sets the TOFU policy for all the user IDs on the given key.
+func (g *GnuPG) SetTofuPolicy(fingerprint fpr.Fingerprint, policy TofuPolicy) error {
+	_, stderr, err := g.run("", "--tofu-policy", string(policy), fingerprint.Hex())
+	if err != nil {
+		if strings.Contains(stderr, noPublicKey) {
+			return fmt.Errorf("no such key %s", fingerprint.Hex())
+		}
+		return err
+	}
+	return nil
+}
+
+// parseOwnertrust finds the given key in the output of `gpg --export-ownertrust`, which has
+// lines like `A999B7498D1A8DC473E53C92309F635DAD1B5517:6:`
+func parseOwnertrust(exported string, fingerprint fpr.Fingerprint) OwnertrustLevel {
+	for _, line := range strings.Split(exported, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(strings.TrimSpace(line), ":")
+		if len(parts) < 2 || parts[0] != fingerprint.Hex() {
+			continue
+		}
+		level, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return OwnertrustUnknown
+		}
+		return OwnertrustLevel(level)
+	}
+	return OwnertrustUnknown
+}
@@ -43,3 +43,63 @@ func TestTrustUltimately(t *testing.T) {
 	})
 
 }
+
+func TestSetOwnertrust(t *testing.T) {
+	gpg := makeGpgWithTempHome(t)
+	assert.NoError(t, gpg.ImportArmoredKey(exampledata.ExamplePublicKey2))
+
+	t.Run("starts off unknown", func(t *testing.T) {
+		level, err := gpg.Ownertrust(exampledata.ExampleFingerprint2)
+		assert.NoError(t, err)
+		assert.Equal(t, OwnertrustUnknown, level)
+	})
+
+	t.Run("sets ownertrust to full", func(t *testing.T) {
+		assert.NoError(t, gpg.SetOwnertrust(exampledata.ExampleFingerprint2, OwnertrustFull))
+
+		level, err := gpg.Ownertrust(exampledata.ExampleFingerprint2)
+		assert.NoError(t, err)
+		assert.Equal(t, OwnertrustFull, level)
+	})
+
+	t.Run("sets ownertrust back to undefined", func(t *testing.T) {
+		assert.NoError(t, gpg.SetOwnertrust(exampledata.ExampleFingerprint2, OwnertrustUndefined))
+
+		level, err := gpg.Ownertrust(exampledata.ExampleFingerprint2)
+		assert.NoError(t, err)
+		assert.Equal(t, OwnertrustUndefined, level)
+	})
+
+	t.Run("refuses to set ownertrust to unknown", func(t *testing.T) {
+		assert.GotError(t, gpg.SetOwnertrust(exampledata.ExampleFingerprint2, OwnertrustUnknown))
+	})
+}
+
+func TestSetTofuPolicy(t *testing.T) {
+	gpg := makeGpgWithTempHome(t)
+	assert.NoError(t, gpg.ImportArmoredKey(exampledata.ExamplePublicKey2))
+
+	t.Run("sets policy to good", func(t *testing.T) {
+		assert.NoError(t, gpg.SetTofuPolicy(exampledata.ExampleFingerprint2, TofuPolicyGood))
+	})
+
+	t.Run("sets policy back to auto", func(t *testing.T) {
+		assert.NoError(t, gpg.SetTofuPolicy(exampledata.ExampleFingerprint2, TofuPolicyAuto))
+	})
+}
+
+func TestParseOwnertrust(t *testing.T) {
+	exported := "# List of assigned trustvalues, created Mon 01 Jan 2018\n" +
+		"# (Use \"gpg --import-ownertrust\" to restore them)\n" +
+		exampledata.ExampleFingerprint3.Hex() + ":6:\n" +
+		exampledata.ExampleFingerprint2.Hex() + ":5:\n"
+
+	t.Run("finds the level for a key", func(t *testing.T) {
+		assert.Equal(t, OwnertrustFull, parseOwnertrust(exported, exampledata.ExampleFingerprint2))
+		assert.Equal(t, OwnertrustUltimate, parseOwnertrust(exported, exampledata.ExampleFingerprint3))
+	})
+
+	t.Run("returns unknown for a missing key", func(t *testing.T) {
+		assert.Equal(t, OwnertrustUnknown, parseOwnertrust(exported, exampledata.ExampleFingerprint4))
+	})
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package keycache stores the public keys Fluidkeys has fetched for other people, so that
+// commands can use them offline and revalidate them cheaply against the key directory.
+package keycache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/natefinch/atomic"
+)
+
+// Cache is a directory of public keys, keyed by fingerprint. Each key is stored with the
+// ETag and Last-Modified headers it was served with and the time it was last fetched:
+//
+// keycache/<FINGERPRINT>.asc
+// keycache/<FINGERPRINT>.json
+type Cache struct {
+	directory string
+}
+
+// Fetcher gets a public key if it's changed since the copy described by the given validators,
+// for example apiclient.Client or any keydirectory.KeyDirectory.
+type Fetcher interface {
+	GetPublicKeyByFingerprintIfModified(fingerprint fpr.Fingerprint,
+		validators apiclient.CacheValidators) (*pgpkey.PgpKey, apiclient.CacheValidators, error)
+}
+
+// ErrNotCached means the cache doesn't have a copy of the requested key.
+var ErrNotCached = fmt.Errorf("key not in cache")
+
+// metadata records where a cached key came from and how fresh it is.
+type metadata struct {
+	Validators apiclient.CacheValidators `json:"validators"`
+	FetchedAt  time.Time                 `json:"fetchedAt"`
+}
+
+// New returns the cache in the given Fluidkeys directory.
+func New(fluidkeysDirectory string) *Cache {
+	return &Cache{directory: filepath.Join(fluidkeysDirectory, "keycache")}
+}
+
+// Get returns the cached copy of the key with the given fingerprint, or ErrNotCached.
+func (c *Cache) Get(fingerprint fpr.Fingerprint) (*pgpkey.PgpKey, error) {
+	armoredKey, err := ioutil.ReadFile(c.keyFilename(fingerprint))
+	if os.IsNotExist(err) {
+		return nil, ErrNotCached
+	} else if err != nil {
+		return nil, err
+	}
+
+	key, err := pgpkey.LoadFromArmoredPublicKey(string(armoredKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cached key %s: %v", fingerprint, err)
+	}
+	if key.Fingerprint() != fingerprint {
+		return nil, fmt.Errorf("%s contains the wrong key", c.keyFilename(fingerprint))
+	}
+	return key, nil
+}
+
+// FetchedAt returns when the key with the given fingerprint was last fetched or revalidated,
+// or ErrNotCached.
+func (c *Cache) FetchedAt(fingerprint fpr.Fingerprint) (time.Time, error) {
+	meta, err := c.loadMetadata(fingerprint)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return meta.FetchedAt, nil
+}
+
+// Store merges the key into any cached copy, so subkeys and revocations already in the cache
+// are kept, then saves it along with the validators it was served with. It returns the
+// merged key.
+func (c *Cache) Store(key *pgpkey.PgpKey, validators apiclient.CacheValidators, now time.Time) (
+	*pgpkey.PgpKey, error) {
+
+	merged := key
+	existing, err := c.Get(key.Fingerprint())
+	switch {
+	case err == nil:
+		if merged, err = pgpkey.Merge(existing, key); err != nil {
+			return nil, err
+		}
+
+	case err != ErrNotCached:
+		return nil, err
+	}
+
+	armoredKey, err := merged.Armor()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(c.directory, 0700); err != nil {
+		return nil, err
+	}
+	if err := atomic.WriteFile(
+		c.keyFilename(key.Fingerprint()), strings.NewReader(armoredKey)); err != nil {
+		return nil, err
+	}
+	if err := c.saveMetadata(
+		key.Fingerprint(), metadata{Validators: validators, FetchedAt: now}); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// Refresh asks fetcher for the key with the given fingerprint, sending the validators of the
+// cached copy (if any) so an unchanged key isn't downloaded again. It stores and returns the
+// merged key, or the cached key if it hasn't changed.
+func (c *Cache) Refresh(fetcher Fetcher, fingerprint fpr.Fingerprint, now time.Time) (
+	*pgpkey.PgpKey, error) {
+
+	validators := apiclient.CacheValidators{}
+	cachedKey, err := c.Get(fingerprint)
+	switch {
+	case err == nil:
+		if meta, err := c.loadMetadata(fingerprint); err == nil {
+			validators = meta.Validators
+		}
+
+	case err != ErrNotCached:
+		return nil, err
+	}
+
+	key, newValidators, err := fetcher.GetPublicKeyByFingerprintIfModified(fingerprint, validators)
+	if err == apiclient.ErrNotModified && cachedKey != nil {
+		if err := c.saveMetadata(
+			fingerprint, metadata{Validators: validators, FetchedAt: now}); err != nil {
+			return nil, err
+		}
+		return cachedKey, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return c.Store(key, newValidators, now)
+}
+
+// Delete removes the cached copy of the key with the given fingerprint, if there is one.
+func (c *Cache) Delete(fingerprint fpr.Fingerprint) error {
+	for _, filename := range []string{
+		c.keyFilename(fingerprint), c.metadataFilename(fingerprint)} {
+
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) loadMetadata(fingerprint fpr.Fingerprint) (*metadata, error) {
+	data, err := ioutil.ReadFile(c.metadataFilename(fingerprint))
+	if os.IsNotExist(err) {
+		return nil, ErrNotCached
+	} else if err != nil {
+		return nil, err
+	}
+
+	meta := metadata{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", c.metadataFilename(fingerprint), err)
+	}
+	return &meta, nil
+}
+
+func (c *Cache) saveMetadata(fingerprint fpr.Fingerprint, meta metadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomic.WriteFile(c.metadataFilename(fingerprint), strings.NewReader(string(data)))
+}
+
+func (c *Cache) keyFilename(fingerprint fpr.Fingerprint) string {
+	return filepath.Join(c.directory, fingerprint.Hex()+".asc")
+}
+
+func (c *Cache) metadataFilename(fingerprint fpr.Fingerprint) string {
+	return filepath.Join(c.directory, fingerprint.Hex()+".json")
+}
@@ -0,0 +1,113 @@
+package keycache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/testhelpers"
+)
+
+func TestRefresh(t *testing.T) {
+	now := time.Date(2019, 6, 15, 0, 0, 0, 0, time.UTC)
+	later := now.Add(time.Duration(24) * time.Hour)
+
+	cache := New(testhelpers.Maketemp(t))
+	fetcher := &mockFetcher{key: loadPublicKey(t), etag: `"v1"`}
+
+	t.Run("Get returns ErrNotCached before anything is fetched", func(t *testing.T) {
+		_, err := cache.Get(exampledata.ExampleFingerprint4)
+		assert.Equal(t, ErrNotCached, err)
+	})
+
+	t.Run("first refresh downloads and stores the key", func(t *testing.T) {
+		key, err := cache.Refresh(fetcher, exampledata.ExampleFingerprint4, now)
+		assert.NoError(t, err)
+		assert.Equal(t, exampledata.ExampleFingerprint4, key.Fingerprint())
+		assert.Equal(t, apiclient.CacheValidators{}, fetcher.gotValidators)
+
+		cachedKey, err := cache.Get(exampledata.ExampleFingerprint4)
+		assert.NoError(t, err)
+		assert.Equal(t, exampledata.ExampleFingerprint4, cachedKey.Fingerprint())
+	})
+
+	t.Run("second refresh revalidates with the etag", func(t *testing.T) {
+		key, err := cache.Refresh(fetcher, exampledata.ExampleFingerprint4, later)
+		assert.NoError(t, err)
+		assert.Equal(t, exampledata.ExampleFingerprint4, key.Fingerprint())
+		assert.Equal(t, `"v1"`, fetcher.gotValidators.ETag)
+
+		fetchedAt, err := cache.FetchedAt(exampledata.ExampleFingerprint4)
+		assert.NoError(t, err)
+		assert.Equal(t, later, fetchedAt.UTC())
+	})
+
+	t.Run("revocations in the cache aren't lost when the key is updated", func(t *testing.T) {
+		privateKey, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(
+			exampledata.ExamplePrivateKey4, "test4")
+		assert.NoError(t, err)
+		revocation, err := privateKey.GetRevocationSignature(0, "no reason", now)
+		assert.NoError(t, err)
+		privateKey.Revocations = append(privateKey.Revocations, revocation)
+		armored, err := privateKey.Armor()
+		assert.NoError(t, err)
+		revokedKey, err := pgpkey.LoadFromArmoredPublicKey(armored)
+		assert.NoError(t, err)
+
+		_, err = cache.Store(revokedKey, apiclient.CacheValidators{ETag: `"v2"`}, now)
+		assert.NoError(t, err)
+
+		fetcher.etag = `"v3"` // the server now serves the key without the revocation
+		key, err := cache.Refresh(fetcher, exampledata.ExampleFingerprint4, later)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(key.Revocations))
+
+		cachedKey, err := cache.Get(exampledata.ExampleFingerprint4)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(cachedKey.Revocations))
+	})
+}
+
+func TestDelete(t *testing.T) {
+	cache := New(testhelpers.Maketemp(t))
+	_, err := cache.Store(loadPublicKey(t), apiclient.CacheValidators{}, time.Now())
+	assert.NoError(t, err)
+
+	assert.NoError(t, cache.Delete(exampledata.ExampleFingerprint4))
+
+	_, err = cache.Get(exampledata.ExampleFingerprint4)
+	assert.Equal(t, ErrNotCached, err)
+	_, err = cache.FetchedAt(exampledata.ExampleFingerprint4)
+	assert.Equal(t, ErrNotCached, err)
+
+	t.Run("doesn't error if the key isn't cached", func(t *testing.T) {
+		assert.NoError(t, cache.Delete(exampledata.ExampleFingerprint4))
+	})
+}
+
+type mockFetcher struct {
+	key           *pgpkey.PgpKey
+	etag          string
+	gotValidators apiclient.CacheValidators
+}
+
+func (m *mockFetcher) GetPublicKeyByFingerprintIfModified(fingerprint fpr.Fingerprint,
+	validators apiclient.CacheValidators) (*pgpkey.PgpKey, apiclient.CacheValidators, error) {
+
+	m.gotValidators = validators
+	if validators.ETag == m.etag {
+		return nil, validators, apiclient.ErrNotModified
+	}
+	return m.key, apiclient.CacheValidators{ETag: m.etag}, nil
+}
+
+func loadPublicKey(t *testing.T) *pgpkey.PgpKey {
+	t.Helper()
+	key, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey4)
+	assert.NoError(t, err)
+	return key
+}
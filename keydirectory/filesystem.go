@@ -0,0 +1,657 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package keydirectory
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fluidkeys/api/v1structs"
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/keylookup"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/gofrs/uuid"
+	"github.com/natefinch/atomic"
+)
+
+// Filesystem is a key directory stored in a directory that the whole team can read and write,
+// for example a network share or a git repository. It's laid out like this:
+//
+// keys/<FINGERPRINT>.asc
+// secrets/<FINGERPRINT>/<secret uuid>.json
+// teams/<team uuid>/roster.toml
+// teams/<team uuid>/roster.toml.asc
+// teams/<team uuid>/requests/<request uuid>.json
+// teams/<team uuid>/requests-to-leave/<request uuid>.json
+//
+// It makes some of the checks the Fluidkeys server makes, for example that rosters are signed by
+// an admin of the team, but only in the client: anyone who can write to the directory can change
+// the files directly. Unlike the Fluidkeys server, it doesn't verify email addresses.
+type Filesystem struct {
+	directory string
+	sync      syncer
+}
+
+// syncer keeps the local directory in sync with everyone else's copy, for example using git.
+type syncer interface {
+	// pull fetches changes made by others before reading.
+	pull() error
+
+	// push shares local changes with others after writing.
+	push(description string) error
+}
+
+// NewFilesystem returns a key directory stored in the given directory, for example on a
+// network share.
+func NewFilesystem(directory string) *Filesystem {
+	return &Filesystem{directory: directory}
+}
+
+// VerifiesEmails returns false: anyone who can write to the directory can add a key with any
+// email address.
+func (f *Filesystem) VerifiesEmails() bool {
+	return false
+}
+
+// GetPublicKey returns the armored public key with a user ID matching the email, or
+// apiclient.ErrPublicKeyNotFound. The user ID is whatever the key's owner put in it: the email
+// address isn't verified.
+func (f *Filesystem) GetPublicKey(email string) (string, error) {
+	if err := f.pull(); err != nil {
+		return "", err
+	}
+
+	filenames, err := filepath.Glob(filepath.Join(f.directory, "keys", "*.asc"))
+	if err != nil {
+		return "", err
+	}
+
+	for _, filename := range filenames {
+		armoredKey, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+		key, err := pgpkey.LoadFromArmoredPublicKey(string(armoredKey))
+		if err != nil {
+			continue
+		}
+		if len(keylookup.FilterByEmail([]*pgpkey.PgpKey{key}, email)) > 0 {
+			return string(armoredKey), nil
+		}
+	}
+	return "", apiclient.ErrPublicKeyNotFound
+}
+
+// GetPublicKeyByFingerprint returns the public key with the given fingerprint, or
+// apiclient.ErrPublicKeyNotFound.
+func (f *Filesystem) GetPublicKeyByFingerprint(fingerprint fpr.Fingerprint) (*pgpkey.PgpKey, error) {
+	if err := f.pull(); err != nil {
+		return nil, err
+	}
+	return f.loadKey(fingerprint)
+}
+
+// GetPublicKeyByFingerprintIfModified returns the public key with the given fingerprint, or
+// apiclient.ErrNotModified if its file hasn't changed since the copy described by validators.
+// The ETag is a hash of the file, since modification times change whenever the directory is
+// checked out.
+func (f *Filesystem) GetPublicKeyByFingerprintIfModified(
+	fingerprint fpr.Fingerprint, validators apiclient.CacheValidators) (
+	*pgpkey.PgpKey, apiclient.CacheValidators, error) {
+
+	if err := f.pull(); err != nil {
+		return nil, apiclient.CacheValidators{}, err
+	}
+
+	armoredKey, err := ioutil.ReadFile(f.keyFilename(fingerprint))
+	if os.IsNotExist(err) {
+		return nil, apiclient.CacheValidators{}, apiclient.ErrPublicKeyNotFound
+	} else if err != nil {
+		return nil, apiclient.CacheValidators{}, err
+	}
+
+	newValidators := apiclient.CacheValidators{
+		ETag: fmt.Sprintf(`"%x"`, sha256.Sum256(armoredKey)),
+	}
+	if validators.ETag == newValidators.ETag {
+		return nil, validators, apiclient.ErrNotModified
+	}
+
+	key, err := f.loadKey(fingerprint)
+	if err != nil {
+		return nil, apiclient.CacheValidators{}, err
+	}
+	return key, newValidators, nil
+}
+
+// UpsertPublicKey stores the public key, which must be the public part of privateKey.
+func (f *Filesystem) UpsertPublicKey(armoredPublicKey string, privateKey *pgpkey.PgpKey) error {
+	key, err := pgpkey.LoadFromArmoredPublicKey(armoredPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %v", err)
+	}
+	if key.Fingerprint() != privateKey.Fingerprint() {
+		return fmt.Errorf("public key %s doesn't match private key %s",
+			key.Fingerprint(), privateKey.Fingerprint())
+	}
+
+	return f.write("Upload public key "+key.Fingerprint().Hex(), func() error {
+		return writeFile(f.keyFilename(key.Fingerprint()), []byte(armoredPublicKey))
+	})
+}
+
+// CreateSecret stores the secret for the recipient, along with metadata (the secret's UUID)
+// encrypted to their key.
+func (f *Filesystem) CreateSecret(recipientFingerprint fpr.Fingerprint,
+	armoredEncryptedSecret string) error {
+
+	secretUUID, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+
+	return f.write("Send secret to "+recipientFingerprint.Hex(), func() error {
+		recipientKey, err := f.loadKey(recipientFingerprint)
+		if err != nil {
+			return err
+		}
+
+		metadata, err := json.Marshal(v1structs.SecretMetadata{SecretUUID: secretUUID.String()})
+		if err != nil {
+			return err
+		}
+		encryptedMetadata, err := recipientKey.EncryptArmored(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt metadata: %v", err)
+		}
+
+		return writeJSON(f.secretFilename(recipientFingerprint, secretUUID.String()),
+			v1structs.Secret{
+				EncryptedMetadata: encryptedMetadata,
+				EncryptedContent:  armoredEncryptedSecret,
+			},
+		)
+	})
+}
+
+// ListSecrets returns the secrets sent to the given key.
+func (f *Filesystem) ListSecrets(fingerprint fpr.Fingerprint) ([]v1structs.Secret, error) {
+	if err := f.pull(); err != nil {
+		return nil, err
+	}
+
+	filenames, err := filepath.Glob(f.secretFilename(fingerprint, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := []v1structs.Secret{}
+	for _, filename := range filenames {
+		secret := v1structs.Secret{}
+		if err := readJSON(filename, &secret); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// DeleteSecret deletes a secret sent to the given key.
+func (f *Filesystem) DeleteSecret(fingerprint fpr.Fingerprint, secretUUID string) error {
+	if _, err := uuid.FromString(secretUUID); err != nil {
+		return fmt.Errorf("invalid secret UUID: %v", err)
+	}
+
+	return f.write("Delete secret "+secretUUID, func() error {
+		return os.Remove(f.secretFilename(fingerprint, secretUUID))
+	})
+}
+
+// UpsertTeam creates or updates a team. The roster must be signed by signerFingerprint, which
+// must be an admin of the new roster and, for an existing team, of the current roster too.
+func (f *Filesystem) UpsertTeam(roster string, rosterSignature string,
+	signerFingerprint fpr.Fingerprint) error {
+
+	newTeam, err := team.Load(roster, rosterSignature)
+	if err != nil {
+		return fmt.Errorf("invalid roster: %v", err)
+	}
+
+	description := fmt.Sprintf("Update team %s to version %d", newTeam.Name, newTeam.Version)
+
+	return f.write(description, func() error {
+		signingKey, err := f.loadKey(signerFingerprint)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key: %v", err)
+		}
+
+		if !newTeam.IsAdmin(signerFingerprint) {
+			return fmt.Errorf("signing key not in roster")
+		}
+		// co-signed rosters carry several signatures: the uploader must have made one of them
+		if err := team.VerifyRosterSignatures(roster, rosterSignature,
+			[]*pgpkey.PgpKey{signingKey}, 1); err != nil {
+
+			return fmt.Errorf("bad roster signature: %v", err)
+		}
+
+		existingTeam, existingRoster, _, err := f.loadTeam(newTeam.UUID)
+		if err == nil {
+			if !existingTeam.IsAdmin(signerFingerprint) {
+				return apiclient.ErrForbidden
+			}
+			if existingRoster != roster && newTeam.Version <= existingTeam.Version {
+				return fmt.Errorf("roster version must increase")
+			}
+		} else if err != apiclient.ErrTeamNotFound {
+			return err
+		}
+
+		filename := f.teamFilename(newTeam.UUID, rosterFilename)
+		if err := writeFile(filename, []byte(roster)); err != nil {
+			return err
+		}
+		return writeFile(filename+".asc", []byte(rosterSignature))
+	})
+}
+
+// GetTeamName returns the name of the team, or apiclient.ErrTeamNotFound.
+func (f *Filesystem) GetTeamName(teamUUID uuid.UUID) (string, error) {
+	if err := f.pull(); err != nil {
+		return "", err
+	}
+
+	t, _, _, err := f.loadTeam(teamUUID)
+	if err != nil {
+		return "", err
+	}
+	return t.Name, nil
+}
+
+// GetTeamRoster returns the roster and signature for the team, or apiclient.ErrForbidden if me
+// isn't a member of the team.
+func (f *Filesystem) GetTeamRoster(teamUUID uuid.UUID, me fpr.Fingerprint) (
+	roster string, signature string, err error) {
+
+	if err := f.pull(); err != nil {
+		return "", "", err
+	}
+
+	t, roster, signature, err := f.loadTeam(teamUUID)
+	if err != nil {
+		return "", "", err
+	}
+	if !t.Contains(me) {
+		return "", "", apiclient.ErrForbidden
+	}
+	return roster, signature, nil
+}
+
+// RequestToJoinTeam records a request for the key to join the team with the given email.
+func (f *Filesystem) RequestToJoinTeam(teamUUID uuid.UUID, fingerprint fpr.Fingerprint,
+	email string) error {
+
+	requestUUID, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+
+	return f.write("Request to join team "+teamUUID.String(), func() error {
+		if _, _, _, err := f.loadTeam(teamUUID); err != nil {
+			return err
+		}
+
+		key, err := f.loadKey(fingerprint)
+		if err != nil {
+			return err
+		}
+		if len(keylookup.FilterByEmail([]*pgpkey.PgpKey{key}, email)) == 0 {
+			return fmt.Errorf("key %s doesn't have email %s", fingerprint, email)
+		}
+
+		existingRequests, err := f.loadRequests(teamUUID)
+		if err != nil {
+			return err
+		}
+		for _, existing := range existingRequests {
+			if existing.Fingerprint == fingerprint.Uri() || strings.EqualFold(existing.Email, email) {
+				return fmt.Errorf("already got request to join team for %s", email)
+			}
+		}
+
+		return writeJSON(f.requestFilename(teamUUID, requestUUID.String()),
+			v1structs.RequestToJoinTeam{
+				UUID:        requestUUID.String(),
+				Fingerprint: fingerprint.Uri(),
+				Email:       email,
+			},
+		)
+	})
+}
+
+// ListRequestsToJoinTeam returns the requests to join the team. The requesting key must be an
+// admin of the team, otherwise it returns apiclient.ErrForbidden.
+func (f *Filesystem) ListRequestsToJoinTeam(teamUUID uuid.UUID, fingerprint fpr.Fingerprint) (
+	requestsToJoinTeam []team.RequestToJoinTeam, err error) {
+
+	if err := f.pull(); err != nil {
+		return nil, err
+	}
+
+	t, _, _, err := f.loadTeam(teamUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !t.IsAdmin(fingerprint) {
+		return nil, apiclient.ErrForbidden
+	}
+
+	storedRequests, err := f.loadRequests(teamUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stored := range storedRequests {
+		requestUUID, err := uuid.FromString(stored.UUID)
+		if err != nil {
+			continue
+		}
+		requestFingerprint, err := fpr.Parse(stored.Fingerprint)
+		if err != nil {
+			continue
+		}
+
+		requestsToJoinTeam = append(requestsToJoinTeam, team.RequestToJoinTeam{
+			UUID:        requestUUID,
+			TeamUUID:    teamUUID,
+			Email:       stored.Email,
+			Fingerprint: requestFingerprint,
+		})
+	}
+	return requestsToJoinTeam, nil
+}
+
+// DeleteRequestToJoinTeam deletes a request to join a team
+func (f *Filesystem) DeleteRequestToJoinTeam(teamUUID uuid.UUID, requestUUID uuid.UUID) error {
+	return f.write("Delete request to join team "+teamUUID.String(), func() error {
+		return os.Remove(f.requestFilename(teamUUID, requestUUID.String()))
+	})
+}
+
+// RequestToLeaveTeam records a request, signed by privateKey, to remove that key from the team.
+func (f *Filesystem) RequestToLeaveTeam(teamUUID uuid.UUID, privateKey *pgpkey.PgpKey) error {
+	requestUUID, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+
+	armoredSignedJSON, err := team.MakeRequestToLeaveSignedJSON(teamUUID, privateKey, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fingerprint := privateKey.Fingerprint()
+
+	return f.write("Request to leave team "+teamUUID.String(), func() error {
+		t, _, _, err := f.loadTeam(teamUUID)
+		if err != nil {
+			return err
+		}
+		if !t.Contains(fingerprint) {
+			return apiclient.ErrForbidden
+		}
+
+		existingRequests, err := f.loadRequestsToLeave(teamUUID)
+		if err != nil {
+			return err
+		}
+		for _, existing := range existingRequests {
+			if existing.Fingerprint == fingerprint.Uri() {
+				return fmt.Errorf("already got request to leave team for %s", fingerprint)
+			}
+		}
+
+		return writeJSON(f.requestToLeaveFilename(teamUUID, requestUUID.String()),
+			apiclient.RequestToLeaveTeam{
+				UUID:              requestUUID.String(),
+				Fingerprint:       fingerprint.Uri(),
+				ArmoredSignedJSON: armoredSignedJSON,
+			},
+		)
+	})
+}
+
+// ListRequestsToLeaveTeam returns the requests to leave the team. The requesting key must be
+// an admin of the team, otherwise it returns apiclient.ErrForbidden.
+func (f *Filesystem) ListRequestsToLeaveTeam(teamUUID uuid.UUID, fingerprint fpr.Fingerprint) (
+	requestsToLeaveTeam []team.RequestToLeaveTeam, err error) {
+
+	if err := f.pull(); err != nil {
+		return nil, err
+	}
+
+	t, _, _, err := f.loadTeam(teamUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !t.IsAdmin(fingerprint) {
+		return nil, apiclient.ErrForbidden
+	}
+
+	storedRequests, err := f.loadRequestsToLeave(teamUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stored := range storedRequests {
+		requestUUID, err := uuid.FromString(stored.UUID)
+		if err != nil {
+			continue
+		}
+		requestFingerprint, err := fpr.Parse(stored.Fingerprint)
+		if err != nil {
+			continue
+		}
+
+		requestsToLeaveTeam = append(requestsToLeaveTeam, team.RequestToLeaveTeam{
+			UUID:              requestUUID,
+			TeamUUID:          teamUUID,
+			Fingerprint:       requestFingerprint,
+			ArmoredSignedJSON: stored.ArmoredSignedJSON,
+		})
+	}
+	return requestsToLeaveTeam, nil
+}
+
+// DeleteRequestToLeaveTeam deletes a request to leave a team
+func (f *Filesystem) DeleteRequestToLeaveTeam(teamUUID uuid.UUID, requestUUID uuid.UUID) error {
+	return f.write("Delete request to leave team "+teamUUID.String(), func() error {
+		return os.Remove(f.requestToLeaveFilename(teamUUID, requestUUID.String()))
+	})
+}
+
+// Log does nothing: events are only used by the Fluidkeys server.
+func (f *Filesystem) Log(event apiclient.Event) error {
+	if event.Name == "" {
+		return fmt.Errorf("invalid event: name can't be empty")
+	}
+	return nil
+}
+
+func (f *Filesystem) pull() error {
+	if f.sync == nil {
+		return nil
+	}
+	return f.sync.pull()
+}
+
+// write pulls any changes, makes the change using writeFunc, then pushes it.
+func (f *Filesystem) write(description string, writeFunc func() error) error {
+	if err := f.pull(); err != nil {
+		return err
+	}
+	if err := writeFunc(); err != nil {
+		return err
+	}
+	if f.sync == nil {
+		return nil
+	}
+	return f.sync.push(description)
+}
+
+func (f *Filesystem) loadKey(fingerprint fpr.Fingerprint) (*pgpkey.PgpKey, error) {
+	armoredKey, err := ioutil.ReadFile(f.keyFilename(fingerprint))
+	if os.IsNotExist(err) {
+		return nil, apiclient.ErrPublicKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	key, err := pgpkey.LoadFromArmoredPublicKey(string(armoredKey))
+	if err != nil {
+		return nil, err
+	}
+	if key.Fingerprint() != fingerprint {
+		return nil, fmt.Errorf("%s contains the wrong key", f.keyFilename(fingerprint))
+	}
+	return key, nil
+}
+
+func (f *Filesystem) loadTeam(teamUUID uuid.UUID) (
+	t *team.Team, roster string, signature string, err error) {
+
+	filename := f.teamFilename(teamUUID, rosterFilename)
+
+	rosterBytes, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, "", "", apiclient.ErrTeamNotFound
+	} else if err != nil {
+		return nil, "", "", err
+	}
+
+	signatureBytes, err := ioutil.ReadFile(filename + ".asc")
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	t, err = team.Load(string(rosterBytes), string(signatureBytes))
+	if err != nil {
+		return nil, "", "", err
+	}
+	return t, string(rosterBytes), string(signatureBytes), nil
+}
+
+func (f *Filesystem) loadRequests(teamUUID uuid.UUID) ([]v1structs.RequestToJoinTeam, error) {
+	filenames, err := filepath.Glob(f.requestFilename(teamUUID, "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(filenames)
+
+	requests := []v1structs.RequestToJoinTeam{}
+	for _, filename := range filenames {
+		request := v1structs.RequestToJoinTeam{}
+		if err := readJSON(filename, &request); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+func (f *Filesystem) loadRequestsToLeave(teamUUID uuid.UUID) (
+	[]apiclient.RequestToLeaveTeam, error) {
+
+	filenames, err := filepath.Glob(f.requestToLeaveFilename(teamUUID, "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(filenames)
+
+	requests := []apiclient.RequestToLeaveTeam{}
+	for _, filename := range filenames {
+		request := apiclient.RequestToLeaveTeam{}
+		if err := readJSON(filename, &request); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+func (f *Filesystem) keyFilename(fingerprint fpr.Fingerprint) string {
+	return filepath.Join(f.directory, "keys", fingerprint.Hex()+".asc")
+}
+
+func (f *Filesystem) secretFilename(fingerprint fpr.Fingerprint, secretUUID string) string {
+	return filepath.Join(f.directory, "secrets", fingerprint.Hex(), secretUUID+".json")
+}
+
+func (f *Filesystem) teamFilename(teamUUID uuid.UUID, filename string) string {
+	return filepath.Join(f.directory, "teams", teamUUID.String(), filename)
+}
+
+func (f *Filesystem) requestFilename(teamUUID uuid.UUID, requestUUID string) string {
+	return f.teamFilename(teamUUID, filepath.Join("requests", requestUUID+".json"))
+}
+
+func (f *Filesystem) requestToLeaveFilename(teamUUID uuid.UUID, requestUUID string) string {
+	return f.teamFilename(teamUUID, filepath.Join("requests-to-leave", requestUUID+".json"))
+}
+
+func readJSON(filename string, data interface{}) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, data); err != nil {
+		return fmt.Errorf("error parsing %s: %v", filename, err)
+	}
+	return nil
+}
+
+func writeJSON(filename string, data interface{}) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filename, content)
+}
+
+// writeFile atomically writes the file, making it readable by the rest of the team.
+func writeFile(filename string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0775); err != nil {
+		return err
+	}
+	if err := atomic.WriteFile(filename, bytes.NewReader(content)); err != nil {
+		return err
+	}
+	return os.Chmod(filename, 0644)
+}
+
+const rosterFilename = "roster.toml"
@@ -0,0 +1,288 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package keydirectory
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/api/v1structs"
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/policy"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/fluidkeys/fluidkeys/testhelpers"
+	"github.com/gofrs/uuid"
+)
+
+func TestFilesystem(t *testing.T) {
+	directory := NewFilesystem(testhelpers.Maketemp(t))
+
+	admin := loadKey(t, exampledata.ExamplePrivateKey2, "test2")
+	member := loadKey(t, exampledata.ExamplePrivateKey3, "test3")
+	memberEmail, err := member.Email()
+	assert.NoError(t, err)
+
+	t.Run("upload and get public keys", func(t *testing.T) {
+		for _, key := range []*pgpkey.PgpKey{admin, member} {
+			armored, err := key.Armor()
+			assert.NoError(t, err)
+			assert.NoError(t, directory.UpsertPublicKey(armored, key))
+		}
+
+		armored, err := directory.GetPublicKey(memberEmail)
+		assert.NoError(t, err)
+		gotKey, err := pgpkey.LoadFromArmoredPublicKey(armored)
+		assert.NoError(t, err)
+		assert.Equal(t, member.Fingerprint(), gotKey.Fingerprint())
+
+		gotKey, err = directory.GetPublicKeyByFingerprint(admin.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, admin.Fingerprint(), gotKey.Fingerprint())
+
+		_, err = directory.GetPublicKey("nobody@example.com")
+		assert.Equal(t, apiclient.ErrPublicKeyNotFound, err)
+	})
+
+	t.Run("revalidate a public key with its etag", func(t *testing.T) {
+		_, validators, err := directory.GetPublicKeyByFingerprintIfModified(
+			admin.Fingerprint(), apiclient.CacheValidators{},
+		)
+		assert.NoError(t, err)
+
+		_, _, err = directory.GetPublicKeyByFingerprintIfModified(admin.Fingerprint(), validators)
+		assert.Equal(t, apiclient.ErrNotModified, err)
+	})
+
+	t.Run("can't upload someone else's public key", func(t *testing.T) {
+		armored, err := member.Armor()
+		assert.NoError(t, err)
+		assert.GotError(t, directory.UpsertPublicKey(armored, admin))
+	})
+
+	theTeam := makeTeam(t, admin)
+
+	t.Run("create team", func(t *testing.T) {
+		roster, signature := theTeam.Roster()
+		assert.NoError(t, directory.UpsertTeam(roster, signature, admin.Fingerprint()))
+
+		name, err := directory.GetTeamName(theTeam.UUID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Kiffix", name)
+
+		_, err = directory.GetTeamName(uuid.Must(uuid.NewV4()))
+		assert.Equal(t, apiclient.ErrTeamNotFound, err)
+	})
+
+	t.Run("request to join team and approve request", func(t *testing.T) {
+		err := directory.RequestToJoinTeam(theTeam.UUID, member.Fingerprint(), memberEmail)
+		assert.NoError(t, err)
+
+		err = directory.RequestToJoinTeam(theTeam.UUID, member.Fingerprint(), memberEmail)
+		assert.GotError(t, err)
+
+		_, _, err = directory.GetTeamRoster(theTeam.UUID, member.Fingerprint())
+		assert.Equal(t, apiclient.ErrForbidden, err)
+
+		_, err = directory.ListRequestsToJoinTeam(theTeam.UUID, member.Fingerprint())
+		assert.Equal(t, apiclient.ErrForbidden, err)
+
+		requests, err := directory.ListRequestsToJoinTeam(theTeam.UUID, admin.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(requests))
+		assert.Equal(t, member.Fingerprint(), requests[0].Fingerprint)
+
+		theTeam.Version++
+		theTeam.UpsertPerson(team.Person{Email: memberEmail, Fingerprint: member.Fingerprint()})
+		assert.NoError(t, theTeam.UpdateRoster(admin))
+		roster, signature := theTeam.Roster()
+		assert.NoError(t, directory.UpsertTeam(roster, signature, admin.Fingerprint()))
+		assert.NoError(t, directory.DeleteRequestToJoinTeam(theTeam.UUID, requests[0].UUID))
+
+		gotRoster, _, err := directory.GetTeamRoster(theTeam.UUID, member.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, roster, gotRoster)
+	})
+
+	t.Run("request to leave team", func(t *testing.T) {
+		assert.NoError(t, directory.RequestToLeaveTeam(theTeam.UUID, member))
+		assert.GotError(t, directory.RequestToLeaveTeam(theTeam.UUID, member))
+
+		_, err := directory.ListRequestsToLeaveTeam(theTeam.UUID, member.Fingerprint())
+		assert.Equal(t, apiclient.ErrForbidden, err)
+
+		requests, err := directory.ListRequestsToLeaveTeam(theTeam.UUID, admin.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(requests))
+		assert.Equal(t, member.Fingerprint(), requests[0].Fingerprint)
+		_, err = requests[0].Verify(member, time.Now())
+		assert.NoError(t, err)
+
+		assert.NoError(t, directory.DeleteRequestToLeaveTeam(theTeam.UUID, requests[0].UUID))
+		requests, err = directory.ListRequestsToLeaveTeam(theTeam.UUID, admin.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(requests))
+	})
+
+	t.Run("rejects roster signed by a non-admin", func(t *testing.T) {
+		forged := theTeam
+		forged.Version++
+		forged.People = []team.Person{
+			{Email: memberEmail, Fingerprint: member.Fingerprint(), IsAdmin: true},
+		}
+		assert.NoError(t, forged.UpdateRoster(member))
+		roster, signature := forged.Roster()
+
+		err := directory.UpsertTeam(roster, signature, member.Fingerprint())
+		assert.Equal(t, apiclient.ErrForbidden, err)
+	})
+
+	t.Run("rejects roster with an older version", func(t *testing.T) {
+		older := theTeam
+		older.Version--
+		older.Name = "Renamed"
+		assert.NoError(t, older.UpdateRoster(admin))
+		roster, signature := older.Roster()
+
+		err := directory.UpsertTeam(roster, signature, admin.Fingerprint())
+		assert.GotError(t, err)
+	})
+
+	t.Run("send, list and delete a secret", func(t *testing.T) {
+		encrypted, err := member.EncryptArmored([]byte("hello world"))
+		assert.NoError(t, err)
+		assert.NoError(t, directory.CreateSecret(member.Fingerprint(), encrypted))
+
+		secrets, err := directory.ListSecrets(member.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(secrets))
+		assert.Equal(t, encrypted, secrets[0].EncryptedContent)
+
+		metadataJSON, _, err := member.DecryptArmoredToString(secrets[0].EncryptedMetadata)
+		assert.NoError(t, err)
+		metadata := v1structs.SecretMetadata{}
+		assert.NoError(t, json.Unmarshal([]byte(metadataJSON), &metadata))
+
+		assert.NoError(t, directory.DeleteSecret(member.Fingerprint(), metadata.SecretUUID))
+		secrets, err = directory.ListSecrets(member.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(secrets))
+	})
+}
+
+func TestGitRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	for name, value := range map[string]string{
+		"GIT_AUTHOR_NAME":     "Test",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "Test",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	tmpdir := testhelpers.Maketemp(t)
+	remote := filepath.Join(tmpdir, "remote.git")
+	runGit(t, "init", "--quiet", "--bare", remote)
+
+	// make an initial commit so both clones share a branch
+	setup := filepath.Join(tmpdir, "setup")
+	runGit(t, "clone", "--quiet", remote, setup)
+	runGit(t, "-C", setup, "commit", "--quiet", "--allow-empty", "--message", "Initial commit")
+	runGit(t, "-C", setup, "push", "--quiet", "origin", "HEAD")
+
+	aliceClone := filepath.Join(tmpdir, "alice")
+	bobClone := filepath.Join(tmpdir, "bob")
+	runGit(t, "clone", "--quiet", remote, aliceClone)
+	runGit(t, "clone", "--quiet", remote, bobClone)
+
+	alice := NewGitRepository(aliceClone)
+	bob := NewGitRepository(bobClone)
+
+	admin := loadKey(t, exampledata.ExamplePrivateKey2, "test2")
+	armored, err := admin.Armor()
+	assert.NoError(t, err)
+
+	t.Run("bob reads a key that alice uploaded", func(t *testing.T) {
+		assert.NoError(t, alice.UpsertPublicKey(armored, admin))
+
+		gotKey, err := bob.GetPublicKeyByFingerprint(admin.Fingerprint())
+		assert.NoError(t, err)
+		assert.Equal(t, admin.Fingerprint(), gotKey.Fingerprint())
+	})
+
+	t.Run("uploading an unchanged key doesn't fail", func(t *testing.T) {
+		assert.NoError(t, alice.UpsertPublicKey(armored, admin))
+	})
+
+	t.Run("alice reads a team that bob created after she pulled", func(t *testing.T) {
+		theTeam := makeTeam(t, admin)
+		roster, signature := theTeam.Roster()
+		assert.NoError(t, bob.UpsertTeam(roster, signature, admin.Fingerprint()))
+
+		alice.sync.(*gitSyncer).pulled = false // as if alice runs fk again
+		name, err := alice.GetTeamName(theTeam.UUID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Kiffix", name)
+	})
+}
+
+func makeTeam(t *testing.T, admin *pgpkey.PgpKey) team.Team {
+	t.Helper()
+	adminEmail, err := admin.Email()
+	assert.NoError(t, err)
+
+	theTeam := team.Team{
+		UUID:    uuid.Must(uuid.NewV4()),
+		Version: 1,
+		Name:    "Kiffix",
+		People: []team.Person{
+			{Email: adminEmail, Fingerprint: admin.Fingerprint(), IsAdmin: true},
+		},
+	}
+	assert.NoError(t, theTeam.UpdateRoster(admin))
+	return theTeam
+}
+
+func loadKey(t *testing.T, armoredPrivateKey string, password string) *pgpkey.PgpKey {
+	t.Helper()
+	key, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(armoredPrivateKey, password)
+	assert.NoError(t, err)
+
+	// workaround as example private keys don't have hash prefs
+	err = key.SetPreferredHashAlgorithms(policy.AdvertiseHashPreferences, time.Now())
+	assert.NoError(t, err)
+	return key
+}
+
+func runGit(t *testing.T, args ...string) {
+	t.Helper()
+	if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, output)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package keydirectory
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// NewGitRepository returns a key directory stored in a clone of a git repository shared by the
+// team. Changes are pulled before reading, and committed and pushed after writing. If the
+// repository has no remote, changes are only committed.
+func NewGitRepository(directory string) *Filesystem {
+	return &Filesystem{
+		directory: directory,
+		sync:      &gitSyncer{directory: directory},
+	}
+}
+
+type gitSyncer struct {
+	directory string
+
+	// pulled records whether we've already pulled since starting, to avoid pulling before every
+	// single read.
+	pulled bool
+}
+
+func (g *gitSyncer) pull() error {
+	if g.pulled {
+		return nil
+	}
+
+	hasRemote, err := g.hasRemote()
+	if err != nil {
+		return err
+	}
+	if hasRemote {
+		if _, err := g.git("pull", "--rebase", "--quiet"); err != nil {
+			return err
+		}
+	}
+	g.pulled = true
+	return nil
+}
+
+func (g *gitSyncer) push(description string) error {
+	if _, err := g.git("add", "--all", "."); err != nil {
+		return err
+	}
+
+	status, err := g.git("status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		return nil // nothing changed, e.g. an identical key was uploaded again
+	}
+
+	if _, err := g.git("commit", "--quiet", "--message", description); err != nil {
+		return err
+	}
+
+	hasRemote, err := g.hasRemote()
+	if err != nil || !hasRemote {
+		return err
+	}
+
+	if _, err := g.git("push", "--quiet"); err != nil {
+		// someone else may have pushed since we pulled, so rebase onto their changes and try
+		// once more
+		if _, pullErr := g.git("pull", "--rebase", "--quiet"); pullErr != nil {
+			return pullErr
+		}
+		if _, err := g.git("push", "--quiet"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *gitSyncer) hasRemote() (bool, error) {
+	remotes, err := g.git("remote")
+	if err != nil {
+		return false, err
+	}
+	return remotes != "", nil
+}
+
+func (g *gitSyncer) git(args ...string) (output string, err error) {
+	cmd := exec.Command("git", append([]string{"-C", g.directory}, args...)...)
+	outputBytes, err := cmd.CombinedOutput()
+	output = strings.TrimSpace(string(outputBytes))
+
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, output)
+	}
+	return output, nil
+}
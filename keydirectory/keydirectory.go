@@ -0,0 +1,77 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package keydirectory defines where Fluidkeys publishes and looks up public keys and
+// exchanges secrets, team rosters, requests to join or leave teams and events.
+// The default is the Fluidkeys server (apiclient.Client), but teams can host their own directory
+// on a shared filesystem or in a git repository.
+package keydirectory
+
+import (
+	"github.com/fluidkeys/api/v1structs"
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/fluidkeys/fluidkeys/team"
+	"github.com/gofrs/uuid"
+)
+
+// KeyDirectory covers the operations the fk command needs from a key directory.
+// Implementations return the same errors as apiclient, for example
+// apiclient.ErrPublicKeyNotFound, apiclient.ErrTeamNotFound and apiclient.ErrForbidden.
+type KeyDirectory interface {
+	// Keys
+	GetPublicKey(email string) (string, error)
+	GetPublicKeyByFingerprint(fingerprint fpr.Fingerprint) (*pgpkey.PgpKey, error)
+	GetPublicKeyByFingerprintIfModified(fingerprint fpr.Fingerprint,
+		validators apiclient.CacheValidators) (*pgpkey.PgpKey, apiclient.CacheValidators, error)
+	UpsertPublicKey(armoredPublicKey string, privateKey *pgpkey.PgpKey) error
+
+	// Secrets
+	CreateSecret(recipientFingerprint fpr.Fingerprint, armoredEncryptedSecret string) error
+	ListSecrets(fingerprint fpr.Fingerprint) ([]v1structs.Secret, error)
+	DeleteSecret(fingerprint fpr.Fingerprint, uuid string) error
+
+	// Teams and rosters
+	UpsertTeam(roster string, rosterSignature string, signerFingerprint fpr.Fingerprint) error
+	GetTeamName(teamUUID uuid.UUID) (string, error)
+	GetTeamRoster(teamUUID uuid.UUID, me fpr.Fingerprint) (roster string, signature string, err error)
+
+	// Requests to join teams
+	RequestToJoinTeam(teamUUID uuid.UUID, fingerprint fpr.Fingerprint, email string) error
+	ListRequestsToJoinTeam(teamUUID uuid.UUID, fingerprint fpr.Fingerprint) (
+		[]team.RequestToJoinTeam, error)
+	DeleteRequestToJoinTeam(teamUUID uuid.UUID, requestUUID uuid.UUID) error
+
+	// Requests to leave teams
+	RequestToLeaveTeam(teamUUID uuid.UUID, privateKey *pgpkey.PgpKey) error
+	ListRequestsToLeaveTeam(teamUUID uuid.UUID, fingerprint fpr.Fingerprint) (
+		[]team.RequestToLeaveTeam, error)
+	DeleteRequestToLeaveTeam(teamUUID uuid.UUID, requestUUID uuid.UUID) error
+
+	// Events
+	Log(event apiclient.Event) error
+
+	// VerifiesEmails returns true if the directory only returns keys for email addresses their
+	// owners have proved they control.
+	VerifiesEmails() bool
+}
+
+var (
+	_ KeyDirectory = &apiclient.Client{}
+	_ KeyDirectory = &Filesystem{}
+)
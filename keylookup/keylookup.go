@@ -0,0 +1,148 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package keylookup finds other people's public keys from several sources, such as the
+// Fluidkeys directory and Web Key Directory.
+package keylookup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fluidkeys/fluidkeys/apiclient"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+)
+
+// ErrNotFound means the source was working, but had no key for the email address.
+var ErrNotFound = errors.New("public key not found")
+
+// EmailLookup is a source of public keys which can be searched by email address.
+type EmailLookup interface {
+	// Name describes the source to the user, for example "Web Key Directory".
+	Name() string
+
+	// FindByEmail returns the public keys for the given email address, or ErrNotFound.
+	FindByEmail(email string) ([]*pgpkey.PgpKey, error)
+}
+
+// FingerprintLookup is a source of public keys which can be searched by fingerprint.
+type FingerprintLookup interface {
+	// Name describes the source to the user, for example "keys.openpgp.org".
+	Name() string
+
+	// FindByFingerprint returns the public key with the given fingerprint, or ErrNotFound.
+	FindByFingerprint(fingerprint fpr.Fingerprint) (*pgpkey.PgpKey, error)
+}
+
+// FindByEmail tries each source in turn and returns the keys from the first one which has any,
+// along with that source.
+// If every source returns ErrNotFound, it returns ErrNotFound. If any source failed for another
+// reason, it returns an error describing the failures.
+func FindByEmail(email string, sources ...EmailLookup) (
+	keys []*pgpkey.PgpKey, source EmailLookup, err error) {
+
+	failures := []string{}
+
+	for _, source := range sources {
+		keys, err := source.FindByEmail(email)
+		if err == ErrNotFound || (err == nil && len(keys) == 0) {
+			continue
+		} else if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", source.Name(), err))
+			continue
+		}
+		return keys, source, nil
+	}
+
+	if len(failures) > 0 {
+		return nil, nil, fmt.Errorf("failed to look up %s: %s", email, strings.Join(failures, ", "))
+	}
+	return nil, nil, ErrNotFound
+}
+
+// FindByFingerprintAndEmail looks up the email address in each source and returns the first key
+// with the given fingerprint. Use it to find a key by fingerprint from sources that can only be
+// searched by email address.
+func FindByFingerprintAndEmail(fingerprint fpr.Fingerprint, email string,
+	sources ...EmailLookup) (*pgpkey.PgpKey, error) {
+
+	failures := []string{}
+
+	for _, source := range sources {
+		keys, err := source.FindByEmail(email)
+		if err != nil && err != ErrNotFound {
+			failures = append(failures, fmt.Sprintf("%s: %v", source.Name(), err))
+			continue
+		}
+
+		for _, key := range keys {
+			if key.Fingerprint() == fingerprint {
+				return key, nil
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("failed to look up %s: %s", email, strings.Join(failures, ", "))
+	}
+	return nil, ErrNotFound
+}
+
+// FilterByEmail returns the keys with a user ID matching the email address. Use it to discard
+// keys that a source returned for a different or partially matching email address.
+func FilterByEmail(keys []*pgpkey.PgpKey, email string) (matching []*pgpkey.PgpKey) {
+	for _, key := range keys {
+		for _, keyEmail := range key.Emails(true) {
+			if strings.EqualFold(keyEmail, email) {
+				matching = append(matching, key)
+				break
+			}
+		}
+	}
+	return matching
+}
+
+// FluidkeysDirectory looks up keys that people have published to the Fluidkeys directory (or
+// the team's own key directory).
+type FluidkeysDirectory struct {
+	Client interface {
+		GetPublicKey(email string) (string, error)
+	}
+}
+
+// Name returns "Fluidkeys"
+func (d FluidkeysDirectory) Name() string {
+	return "Fluidkeys"
+}
+
+// FindByEmail returns the key published to Fluidkeys for the email address, or ErrNotFound.
+func (d FluidkeysDirectory) FindByEmail(email string) ([]*pgpkey.PgpKey, error) {
+	armoredPublicKey, err := d.Client.GetPublicKey(email)
+	if err == apiclient.ErrPublicKeyNotFound {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	key, err := pgpkey.LoadFromArmoredPublicKey(armoredPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load public key: %v", err)
+	}
+	return []*pgpkey.PgpKey{key}, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package keylookup
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+)
+
+func TestFindByEmail(t *testing.T) {
+	key2, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey2)
+	assert.NoError(t, err)
+	key3, err := pgpkey.LoadFromArmoredPublicKey(exampledata.ExamplePublicKey3)
+	assert.NoError(t, err)
+
+	notFound := mockLookup{name: "empty", err: ErrNotFound}
+	broken := mockLookup{name: "broken", err: fmt.Errorf("no route to host")}
+	hasKey2 := mockLookup{name: "key2", keys: []*pgpkey.PgpKey{key2}}
+	hasKey3 := mockLookup{name: "key3", keys: []*pgpkey.PgpKey{key3}}
+
+	t.Run("returns keys from first source that has any", func(t *testing.T) {
+		keys, source, err := FindByEmail("test@example.com", notFound, broken, hasKey2, hasKey3)
+		assert.NoError(t, err)
+		assert.Equal(t, "key2", source.Name())
+		assert.Equal(t, []*pgpkey.PgpKey{key2}, keys)
+	})
+
+	t.Run("returns ErrNotFound if no source has a key", func(t *testing.T) {
+		_, _, err := FindByEmail("test@example.com", notFound, notFound)
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("reports sources that failed", func(t *testing.T) {
+		_, _, err := FindByEmail("test@example.com", notFound, broken)
+		assert.Equal(t,
+			fmt.Errorf("failed to look up test@example.com: broken: no route to host"), err)
+	})
+
+	t.Run("FindByFingerprintAndEmail picks the matching key", func(t *testing.T) {
+		both := mockLookup{name: "both", keys: []*pgpkey.PgpKey{key2, key3}}
+		key, err := FindByFingerprintAndEmail(
+			exampledata.ExampleFingerprint3, "test@example.com", hasKey2, both)
+		assert.NoError(t, err)
+		assert.Equal(t, exampledata.ExampleFingerprint3, key.Fingerprint())
+
+		_, err = FindByFingerprintAndEmail(
+			exampledata.ExampleFingerprint4, "test@example.com", hasKey2, both)
+		assert.Equal(t, ErrNotFound, err)
+	})
+}
+
+type mockLookup struct {
+	name string
+	keys []*pgpkey.PgpKey
+	err  error
+}
+
+func (m mockLookup) Name() string {
+	return m.name
+}
+
+func (m mockLookup) FindByEmail(email string) ([]*pgpkey.PgpKey, error) {
+	return m.keys, m.err
+}
@@ -0,0 +1,337 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package keyserver looks up and publishes public keys on OpenPGP keyservers, using either HKP
+// (/pks/lookup, as used by SKS and Hockeypuck) or the VKS API used by keys.openpgp.org.
+package keyserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fluidkeys/crypto/openpgp"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/keylookup"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+)
+
+// Protocol is the API spoken by a keyserver
+type Protocol string
+
+const (
+	// HKP is the HTTP Keyserver Protocol, see
+	// https://tools.ietf.org/html/draft-shaw-openpgp-hkp-00
+	HKP Protocol = "hkp"
+
+	// VKS is the Verifying Keyserver API, see https://keys.openpgp.org/about/api
+	VKS Protocol = "vks"
+)
+
+// Client talks to a single keyserver.
+type Client struct {
+	BaseURL    *url.URL
+	Protocol   Protocol
+	HTTPClient *http.Client
+}
+
+// New returns a client for the keyserver at the given address, for example
+// `https://keys.openpgp.org` or `hkps://keyserver.ubuntu.com`. The hkp:// and hkps:// schemes
+// are converted to http:// (on port 11371 by default) and https://.
+func New(address string, protocol Protocol) (*Client, error) {
+	if protocol != HKP && protocol != VKS {
+		return nil, fmt.Errorf("unknown keyserver protocol: %q", protocol)
+	}
+
+	baseURL, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		BaseURL:    baseURL,
+		Protocol:   protocol,
+		HTTPClient: &http.Client{Timeout: defaultTimeout},
+	}, nil
+}
+
+// ParseAddress parses a keyserver address into an http or https URL.
+func ParseAddress(address string) (*url.URL, error) {
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyserver address %q: %v", address, err)
+	}
+
+	switch parsed.Scheme {
+	case "hkp":
+		parsed.Scheme = "http"
+		if parsed.Port() == "" {
+			parsed.Host += ":11371"
+		}
+
+	case "hkps":
+		parsed.Scheme = "https"
+
+	case "http", "https":
+
+	default:
+		return nil, fmt.Errorf("invalid keyserver address %q: scheme must be one of "+
+			"https, http, hkps or hkp", address)
+	}
+
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid keyserver address %q: missing host", address)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+	return parsed, nil
+}
+
+// Name returns the keyserver's hostname, for example "keys.openpgp.org"
+func (c *Client) Name() string {
+	return c.BaseURL.Hostname()
+}
+
+// FindByEmail returns the keys with a user ID matching the email address, or
+// keylookup.ErrNotFound.
+func (c *Client) FindByEmail(email string) ([]*pgpkey.PgpKey, error) {
+	var lookupURL string
+
+	switch c.Protocol {
+	case VKS:
+		lookupURL = c.url("/vks/v1/by-email/"+url.PathEscape(email), nil)
+	default:
+		lookupURL = c.url("/pks/lookup", hkpQuery(email))
+	}
+
+	keys, err := c.fetchKeys(lookupURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// HKP searches match substrings of user IDs, so only keep exact matches.
+	keys = keylookup.FilterByEmail(keys, email)
+	if len(keys) == 0 {
+		return nil, keylookup.ErrNotFound
+	}
+	return keys, nil
+}
+
+// FindByFingerprint returns the key with the given fingerprint, or keylookup.ErrNotFound.
+func (c *Client) FindByFingerprint(fingerprint fpr.Fingerprint) (*pgpkey.PgpKey, error) {
+	var lookupURL string
+
+	switch c.Protocol {
+	case VKS:
+		lookupURL = c.url("/vks/v1/by-fingerprint/"+fingerprint.Hex(), nil)
+	default:
+		lookupURL = c.url("/pks/lookup", hkpQuery("0x"+fingerprint.Hex()))
+	}
+
+	keys, err := c.fetchKeys(lookupURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't trust the keyserver to return the key we asked for.
+	for _, key := range keys {
+		if key.Fingerprint() == fingerprint {
+			return key, nil
+		}
+	}
+	return nil, keylookup.ErrNotFound
+}
+
+// Upload publishes the public key to the keyserver.
+// VKS keyservers only publish user IDs once the owner has verified their email address. If
+// requestVerification is true, Upload asks the keyserver to send verification emails for any
+// unpublished addresses and returns those addresses.
+func (c *Client) Upload(armoredPublicKey string, requestVerification bool) (
+	verificationSentTo []string, err error) {
+
+	switch c.Protocol {
+	case VKS:
+		return c.uploadVKS(armoredPublicKey, requestVerification)
+	default:
+		return nil, c.uploadHKP(armoredPublicKey)
+	}
+}
+
+func (c *Client) uploadHKP(armoredPublicKey string) error {
+	form := url.Values{"keytext": []string{armoredPublicKey}}
+	response, err := c.HTTPClient.PostForm(c.url("/pks/add", nil), form)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return c.makeError(response)
+	}
+	return nil
+}
+
+func (c *Client) uploadVKS(armoredPublicKey string, requestVerification bool) (
+	verificationSentTo []string, err error) {
+
+	uploadResponse := vksUploadResponse{}
+	err = c.postJSON("/vks/v1/upload", vksUploadRequest{KeyText: armoredPublicKey}, &uploadResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if !requestVerification {
+		return nil, nil
+	}
+
+	unpublished := []string{}
+	for email, status := range uploadResponse.Status {
+		if status == vksStatusUnpublished {
+			unpublished = append(unpublished, email)
+		}
+	}
+	if len(unpublished) == 0 {
+		return nil, nil
+	}
+	sort.Strings(unpublished)
+
+	verifyRequest := vksRequestVerifyRequest{Token: uploadResponse.Token, Addresses: unpublished}
+	if err := c.postJSON("/vks/v1/request-verify", verifyRequest, nil); err != nil {
+		return nil, fmt.Errorf("uploaded key, but failed to request verification: %v", err)
+	}
+	return unpublished, nil
+}
+
+func (c *Client) postJSON(path string, requestData interface{}, responseData interface{}) error {
+	requestJSON, err := json.Marshal(requestData)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.HTTPClient.Post(
+		c.url(path, nil), "application/json", bytes.NewReader(requestJSON),
+	)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return c.makeError(response)
+	}
+
+	if responseData != nil {
+		if err := json.NewDecoder(response.Body).Decode(responseData); err != nil {
+			return fmt.Errorf("invalid response from %s: %v", c.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) fetchKeys(lookupURL string) ([]*pgpkey.PgpKey, error) {
+	response, err := c.HTTPClient.Get(lookupURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, keylookup.ErrNotFound
+
+	case response.StatusCode != http.StatusOK:
+		return nil, c.makeError(response)
+	}
+
+	body := bytes.NewBuffer(nil)
+	bytesRead, err := io.CopyN(body, response.Body, maxResponseBytes+1)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error reading response from %s: %v", c.Name(), err)
+	} else if bytesRead > maxResponseBytes {
+		return nil, fmt.Errorf("response from %s is too large", c.Name())
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key from %s: %v", c.Name(), err)
+	}
+
+	keys := []*pgpkey.PgpKey{}
+	for _, entity := range entities {
+		keys = append(keys, &pgpkey.PgpKey{Entity: *entity})
+	}
+	return keys, nil
+}
+
+func (c *Client) url(path string, query url.Values) string {
+	u := *c.BaseURL
+	u.Path = c.BaseURL.Path + path
+	u.RawPath = ""
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+func (c *Client) makeError(response *http.Response) error {
+	body := bytes.NewBuffer(nil)
+	io.CopyN(body, response.Body, 1024)
+
+	var vksError vksErrorResponse
+	if json.Unmarshal(body.Bytes(), &vksError) == nil && vksError.Error != "" {
+		return fmt.Errorf("%s returned HTTP %d: %s", c.Name(), response.StatusCode, vksError.Error)
+	}
+	return fmt.Errorf("%s returned HTTP %d", c.Name(), response.StatusCode)
+}
+
+func hkpQuery(search string) url.Values {
+	return url.Values{
+		"op":      []string{"get"},
+		"options": []string{"mr"},
+		"search":  []string{search},
+	}
+}
+
+type vksUploadRequest struct {
+	KeyText string `json:"keytext"`
+}
+
+type vksUploadResponse struct {
+	KeyFingerprint string            `json:"key_fpr"`
+	Status         map[string]string `json:"status"`
+	Token          string            `json:"token"`
+}
+
+type vksRequestVerifyRequest struct {
+	Token     string   `json:"token"`
+	Addresses []string `json:"addresses"`
+}
+
+type vksErrorResponse struct {
+	Error string `json:"error"`
+}
+
+const (
+	vksStatusUnpublished = "unpublished"
+	defaultTimeout       = 10 * time.Second
+	maxResponseBytes     = 1024 * 1024
+)
@@ -0,0 +1,204 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package keyserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/keylookup"
+)
+
+func TestParseAddress(t *testing.T) {
+	for _, test := range []struct {
+		address  string
+		expected string
+	}{
+		{"https://keys.openpgp.org", "https://keys.openpgp.org"},
+		{"hkps://keyserver.ubuntu.com/", "https://keyserver.ubuntu.com"},
+		{"hkp://pool.sks-keyservers.net", "http://pool.sks-keyservers.net:11371"},
+		{"hkp://localhost:8080", "http://localhost:8080"},
+	} {
+		t.Run(test.address, func(t *testing.T) {
+			parsed, err := ParseAddress(test.address)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, parsed.String())
+		})
+	}
+
+	t.Run("invalid scheme", func(t *testing.T) {
+		_, err := ParseAddress("ldap://keys.example.com")
+		assert.GotError(t, err)
+	})
+
+	t.Run("missing host", func(t *testing.T) {
+		_, err := ParseAddress("https://")
+		assert.GotError(t, err)
+	})
+}
+
+func TestHKP(t *testing.T) {
+	var gotKeyText string
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/pks/lookup":
+				assert.Equal(t, "get", r.URL.Query().Get("op"))
+				switch r.URL.Query().Get("search") {
+				case "test3@example.com", "0x" + exampledata.ExampleFingerprint3.Hex(), "test":
+					w.Write([]byte(exampledata.ExamplePublicKey3))
+				case "0x" + exampledata.ExampleFingerprint2.Hex():
+					w.Write([]byte(exampledata.ExamplePublicKey3)) // wrong key!
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+
+			case "/pks/add":
+				gotKeyText = r.FormValue("keytext")
+
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(server.URL, HKP)
+	assert.NoError(t, err)
+
+	t.Run("find by email", func(t *testing.T) {
+		keys, err := client.FindByEmail("test3@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(keys))
+		assert.Equal(t, exampledata.ExampleFingerprint3, keys[0].Fingerprint())
+	})
+
+	t.Run("find by email ignores partial matches", func(t *testing.T) {
+		_, err := client.FindByEmail("test")
+		assert.Equal(t, keylookup.ErrNotFound, err)
+	})
+
+	t.Run("find by fingerprint", func(t *testing.T) {
+		key, err := client.FindByFingerprint(exampledata.ExampleFingerprint3)
+		assert.NoError(t, err)
+		assert.Equal(t, exampledata.ExampleFingerprint3, key.Fingerprint())
+	})
+
+	t.Run("find by fingerprint rejects a different key", func(t *testing.T) {
+		_, err := client.FindByFingerprint(exampledata.ExampleFingerprint2)
+		assert.Equal(t, keylookup.ErrNotFound, err)
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		verificationSentTo, err := client.Upload(exampledata.ExamplePublicKey2, true)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(verificationSentTo))
+		assert.Equal(t, exampledata.ExamplePublicKey2, gotKeyText)
+	})
+}
+
+func TestVKS(t *testing.T) {
+	var gotVerifyRequest *vksRequestVerifyRequest
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method + " " + r.URL.Path {
+			case "GET /vks/v1/by-fingerprint/" + exampledata.ExampleFingerprint2.Hex():
+				w.Write([]byte(exampledata.ExamplePublicKey2))
+
+			case "GET /vks/v1/by-email/test2@example.com":
+				w.Write([]byte(exampledata.ExamplePublicKey2))
+
+			case "POST /vks/v1/upload":
+				request := vksUploadRequest{}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+				if request.KeyText == "" {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error": "missing keytext"}`))
+					return
+				}
+				json.NewEncoder(w).Encode(vksUploadResponse{
+					KeyFingerprint: exampledata.ExampleFingerprint3.Hex(),
+					Status: map[string]string{
+						"test3@example.com":   "published",
+						"another@example.com": "unpublished",
+					},
+					Token: "upload-token",
+				})
+
+			case "POST /vks/v1/request-verify":
+				gotVerifyRequest = &vksRequestVerifyRequest{}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(gotVerifyRequest))
+				w.Write([]byte(`{}`))
+
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+	))
+	defer server.Close()
+
+	client, err := New(server.URL, VKS)
+	assert.NoError(t, err)
+
+	t.Run("find by email", func(t *testing.T) {
+		keys, err := client.FindByEmail("test2@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(keys))
+		assert.Equal(t, exampledata.ExampleFingerprint2, keys[0].Fingerprint())
+	})
+
+	t.Run("find by fingerprint", func(t *testing.T) {
+		key, err := client.FindByFingerprint(exampledata.ExampleFingerprint2)
+		assert.NoError(t, err)
+		assert.Equal(t, exampledata.ExampleFingerprint2, key.Fingerprint())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := client.FindByFingerprint(exampledata.ExampleFingerprint4)
+		assert.Equal(t, keylookup.ErrNotFound, err)
+	})
+
+	t.Run("upload without requesting verification", func(t *testing.T) {
+		verificationSentTo, err := client.Upload(exampledata.ExamplePublicKey3, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(verificationSentTo))
+		assert.Equal(t, (*vksRequestVerifyRequest)(nil), gotVerifyRequest)
+	})
+
+	t.Run("upload and request verification of unpublished addresses", func(t *testing.T) {
+		verificationSentTo, err := client.Upload(exampledata.ExamplePublicKey3, true)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"another@example.com"}, verificationSentTo)
+		assert.Equal(t, &vksRequestVerifyRequest{
+			Token:     "upload-token",
+			Addresses: []string{"another@example.com"},
+		}, gotVerifyRequest)
+	})
+
+	t.Run("upload error", func(t *testing.T) {
+		_, err := client.Upload("", true)
+		assert.GotError(t, err)
+		assert.Equal(t, "127.0.0.1 returned HTTP 400: missing keytext", err.Error())
+	})
+}
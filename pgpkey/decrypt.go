@@ -29,23 +29,55 @@ import (
 	"github.com/fluidkeys/crypto/openpgp/packet"
 )
 
-// DecryptArmored takes an ascii armored encrypted PGP message and attempts to decrypt it
-// against the key, returning an io.Reader
-func (p *PgpKey) DecryptArmored(encrypted string) (io.Reader, *packet.LiteralData, error) {
-	err := p.ensureGotDecryptedPrivateKey()
+// EncryptArmored encrypts the plaintext to the key, returning an ascii armored PGP message.
+func (p *PgpKey) EncryptArmored(plaintext []byte) (string, error) {
+	buffer := bytes.NewBuffer(nil)
+	message, err := armor.Encode(buffer, "PGP MESSAGE", nil)
 	if err != nil {
-		return nil, nil, err
+		return "", err
+	}
+
+	pgpWriteCloser, err := openpgp.Encrypt(message, []*openpgp.Entity{&p.Entity}, nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := pgpWriteCloser.Write(plaintext); err != nil {
+		return "", err
+	}
+	if err := pgpWriteCloser.Close(); err != nil {
+		return "", err
+	}
+	if err := message.Close(); err != nil {
+		return "", err
 	}
+	return buffer.String(), nil
+}
 
+// DecryptArmored takes an ascii armored encrypted PGP message and attempts to decrypt it
+// against the key, returning an io.Reader
+func (p *PgpKey) DecryptArmored(encrypted string) (io.Reader, *packet.LiteralData, error) {
 	buffer := strings.NewReader(encrypted)
 	block, err := armor.Decode(buffer)
 	if err != nil {
 		return nil, nil, fmt.Errorf("error decoding armor: %s", err)
 	}
 
+	return p.Decrypt(block.Body)
+}
+
+// Decrypt takes a binary (not armored) encrypted PGP message and attempts to decrypt it against
+// the key, returning an io.Reader.
+// The integrity of the message is only checked once the returned reader reaches io.EOF, so
+// callers must not trust the decrypted data until it's been read without error.
+func (p *PgpKey) Decrypt(encrypted io.Reader) (io.Reader, *packet.LiteralData, error) {
+	err := p.ensureGotDecryptedPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var keyRing openpgp.EntityList = []*openpgp.Entity{&p.Entity}
 
-	messageDetails, err := openpgp.ReadMessage(block.Body, keyRing, nil, nil)
+	messageDetails, err := openpgp.ReadMessage(encrypted, keyRing, nil, nil)
 	if err != nil {
 		return nil, nil, fmt.Errorf("error reading message: %s", err)
 	}
@@ -0,0 +1,178 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package pgpkey
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/packet"
+)
+
+// Serialize writes the public part of the key to w. It's like openpgp.Entity.Serialize, but also
+// writes any revocation signatures on the primary key (which Entity.Serialize drops) and writes
+// user IDs in a consistent order.
+func (key *PgpKey) Serialize(w io.Writer) error {
+	if err := key.PrimaryKey.Serialize(w); err != nil {
+		return err
+	}
+	for _, revocation := range key.Revocations {
+		if err := revocation.Serialize(w); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedIdentityNames(key.Identities) {
+		identity := key.Identities[name]
+		if err := identity.UserId.Serialize(w); err != nil {
+			return err
+		}
+		if err := identity.SelfSignature.Serialize(w); err != nil {
+			return err
+		}
+		for _, signature := range identity.Signatures {
+			if err := signature.Serialize(w); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, subkey := range key.Subkeys {
+		if err := subkey.PublicKey.Serialize(w); err != nil {
+			return err
+		}
+		if err := subkey.Sig.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge combines two copies of the same public key, for example a cached copy and a freshly
+// downloaded one, in a similar way to GnuPG's import:
+//
+// * user IDs, certifications and subkeys from either copy are kept
+// * the newest self-signature wins (for example, one with a later expiry date)
+// * revocations from either copy are always kept, so they're never lost
+func Merge(existing *PgpKey, update *PgpKey) (*PgpKey, error) {
+	if existing.Fingerprint() != update.Fingerprint() {
+		return nil, fmt.Errorf("can't merge different keys %s and %s",
+			existing.Fingerprint(), update.Fingerprint())
+	}
+
+	merged := PgpKey{openpgp.Entity{
+		PrimaryKey:  update.PrimaryKey,
+		Identities:  map[string]*openpgp.Identity{},
+		Revocations: mergeSignatures(existing.Revocations, update.Revocations),
+	}}
+
+	for name, identity := range existing.Identities {
+		merged.Identities[name] = copyIdentity(identity)
+	}
+	for name, identity := range update.Identities {
+		mergedIdentity, inBoth := merged.Identities[name]
+		if !inBoth {
+			merged.Identities[name] = copyIdentity(identity)
+			continue
+		}
+
+		if identity.SelfSignature.CreationTime.After(mergedIdentity.SelfSignature.CreationTime) {
+			mergedIdentity.SelfSignature = identity.SelfSignature
+		}
+		mergedIdentity.Signatures = mergeSignatures(mergedIdentity.Signatures, identity.Signatures)
+	}
+
+	merged.Subkeys = append(merged.Subkeys, existing.Subkeys...)
+	for _, subkey := range update.Subkeys {
+		found := false
+		for i := range merged.Subkeys {
+			if merged.Subkeys[i].PublicKey.KeyId == subkey.PublicKey.KeyId {
+				merged.Subkeys[i].Sig = newestSubkeySignature(merged.Subkeys[i].Sig, subkey.Sig)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Subkeys = append(merged.Subkeys, subkey)
+		}
+	}
+
+	return &merged, nil
+}
+
+func copyIdentity(identity *openpgp.Identity) *openpgp.Identity {
+	copied := *identity
+	copied.Signatures = append([]*packet.Signature{}, identity.Signatures...)
+	return &copied
+}
+
+// mergeSignatures returns all the signatures in a followed by any in b that aren't in a.
+func mergeSignatures(a []*packet.Signature, b []*packet.Signature) []*packet.Signature {
+	merged := append([]*packet.Signature{}, a...)
+	seen := map[string]bool{}
+	for _, signature := range a {
+		seen[signatureID(signature)] = true
+	}
+
+	for _, signature := range b {
+		if !seen[signatureID(signature)] {
+			merged = append(merged, signature)
+			seen[signatureID(signature)] = true
+		}
+	}
+	return merged
+}
+
+// newestSubkeySignature returns the subkey's revocation if either signature is one, otherwise
+// the newest binding signature.
+func newestSubkeySignature(existing *packet.Signature, update *packet.Signature) *packet.Signature {
+	switch {
+	case existing.SigType == packet.SigTypeSubkeyRevocation:
+		return existing
+
+	case update.SigType == packet.SigTypeSubkeyRevocation:
+		return update
+
+	case update.CreationTime.After(existing.CreationTime):
+		return update
+
+	default:
+		return existing
+	}
+}
+
+// signatureID identifies a signature well enough to spot the same signature in two copies of
+// a key.
+func signatureID(signature *packet.Signature) string {
+	var issuer uint64
+	if signature.IssuerKeyId != nil {
+		issuer = *signature.IssuerKeyId
+	}
+	return fmt.Sprintf("%d-%X-%d", signature.SigType, issuer, signature.CreationTime.UnixNano())
+}
+
+func sortedIdentityNames(identities map[string]*openpgp.Identity) []string {
+	names := []string{}
+	for name := range identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,117 @@
+package pgpkey
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/crypto/openpgp/packet"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+)
+
+func TestArmorKeepsRevocations(t *testing.T) {
+	now := time.Date(2019, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	key, err := LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey4, "test4")
+	assert.NoError(t, err)
+
+	revocation, err := key.GetRevocationSignature(0, "no reason", now)
+	assert.NoError(t, err)
+	key.Revocations = append(key.Revocations, revocation)
+
+	armored, err := key.Armor()
+	assert.NoError(t, err)
+
+	reloaded, err := LoadFromArmoredPublicKey(armored)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(reloaded.Revocations))
+}
+
+func TestMerge(t *testing.T) {
+	now := time.Date(2019, 6, 15, 0, 0, 0, 0, time.UTC)
+	later := now.Add(time.Duration(24) * time.Hour)
+
+	loadPublic := func(t *testing.T) *PgpKey {
+		key, err := LoadFromArmoredPublicKey(exampledata.ExamplePublicKey4)
+		assert.NoError(t, err)
+		return key
+	}
+
+	t.Run("keeps new subkeys from the update", func(t *testing.T) {
+		existing := loadPublic(t)
+
+		privateKey, err := LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey4, "test4")
+		assert.NoError(t, err)
+		err = privateKey.CreateNewEncryptionSubkey(later.Add(time.Duration(24)*time.Hour), later, rand.Reader)
+		assert.NoError(t, err)
+		update := publicCopy(t, privateKey)
+
+		merged, err := Merge(existing, update)
+		assert.NoError(t, err)
+		assert.Equal(t, len(existing.Subkeys)+1, len(merged.Subkeys))
+	})
+
+	t.Run("keeps revocations only in the existing copy", func(t *testing.T) {
+		privateKey, err := LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey4, "test4")
+		assert.NoError(t, err)
+		revocation, err := privateKey.GetRevocationSignature(0, "no reason", now)
+		assert.NoError(t, err)
+		privateKey.Revocations = append(privateKey.Revocations, revocation)
+		existing := publicCopy(t, privateKey)
+
+		merged, err := Merge(existing, loadPublic(t))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(merged.Revocations))
+
+		t.Run("and the merged key still has it after armoring", func(t *testing.T) {
+			reloaded := publicCopy(t, merged)
+			assert.Equal(t, 1, len(reloaded.Revocations))
+		})
+	})
+
+	t.Run("doesn't duplicate revocations in both copies", func(t *testing.T) {
+		privateKey, err := LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey4, "test4")
+		assert.NoError(t, err)
+		revocation, err := privateKey.GetRevocationSignature(0, "no reason", now)
+		assert.NoError(t, err)
+		privateKey.Revocations = append(privateKey.Revocations, revocation)
+
+		merged, err := Merge(publicCopy(t, privateKey), publicCopy(t, privateKey))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(merged.Revocations))
+	})
+
+	t.Run("a subkey revocation beats a newer binding signature", func(t *testing.T) {
+		existing := loadPublic(t)
+		existing.Subkeys[0].Sig = &packet.Signature{
+			SigType:      packet.SigTypeSubkeyRevocation,
+			CreationTime: now,
+		}
+
+		update := loadPublic(t)
+		update.Subkeys[0].Sig.CreationTime = later
+
+		merged, err := Merge(existing, update)
+		assert.NoError(t, err)
+		var expected packet.SignatureType = packet.SigTypeSubkeyRevocation
+		assert.Equal(t, expected, merged.Subkeys[0].Sig.SigType)
+	})
+
+	t.Run("errors for different keys", func(t *testing.T) {
+		otherKey, err := LoadFromArmoredPublicKey(exampledata.ExamplePublicKey2)
+		assert.NoError(t, err)
+
+		_, err = Merge(loadPublic(t), otherKey)
+		assert.GotError(t, err)
+	})
+}
+
+func publicCopy(t *testing.T, key *PgpKey) *PgpKey {
+	t.Helper()
+	armored, err := key.Armor()
+	assert.NoError(t, err)
+	publicKey, err := LoadFromArmoredPublicKey(armored)
+	assert.NoError(t, err)
+	return publicKey
+}
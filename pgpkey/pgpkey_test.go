@@ -1276,3 +1276,15 @@ lj2PQbxhrA==
 -----END PGP PUBLIC KEY BLOCK-----`
 
 const exampleUid string = "<test@example.com>"
+
+func TestEncryptArmored(t *testing.T) {
+	key, err := LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey4, "test4")
+	assert.NoError(t, err)
+
+	encrypted, err := key.EncryptArmored([]byte("hello world"))
+	assert.NoError(t, err)
+
+	decrypted, _, err := key.DecryptArmoredToString(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", decrypted)
+}
@@ -21,6 +21,7 @@ import (
 	"bytes"
 
 	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/clearsign"
 )
 
 func (p *PgpKey) MakeArmoredDetachedSignature(dataToSign []byte) (string, error) {
@@ -38,3 +39,26 @@ func (p *PgpKey) MakeArmoredDetachedSignature(dataToSign []byte) (string, error)
 	}
 	return outputBuf.String(), nil
 }
+
+// MakeArmoredClearSignature returns the data wrapped in a cleartext signed message, which
+// remains readable without any OpenPGP software.
+func (p *PgpKey) MakeArmoredClearSignature(dataToSign []byte) (string, error) {
+	err := p.ensureGotDecryptedPrivateKey()
+	if err != nil {
+		return "", err
+	}
+
+	outputBuf := bytes.NewBuffer(nil)
+
+	plaintext, err := clearsign.Encode(outputBuf, p.Entity.PrivateKey, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err = plaintext.Write(dataToSign); err != nil {
+		return "", err
+	}
+	if err = plaintext.Close(); err != nil {
+		return "", err
+	}
+	return outputBuf.String(), nil
+}
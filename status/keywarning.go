@@ -60,6 +60,8 @@ const (
 	ConfigMaintainAutomaticallyNotSet         = 22
 	ConfigPublishToAPINotSet                  = 23
 	ConfigMaintainAutomaticallyButDontPublish = 24
+
+	PrimaryKeyRevoked = 25
 )
 
 type KeyWarning struct {
@@ -89,6 +91,9 @@ func (w KeyWarning) String() string {
 	case PrimaryKeyNoExpiry:
 		return "Primary key never expires"
 
+	case PrimaryKeyRevoked:
+		return colour.Danger("Key has been revoked")
+
 	case NoValidEncryptionSubkey:
 		return colour.Danger("Missing encryption subkey")
 
@@ -58,6 +58,54 @@ func GetKeyWarnings(key pgpkey.PgpKey, config *config.Config) []KeyWarning {
 	return warnings
 }
 
+// GetPublicKeyWarnings returns KeyWarnings for problems with someone else's public key that
+// stop (or will soon stop) us from using it: being revoked, expired or about to expire, or
+// having no valid encryption subkey.
+func GetPublicKeyWarnings(key pgpkey.PgpKey, now time.Time) []KeyWarning {
+	if len(key.Revocations) > 0 {
+		return []KeyWarning{KeyWarning{Type: PrimaryKeyRevoked}}
+	}
+
+	var warnings []KeyWarning
+
+	primaryKeyWarnings := getPrimaryKeyWarnings(key, now)
+	for _, warning := range primaryKeyWarnings {
+		switch warning.Type {
+		case PrimaryKeyExpired, PrimaryKeyOverdueForRotation:
+			warnings = append(warnings, warning)
+		}
+	}
+
+	if ContainsWarningType(primaryKeyWarnings, PrimaryKeyExpired) {
+		return warnings // the subkey is unusable too, no need to say so twice
+	}
+
+	for _, warning := range getEncryptionSubkeyWarnings(key, now) {
+		switch warning.Type {
+		case NoValidEncryptionSubkey, SubkeyOverdueForRotation:
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// IsUnusable returns true if any of the warnings mean the key can't be used to encrypt to.
+func IsUnusable(warnings []KeyWarning) bool {
+	return ContainsWarningType(warnings, PrimaryKeyRevoked) ||
+		ContainsWarningType(warnings, PrimaryKeyExpired) ||
+		ContainsWarningType(warnings, NoValidEncryptionSubkey)
+}
+
+// ContainsWarningType returns true if any of the warnings are of the given type.
+func ContainsWarningType(warnings []KeyWarning, warningType WarningType) bool {
+	for _, warning := range warnings {
+		if warning.Type == warningType {
+			return true
+		}
+	}
+	return false
+}
+
 func getEncryptionSubkeyWarnings(key pgpkey.PgpKey, now time.Time) []KeyWarning {
 	encryptionSubkey := key.EncryptionSubkey(now)
 
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/fluidkeys/crypto/openpgp/packet"
+	"github.com/fluidkeys/fluidkeys/assert"
 	"github.com/fluidkeys/fluidkeys/config"
 	"github.com/fluidkeys/fluidkeys/exampledata"
 	"github.com/fluidkeys/fluidkeys/openpgpdefs/compression"
@@ -141,6 +142,63 @@ func TestGetEncryptionSubkeyWarnings(t *testing.T) {
 	})
 }
 
+func TestGetPublicKeyWarnings(t *testing.T) {
+	now := time.Date(2018, 9, 24, 18, 0, 0, 0, time.UTC)
+	veryFarAway := now.Add(time.Duration(100*24) * time.Hour)
+
+	loadKey := func(t *testing.T) *pgpkey.PgpKey {
+		pgpKey, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey2, "test2")
+		if err != nil {
+			t.Fatalf("Failed to load example test data: %v", err)
+		}
+		err = pgpKey.UpdateExpiryForAllUserIds(veryFarAway, now)
+		if err != nil {
+			t.Fatalf("failed to update expiry on test key")
+		}
+		err = pgpKey.UpdateSubkeyValidUntil(pgpKey.EncryptionSubkey(now).PublicKey.KeyId, veryFarAway, now)
+		if err != nil {
+			t.Fatalf("failed to update expiry on test subkey")
+		}
+		return pgpKey
+	}
+
+	t.Run("with a healthy key", func(t *testing.T) {
+		got := GetPublicKeyWarnings(*loadKey(t), now)
+
+		assertEqualSliceOfKeyWarningTypes(t, []KeyWarning{}, got)
+		assert.Equal(t, false, IsUnusable(got))
+	})
+
+	t.Run("with a revoked key", func(t *testing.T) {
+		pgpKey := loadKey(t)
+		revocation, err := pgpKey.GetRevocationSignature(0, "no reason", now)
+		assert.NoError(t, err)
+		pgpKey.Revocations = append(pgpKey.Revocations, revocation)
+
+		got := GetPublicKeyWarnings(*pgpKey, now)
+
+		assertEqualSliceOfKeyWarningTypes(t, []KeyWarning{KeyWarning{Type: PrimaryKeyRevoked}}, got)
+		assert.Equal(t, true, IsUnusable(got))
+	})
+
+	t.Run("with an expired key", func(t *testing.T) {
+		got := GetPublicKeyWarnings(*loadKey(t), veryFarAway.Add(time.Hour))
+
+		assertEqualSliceOfKeyWarningTypes(t, []KeyWarning{KeyWarning{Type: PrimaryKeyExpired}}, got)
+		assert.Equal(t, true, IsUnusable(got))
+	})
+
+	t.Run("with a key expiring soon", func(t *testing.T) {
+		got := GetPublicKeyWarnings(*loadKey(t), veryFarAway.Add(-24*time.Hour))
+
+		assertEqualSliceOfKeyWarningTypes(t, []KeyWarning{
+			KeyWarning{Type: PrimaryKeyOverdueForRotation},
+			KeyWarning{Type: SubkeyOverdueForRotation},
+		}, got)
+		assert.Equal(t, false, IsUnusable(got))
+	})
+}
+
 func TestGetSignatureHashWarnings(t *testing.T) {
 	// OpenPGP hashes:
 	// https://tools.ietf.org/html/rfc4880#section-9.4
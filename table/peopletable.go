@@ -25,7 +25,7 @@ import (
 type PersonRow struct {
 	Email              string
 	TimeSinceLastFetch string
-	IsAdmin            bool
+	Role               string
 }
 
 // FormatPeopleTable takes a slice of people rows and returns a string containing a formatted table.
@@ -47,7 +47,7 @@ func makePeopleTableRows(peopleRows []PersonRow) (rows []row) {
 		rows = append(rows, []string{
 			peopleRow.Email,
 			peopleRow.TimeSinceLastFetch,
-			returnAdminIfTrue(peopleRow.IsAdmin),
+			peopleRow.Role,
 		})
 		rows = append(rows, placeholderDividerRow)
 	}
@@ -57,12 +57,5 @@ func makePeopleTableRows(peopleRows []PersonRow) (rows []row) {
 var peopleHeader = row{
 	colour.TableHeader("Team Member"),
 	colour.TableHeader("Last Fetched"),
-	colour.TableHeader(""),
-}
-
-func returnAdminIfTrue(status bool) string {
-	if status {
-		return "admin"
-	}
-	return ""
+	colour.TableHeader("Role"),
 }
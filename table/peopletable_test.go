@@ -0,0 +1,35 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package table
+
+import (
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+)
+
+func TestMakePeopleTableRows(t *testing.T) {
+	rows := makePeopleTableRows([]PersonRow{
+		{Email: "owner@example.com", TimeSinceLastFetch: "-", Role: "owner"},
+		{Email: "bot@example.com", TimeSinceLastFetch: "2 hours ago", Role: "bot"},
+	})
+
+	assert.Equal(t, 6, len(rows)) // header and each person, each followed by a divider
+	assert.Equal(t, row{"owner@example.com", "-", "owner"}, rows[2])
+	assert.Equal(t, row{"bot@example.com", "2 hours ago", "bot"}, rows[4])
+}
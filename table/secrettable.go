@@ -0,0 +1,69 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package table
+
+import (
+	"github.com/fluidkeys/fluidkeys/colour"
+)
+
+// A SecretRow is used to format a row in the secrets table
+type SecretRow struct {
+	UUID     string
+	Filename string
+	Size     string
+	Received string
+}
+
+// FormatSecretTable takes a slice of secret rows and returns a string containing a formatted
+// table.
+func FormatSecretTable(secretRows []SecretRow) (output string) {
+	rows := makeSecretTableRows(secretRows)
+	rowStrings := formatTableStringsFromRows(rows)
+	for _, rowString := range rowStrings {
+		output += rowString + "\n"
+	}
+	return output + "\n"
+}
+
+func makeSecretTableRows(secretRows []SecretRow) (rows []row) {
+	placeholderDividerRow := row{divider, divider, divider, divider}
+
+	rows = append(rows, secretHeader)
+	rows = append(rows, placeholderDividerRow)
+	for _, secretRow := range secretRows {
+		filename := secretRow.Filename
+		if filename == "" {
+			filename = colour.Disabled("-")
+		}
+
+		rows = append(rows, []string{
+			secretRow.UUID,
+			filename,
+			secretRow.Size,
+			secretRow.Received,
+		})
+	}
+	return rows
+}
+
+var secretHeader = row{
+	colour.TableHeader("ID"),
+	colour.TableHeader("Filename"),
+	colour.TableHeader("Size"),
+	colour.TableHeader("Received"),
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package team
+
+import (
+	"fmt"
+	"strings"
+
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+)
+
+// ChangeType describes how a person changed between two versions of a roster.
+type ChangeType string
+
+const (
+	// PersonAdded means the person wasn't in the old roster
+	PersonAdded ChangeType = "added"
+	// PersonRemoved means the person isn't in the new roster
+	PersonRemoved ChangeType = "removed"
+	// EmailChanged means the person's key is the same but their email address has changed
+	EmailChanged ChangeType = "email changed"
+	// KeyReplaced means the person has different keys, and some of their old keys are gone
+	KeyReplaced ChangeType = "key replaced"
+	// KeyAdded means the person has an extra key, as well as all their old ones
+	KeyAdded ChangeType = "key added"
+	// KeyRemoved means one of the person's keys is gone, but the rest are unchanged
+	KeyRemoved ChangeType = "key removed"
+	// PromotedToAdmin means the person can now sign the roster
+	PromotedToAdmin ChangeType = "promoted to admin"
+	// DemotedFromAdmin means the person can no longer sign the roster
+	DemotedFromAdmin ChangeType = "demoted from admin"
+	// RoleChanged means the person's role changed without them becoming or stopping being an
+	// admin, for example from member to bot
+	RoleChanged ChangeType = "role changed"
+)
+
+// Change is a single difference to a person between two versions of a roster.
+type Change struct {
+	Type     ChangeType
+	Email    string // the person's email in the new roster, or the old one if they were removed
+	OldEmail string // only set for EmailChanged
+
+	OldFingerprints []fpr.Fingerprint
+	NewFingerprints []fpr.Fingerprint
+
+	OldRole Role
+	NewRole Role
+}
+
+// IsSecurityRelevant returns true if the change gives the person a key they didn't have before.
+// Whoever holds that key can read secrets sent to the person, so it's worth checking with them.
+func (c Change) IsSecurityRelevant() bool {
+	return c.Type == KeyReplaced || c.Type == KeyAdded
+}
+
+func (c Change) String() string {
+	switch c.Type {
+	case PersonAdded:
+		return fmt.Sprintf("%s was added as %s with key %s",
+			c.Email, c.NewRole, formatFingerprints(c.NewFingerprints))
+	case PersonRemoved:
+		return fmt.Sprintf("%s was removed", c.Email)
+	case EmailChanged:
+		return fmt.Sprintf("%s changed their email to %s", c.OldEmail, c.Email)
+	case KeyReplaced:
+		return fmt.Sprintf("%s's key was replaced: %s is now %s", c.Email,
+			formatFingerprints(c.OldFingerprints), formatFingerprints(c.NewFingerprints))
+	case KeyAdded:
+		return fmt.Sprintf("%s added key %s", c.Email,
+			formatFingerprints(subtractFingerprints(c.NewFingerprints, c.OldFingerprints)))
+	case KeyRemoved:
+		return fmt.Sprintf("%s removed key %s", c.Email,
+			formatFingerprints(subtractFingerprints(c.OldFingerprints, c.NewFingerprints)))
+	case PromotedToAdmin, DemotedFromAdmin, RoleChanged:
+		return fmt.Sprintf("%s was %s (%s to %s)", c.Email, c.Type, c.OldRole, c.NewRole)
+	}
+	return fmt.Sprintf("%s: %s", c.Email, c.Type)
+}
+
+// Diff returns the changes to people between the before and after versions of a team. People
+// are matched by email address, or by key if their email changed.
+func Diff(before Team, after Team) (changes []Change) {
+	matched := make([]bool, len(after.People))
+
+	findMatch := func(person Person) int {
+		for i, candidate := range after.People {
+			if !matched[i] && person.emailMatches(candidate) {
+				return i
+			}
+		}
+		for i, candidate := range after.People {
+			if !matched[i] && person.sharesKeyWith(candidate) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, oldPerson := range before.People {
+		i := findMatch(oldPerson)
+		if i == -1 {
+			changes = append(changes, Change{
+				Type:            PersonRemoved,
+				Email:           oldPerson.Email,
+				OldFingerprints: oldPerson.Fingerprints(),
+				OldRole:         oldPerson.GetRole(),
+			})
+			continue
+		}
+		matched[i] = true
+		changes = append(changes, diffPerson(oldPerson, after.People[i])...)
+	}
+
+	for i, newPerson := range after.People {
+		if !matched[i] {
+			changes = append(changes, Change{
+				Type:            PersonAdded,
+				Email:           newPerson.Email,
+				NewFingerprints: newPerson.Fingerprints(),
+				NewRole:         newPerson.GetRole(),
+			})
+		}
+	}
+	return changes
+}
+
+func diffPerson(before Person, after Person) (changes []Change) {
+	change := Change{
+		Email:           after.Email,
+		OldFingerprints: before.Fingerprints(),
+		NewFingerprints: after.Fingerprints(),
+		OldRole:         before.GetRole(),
+		NewRole:         after.GetRole(),
+	}
+
+	if !before.emailMatches(after) {
+		emailChange := change
+		emailChange.Type = EmailChanged
+		emailChange.OldEmail = before.Email
+		changes = append(changes, emailChange)
+	}
+
+	added := subtractFingerprints(after.Fingerprints(), before.Fingerprints())
+	removed := subtractFingerprints(before.Fingerprints(), after.Fingerprints())
+	switch {
+	case len(added) > 0 && len(removed) > 0:
+		change.Type = KeyReplaced
+		changes = append(changes, change)
+	case len(added) > 0:
+		change.Type = KeyAdded
+		changes = append(changes, change)
+	case len(removed) > 0:
+		change.Type = KeyRemoved
+		changes = append(changes, change)
+	}
+
+	switch {
+	case !before.IsAdmin && after.IsAdmin:
+		change.Type = PromotedToAdmin
+		changes = append(changes, change)
+	case before.IsAdmin && !after.IsAdmin:
+		change.Type = DemotedFromAdmin
+		changes = append(changes, change)
+	case before.GetRole() != after.GetRole():
+		change.Type = RoleChanged
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// subtractFingerprints returns the fingerprints in a that aren't in b.
+func subtractFingerprints(a []fpr.Fingerprint, b []fpr.Fingerprint) (result []fpr.Fingerprint) {
+	for _, fingerprint := range a {
+		found := false
+		for _, other := range b {
+			if fingerprint == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, fingerprint)
+		}
+	}
+	return result
+}
+
+func formatFingerprints(fingerprints []fpr.Fingerprint) string {
+	formatted := []string{}
+	for _, fingerprint := range fingerprints {
+		formatted = append(formatted, fingerprint.String())
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,119 @@
+package team
+
+import (
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+)
+
+func TestDiff(t *testing.T) {
+	fp2 := exampledata.ExampleFingerprint2
+	fp3 := exampledata.ExampleFingerprint3
+	fp4 := exampledata.ExampleFingerprint4
+
+	before := Team{
+		People: []Person{
+			{Email: "admin@example.com", Fingerprint: fp2, IsAdmin: true},
+			{Email: "jane@example.com", Fingerprint: fp3},
+		},
+	}
+
+	t.Run("no changes", func(t *testing.T) {
+		assert.Equal(t, 0, len(Diff(before, before)))
+	})
+
+	t.Run("person added", func(t *testing.T) {
+		after := before
+		after.People = append(append([]Person{}, before.People...),
+			Person{Email: "new@example.com", Fingerprint: fp4})
+
+		assert.Equal(t, []Change{{
+			Type:            PersonAdded,
+			Email:           "new@example.com",
+			NewFingerprints: []fpr.Fingerprint{fp4},
+			NewRole:         RoleMember,
+		}}, Diff(before, after))
+	})
+
+	t.Run("person removed", func(t *testing.T) {
+		after := before
+		after.People = before.People[:1]
+
+		assert.Equal(t, []Change{{
+			Type:            PersonRemoved,
+			Email:           "jane@example.com",
+			OldFingerprints: []fpr.Fingerprint{fp3},
+			OldRole:         RoleMember,
+		}}, Diff(before, after))
+	})
+
+	t.Run("email changed", func(t *testing.T) {
+		after := before
+		after.People = []Person{before.People[0], {Email: "jane@new.com", Fingerprint: fp3}}
+
+		changes := Diff(before, after)
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, EmailChanged, changes[0].Type)
+		assert.Equal(t, "jane@example.com", changes[0].OldEmail)
+		assert.Equal(t, "jane@new.com", changes[0].Email)
+		assert.Equal(t, false, changes[0].IsSecurityRelevant())
+	})
+
+	t.Run("key replaced", func(t *testing.T) {
+		after := before
+		after.People = []Person{before.People[0], {Email: "jane@example.com", Fingerprint: fp4}}
+
+		changes := Diff(before, after)
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, KeyReplaced, changes[0].Type)
+		assert.Equal(t, true, changes[0].IsSecurityRelevant())
+		assert.Equal(t,
+			"jane@example.com's key was replaced: "+fp3.String()+" is now "+fp4.String(),
+			changes[0].String())
+	})
+
+	t.Run("key added", func(t *testing.T) {
+		after := before
+		after.People = []Person{before.People[0], {
+			Email:                  "jane@example.com",
+			Fingerprint:            fp3,
+			AdditionalFingerprints: []fpr.Fingerprint{fp4},
+		}}
+
+		changes := Diff(before, after)
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, KeyAdded, changes[0].Type)
+		assert.Equal(t, true, changes[0].IsSecurityRelevant())
+	})
+
+	t.Run("promoted and demoted", func(t *testing.T) {
+		after := before
+		after.People = []Person{
+			{Email: "admin@example.com", Fingerprint: fp2, IsAdmin: false},
+			{Email: "jane@example.com", Fingerprint: fp3, IsAdmin: true},
+		}
+
+		changes := Diff(before, after)
+		assert.Equal(t, 2, len(changes))
+		assert.Equal(t, DemotedFromAdmin, changes[0].Type)
+		assert.Equal(t, "admin@example.com", changes[0].Email)
+		assert.Equal(t, PromotedToAdmin, changes[1].Type)
+		assert.Equal(t, "jane@example.com was promoted to admin (member to admin)",
+			changes[1].String())
+	})
+
+	t.Run("role changed", func(t *testing.T) {
+		after := before
+		after.People = []Person{
+			before.People[0], {Email: "jane@example.com", Fingerprint: fp3, Role: RoleBot},
+		}
+
+		changes := Diff(before, after)
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, RoleChanged, changes[0].Type)
+		assert.Equal(t, RoleMember, changes[0].OldRole)
+		assert.Equal(t, RoleBot, changes[0].NewRole)
+	})
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package team
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Group is a named subset of the team, for example "ops" or "finance", used to share secrets
+// with only some of the team. Members are the email addresses of people in the roster.
+type Group struct {
+	Name    string   `toml:"name"`
+	Members []string `toml:"members"`
+}
+
+// GroupPrefix marks a recipient as the name of a group, for example `--to=@ops`
+const GroupPrefix = "@"
+
+// IsGroupName returns whether the given recipient refers to a group, e.g. `@ops`
+func IsGroupName(recipient string) bool {
+	return strings.HasPrefix(recipient, GroupPrefix)
+}
+
+// GetGroup returns the group with the given name, ignoring case and any leading `@`
+func (t Team) GetGroup(name string) (*Group, error) {
+	name = strings.TrimPrefix(name, GroupPrefix)
+
+	for i := range t.Groups {
+		if strings.EqualFold(t.Groups[i].Name, name) {
+			return &t.Groups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("group not found")
+}
+
+// GroupPeople returns the people in the group with the given name, with the fingerprints
+// listed for them in the roster.
+func (t Team) GroupPeople(name string) ([]Person, error) {
+	group, err := t.GetGroup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	people := []Person{}
+	for _, email := range group.Members {
+		person, err := t.GetPersonForEmail(email)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %s isn't in the team", group.Name, email)
+		}
+		people = append(people, *person)
+	}
+	return people, nil
+}
+
+// validateGroups checks that group names are valid and unique, and that each group only lists
+// people in the team, once.
+func (t Team) validateGroups() error {
+	namesSeen := map[string]bool{}
+
+	for _, group := range t.Groups {
+		if !validGroupName.MatchString(group.Name) {
+			return fmt.Errorf("invalid group name: '%s'", group.Name)
+		}
+
+		name := strings.ToLower(group.Name)
+		if namesSeen[name] {
+			return fmt.Errorf("group listed more than once: %s", group.Name)
+		}
+		namesSeen[name] = true
+
+		membersSeen := map[string]bool{}
+		for _, email := range group.Members {
+			if _, err := t.GetPersonForEmail(email); err != nil {
+				return fmt.Errorf("group %s: %s isn't in the team", group.Name, email)
+			}
+
+			if membersSeen[strings.ToLower(email)] {
+				return fmt.Errorf("group %s lists %s more than once", group.Name, email)
+			}
+			membersSeen[strings.ToLower(email)] = true
+		}
+	}
+	return nil
+}
+
+// renameGroupMember updates any groups listing oldEmail to list newEmail instead.
+func (t *Team) renameGroupMember(oldEmail string, newEmail string) {
+	for i := range t.Groups {
+		members := []string{}
+		for _, email := range t.Groups[i].Members {
+			switch {
+			case strings.EqualFold(email, oldEmail):
+				if !containsEmail(members, newEmail) {
+					members = append(members, newEmail)
+				}
+			case strings.EqualFold(email, newEmail):
+				if !containsEmail(members, newEmail) {
+					members = append(members, email)
+				}
+			default:
+				members = append(members, email)
+			}
+		}
+		t.Groups[i].Members = members
+	}
+}
+
+// removeGroupMember removes the given email from any groups listing it.
+func (t *Team) removeGroupMember(oldEmail string) {
+	for i := range t.Groups {
+		members := []string{}
+		for _, email := range t.Groups[i].Members {
+			if !strings.EqualFold(email, oldEmail) {
+				members = append(members, email)
+			}
+		}
+		t.Groups[i].Members = members
+	}
+}
+
+func containsEmail(emails []string, email string) bool {
+	for _, e := range emails {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return false
+}
+
+var validGroupName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
@@ -0,0 +1,140 @@
+package team
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/gofrs/uuid"
+)
+
+func TestGroups(t *testing.T) {
+	makeTeam := func(groups ...Group) Team {
+		return Team{
+			Name: "Kiffix",
+			UUID: uuid.Must(uuid.FromString("6caa3730-2ca3-47b9-b671-5dc326100431")),
+			People: []Person{
+				{
+					Email:       "test2@example.com",
+					Fingerprint: exampledata.ExampleFingerprint2,
+					IsAdmin:     true,
+				},
+				{
+					Email:       "test3@example.com",
+					Fingerprint: exampledata.ExampleFingerprint3,
+				},
+				{
+					Email:       "test4@example.com",
+					Fingerprint: exampledata.ExampleFingerprint4,
+				},
+			},
+			Groups: groups,
+		}
+	}
+
+	t.Run("Validate", func(t *testing.T) {
+		var tests = []struct {
+			name        string
+			groups      []Group
+			expectedErr error
+		}{
+			{
+				"valid groups",
+				[]Group{
+					{Name: "ops", Members: []string{"test2@example.com", "TEST3@example.com"}},
+					{Name: "finance", Members: []string{"test4@example.com"}},
+					{Name: "empty"},
+				},
+				nil,
+			},
+			{
+				"unknown member",
+				[]Group{{Name: "ops", Members: []string{"unknown@example.com"}}},
+				fmt.Errorf("group ops: unknown@example.com isn't in the team"),
+			},
+			{
+				"member listed twice",
+				[]Group{{Name: "ops", Members: []string{"test2@example.com", "Test2@example.com"}}},
+				fmt.Errorf("group ops lists Test2@example.com more than once"),
+			},
+			{
+				"group listed twice",
+				[]Group{{Name: "ops"}, {Name: "OPS"}},
+				fmt.Errorf("group listed more than once: OPS"),
+			},
+			{
+				"invalid name",
+				[]Group{{Name: "@ops"}},
+				fmt.Errorf("invalid group name: '@ops'"),
+			},
+			{
+				"empty name",
+				[]Group{{Name: ""}},
+				fmt.Errorf("invalid group name: ''"),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				team := makeTeam(test.groups...)
+				assert.Equal(t, test.expectedErr, team.Validate())
+			})
+		}
+	})
+
+	t.Run("GroupPeople", func(t *testing.T) {
+		team := makeTeam(Group{Name: "ops", Members: []string{"test4@example.com", "test2@example.com"}})
+
+		t.Run("with @ prefix and different case", func(t *testing.T) {
+			people, err := team.GroupPeople("@OPS")
+			assert.NoError(t, err)
+			assert.Equal(t, []Person{team.People[2], team.People[0]}, people)
+		})
+
+		t.Run("for missing group", func(t *testing.T) {
+			_, err := team.GroupPeople("@finance")
+			assert.Equal(t, fmt.Errorf("group not found"), err)
+		})
+	})
+
+	t.Run("RemovePerson removes them from groups", func(t *testing.T) {
+		team := makeTeam(Group{Name: "ops", Members: []string{"test3@example.com", "test4@example.com"}})
+
+		assert.NoError(t, team.RemovePerson(exampledata.ExampleFingerprint3))
+		assert.Equal(t, []string{"test4@example.com"}, team.Groups[0].Members)
+		assert.NoError(t, team.Validate())
+	})
+
+	t.Run("UpsertPerson with new email renames them in groups", func(t *testing.T) {
+		team := makeTeam(Group{Name: "ops", Members: []string{"test3@example.com"}})
+
+		team.UpsertPerson(Person{
+			Email:       "new3@example.com",
+			Fingerprint: exampledata.ExampleFingerprint3,
+		})
+		assert.Equal(t, []string{"new3@example.com"}, team.Groups[0].Members)
+		assert.NoError(t, team.Validate())
+	})
+
+	t.Run("groups round trip through the roster", func(t *testing.T) {
+		team := makeTeam(Group{Name: "ops", Members: []string{"test2@example.com"}})
+
+		roster, err := team.serialize()
+		assert.NoError(t, err)
+		if !strings.Contains(roster, "[[group]]") {
+			t.Fatalf("expected roster to contain [[group]], got %s", roster)
+		}
+
+		parsed, err := parse(strings.NewReader(roster))
+		assert.NoError(t, err)
+		assert.Equal(t, team.Groups, parsed.Groups)
+	})
+
+	t.Run("IsGroupName", func(t *testing.T) {
+		assert.Equal(t, true, IsGroupName("@ops"))
+		assert.Equal(t, false, IsGroupName("ops@example.com"))
+		assert.Equal(t, false, IsGroupName("ops"))
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package team
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// RosterHash returns the hash of the given roster, as recorded in the next version of the
+// roster's previous_roster_hash.
+func RosterHash(roster string) string {
+	hash := sha256.Sum256([]byte(roster))
+	return rosterHashPrefix + hex.EncodeToString(hash[:])
+}
+
+// ChainFrom sets the team's version and previous roster hash so that its next roster follows
+// on from the roster of the previous team.
+func (t *Team) ChainFrom(previous Team) {
+	t.Version = previous.Version + 1
+	t.PreviousRosterHash = ""
+
+	if previous.roster != "" {
+		t.PreviousRosterHash = RosterHash(previous.roster)
+	}
+}
+
+// ValidateChain checks that a roster fetched from the key directory follows on from the last
+// roster we accepted for the team. It rejects older rosters (a rollback) and rosters that
+// don't chain from the last one we accepted (a fork).
+// If one or more versions were skipped it returns ErrRosterVersionsSkipped: the intermediate
+// rosters aren't available to check, so the fetched roster can only be accepted if it's signed
+// by the admins of the last roster we accepted, as if it were the next version.
+func ValidateChain(lastAccepted *Team, fetched *Team) error {
+	if fetched.UUID != lastAccepted.UUID {
+		return fmt.Errorf("roster is for team %s, not %s", fetched.UUID, lastAccepted.UUID)
+	}
+
+	switch {
+	case fetched.Version < lastAccepted.Version:
+		return fmt.Errorf("roster v%d is older than v%d accepted previously",
+			fetched.Version, lastAccepted.Version)
+
+	case fetched.Version == lastAccepted.Version:
+		if fetched.roster != lastAccepted.roster {
+			return fmt.Errorf("roster v%d is different to the v%d accepted previously",
+				fetched.Version, lastAccepted.Version)
+		}
+		return nil
+
+	case fetched.Version == lastAccepted.Version+1:
+		return validatePreviousRosterHash(lastAccepted, fetched)
+
+	default:
+		return ErrRosterVersionsSkipped
+	}
+}
+
+// validatePreviousRosterHash checks the after team's previous_roster_hash matches the roster
+// of the before team. If the before team has no roster (it's never been signed) there's nothing
+// to check.
+func validatePreviousRosterHash(before, after *Team) error {
+	if before.roster == "" {
+		return nil
+	}
+
+	if after.PreviousRosterHash != RosterHash(before.roster) {
+		return fmt.Errorf("roster v%d doesn't follow on from v%d (previous_roster_hash is %q)",
+			after.Version, before.Version, after.PreviousRosterHash)
+	}
+	return nil
+}
+
+// ErrRosterVersionsSkipped means a fetched roster is newer than the next version, so it can't
+// be checked against the last roster we accepted.
+var ErrRosterVersionsSkipped = fmt.Errorf("roster versions were skipped")
+
+const rosterHashPrefix = "sha256:"
@@ -0,0 +1,120 @@
+package team
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/gofrs/uuid"
+)
+
+func TestRosterHash(t *testing.T) {
+	assert.Equal(t,
+		"sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		RosterHash("foo"),
+	)
+}
+
+func TestValidateChain(t *testing.T) {
+	lastAccepted := Team{
+		UUID:    uuid.Must(uuid.NewV4()),
+		Version: 2,
+		Name:    "Test team",
+		People: []Person{
+			{Email: "tina@example.com", Fingerprint: exampledata.ExampleFingerprint2, IsAdmin: true},
+		},
+		roster: "roster v2",
+	}
+
+	makeNext := func() Team {
+		next := lastAccepted
+		next.ChainFrom(lastAccepted)
+		next.roster = "roster v3"
+		return next
+	}
+
+	t.Run("ChainFrom sets version and previous_roster_hash", func(t *testing.T) {
+		next := makeNext()
+		assert.Equal(t, uint(3), next.Version)
+		assert.Equal(t, RosterHash("roster v2"), next.PreviousRosterHash)
+	})
+
+	t.Run("accepts the next version chained from the last one", func(t *testing.T) {
+		next := makeNext()
+		assert.NoError(t, ValidateChain(&lastAccepted, &next))
+	})
+
+	t.Run("accepts the same roster again", func(t *testing.T) {
+		same := lastAccepted
+		assert.NoError(t, ValidateChain(&lastAccepted, &same))
+	})
+
+	t.Run("rejects an older version", func(t *testing.T) {
+		older := lastAccepted
+		older.Version = 1
+		older.roster = "roster v1"
+
+		assert.Equal(t,
+			fmt.Errorf("roster v1 is older than v2 accepted previously"),
+			ValidateChain(&lastAccepted, &older),
+		)
+	})
+
+	t.Run("rejects a different roster with the same version", func(t *testing.T) {
+		forked := lastAccepted
+		forked.roster = "another roster v2"
+
+		assert.Equal(t,
+			fmt.Errorf("roster v2 is different to the v2 accepted previously"),
+			ValidateChain(&lastAccepted, &forked),
+		)
+	})
+
+	t.Run("rejects the next version chained from a different roster", func(t *testing.T) {
+		forked := makeNext()
+		forked.PreviousRosterHash = RosterHash("another roster v2")
+
+		assert.GotError(t, ValidateChain(&lastAccepted, &forked))
+	})
+
+	t.Run("rejects the next version with no previous_roster_hash", func(t *testing.T) {
+		next := makeNext()
+		next.PreviousRosterHash = ""
+
+		assert.GotError(t, ValidateChain(&lastAccepted, &next))
+	})
+
+	t.Run("returns ErrRosterVersionsSkipped if versions were skipped", func(t *testing.T) {
+		later := makeNext()
+		later.Version = 5
+
+		assert.Equal(t, ErrRosterVersionsSkipped, ValidateChain(&lastAccepted, &later))
+	})
+
+	t.Run("rejects a roster for a different team", func(t *testing.T) {
+		other := makeNext()
+		other.UUID = uuid.Must(uuid.NewV4())
+
+		assert.GotError(t, ValidateChain(&lastAccepted, &other))
+	})
+}
+
+func TestCommitDraftSavesHistory(t *testing.T) {
+	rosterSaver := makeRosterSaveInTmpDirectory(t)
+	defer os.RemoveAll(rosterSaver.Directory)
+
+	for _, roster := range []string{"roster v1", "roster v2"} {
+		assert.NoError(t, rosterSaver.Save(roster, "signature of "+roster))
+	}
+
+	for _, roster := range []string{"roster v1", "roster v2"} {
+		hash := RosterHash(roster)[len("sha256:"):]
+		historyFilename := filepath.Join(rosterSaver.Directory, "history", hash+".toml")
+
+		assert.Equal(t, roster, readFile(t, historyFilename))
+		assert.Equal(t, "signature of "+roster, readFile(t, historyFilename+".asc"))
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package team
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/clearsign"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/gofrs/uuid"
+)
+
+// Invite is an admin's invitation for an email address to join a team. The admin who made it
+// can treat a request to join from that (verified) email as pre-authorized, without checking
+// the requester's verification details.
+type Invite struct {
+	TeamUUID  uuid.UUID
+	TeamName  string
+	Email     string
+	InvitedBy fpr.Fingerprint
+	ExpiresAt time.Time
+
+	armoredSignedJSON string
+}
+
+// inviteSignedData is the JSON signed by the admin making the invite.
+type inviteSignedData struct {
+	TeamUUID  string    `json:"teamUuid"`
+	TeamName  string    `json:"teamName"`
+	Email     string    `json:"email"`
+	InvitedBy string    `json:"invitedBy"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// MakeInviteToken returns a token inviting the given email to join the team, signed by the
+// admin's private key, which must already be unlocked. The token is only valid until expiresAt.
+func MakeInviteToken(t Team, email string, adminKey *pgpkey.PgpKey, expiresAt time.Time) (
+	token string, err error) {
+
+	if !t.IsAdmin(adminKey.Fingerprint()) {
+		return "", fmt.Errorf("key %s isn't an admin of %s", adminKey.Fingerprint(), t.Name)
+	}
+
+	jsonBytes, err := json.Marshal(inviteSignedData{
+		TeamUUID:  t.UUID.String(),
+		TeamName:  t.Name,
+		Email:     email,
+		InvitedBy: adminKey.Fingerprint().Uri(),
+		ExpiresAt: expiresAt.UTC(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't marshal JSON: %v", err)
+	}
+
+	armoredSignedJSON, err := adminKey.MakeArmoredClearSignature(jsonBytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(armoredSignedJSON)), nil
+}
+
+// ParseInviteToken reads the invite from the token without checking its signature: use Verify
+// before trusting it.
+func ParseInviteToken(token string) (*Invite, error) {
+	armoredSignedJSON, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return nil, fmt.Errorf("invalid invite token: %v", err)
+	}
+
+	block, _ := clearsign.Decode(armoredSignedJSON)
+	if block == nil {
+		return nil, fmt.Errorf("invalid invite token: not clearsigned")
+	}
+
+	var signedData inviteSignedData
+	if err := json.Unmarshal(block.Plaintext, &signedData); err != nil {
+		return nil, fmt.Errorf("invalid invite token: %v", err)
+	}
+
+	teamUUID, err := uuid.FromString(signedData.TeamUUID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid team UUID in invite: %v", err)
+	}
+	invitedBy, err := fpr.Parse(signedData.InvitedBy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fingerprint in invite: %v", err)
+	}
+
+	return &Invite{
+		TeamUUID:          teamUUID,
+		TeamName:          signedData.TeamName,
+		Email:             signedData.Email,
+		InvitedBy:         invitedBy,
+		ExpiresAt:         signedData.ExpiresAt,
+		armoredSignedJSON: string(armoredSignedJSON),
+	}, nil
+}
+
+// HasExpired returns true if the invite is no longer valid at the given time.
+func (i Invite) HasExpired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
+
+// IsFor returns true if the invite is for the given email address.
+func (i Invite) IsFor(email string) bool {
+	return strings.EqualFold(i.Email, email)
+}
+
+// Verify checks that the invite is signed by the given key, that the key belongs to an admin
+// of the team, and that the invite is for the given email and hasn't expired.
+func (i Invite) Verify(t Team, adminKey *pgpkey.PgpKey, email string, now time.Time) error {
+	if adminKey.Fingerprint() != i.InvitedBy {
+		return fmt.Errorf("got key %s but invite is from %s", adminKey.Fingerprint(), i.InvitedBy)
+	}
+
+	block, _ := clearsign.Decode([]byte(i.armoredSignedJSON))
+	if block == nil {
+		return fmt.Errorf("invite isn't clearsigned")
+	}
+
+	if _, err := openpgp.CheckDetachedSignature(
+		openpgp.EntityList{&adminKey.Entity},
+		bytes.NewReader(block.Bytes),
+		block.ArmoredSignature.Body,
+	); err != nil {
+		return fmt.Errorf("bad signature on invite: %v", err)
+	}
+
+	if i.TeamUUID != t.UUID {
+		return fmt.Errorf("invite is for a different team: %s", i.TeamUUID)
+	}
+	if !t.IsAdmin(i.InvitedBy) {
+		return fmt.Errorf("invite is from %s who isn't an admin of the team", i.InvitedBy)
+	}
+	if !i.IsFor(email) {
+		return fmt.Errorf("invite is for %s, not %s", i.Email, email)
+	}
+	if i.HasExpired(now) {
+		return fmt.Errorf("invite expired at %s", i.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
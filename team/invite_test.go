@@ -0,0 +1,91 @@
+package team
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/gofrs/uuid"
+)
+
+func TestInviteToken(t *testing.T) {
+	adminKey, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(
+		exampledata.ExamplePrivateKey2, "test2")
+	assert.NoError(t, err)
+	memberKey, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(
+		exampledata.ExamplePrivateKey3, "test3")
+	assert.NoError(t, err)
+
+	theTeam := Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []Person{
+			{Email: "admin@example.com", Fingerprint: adminKey.Fingerprint(), IsAdmin: true},
+			{Email: "member@example.com", Fingerprint: memberKey.Fingerprint(), IsAdmin: false},
+		},
+	}
+
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(7 * 24 * time.Hour)
+
+	token, err := MakeInviteToken(theTeam, "new@example.com", adminKey, expiresAt)
+	assert.NoError(t, err)
+
+	t.Run("parses the invite", func(t *testing.T) {
+		invite, err := ParseInviteToken(token)
+		assert.NoError(t, err)
+		assert.Equal(t, theTeam.UUID, invite.TeamUUID)
+		assert.Equal(t, "Kiffix", invite.TeamName)
+		assert.Equal(t, "new@example.com", invite.Email)
+		assert.Equal(t, adminKey.Fingerprint(), invite.InvitedBy)
+		assert.Equal(t, true, expiresAt.Equal(invite.ExpiresAt))
+	})
+
+	t.Run("only admins can make invites", func(t *testing.T) {
+		_, err := MakeInviteToken(theTeam, "new@example.com", memberKey, expiresAt)
+		assert.GotError(t, err)
+	})
+
+	t.Run("rejects a token that isn't an invite", func(t *testing.T) {
+		_, err := ParseInviteToken("not-a-token")
+		assert.GotError(t, err)
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		invite, err := ParseInviteToken(token)
+		assert.NoError(t, err)
+
+		t.Run("valid for the invited email, ignoring case", func(t *testing.T) {
+			assert.NoError(t, invite.Verify(theTeam, adminKey, "New@Example.com", now))
+		})
+
+		t.Run("a different email", func(t *testing.T) {
+			assert.GotError(t, invite.Verify(theTeam, adminKey, "other@example.com", now))
+		})
+
+		t.Run("after it has expired", func(t *testing.T) {
+			assert.GotError(t, invite.Verify(theTeam, adminKey, "new@example.com", expiresAt))
+		})
+
+		t.Run("a different team", func(t *testing.T) {
+			otherTeam := theTeam
+			otherTeam.UUID = uuid.Must(uuid.NewV4())
+			assert.GotError(t, invite.Verify(otherTeam, adminKey, "new@example.com", now))
+		})
+
+		t.Run("the admin is no longer an admin", func(t *testing.T) {
+			demotedTeam := theTeam
+			demotedTeam.People = []Person{
+				{Email: "admin@example.com", Fingerprint: adminKey.Fingerprint(), IsAdmin: false},
+				{Email: "member@example.com", Fingerprint: memberKey.Fingerprint(), IsAdmin: true},
+			}
+			assert.GotError(t, invite.Verify(demotedTeam, adminKey, "new@example.com", now))
+		})
+
+		t.Run("checking against a different key", func(t *testing.T) {
+			assert.GotError(t, invite.Verify(theTeam, memberKey, "new@example.com", now))
+		})
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package team
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JoinRules are rules for requests to join the team. They're part of the roster, so they're
+// signed by the team's admins like everything else in it.
+type JoinRules struct {
+	// AllowedEmailDomains, if set, means only people with an email address at one of these
+	// domains (e.g. "example.com") can join. Other requests are rejected automatically.
+	AllowedEmailDomains []string `toml:"allowed_email_domains,omitempty"`
+
+	// AutoApprove means `fk sync`, run by an admin, approves requests from allowed email
+	// domains if the key is published in Fluidkeys with a verified email address.
+	AutoApprove bool `toml:"auto_approve,omitempty"`
+}
+
+// CheckJoinRules returns an error if someone with the given email isn't allowed to join the
+// team by its join rules.
+func (t Team) CheckJoinRules(email string) error {
+	if !t.RestrictsEmailDomains() {
+		return nil
+	}
+
+	domain := emailDomain(email)
+	for _, allowed := range t.JoinRules.AllowedEmailDomains {
+		if domain != "" && strings.EqualFold(domain, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("only email addresses at %s can join the team",
+		strings.Join(t.JoinRules.AllowedEmailDomains, ", "))
+}
+
+// RestrictsEmailDomains returns whether only people at certain email domains can join the team.
+func (t Team) RestrictsEmailDomains() bool {
+	return t.JoinRules != nil && len(t.JoinRules.AllowedEmailDomains) > 0
+}
+
+// AutoApprovesRequests returns whether requests to join the team from allowed email domains
+// can be approved automatically.
+func (t Team) AutoApprovesRequests() bool {
+	return t.RestrictsEmailDomains() && t.JoinRules.AutoApprove
+}
+
+func (r JoinRules) validate() error {
+	for _, domain := range r.AllowedEmailDomains {
+		if domain == "" || strings.ContainsAny(domain, "@ ") || !strings.Contains(domain, ".") {
+			return fmt.Errorf("invalid email domain in join rules: '%s'", domain)
+		}
+	}
+
+	if r.AutoApprove && len(r.AllowedEmailDomains) == 0 {
+		return fmt.Errorf("join rules can't auto approve without allowed email domains")
+	}
+	return nil
+}
+
+// emailDomain returns the part of the email address after the @, or "" if there isn't one.
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at == -1 {
+		return ""
+	}
+	return email[at+1:]
+}
@@ -0,0 +1,110 @@
+package team
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/gofrs/uuid"
+)
+
+func TestJoinRules(t *testing.T) {
+	makeTeam := func(rules *JoinRules) Team {
+		return Team{
+			Name: "Kiffix",
+			UUID: uuid.Must(uuid.FromString("6caa3730-2ca3-47b9-b671-5dc326100431")),
+			People: []Person{
+				{
+					Email:       "test2@example.com",
+					Fingerprint: exampledata.ExampleFingerprint2,
+					IsAdmin:     true,
+				},
+			},
+			JoinRules: rules,
+		}
+	}
+
+	t.Run("CheckJoinRules", func(t *testing.T) {
+		var tests = []struct {
+			name        string
+			rules       *JoinRules
+			email       string
+			expectedErr error
+		}{
+			{"no rules", nil, "anyone@gmail.com", nil},
+			{"no domains", &JoinRules{}, "anyone@gmail.com", nil},
+			{
+				"allowed domain, ignoring case",
+				&JoinRules{AllowedEmailDomains: []string{"example.com", "example.org"}},
+				"Jane@Example.ORG",
+				nil,
+			},
+			{
+				"other domain",
+				&JoinRules{AllowedEmailDomains: []string{"example.com", "example.org"}},
+				"jane@gmail.com",
+				fmt.Errorf("only email addresses at example.com, example.org can join the team"),
+			},
+			{
+				"subdomain isn't allowed",
+				&JoinRules{AllowedEmailDomains: []string{"example.com"}},
+				"jane@evil.example.com",
+				fmt.Errorf("only email addresses at example.com can join the team"),
+			},
+			{
+				"domain in the local part isn't allowed",
+				&JoinRules{AllowedEmailDomains: []string{"example.com"}},
+				"example.com@gmail.com",
+				fmt.Errorf("only email addresses at example.com can join the team"),
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				assert.Equal(t, test.expectedErr, makeTeam(test.rules).CheckJoinRules(test.email))
+			})
+		}
+	})
+
+	t.Run("AutoApprovesRequests", func(t *testing.T) {
+		assert.Equal(t, false, makeTeam(nil).AutoApprovesRequests())
+		assert.Equal(t, false, makeTeam(&JoinRules{
+			AllowedEmailDomains: []string{"example.com"},
+		}).AutoApprovesRequests())
+		assert.Equal(t, true, makeTeam(&JoinRules{
+			AllowedEmailDomains: []string{"example.com"},
+			AutoApprove:         true,
+		}).AutoApprovesRequests())
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		team := makeTeam(&JoinRules{AutoApprove: true})
+		assert.Equal(t,
+			fmt.Errorf("join rules can't auto approve without allowed email domains"),
+			team.Validate())
+
+		team = makeTeam(&JoinRules{AllowedEmailDomains: []string{"@example.com"}})
+		assert.Equal(t,
+			fmt.Errorf("invalid email domain in join rules: '@example.com'"),
+			team.Validate())
+	})
+
+	t.Run("join rules round trip through the roster", func(t *testing.T) {
+		team := makeTeam(&JoinRules{
+			AllowedEmailDomains: []string{"example.com"},
+			AutoApprove:         true,
+		})
+
+		roster, err := team.serialize()
+		assert.NoError(t, err)
+		if !strings.Contains(roster, "[join_rules]") {
+			t.Fatalf("expected roster to contain [join_rules], got %s", roster)
+		}
+
+		parsed, err := parse(strings.NewReader(roster))
+		assert.NoError(t, err)
+		assert.Equal(t, team.JoinRules, parsed.JoinRules)
+	})
+}
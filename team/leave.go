@@ -0,0 +1,128 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package team
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/clearsign"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/gofrs/uuid"
+)
+
+// RequestToLeaveTeam is a member's request to be removed from a team. ArmoredSignedJSON is
+// clearsigned by the member's key so an admin can check the request really came from them
+// before removing them from the roster.
+type RequestToLeaveTeam struct {
+	UUID              uuid.UUID
+	TeamUUID          uuid.UUID
+	Fingerprint       fpr.Fingerprint
+	ArmoredSignedJSON string
+}
+
+// RequestToLeaveValidFor is how long after it was signed a request to leave a team can still
+// be acted on. Older requests are rejected so a captured request can't be replayed later, for
+// example after the member has rejoined the team.
+const RequestToLeaveValidFor = time.Duration(7*24) * time.Hour
+
+// leaveTeamSignedData is the JSON signed by the member leaving the team.
+type leaveTeamSignedData struct {
+	TeamUUID      string    `json:"teamUuid"`
+	Fingerprint   string    `json:"fingerprint"`
+	Timestamp     time.Time `json:"timestamp"`
+	SingleUseUUID string    `json:"singleUseUuid"`
+}
+
+// MakeRequestToLeaveSignedJSON returns clearsigned JSON asking to remove the given key from
+// the team with the given UUID. The private key must already be unlocked.
+func MakeRequestToLeaveSignedJSON(teamUUID uuid.UUID, privateKey *pgpkey.PgpKey, now time.Time) (
+	armoredSignedJSON string, err error) {
+
+	singleUseUUID, err := uuid.NewV4()
+	if err != nil {
+		return "", fmt.Errorf("couldn't generate UUID: %v", err)
+	}
+
+	jsonBytes, err := json.Marshal(leaveTeamSignedData{
+		TeamUUID:      teamUUID.String(),
+		Fingerprint:   privateKey.Fingerprint().Uri(),
+		Timestamp:     now,
+		SingleUseUUID: singleUseUUID.String(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't marshal JSON: %v", err)
+	}
+
+	return privateKey.MakeArmoredClearSignature(jsonBytes)
+}
+
+// Verify checks that the request is clearsigned by the given key, that the signed JSON asks to
+// remove that key from the request's team, and that it was signed within
+// RequestToLeaveValidFor of now. It returns the single use UUID from the signed JSON, which
+// callers should record so the same request can't be acted on twice.
+func (r RequestToLeaveTeam) Verify(key *pgpkey.PgpKey, now time.Time) (
+	singleUseUUID uuid.UUID, err error) {
+
+	if key.Fingerprint() != r.Fingerprint {
+		return uuid.Nil, fmt.Errorf(
+			"got key %s but request is from %s", key.Fingerprint(), r.Fingerprint)
+	}
+
+	block, _ := clearsign.Decode([]byte(r.ArmoredSignedJSON))
+	if block == nil {
+		return uuid.Nil, fmt.Errorf("request isn't clearsigned")
+	}
+
+	if _, err := openpgp.CheckDetachedSignature(
+		openpgp.EntityList{&key.Entity},
+		bytes.NewReader(block.Bytes),
+		block.ArmoredSignature.Body,
+	); err != nil {
+		return uuid.Nil, fmt.Errorf("bad signature on request: %v", err)
+	}
+
+	var signedData leaveTeamSignedData
+	if err := json.Unmarshal(block.Plaintext, &signedData); err != nil {
+		return uuid.Nil, fmt.Errorf("invalid signed JSON: %v", err)
+	}
+
+	if signedData.TeamUUID != r.TeamUUID.String() {
+		return uuid.Nil, fmt.Errorf("request is signed for a different team: %s", signedData.TeamUUID)
+	}
+	if signedData.Fingerprint != r.Fingerprint.Uri() {
+		return uuid.Nil, fmt.Errorf("request is signed for a different key: %s", signedData.Fingerprint)
+	}
+
+	if now.Sub(signedData.Timestamp) > RequestToLeaveValidFor {
+		return uuid.Nil, fmt.Errorf("request was signed too long ago, at %s", signedData.Timestamp)
+	}
+	if signedData.Timestamp.Sub(now) > time.Hour {
+		return uuid.Nil, fmt.Errorf("request is signed in the future, at %s", signedData.Timestamp)
+	}
+
+	singleUseUUID, err = uuid.FromString(signedData.SingleUseUUID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid single use UUID: %v", err)
+	}
+	return singleUseUUID, nil
+}
@@ -0,0 +1,89 @@
+package team
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/gofrs/uuid"
+)
+
+func TestRequestToLeaveTeamVerify(t *testing.T) {
+	key2, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey2, "test2")
+	assert.NoError(t, err)
+	key3, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey3, "test3")
+	assert.NoError(t, err)
+
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	teamUUID := uuid.Must(uuid.NewV4())
+	signedJSON, err := MakeRequestToLeaveSignedJSON(teamUUID, key2, now)
+	assert.NoError(t, err)
+
+	t.Run("request signed by the leaving key", func(t *testing.T) {
+		request := RequestToLeaveTeam{
+			TeamUUID:          teamUUID,
+			Fingerprint:       key2.Fingerprint(),
+			ArmoredSignedJSON: signedJSON,
+		}
+		singleUseUUID, err := request.Verify(key2, now)
+		assert.NoError(t, err)
+
+		t.Run("returns the same single use UUID each time", func(t *testing.T) {
+			again, err := request.Verify(key2, now.Add(time.Hour))
+			assert.NoError(t, err)
+			assert.Equal(t, singleUseUUID, again)
+		})
+	})
+
+	t.Run("request signed too long ago", func(t *testing.T) {
+		request := RequestToLeaveTeam{
+			TeamUUID:          teamUUID,
+			Fingerprint:       key2.Fingerprint(),
+			ArmoredSignedJSON: signedJSON,
+		}
+		_, err := request.Verify(key2, now.Add(RequestToLeaveValidFor+time.Minute))
+		assert.GotError(t, err)
+	})
+
+	t.Run("request signed in the future", func(t *testing.T) {
+		request := RequestToLeaveTeam{
+			TeamUUID:          teamUUID,
+			Fingerprint:       key2.Fingerprint(),
+			ArmoredSignedJSON: signedJSON,
+		}
+		_, err := request.Verify(key2, now.Add(-2*time.Hour))
+		assert.GotError(t, err)
+	})
+
+	t.Run("checking against a different key", func(t *testing.T) {
+		request := RequestToLeaveTeam{
+			TeamUUID:          teamUUID,
+			Fingerprint:       key3.Fingerprint(),
+			ArmoredSignedJSON: signedJSON,
+		}
+		_, err := request.Verify(key3, now)
+		assert.GotError(t, err)
+	})
+
+	t.Run("request signed for a different team", func(t *testing.T) {
+		request := RequestToLeaveTeam{
+			TeamUUID:          uuid.Must(uuid.NewV4()),
+			Fingerprint:       key2.Fingerprint(),
+			ArmoredSignedJSON: signedJSON,
+		}
+		_, err := request.Verify(key2, now)
+		assert.GotError(t, err)
+	})
+
+	t.Run("request that isn't clearsigned", func(t *testing.T) {
+		request := RequestToLeaveTeam{
+			TeamUUID:          teamUUID,
+			Fingerprint:       key2.Fingerprint(),
+			ArmoredSignedJSON: `{"teamUuid": "` + teamUUID.String() + `"}`,
+		}
+		_, err := request.Verify(key2, now)
+		assert.GotError(t, err)
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package team
+
+import "fmt"
+
+// Role is what a person is allowed to do in the team. Owners and admins have is_admin set in
+// the roster, so older versions of Fluidkeys (which only know about is_admin) still check
+// roster signatures correctly.
+type Role string
+
+const (
+	// RoleOwner can sign the roster and is the only role that can add, promote or demote
+	// admins and owners.
+	RoleOwner Role = "owner"
+
+	// RoleAdmin can sign the roster, adding and removing members.
+	RoleAdmin Role = "admin"
+
+	// RoleMember can fetch everyone's keys and share secrets with the team.
+	RoleMember Role = "member"
+
+	// RoleAuditor is a read-only member, for example someone reviewing who has access.
+	RoleAuditor Role = "auditor"
+
+	// RoleBot is a service account, for example a deploy server. Bots can't be admins.
+	RoleBot Role = "bot"
+)
+
+// Roles lists every role, most powerful first.
+var Roles = []Role{RoleOwner, RoleAdmin, RoleMember, RoleAuditor, RoleBot}
+
+// IsAdmin returns true if the role can sign the roster.
+func (r Role) IsAdmin() bool {
+	return r == RoleOwner || r == RoleAdmin
+}
+
+func (r Role) isValid() bool {
+	for _, role := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRole returns the person's role. Rosters made before roles existed only have is_admin,
+// so people without a role are admins or members.
+func (p Person) GetRole() Role {
+	if p.Role != "" {
+		return p.Role
+	}
+	if p.IsAdmin {
+		return RoleAdmin
+	}
+	return RoleMember
+}
+
+// Owners returns the People whose role is owner
+func (t Team) Owners() (owners []Person) {
+	for _, person := range t.People {
+		if person.GetRole() == RoleOwner {
+			owners = append(owners, person)
+		}
+	}
+	return owners
+}
+
+// validateRole checks the person's role is known and agrees with is_admin.
+func (p Person) validateRole() error {
+	if p.Role == "" {
+		return nil
+	}
+
+	if !p.Role.isValid() {
+		return fmt.Errorf("unknown role for %s: %s", p.Email, p.Role)
+	}
+
+	if p.Role == RoleBot && p.IsAdmin {
+		return fmt.Errorf("bots can't be admins: %s", p.Email)
+	}
+
+	if p.Role.IsAdmin() != p.IsAdmin {
+		return fmt.Errorf("%s has role %s, so is_admin must be %v", p.Email, p.Role, p.Role.IsAdmin())
+	}
+	return nil
+}
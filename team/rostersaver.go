@@ -23,6 +23,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // RosterSaver provides a way to do a 2-part save where a roster is saved as a "draft"
@@ -145,6 +146,98 @@ func (rs *RosterSaver) CommitDraft() error {
 	}
 	rs.draftSignatureFilename = ""
 
+	if err := rs.saveHistory(rosterFilename, signatureFilename); err != nil {
+		// the new roster is in place, so don't fail, but the history is incomplete
+		log.Printf("failed to save roster to history: %v", err)
+	}
+
+	// any pending roster has now either been committed or superseded
+	if err := rs.DiscardPending(); err != nil {
+		log.Printf("failed to delete pending roster: %v", err)
+	}
+
+	return nil
+}
+
+// saveHistory copies the roster and signature into the history directory, so every roster we've
+// accepted is kept. They're named by the roster's hash, for example:
+//
+// history/<sha256 of roster>.toml
+// history/<sha256 of roster>.toml.asc
+func (rs *RosterSaver) saveHistory(rosterPath, signaturePath string) error {
+	roster, err := ioutil.ReadFile(rosterPath)
+	if err != nil {
+		return err
+	}
+	signature, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return err
+	}
+
+	historyDirectory := filepath.Join(rs.Directory, historyDirectoryName)
+	if err := os.MkdirAll(historyDirectory, 0700); err != nil {
+		return err
+	}
+
+	hash := strings.TrimPrefix(RosterHash(string(roster)), rosterHashPrefix)
+	historyRosterPath := filepath.Join(historyDirectory, hash+".toml")
+
+	if err := ioutil.WriteFile(historyRosterPath, roster, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(historyRosterPath+".asc", signature, 0600)
+}
+
+// SavePending saves a roster that doesn't yet have enough admin signatures, replacing any
+// previous pending roster. It's kept alongside the current roster while other admins co-sign it.
+func (rs *RosterSaver) SavePending(roster string, signature string) error {
+	if err := rs.SaveDraft(roster, signature); err != nil {
+		return err
+	}
+
+	if err := os.Rename(
+		rs.draftRosterFilename, filepath.Join(rs.Directory, pendingRosterFilename)); err != nil {
+		_ = rs.DiscardDraft()
+		return err
+	}
+	rs.draftRosterFilename = ""
+
+	if err := os.Rename(
+		rs.draftSignatureFilename, filepath.Join(rs.Directory, pendingSignatureFilename)); err != nil {
+		_ = os.Remove(rs.draftSignatureFilename)
+		rs.draftSignatureFilename = ""
+		_ = rs.DiscardPending()
+		return err
+	}
+	rs.draftSignatureFilename = ""
+	return nil
+}
+
+// LoadPending returns the pending roster and signature saved by SavePending, or empty strings
+// if there isn't one.
+func (rs *RosterSaver) LoadPending() (roster string, signature string, err error) {
+	rosterBytes, err := ioutil.ReadFile(filepath.Join(rs.Directory, pendingRosterFilename))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+
+	signatureBytes, err := ioutil.ReadFile(filepath.Join(rs.Directory, pendingSignatureFilename))
+	if err != nil {
+		return "", "", err
+	}
+	return string(rosterBytes), string(signatureBytes), nil
+}
+
+// DiscardPending deletes the pending roster and signature, if there is one.
+func (rs *RosterSaver) DiscardPending() error {
+	for _, filename := range []string{pendingRosterFilename, pendingSignatureFilename} {
+		if err := os.Remove(filepath.Join(rs.Directory, filename)); err != nil &&
+			!os.IsNotExist(err) {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -175,4 +268,8 @@ const (
 	rosterFilename       = "roster.toml"
 	rosterBackupFilename = "roster.toml.BAK"
 	signatureFilename    = "roster.toml.asc"
+	historyDirectoryName = "history"
+
+	pendingRosterFilename    = "roster.toml.pending"
+	pendingSignatureFilename = "roster.toml.pending.asc"
 )
@@ -0,0 +1,233 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package team
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/crypto/openpgp/errors"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+)
+
+// SignaturesRequired returns how many admins must sign the roster: the roster's
+// required_signatures, or 1 if that isn't set.
+func (t Team) SignaturesRequired() int {
+	if t.RequiredSignatures < 1 {
+		return 1
+	}
+	return int(t.RequiredSignatures)
+}
+
+// Signers returns the fingerprints of the keys that have signed the team's roster, out of
+// the given keys.
+func (t Team) Signers(keys []*pgpkey.PgpKey) []fpr.Fingerprint {
+	return RosterSigners(t.roster, t.signature, keys)
+}
+
+// RosterSigners returns the fingerprints of the given keys that made a valid signature over
+// the roster. The signature can contain several ASCII armored detached signatures, one after
+// the other, and each key is only counted once.
+func RosterSigners(roster string, signature string, keys []*pgpkey.PgpKey) []fpr.Fingerprint {
+	signers, _ := checkSignatures(roster, signature, keys)
+	return signers
+}
+
+// checkSignatures returns the keys that made valid signatures over the roster, and the last
+// error from checking a signature, if any. A signature that fails to verify is reported in
+// preference to one made by a key that isn't among the given keys
+// (openpgp/errors.ErrUnknownIssuer), so that error means no signature was shown to be bad.
+func checkSignatures(roster string, signature string, keys []*pgpkey.PgpKey) (
+	signers []fpr.Fingerprint, err error) {
+
+	var keyring openpgp.EntityList
+	for i := range keys {
+		keyring = append(keyring, &keys[i].Entity)
+	}
+
+	signers = []fpr.Fingerprint{}
+	for _, armoredSignature := range splitSignatures(signature) {
+		signer, checkErr := openpgp.CheckArmoredDetachedSignature(
+			keyring,
+			strings.NewReader(roster),
+			strings.NewReader(armoredSignature),
+		)
+		if checkErr != nil {
+			if checkErr != errors.ErrUnknownIssuer || err == nil {
+				err = checkErr
+			}
+			continue
+		}
+
+		fingerprint := fpr.FromBytes(signer.PrimaryKey.Fingerprint)
+		if !fpr.Contains(signers, fingerprint) {
+			signers = append(signers, fingerprint)
+		}
+	}
+	return signers, err
+}
+
+// countSigners returns how many different people made the given signatures, counting any
+// fingerprint that's not in the team as a separate person.
+func (t Team) countSigners(signers []fpr.Fingerprint) int {
+	counted := map[string]bool{}
+	for _, fingerprint := range signers {
+		if person, err := t.GetPersonForFingerprint(fingerprint); err == nil {
+			counted[person.Fingerprint.Hex()] = true
+		} else {
+			counted[fingerprint.Hex()] = true
+		}
+	}
+	return len(counted)
+}
+
+// VerifyRosterSignatures checks that at least `required` of the given keys made a valid
+// signature over the roster. If some, but not enough, keys signed the roster it returns
+// *ErrNotEnoughSignatures.
+func VerifyRosterSignatures(
+	roster string, signature string, keys []*pgpkey.PgpKey, required int) error {
+
+	if signature == "" {
+		return fmt.Errorf("empty signature")
+	}
+
+	signers, err := checkSignatures(roster, signature, keys)
+	switch {
+	case len(signers) == 0 && err != nil:
+		return err
+
+	case len(signers) == 0:
+		return fmt.Errorf("no valid signature from a team admin")
+
+	case len(signers) < required:
+		return &ErrNotEnoughSignatures{Got: len(signers), Required: required}
+	}
+	return nil
+}
+
+// VerifyUpdateSignatures checks that at least `required` different admins of this team made a
+// valid signature over the updated roster, using the given keys. Signers are looked up in this
+// (the current) roster, so admins added by the update don't count, and an admin who signs with
+// several of their keys only counts once. If some, but not enough, admins signed the roster it
+// returns *ErrNotEnoughSignatures.
+func (t Team) VerifyUpdateSignatures(
+	roster string, signature string, keys []*pgpkey.PgpKey, required int) error {
+
+	if signature == "" {
+		return fmt.Errorf("empty signature")
+	}
+
+	signers, err := checkSignatures(roster, signature, keys)
+	admins := t.countAdminSigners(signers)
+	switch {
+	case admins == 0 && err != nil:
+		return err
+
+	case admins == 0:
+		return fmt.Errorf("no valid signature from a team admin")
+
+	case admins < required:
+		return &ErrNotEnoughSignatures{Got: admins, Required: required}
+	}
+	return nil
+}
+
+// VerifyRoleChangeSignatures checks that, if this team has owners and the updated roster makes
+// a change only owners can make (see validateRoleChanges), one of this team's owners made a
+// valid signature over the updated roster. Other admins' clients refuse to make those changes,
+// but this stops them being accepted from a modified client.
+func (t Team) VerifyRoleChangeSignatures(
+	updated Team, roster string, signature string, keys []*pgpkey.PgpKey) error {
+
+	if len(t.Owners()) == 0 {
+		return nil
+	}
+
+	if len(updated.Owners()) == 0 {
+		return fmt.Errorf("team must have at least one owner")
+	}
+
+	changeErr := ownerOnlyChange(&t, &updated)
+	if changeErr == nil {
+		return nil
+	}
+
+	signers, _ := checkSignatures(roster, signature, keys)
+	for _, fingerprint := range signers {
+		if person, err := t.GetPersonForFingerprint(fingerprint); err == nil &&
+			person.GetRole() == RoleOwner {
+			return nil
+		}
+	}
+	return fmt.Errorf("no valid signature from a team owner: %v", changeErr)
+}
+
+// countAdminSigners returns how many different admins of the team made the given signatures,
+// ignoring any fingerprint that isn't an admin's.
+func (t Team) countAdminSigners(signers []fpr.Fingerprint) int {
+	counted := map[string]bool{}
+	for _, fingerprint := range signers {
+		if person, err := t.GetPersonForFingerprint(fingerprint); err == nil && person.IsAdmin {
+			counted[person.Fingerprint.Hex()] = true
+		}
+	}
+	return len(counted)
+}
+
+// AddRosterSignature signs the roster with signingKey, and returns the existing signature
+// followed by the new one.
+func AddRosterSignature(roster string, signature string, signingKey *pgpkey.PgpKey) (
+	string, error) {
+
+	newSignature, err := signingKey.MakeArmoredDetachedSignature([]byte(roster))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign team roster: %v", err)
+	}
+
+	if strings.TrimSpace(signature) == "" {
+		return newSignature, nil
+	}
+	return strings.TrimRight(signature, "\n") + "\n" + newSignature, nil
+}
+
+// ErrNotEnoughSignatures means a roster has valid signatures from admins, but fewer than
+// the required number.
+type ErrNotEnoughSignatures struct {
+	Got      int
+	Required int
+}
+
+func (e *ErrNotEnoughSignatures) Error() string {
+	return fmt.Sprintf("roster has %d of the %d admin signatures required", e.Got, e.Required)
+}
+
+// splitSignatures splits a signature containing several ASCII armored blocks into the
+// individual blocks.
+func splitSignatures(signature string) []string {
+	const endMarker = "-----END PGP SIGNATURE-----"
+
+	signatures := []string{}
+	for _, part := range strings.SplitAfter(signature, endMarker) {
+		if strings.Contains(part, "-----BEGIN PGP SIGNATURE-----") {
+			signatures = append(signatures, strings.TrimLeft(part, "\n")+"\n")
+		}
+	}
+	return signatures
+}
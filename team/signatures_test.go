@@ -0,0 +1,272 @@
+package team
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/fluidkeys/crypto/openpgp/errors"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+	"github.com/gofrs/uuid"
+)
+
+func TestVerifyRosterQuorum(t *testing.T) {
+	key2, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey2, "test2")
+	assert.NoError(t, err)
+	key3, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey3, "test3")
+	assert.NoError(t, err)
+	key4, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey4, "test4")
+	assert.NoError(t, err)
+	adminKeys := []*pgpkey.PgpKey{key2, key3, key4}
+
+	theTeam := Team{
+		UUID:               uuid.Must(uuid.NewV4()),
+		Name:               "Kiffix",
+		RequiredSignatures: 2,
+		People: []Person{
+			{Email: "test2@example.com", Fingerprint: key2.Fingerprint(), IsAdmin: true},
+			{Email: "test3@example.com", Fingerprint: key3.Fingerprint(), IsAdmin: true},
+			{Email: "test4@example.com", Fingerprint: key4.Fingerprint(), IsAdmin: false},
+		},
+	}
+	roster, err := theTeam.PreviewRoster()
+	assert.NoError(t, err)
+
+	oneSignature, err := AddRosterSignature(roster, "", key2)
+	assert.NoError(t, err)
+
+	t.Run("one signature isn't enough", func(t *testing.T) {
+		err := VerifyRoster(roster, oneSignature, adminKeys)
+		assert.Equal(t, &ErrNotEnoughSignatures{Got: 1, Required: 2}, err)
+	})
+
+	t.Run("the same admin signing twice isn't enough", func(t *testing.T) {
+		sameAdminTwice, err := AddRosterSignature(roster, oneSignature, key2)
+		assert.NoError(t, err)
+
+		err = VerifyRoster(roster, sameAdminTwice, adminKeys)
+		assert.Equal(t, &ErrNotEnoughSignatures{Got: 1, Required: 2}, err)
+	})
+
+	t.Run("two admins' signatures are enough", func(t *testing.T) {
+		twoSignatures, err := AddRosterSignature(roster, oneSignature, key3)
+		assert.NoError(t, err)
+
+		assert.NoError(t, VerifyRoster(roster, twoSignatures, adminKeys))
+		assert.Equal(t,
+			[]fpr.Fingerprint{key2.Fingerprint(), key3.Fingerprint()},
+			RosterSigners(roster, twoSignatures, adminKeys),
+		)
+	})
+
+	t.Run("signatures from keys that aren't given don't count", func(t *testing.T) {
+		twoSignatures, err := AddRosterSignature(roster, oneSignature, key4)
+		assert.NoError(t, err)
+
+		err = VerifyRoster(roster, twoSignatures, []*pgpkey.PgpKey{key2, key3})
+		assert.Equal(t, &ErrNotEnoughSignatures{Got: 1, Required: 2}, err)
+	})
+
+	t.Run("signatures only from keys that aren't given are from an unknown issuer",
+		func(t *testing.T) {
+			unknownSignature, err := AddRosterSignature(roster, "", key4)
+			assert.NoError(t, err)
+
+			err = VerifyRoster(roster, unknownSignature, []*pgpkey.PgpKey{key2, key3})
+			assert.Equal(t, errors.ErrUnknownIssuer, err)
+		})
+
+	t.Run("a bad signature is reported over one from an unknown issuer", func(t *testing.T) {
+		badSignature, err := AddRosterSignature(roster+"# changed\n", "", key3)
+		assert.NoError(t, err)
+		signatures, err := AddRosterSignature(roster, badSignature, key4)
+		assert.NoError(t, err)
+
+		err = VerifyRoster(roster, signatures, []*pgpkey.PgpKey{key2, key3})
+		assert.GotError(t, err)
+		assert.Equal(t, false, err == errors.ErrUnknownIssuer)
+	})
+
+	t.Run("required_signatures can't be more than the number of admins", func(t *testing.T) {
+		invalidTeam := theTeam
+		invalidTeam.RequiredSignatures = 3
+
+		assert.Equal(t,
+			fmt.Errorf("required_signatures is 3 but the team only has 2 administrators"),
+			invalidTeam.Validate(),
+		)
+	})
+}
+
+func TestVerifyUpdateSignatures(t *testing.T) {
+	key2, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey2, "test2")
+	assert.NoError(t, err)
+	key3, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey3, "test3")
+	assert.NoError(t, err)
+	key4, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey4, "test4")
+	assert.NoError(t, err)
+	adminKeys := []*pgpkey.PgpKey{key2, key3, key4}
+
+	current := Team{
+		UUID:               uuid.Must(uuid.NewV4()),
+		Name:               "Kiffix",
+		RequiredSignatures: 2,
+		People: []Person{
+			{
+				Email:                  "test2@example.com",
+				Fingerprint:            key2.Fingerprint(),
+				AdditionalFingerprints: []fpr.Fingerprint{key3.Fingerprint()},
+				IsAdmin:                true,
+			},
+			{Email: "test4@example.com", Fingerprint: key4.Fingerprint(), IsAdmin: true},
+		},
+	}
+	updated := current
+	updated.Name = "Kiffix Ltd"
+	roster, err := updated.PreviewRoster()
+	assert.NoError(t, err)
+
+	signedBy := func(keys ...*pgpkey.PgpKey) (signature string) {
+		for _, key := range keys {
+			signature, err = AddRosterSignature(roster, signature, key)
+			assert.NoError(t, err)
+		}
+		return signature
+	}
+
+	t.Run("one admin signing with two of their keys isn't enough", func(t *testing.T) {
+		err := current.VerifyUpdateSignatures(roster, signedBy(key2, key3), adminKeys, 2)
+		assert.Equal(t, &ErrNotEnoughSignatures{Got: 1, Required: 2}, err)
+	})
+
+	t.Run("two admins' signatures are enough", func(t *testing.T) {
+		assert.NoError(t,
+			current.VerifyUpdateSignatures(roster, signedBy(key3, key4), adminKeys, 2))
+	})
+
+	t.Run("signatures from people who aren't admins don't count", func(t *testing.T) {
+		demoted := current
+		demoted.People = []Person{current.People[0], current.People[1]}
+		demoted.People[1].IsAdmin = false
+
+		err := demoted.VerifyUpdateSignatures(roster, signedBy(key2, key4), adminKeys, 2)
+		assert.Equal(t, &ErrNotEnoughSignatures{Got: 1, Required: 2}, err)
+	})
+}
+
+func TestVerifyRoleChangeSignatures(t *testing.T) {
+	key2, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey2, "test2")
+	assert.NoError(t, err)
+	key3, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey3, "test3")
+	assert.NoError(t, err)
+	key4, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(exampledata.ExamplePrivateKey4, "test4")
+	assert.NoError(t, err)
+	adminKeys := []*pgpkey.PgpKey{key2, key4}
+
+	current := Team{
+		UUID: uuid.Must(uuid.NewV4()),
+		Name: "Kiffix",
+		People: []Person{
+			{
+				Email:       "test2@example.com",
+				Fingerprint: key2.Fingerprint(),
+				IsAdmin:     true,
+				Role:        RoleOwner,
+			},
+			{Email: "test4@example.com", Fingerprint: key4.Fingerprint(), IsAdmin: true},
+			{Email: "test3@example.com", Fingerprint: key3.Fingerprint()},
+		},
+	}
+
+	promoted := current
+	promoted.People = []Person{current.People[0], current.People[1], current.People[2]}
+	promoted.People[2].IsAdmin = true
+	promoted.People[2].Role = RoleAdmin
+
+	renamed := current
+	renamed.Name = "Kiffix Ltd"
+
+	signed := func(updated Team, keys ...*pgpkey.PgpKey) (roster, signature string) {
+		roster, err := updated.PreviewRoster()
+		assert.NoError(t, err)
+		for _, key := range keys {
+			signature, err = AddRosterSignature(roster, signature, key)
+			assert.NoError(t, err)
+		}
+		return roster, signature
+	}
+
+	t.Run("role changes signed only by an admin are rejected", func(t *testing.T) {
+		roster, signature := signed(promoted, key4)
+		err := current.VerifyRoleChangeSignatures(promoted, roster, signature, adminKeys)
+		assert.GotError(t, err)
+	})
+
+	t.Run("role changes signed by an owner are accepted", func(t *testing.T) {
+		roster, signature := signed(promoted, key4, key2)
+		assert.NoError(t,
+			current.VerifyRoleChangeSignatures(promoted, roster, signature, adminKeys))
+	})
+
+	t.Run("a key added to an owner by an admin doesn't count as the owner's", func(t *testing.T) {
+		withoutMember := current
+		withoutMember.People = []Person{current.People[0], current.People[1]}
+
+		ownerWithAdminsKey := withoutMember
+		ownerWithAdminsKey.People = []Person{current.People[0], current.People[1]}
+		ownerWithAdminsKey.People[0].AdditionalFingerprints = []fpr.Fingerprint{key3.Fingerprint()}
+
+		roster, signature := signed(ownerWithAdminsKey, key4, key3)
+		keys := []*pgpkey.PgpKey{key2, key3, key4}
+		assert.GotError(t, withoutMember.VerifyRoleChangeSignatures(
+			ownerWithAdminsKey, roster, signature, keys))
+	})
+
+	t.Run("other changes don't need an owner", func(t *testing.T) {
+		roster, signature := signed(renamed, key4)
+		assert.NoError(t,
+			current.VerifyRoleChangeSignatures(renamed, roster, signature, adminKeys))
+	})
+
+	t.Run("teams without owners don't need an owner", func(t *testing.T) {
+		ownerless := current
+		ownerless.People = []Person{current.People[0], current.People[1], current.People[2]}
+		ownerless.People[0].Role = RoleAdmin
+
+		roster, signature := signed(promoted, key4)
+		assert.NoError(t,
+			ownerless.VerifyRoleChangeSignatures(promoted, roster, signature, adminKeys))
+	})
+}
+
+func TestPendingRoster(t *testing.T) {
+	rosterSaver := makeRosterSaveInTmpDirectory(t)
+	defer os.RemoveAll(rosterSaver.Directory)
+
+	t.Run("LoadPending returns nothing when there's no pending roster", func(t *testing.T) {
+		roster, signature, err := rosterSaver.LoadPending()
+		assert.NoError(t, err)
+		assert.Equal(t, "", roster)
+		assert.Equal(t, "", signature)
+	})
+
+	t.Run("SavePending saves a pending roster", func(t *testing.T) {
+		assert.NoError(t, rosterSaver.SavePending("pending roster", "pending signature"))
+
+		roster, signature, err := rosterSaver.LoadPending()
+		assert.NoError(t, err)
+		assert.Equal(t, "pending roster", roster)
+		assert.Equal(t, "pending signature", signature)
+	})
+
+	t.Run("saving a roster deletes the pending roster", func(t *testing.T) {
+		assert.NoError(t, rosterSaver.Save("pending roster", "both signatures"))
+
+		roster, _, err := rosterSaver.LoadPending()
+		assert.NoError(t, err)
+		assert.Equal(t, "", roster)
+	})
+}
@@ -11,7 +11,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/fluidkeys/crypto/openpgp"
 	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
 	"github.com/fluidkeys/fluidkeys/pgpkey"
 	"github.com/gofrs/uuid"
@@ -21,6 +20,32 @@ import (
 // roster.toml
 // Returns a slice of Team
 func LoadTeams(fluidkeysDirectory string) ([]Team, error) {
+	rosters, err := LoadRosters(fluidkeysDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	teams := []Team{}
+	for _, loaded := range rosters {
+		if loaded.Err != nil {
+			return nil, loaded.Err
+		}
+		teams = append(teams, *loaded.Team)
+	}
+	return teams, nil
+}
+
+// LoadedRoster is the result of loading the roster in one team subdirectory. If the roster
+// couldn't be read or loaded, Team is nil and Err says why.
+type LoadedRoster struct {
+	Directory string
+	Team      *Team
+	Err       error
+}
+
+// LoadRosters is like LoadTeams, but returns a result for every team subdirectory rather than
+// stopping at the first roster that fails to load.
+func LoadRosters(fluidkeysDirectory string) ([]LoadedRoster, error) {
 	teamsDirectory, err := getTeamDirectory(fluidkeysDirectory)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get teams directory: %v", err)
@@ -31,26 +56,31 @@ func LoadTeams(fluidkeysDirectory string) ([]Team, error) {
 		return nil, err
 	}
 
-	teams := []Team{}
+	rosters := []LoadedRoster{}
 	for _, subdir := range teamSubdirs {
-		log.Printf("loading team roster from %s\n", subdir)
-		roster, err := ioutil.ReadFile(filepath.Join(subdir, rosterFilename))
-		if err != nil {
-			return nil, fmt.Errorf("failed to read roster from %s: %v", subdir, err)
-		}
+		team, err := loadFromDirectory(subdir)
+		rosters = append(rosters, LoadedRoster{Directory: subdir, Team: team, Err: err})
+	}
+	return rosters, nil
+}
 
-		signature, err := ioutil.ReadFile(filepath.Join(subdir, signatureFilename))
-		if err != nil {
-			return nil, fmt.Errorf("failed to read signature from %s: %v", subdir, err)
-		}
+func loadFromDirectory(subdir string) (*Team, error) {
+	log.Printf("loading team roster from %s\n", subdir)
+	roster, err := ioutil.ReadFile(filepath.Join(subdir, rosterFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roster from %s: %v", subdir, err)
+	}
 
-		team, err := Load(string(roster), string(signature))
-		if err != nil {
-			return nil, fmt.Errorf("failed to load team from %s: %v", subdir, err)
-		}
-		teams = append(teams, *team)
+	signature, err := ioutil.ReadFile(filepath.Join(subdir, signatureFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature from %s: %v", subdir, err)
 	}
-	return teams, nil
+
+	team, err := Load(string(roster), string(signature))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load team from %s: %v", subdir, err)
+	}
+	return team, nil
 }
 
 // Load loads a team from the given roster and signature
@@ -93,24 +123,21 @@ func (t Team) Admins() (admins []Person) {
 }
 
 // VerifyRoster cryptographically checks the signature against the roster, using the given
-// signing keys
+// signing keys. If the roster sets required_signatures, that many different admins must have
+// signed it: an admin signing with several of their keys only counts once.
 func VerifyRoster(roster string, signature string, adminKeys []*pgpkey.PgpKey) error {
-	if signature == "" {
-		return fmt.Errorf("empty signature")
+	t, err := parse(strings.NewReader(roster))
+	if err != nil {
+		return VerifyRosterSignatures(roster, signature, adminKeys, 1)
 	}
-	var keyring openpgp.EntityList
 
-	for i := range adminKeys {
-		key := adminKeys[i]
-		keyring = append(keyring, &key.Entity)
+	if err := VerifyRosterSignatures(roster, signature, adminKeys, 1); err != nil {
+		return err
 	}
 
-	if _, err := openpgp.CheckArmoredDetachedSignature(
-		keyring,
-		strings.NewReader(roster),
-		strings.NewReader(signature),
-	); err != nil {
-		return err
+	signers := t.countSigners(RosterSigners(roster, signature, adminKeys))
+	if signers < t.SignaturesRequired() {
+		return &ErrNotEnoughSignatures{Got: signers, Required: t.SignaturesRequired()}
 	}
 	return nil
 }
@@ -176,26 +203,49 @@ func (t *Team) Validate() error {
 
 	fingerprintsSeen := map[fpr.Fingerprint]bool{}
 	for _, person := range t.People {
-		if _, alreadySeen := fingerprintsSeen[person.Fingerprint]; alreadySeen {
-			return fmt.Errorf("fingerprint listed more than once: %s", person.Fingerprint)
+		for _, fingerprint := range person.Fingerprints() {
+			if _, alreadySeen := fingerprintsSeen[fingerprint]; alreadySeen {
+				return fmt.Errorf("fingerprint listed more than once: %s", fingerprint)
+			}
+			fingerprintsSeen[fingerprint] = true
 		}
-		fingerprintsSeen[person.Fingerprint] = true
 	}
 
 	if len(t.People) == 0 {
 		return fmt.Errorf("team has no members")
 	}
 
+	for _, person := range t.People {
+		if err := person.validateRole(); err != nil {
+			return err
+		}
+	}
+
+	if err := t.validateGroups(); err != nil {
+		return err
+	}
+
+	if t.JoinRules != nil {
+		if err := t.JoinRules.validate(); err != nil {
+			return err
+		}
+	}
+
 	if len(t.Admins()) == 0 {
 		return fmt.Errorf("team has no administrators")
 	}
+
+	if t.SignaturesRequired() > len(t.Admins()) {
+		return fmt.Errorf("required_signatures is %d but the team only has %d administrators",
+			t.SignaturesRequired(), len(t.Admins()))
+	}
 	return nil
 }
 
 // IsAdmin takes a given fingerprint and returns whether they are an administor of the team
 func (t Team) IsAdmin(fingerprint fpr.Fingerprint) bool {
 	for _, person := range t.People {
-		if person.IsAdmin && person.Fingerprint == fingerprint {
+		if person.IsAdmin && person.HasFingerprint(fingerprint) {
 			return true
 		}
 	}
@@ -205,7 +255,7 @@ func (t Team) IsAdmin(fingerprint fpr.Fingerprint) bool {
 // Contains returns whether the given fingerprint is a member of the team
 func (t Team) Contains(fingerprint fpr.Fingerprint) bool {
 	for _, person := range t.People {
-		if person.Fingerprint == fingerprint {
+		if person.HasFingerprint(fingerprint) {
 			return true
 		}
 	}
@@ -213,10 +263,21 @@ func (t Team) Contains(fingerprint fpr.Fingerprint) bool {
 }
 
 // GetPersonForFingerprint takes a fingerprint and returns the person in the team with the
-// matching fingperint.
+// matching fingperint (which may be one of their additional keys).
 func (t *Team) GetPersonForFingerprint(fingerprint fpr.Fingerprint) (*Person, error) {
 	for _, person := range t.People {
-		if person.Fingerprint == fingerprint {
+		if person.HasFingerprint(fingerprint) {
+			return &person, nil
+		}
+	}
+
+	return nil, fmt.Errorf("person not found")
+}
+
+// GetPersonForEmail returns the person in the team with the given email, ignoring case.
+func (t *Team) GetPersonForEmail(email string) (*Person, error) {
+	for _, person := range t.People {
+		if person.emailMatches(Person{Email: email}) {
 			return &person, nil
 		}
 	}
@@ -228,14 +289,20 @@ func (t *Team) GetPersonForFingerprint(fingerprint fpr.Fingerprint) (*Person, er
 // be overwritten, returning an error if so.
 func (t *Team) GetUpsertPersonWarnings(newPerson Person) (existingPerson *Person, err error) {
 	for _, existingPerson := range t.People {
-		if existingPerson == newPerson {
+		if existingPerson.equals(newPerson) {
 			return &existingPerson, ErrPersonWouldNotBeChanged
 		}
 
-		fingerprintsEqual := existingPerson.Fingerprint == newPerson.Fingerprint
+		fingerprintsEqual := existingPerson.sharesKeyWith(newPerson)
 		emailsEqual := existingPerson.emailMatches(newPerson)
 		isAdminsEqual := existingPerson.IsAdmin == newPerson.IsAdmin
 
+		if emailsEqual && isAdminsEqual && existingPerson.hasAllKeysOf(newPerson) &&
+			existingPerson.GetRole() == newPerson.GetRole() {
+			// e.g. a request to join from one of their additional keys
+			return &existingPerson, ErrPersonWouldNotBeChanged
+		}
+
 		// 1. same email, different fingerprint
 		// 2. same fingerprint, different email
 		// 3. promoted to admin
@@ -263,14 +330,28 @@ func (t *Team) GetUpsertPersonWarnings(newPerson Person) (existingPerson *Person
 }
 
 // UpsertPerson adds a Person to the team and removes anyone else that matches either the email or
-// fingerprint.
+// any of their fingerprints.
+// If the new person has the same email as an existing person, and only keys the existing person
+// already has, the existing person's keys are kept.
 func (t *Team) UpsertPerson(newPerson Person) {
 	newPeople := []Person{}
 
 	addedNewPerson := false
 
+	for _, existingPerson := range t.People {
+		if existingPerson.emailMatches(newPerson) && existingPerson.hasAllKeysOf(newPerson) {
+			newPerson.Fingerprint = existingPerson.Fingerprint
+			newPerson.AdditionalFingerprints = existingPerson.AdditionalFingerprints
+		}
+	}
+
 	for _, existingPerson := range t.People {
 		if existingPerson.conflicts(newPerson) {
+			if !addedNewPerson {
+				t.renameGroupMember(existingPerson.Email, newPerson.Email)
+			} else if !existingPerson.emailMatches(newPerson) {
+				t.removeGroupMember(existingPerson.Email)
+			}
 			newPeople = append(newPeople, newPerson)
 			addedNewPerson = true
 		} else {
@@ -285,6 +366,27 @@ func (t *Team) UpsertPerson(newPerson Person) {
 	t.People = newPeople
 }
 
+// RemovePerson removes the person with the given fingerprint (or additional fingerprint) from the
+// team (and any groups), returning an error if they aren't in it.
+func (t *Team) RemovePerson(fingerprint fpr.Fingerprint) error {
+	newPeople := []Person{}
+
+	for _, existingPerson := range t.People {
+		if !existingPerson.HasFingerprint(fingerprint) {
+			newPeople = append(newPeople, existingPerson)
+		} else {
+			t.removeGroupMember(existingPerson.Email)
+		}
+	}
+
+	if len(newPeople) == len(t.People) {
+		return fmt.Errorf("person not found")
+	}
+
+	t.People = newPeople
+	return nil
+}
+
 func getTeamDirectory(fluidkeysDirectory string) (directory string, err error) {
 	teamsDirectory := filepath.Join(fluidkeysDirectory, "teams")
 	err = os.MkdirAll(teamsDirectory, 0700)
@@ -320,6 +422,16 @@ func findTeamSubdirectories(directory string) (teamSubdirs []string, err error)
 	return teamSubdirs, nil
 }
 
+// Slug returns the team's name in lowercase, with anything other than letters, numbers, dashes
+// and underscores replaced, e.g. "Kiffix & Co" becomes "kiffix-and-co". If that leaves nothing,
+// it returns the team's UUID.
+func (t Team) Slug() string {
+	if slug := slugify(t.Name); slug != "" {
+		return slug
+	}
+	return t.UUID.String()
+}
+
 func (t Team) subDirectory() string {
 	slug := slugify(t.Name)
 
@@ -374,18 +486,35 @@ type Team struct {
 	UUID    uuid.UUID `toml:"uuid"`
 	Version uint      `toml:"version"`
 	Name    string    `toml:"name"`
-	People  []Person  `toml:"person"`
+
+	// PreviousRosterHash is the RosterHash of the previous version of the roster. It chains
+	// each version of the roster to the one before, so a roster can't be swapped for an
+	// older one, or a different history.
+	PreviousRosterHash string `toml:"previous_roster_hash,omitempty"`
+
+	// RequiredSignatures is how many admins must sign each new version of the roster. If it's
+	// not set, one admin's signature is enough.
+	RequiredSignatures uint `toml:"required_signatures,omitzero"`
+
+	// JoinRules are optional, see JoinRules.
+	JoinRules *JoinRules `toml:"join_rules,omitempty"`
+
+	People []Person `toml:"person"`
+
+	// Groups are optional named subsets of People, see Group.
+	Groups []Group `toml:"group,omitempty"`
 
 	roster    string
 	signature string
 }
 
-// Fingerprints returns the key fingerprints for all people in the team
+// Fingerprints returns the key fingerprints for all people in the team, including additional
+// fingerprints
 func (t *Team) Fingerprints() []fpr.Fingerprint {
 	fingerprints := []fpr.Fingerprint{}
 
 	for _, person := range t.People {
-		fingerprints = append(fingerprints, person.Fingerprint)
+		fingerprints = append(fingerprints, person.Fingerprints()...)
 	}
 	return fingerprints
 }
@@ -395,10 +524,74 @@ type Person struct {
 	Email       string          `toml:"email"`
 	Fingerprint fpr.Fingerprint `toml:"fingerprint"`
 	IsAdmin     bool            `toml:"is_admin"`
+
+	// Role is optional: see GetRole.
+	Role Role `toml:"role,omitempty"`
+
+	// AdditionalFingerprints are the person's other keys, for example on a second laptop or a
+	// hardware token. Every key is treated as that person, and secrets for the team are
+	// encrypted to all of them.
+	AdditionalFingerprints []fpr.Fingerprint `toml:"additional_fingerprints,omitempty"`
+}
+
+// Fingerprints returns all of the person's keys, starting with Fingerprint
+func (p Person) Fingerprints() []fpr.Fingerprint {
+	return append([]fpr.Fingerprint{p.Fingerprint}, p.AdditionalFingerprints...)
+}
+
+// HasFingerprint returns whether the given fingerprint is one of the person's keys
+func (p Person) HasFingerprint(fingerprint fpr.Fingerprint) bool {
+	return fpr.Contains(p.Fingerprints(), fingerprint)
+}
+
+// WithFingerprint returns a copy of the person with the given key (which should be one of their
+// keys) as their Fingerprint and their other keys as AdditionalFingerprints.
+func (p Person) WithFingerprint(fingerprint fpr.Fingerprint) Person {
+	others := []fpr.Fingerprint{}
+	for _, f := range p.Fingerprints() {
+		if f != fingerprint {
+			others = append(others, f)
+		}
+	}
+	if len(others) == 0 {
+		others = nil
+	}
+
+	p.Fingerprint = fingerprint
+	p.AdditionalFingerprints = others
+	return p
+}
+
+// equals returns true if the people are the same, treating a missing role as the role implied
+// by is_admin.
+func (p Person) equals(other Person) bool {
+	return p.Email == other.Email && p.Fingerprint == other.Fingerprint &&
+		p.hasAllKeysOf(other) && other.hasAllKeysOf(p) &&
+		p.IsAdmin == other.IsAdmin && p.GetRole() == other.GetRole()
 }
 
 func (p Person) conflicts(other Person) bool {
-	return p.emailMatches(other) || p.Fingerprint == other.Fingerprint
+	return p.emailMatches(other) || p.sharesKeyWith(other)
+}
+
+// sharesKeyWith returns true if any of the other person's keys are also one of this person's
+func (p Person) sharesKeyWith(other Person) bool {
+	for _, fingerprint := range other.Fingerprints() {
+		if p.HasFingerprint(fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAllKeysOf returns true if all the other person's keys are also this person's
+func (p Person) hasAllKeysOf(other Person) bool {
+	for _, fingerprint := range other.Fingerprints() {
+		if !p.HasFingerprint(fingerprint) {
+			return false
+		}
+	}
+	return true
 }
 
 func (p Person) emailMatches(other Person) bool {
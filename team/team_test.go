@@ -92,6 +92,57 @@ func TestLoadTeams(t *testing.T) {
 
 }
 
+func TestLoadRosters(t *testing.T) {
+	person := Person{
+		Email:       "test3@example.com",
+		Fingerprint: exampledata.ExampleFingerprint3,
+		IsAdmin:     true,
+	}
+
+	goodTeam := Team{
+		Name:   "Good Team",
+		UUID:   uuid.Must(uuid.NewV4()),
+		People: []Person{person},
+	}
+	brokenTeam := Team{
+		Name:   "Broken Team",
+		UUID:   uuid.Must(uuid.NewV4()),
+		People: []Person{person},
+	}
+
+	fluidkeysDir := testhelpers.Maketemp(t)
+
+	saveTeam(t, &brokenTeam, fluidkeysDir)
+	saveTeam(t, &goodTeam, fluidkeysDir)
+
+	brokenDir, err := Directory(brokenTeam, fluidkeysDir)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(
+		filepath.Join(brokenDir, rosterFilename), []byte("not [valid toml"), 0600))
+
+	rosters, err := LoadRosters(fluidkeysDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(rosters))
+
+	t.Run("returns an error for the roster that doesn't load", func(t *testing.T) {
+		assert.Equal(t, brokenDir, rosters[0].Directory)
+		assert.GotError(t, rosters[0].Err)
+		if rosters[0].Team != nil {
+			t.Fatalf("expected no team for broken roster, got %v", rosters[0].Team)
+		}
+	})
+
+	t.Run("still loads the other roster", func(t *testing.T) {
+		assert.NoError(t, rosters[1].Err)
+		assert.Equal(t, goodTeam.UUID, rosters[1].Team.UUID)
+	})
+
+	t.Run("LoadTeams fails on the broken roster", func(t *testing.T) {
+		_, err := LoadTeams(fluidkeysDir)
+		assert.GotError(t, err)
+	})
+}
+
 func TestLoad(t *testing.T) {
 	roster := `# Fluidkeys CIC team roster. Everyone in the team has a copy of this file.
 #
@@ -321,6 +372,42 @@ func TestValidate(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("with unknown role", func(t *testing.T) {
+		team := Team{
+			Name: "Kiffix",
+			UUID: uuid.Must(uuid.NewV4()),
+			People: []Person{
+				{
+					Email:       "test@example.com",
+					Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+					IsAdmin:     true,
+					Role:        "superuser",
+				},
+			},
+		}
+
+		err := team.Validate()
+		assert.Equal(t, fmt.Errorf("unknown role for test@example.com: superuser"), err)
+	})
+
+	t.Run("with role that doesn't match is_admin", func(t *testing.T) {
+		team := Team{
+			Name: "Kiffix",
+			UUID: uuid.Must(uuid.NewV4()),
+			People: []Person{
+				{
+					Email:       "test@example.com",
+					Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+					IsAdmin:     false,
+					Role:        RoleOwner,
+				},
+			},
+		}
+
+		err := team.Validate()
+		assert.Equal(t, fmt.Errorf("test@example.com has role owner, so is_admin must be true"), err)
+	})
+
 	t.Run("missing UUID", func(t *testing.T) {
 		team := Team{
 			Name: "Kiffix",
@@ -441,6 +528,102 @@ func TestVerifyRoster(t *testing.T) {
 
 }
 
+func TestAdditionalFingerprints(t *testing.T) {
+	person := Person{
+		Email:       "test2@example.com",
+		Fingerprint: exampledata.ExampleFingerprint2,
+		IsAdmin:     true,
+		AdditionalFingerprints: []fpr.Fingerprint{
+			exampledata.ExampleFingerprint3,
+		},
+	}
+	testTeam := Team{
+		Name:   "Kiffix",
+		UUID:   uuid.Must(uuid.NewV4()),
+		People: []Person{person},
+	}
+
+	t.Run("Validate rejects a fingerprint listed for two people", func(t *testing.T) {
+		invalid := testTeam
+		invalid.People = []Person{person, {
+			Email:       "test3@example.com",
+			Fingerprint: exampledata.ExampleFingerprint3,
+		}}
+		assert.Equal(t,
+			fmt.Errorf("fingerprint listed more than once: %s", exampledata.ExampleFingerprint3),
+			invalid.Validate())
+	})
+
+	t.Run("Validate rejects a fingerprint listed twice for one person", func(t *testing.T) {
+		invalid := testTeam
+		invalid.People = []Person{person}
+		invalid.People[0].AdditionalFingerprints = []fpr.Fingerprint{
+			exampledata.ExampleFingerprint2,
+		}
+		assert.Equal(t,
+			fmt.Errorf("fingerprint listed more than once: %s", exampledata.ExampleFingerprint2),
+			invalid.Validate())
+	})
+
+	t.Run("every key is treated as the person", func(t *testing.T) {
+		assert.Equal(t, true, testTeam.Contains(exampledata.ExampleFingerprint3))
+		assert.Equal(t, true, testTeam.IsAdmin(exampledata.ExampleFingerprint3))
+
+		got, err := testTeam.GetPersonForFingerprint(exampledata.ExampleFingerprint3)
+		assert.NoError(t, err)
+		assert.Equal(t, "test2@example.com", got.Email)
+
+		assert.Equal(t,
+			[]fpr.Fingerprint{exampledata.ExampleFingerprint2, exampledata.ExampleFingerprint3},
+			testTeam.Fingerprints())
+	})
+
+	t.Run("WithFingerprint puts the given key first", func(t *testing.T) {
+		got := person.WithFingerprint(exampledata.ExampleFingerprint3)
+		assert.Equal(t, exampledata.ExampleFingerprint3, got.Fingerprint)
+		assert.Equal(t, []fpr.Fingerprint{exampledata.ExampleFingerprint2},
+			got.AdditionalFingerprints)
+	})
+
+	t.Run("RemovePerson by an additional key", func(t *testing.T) {
+		removeFrom := testTeam
+		removeFrom.People = []Person{person, {
+			Email:       "test4@example.com",
+			Fingerprint: exampledata.ExampleFingerprint4,
+		}}
+		assert.NoError(t, removeFrom.RemovePerson(exampledata.ExampleFingerprint3))
+		assert.Equal(t, 1, len(removeFrom.People))
+		assert.Equal(t, "test4@example.com", removeFrom.People[0].Email)
+	})
+
+	t.Run("VerifyRoster counts an admin signing with two keys once", func(t *testing.T) {
+		key2, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(
+			exampledata.ExamplePrivateKey2, "test2")
+		assert.NoError(t, err)
+		key3, err := pgpkey.LoadFromArmoredEncryptedPrivateKey(
+			exampledata.ExamplePrivateKey3, "test3")
+		assert.NoError(t, err)
+
+		multisig := testTeam
+		multisig.People = []Person{person, {
+			Email:       "test4@example.com",
+			Fingerprint: exampledata.ExampleFingerprint4,
+			IsAdmin:     true,
+		}}
+		multisig.RequiredSignatures = 2
+
+		roster, err := multisig.serialize()
+		assert.NoError(t, err)
+		signature, err := AddRosterSignature(roster, "", key2)
+		assert.NoError(t, err)
+		signature, err = AddRosterSignature(roster, signature, key3)
+		assert.NoError(t, err)
+
+		err = VerifyRoster(roster, signature, []*pgpkey.PgpKey{key2, key3})
+		assert.Equal(t, &ErrNotEnoughSignatures{Got: 1, Required: 2}, err)
+	})
+}
+
 func TestIsAdmin(t *testing.T) {
 	adminPerson := Person{
 		Email:       "admin@example.com",
@@ -533,6 +716,56 @@ func TestGetPersonForFingerprint(t *testing.T) {
 	})
 }
 
+func TestRemovePerson(t *testing.T) {
+	personOne := Person{
+		Email:       "test@example.com",
+		Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+	}
+	personTwo := Person{
+		Email:       "another@example.com",
+		Fingerprint: fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+	}
+
+	t.Run("with a team member with matching fingerprint", func(t *testing.T) {
+		team := Team{People: []Person{personOne, personTwo}}
+
+		err := team.RemovePerson(personOne.Fingerprint)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Person{personTwo}, team.People)
+	})
+
+	t.Run("with no matching fingerprints", func(t *testing.T) {
+		team := Team{People: []Person{personOne, personTwo}}
+
+		err := team.RemovePerson(fpr.MustParse("EEEEFFFFEEEEFFFFEEEEFFFFEEEEFFFFEEEEFFFF"))
+
+		assert.Equal(t, fmt.Errorf("person not found"), err)
+		assert.Equal(t, []Person{personOne, personTwo}, team.People)
+	})
+}
+
+func TestGetPersonForEmail(t *testing.T) {
+	personOne := Person{
+		Email:       "test@example.com",
+		Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+	}
+	team := Team{People: []Person{personOne}}
+
+	t.Run("with matching email in a different case", func(t *testing.T) {
+		person, err := team.GetPersonForEmail("Test@Example.com")
+
+		assert.NoError(t, err)
+		assert.Equal(t, personOne, *person)
+	})
+
+	t.Run("with no matching email", func(t *testing.T) {
+		_, err := team.GetPersonForEmail("another@example.com")
+
+		assert.Equal(t, fmt.Errorf("person not found"), err)
+	})
+}
+
 func TestGetUpsertPersonWarnings(t *testing.T) {
 
 	var tests = []struct {
@@ -722,6 +955,107 @@ func TestGetUpsertPersonWarnings(t *testing.T) {
 				},
 			},
 		},
+		{
+			"adding a person with one of their additional keys",
+			Person{
+				Email:       "person@example.com",
+				Fingerprint: fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+			},
+			Team{
+				UUID: uuid.Must(uuid.FromString("8e26e4df0d474f7f9a07a37b2aa92104")),
+				Name: "Kiffix",
+				People: []Person{
+					{
+						Email:       "person@example.com",
+						Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+						AdditionalFingerprints: []fpr.Fingerprint{
+							fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+						},
+					},
+				},
+			},
+			ErrPersonWouldNotBeChanged,
+			Team{
+				UUID: uuid.Must(uuid.FromString("8e26e4df0d474f7f9a07a37b2aa92104")),
+				Name: "Kiffix",
+				People: []Person{
+					{
+						Email:       "person@example.com",
+						Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+						AdditionalFingerprints: []fpr.Fingerprint{
+							fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+						},
+					},
+				},
+			},
+		},
+		{
+			"promoting a person with several keys keeps their keys",
+			Person{
+				Email:       "person@example.com",
+				Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+				IsAdmin:     true,
+			},
+			Team{
+				UUID: uuid.Must(uuid.FromString("8e26e4df0d474f7f9a07a37b2aa92104")),
+				Name: "Kiffix",
+				People: []Person{
+					{
+						Email:       "person@example.com",
+						Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+						AdditionalFingerprints: []fpr.Fingerprint{
+							fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+						},
+					},
+				},
+			},
+			ErrPersonWouldBePromotedToAdmin,
+			Team{
+				UUID: uuid.Must(uuid.FromString("8e26e4df0d474f7f9a07a37b2aa92104")),
+				Name: "Kiffix",
+				People: []Person{
+					{
+						Email:       "person@example.com",
+						Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+						AdditionalFingerprints: []fpr.Fingerprint{
+							fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+						},
+						IsAdmin: true,
+					},
+				},
+			},
+		},
+		{
+			"adding someone else with another person's additional key",
+			Person{
+				Email:       "another@example.com",
+				Fingerprint: fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+			},
+			Team{
+				UUID: uuid.Must(uuid.FromString("8e26e4df0d474f7f9a07a37b2aa92104")),
+				Name: "Kiffix",
+				People: []Person{
+					{
+						Email:       "person@example.com",
+						Fingerprint: fpr.MustParse("AAAABBBBAAAABBBBAAAAAAAABBBBAAAABBBBAAAA"),
+						AdditionalFingerprints: []fpr.Fingerprint{
+							fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+						},
+					},
+				},
+			},
+			ErrEmailWouldBeUpdated,
+			Team{
+				UUID: uuid.Must(uuid.FromString("8e26e4df0d474f7f9a07a37b2aa92104")),
+				Name: "Kiffix",
+				People: []Person{
+					{
+						Email:       "another@example.com",
+						Fingerprint: fpr.MustParse("CCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDDCCCCDDDD"),
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
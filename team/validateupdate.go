@@ -1,6 +1,10 @@
 package team
 
-import "fmt"
+import (
+	"fmt"
+
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
+)
 
 // ValidateUpdate tests whether the given changes to a team are OK
 func ValidateUpdate(before *Team, after *Team, me *Person) error {
@@ -36,6 +40,10 @@ func ValidateUpdate(before *Team, after *Team, me *Person) error {
 		return err
 	}
 
+	if err := validatePreviousRosterHash(before, after); err != nil {
+		return err
+	}
+
 	if err := validateIAmAdmin(before, after, me); err != nil {
 		return err
 	}
@@ -48,6 +56,10 @@ func ValidateUpdate(before *Team, after *Team, me *Person) error {
 		return err
 	}
 
+	if err := validateRoleChanges(before, after, me); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -93,3 +105,76 @@ func validateCannotDemoteSelfAsAdmin(before, after *Team, me *Person) error {
 	}
 	return nil
 }
+
+// validateRoleChanges checks I'm allowed to make the changes to people's roles. Once a team has
+// owners, only owners can add, remove, promote or demote admins and owners, and the team must
+// keep at least one owner. Teams without owners work as before: any admin can change anything.
+func validateRoleChanges(before, after *Team, me *Person) error {
+	if len(before.Owners()) == 0 {
+		return nil
+	}
+
+	if len(after.Owners()) == 0 {
+		return fmt.Errorf("team must have at least one owner")
+	}
+
+	myPerson, err := before.GetPersonForFingerprint(me.Fingerprint)
+	if err != nil {
+		return err
+	}
+	if myPerson.GetRole() == RoleOwner {
+		return nil
+	}
+	return ownerOnlyChange(before, after)
+}
+
+// ownerOnlyChange returns an error describing the first change between the rosters that only
+// an owner can make (adding, removing, promoting or demoting an admin or owner, or changing their
+// keys), or nil if there isn't one.
+// Changing an admin's or owner's keys is owner-only since a key added to an owner would make the
+// roster treat whoever holds it as that owner.
+func ownerOnlyChange(before, after *Team) error {
+	for _, afterPerson := range after.People {
+		beforePerson, err := before.GetPersonForFingerprint(afterPerson.Fingerprint)
+		if err != nil { // added to the team
+			if afterPerson.GetRole().IsAdmin() {
+				return fmt.Errorf("only owners can add %ss: %s", afterPerson.GetRole(),
+					afterPerson.Email)
+			}
+			continue
+		}
+
+		beforeRole, afterRole := beforePerson.GetRole(), afterPerson.GetRole()
+		if beforeRole != afterRole && (beforeRole.IsAdmin() || afterRole.IsAdmin()) {
+			return fmt.Errorf("only owners can change %s from %s to %s",
+				afterPerson.Email, beforeRole, afterRole)
+		}
+
+		if (beforeRole.IsAdmin() || afterRole.IsAdmin()) &&
+			!sameFingerprints(beforePerson.Fingerprints(), afterPerson.Fingerprints()) {
+			return fmt.Errorf("only owners can change the keys of %ss: %s", afterRole,
+				afterPerson.Email)
+		}
+	}
+
+	for _, beforePerson := range before.People {
+		if beforePerson.GetRole().IsAdmin() && !after.Contains(beforePerson.Fingerprint) {
+			return fmt.Errorf("only owners can remove %ss: %s", beforePerson.GetRole(),
+				beforePerson.Email)
+		}
+	}
+	return nil
+}
+
+// sameFingerprints returns whether the two lists contain the same fingerprints, in any order.
+func sameFingerprints(a, b []fpr.Fingerprint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, fingerprint := range a {
+		if !fpr.Contains(b, fingerprint) {
+			return false
+		}
+	}
+	return true
+}
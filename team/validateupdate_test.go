@@ -6,6 +6,7 @@ import (
 
 	"github.com/fluidkeys/fluidkeys/assert"
 	"github.com/fluidkeys/fluidkeys/exampledata"
+	fpr "github.com/fluidkeys/fluidkeys/fingerprint"
 	"github.com/gofrs/uuid"
 )
 
@@ -215,6 +216,168 @@ func TestValidateUpate(t *testing.T) {
 	})
 }
 
+func TestValidateUpdatePreviousRosterHash(t *testing.T) {
+	tina := Person{
+		Email:       "tina@example.com",
+		Fingerprint: exampledata.ExampleFingerprint2,
+		IsAdmin:     true,
+	}
+
+	before := Team{
+		UUID:    uuid.Must(uuid.NewV4()),
+		Version: 2,
+		Name:    "Test team",
+		People:  []Person{tina},
+		roster:  "roster v2",
+	}
+
+	t.Run("ok when chained from the current roster", func(t *testing.T) {
+		after := before
+		after.ChainFrom(before)
+
+		assert.NoError(t, ValidateUpdate(&before, &after, &tina))
+	})
+
+	t.Run("error when chained from a different roster", func(t *testing.T) {
+		after := before
+		after.ChainFrom(before)
+		after.PreviousRosterHash = RosterHash("roster v1")
+
+		assert.GotError(t, ValidateUpdate(&before, &after, &tina))
+	})
+}
+
+func TestValidateUpdateRoles(t *testing.T) {
+	owner := Person{
+		Email:       "owner@example.com",
+		Fingerprint: exampledata.ExampleFingerprint2,
+		IsAdmin:     true,
+		Role:        RoleOwner,
+	}
+	admin := Person{
+		Email:       "admin@example.com",
+		Fingerprint: exampledata.ExampleFingerprint3,
+		IsAdmin:     true,
+		Role:        RoleAdmin,
+	}
+	member := Person{
+		Email:       "member@example.com",
+		Fingerprint: exampledata.ExampleFingerprint4,
+		Role:        RoleMember,
+	}
+	memberAsAdmin := member
+	memberAsAdmin.IsAdmin = true
+	memberAsAdmin.Role = RoleAdmin
+
+	memberAsAuditor := member
+	memberAsAuditor.Role = RoleAuditor
+
+	team := Team{
+		UUID:    uuid.Must(uuid.NewV4()),
+		Version: 2,
+		Name:    "Test team",
+		People:  []Person{owner, admin, member},
+	}
+
+	t.Run("an owner can promote a member to admin", func(t *testing.T) {
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{owner, admin, memberAsAdmin}
+
+		assert.NoError(t, ValidateUpdate(&team, &updatedTeam, &owner))
+	})
+
+	t.Run("an admin can't promote a member to admin", func(t *testing.T) {
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{owner, admin, memberAsAdmin}
+
+		assert.Equal(t,
+			fmt.Errorf("only owners can change member@example.com from member to admin"),
+			ValidateUpdate(&team, &updatedTeam, &admin))
+	})
+
+	t.Run("an admin can change a member to auditor", func(t *testing.T) {
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{owner, admin, memberAsAuditor}
+
+		assert.NoError(t, ValidateUpdate(&team, &updatedTeam, &admin))
+	})
+
+	t.Run("an admin can't remove an owner", func(t *testing.T) {
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{admin, member}
+
+		assert.GotError(t, ValidateUpdate(&team, &updatedTeam, &admin))
+	})
+
+	t.Run("an admin can't add an admin", func(t *testing.T) {
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{owner, admin, memberAsAdmin}
+		before := team
+		before.People = []Person{owner, admin}
+
+		assert.Equal(t,
+			fmt.Errorf("only owners can add admins: member@example.com"),
+			ValidateUpdate(&before, &updatedTeam, &admin))
+	})
+
+	t.Run("an admin can't add a key to an owner", func(t *testing.T) {
+		ownerWithAdminsKey := owner
+		ownerWithAdminsKey.AdditionalFingerprints = []fpr.Fingerprint{
+			fpr.MustParse("E63AF0E74EB5DE3FB72DC981C991709318ECBDE7"),
+		}
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{ownerWithAdminsKey, admin, member}
+
+		assert.Equal(t,
+			fmt.Errorf("only owners can change the keys of owners: owner@example.com"),
+			ValidateUpdate(&team, &updatedTeam, &admin))
+	})
+
+	t.Run("an admin can't change their own keys", func(t *testing.T) {
+		adminWithNewKey := admin
+		adminWithNewKey.Fingerprint = fpr.MustParse("E63AF0E74EB5DE3FB72DC981C991709318ECBDE7")
+		adminWithNewKey.AdditionalFingerprints = []fpr.Fingerprint{admin.Fingerprint}
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{owner, adminWithNewKey, member}
+
+		assert.GotError(t, ValidateUpdate(&team, &updatedTeam, &admin))
+	})
+
+	t.Run("an admin can change a member's keys", func(t *testing.T) {
+		memberWithNewKey := member
+		memberWithNewKey.AdditionalFingerprints = []fpr.Fingerprint{
+			fpr.MustParse("E63AF0E74EB5DE3FB72DC981C991709318ECBDE7"),
+		}
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{owner, admin, memberWithNewKey}
+
+		assert.NoError(t, ValidateUpdate(&team, &updatedTeam, &admin))
+	})
+
+	t.Run("error if the last owner is demoted", func(t *testing.T) {
+		ownerAsAdmin := owner
+		ownerAsAdmin.Role = RoleAdmin
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{ownerAsAdmin, admin, member}
+
+		assert.Equal(t,
+			fmt.Errorf("team must have at least one owner"),
+			ValidateUpdate(&team, &updatedTeam, &owner))
+	})
+
+	t.Run("error if a bot is made an admin", func(t *testing.T) {
+		bot := member
+		bot.Role = RoleBot
+		bot.IsAdmin = true
+		updatedTeam := bumpVersion(team)
+		updatedTeam.People = []Person{owner, admin, bot}
+
+		assert.Equal(t,
+			fmt.Errorf("bots can't be admins: member@example.com"),
+			ValidateUpdate(&team, &updatedTeam, &owner))
+	})
+}
+
 func bumpVersion(t Team) Team {
 	newTeam := t
 	newTeam.Version = t.Version + 1
@@ -54,14 +54,16 @@ func (u User) GroupedMemberships() (groupedMemberships []GroupedMembership, err
 	for _, t := range allTeams {
 		teamMemberships := []TeamMembership{}
 		for _, person := range t.People {
-			if isMember(myFingerprints, person.Fingerprint) {
-				teamMemberships = append(
-					teamMemberships,
-					TeamMembership{
-						Team: t,
-						Me:   person,
-					},
-				)
+			for _, fingerprint := range person.Fingerprints() {
+				if isMember(myFingerprints, fingerprint) {
+					teamMemberships = append(
+						teamMemberships,
+						TeamMembership{
+							Team: t,
+							Me:   person.WithFingerprint(fingerprint),
+						},
+					)
+				}
 			}
 		}
 		if len(teamMemberships) > 0 {
@@ -92,14 +94,16 @@ func (u User) Memberships() (teamMemberships []TeamMembership, err error) {
 
 	for _, t := range allTeams {
 		for _, person := range t.People {
-			if isMember(myFingerprints, person.Fingerprint) {
-				teamMemberships = append(
-					teamMemberships,
-					TeamMembership{
-						Team: t,
-						Me:   person,
-					},
-				)
+			for _, fingerprint := range person.Fingerprints() {
+				if isMember(myFingerprints, fingerprint) {
+					teamMemberships = append(
+						teamMemberships,
+						TeamMembership{
+							Team: t,
+							Me:   person.WithFingerprint(fingerprint),
+						},
+					)
+				}
 			}
 		}
 
@@ -196,6 +200,8 @@ func fingerprintHasMembership(fingerprint fpr.Fingerprint, memberships []TeamMem
 
 // TeamMembership records a connection between a Person and a Team. It's possible for several of
 // a user's keys to all be in the same team.
+// Me is the user's entry in the roster, with Fingerprint set to the user's key: if the user has
+// several keys in the roster, there's a TeamMembership for each key in GnuPG.
 type TeamMembership struct {
 	Team team.Team
 	Me   team.Person
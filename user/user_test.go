@@ -129,6 +129,47 @@ func TestMembershipFunctions(t *testing.T) {
 
 }
 
+func TestMembershipsWithAdditionalFingerprints(t *testing.T) {
+	fluidkeysDir := testhelpers.Maketemp(t)
+	db := database.New(fluidkeysDir)
+	user := New(fluidkeysDir, &db)
+
+	// my laptop key is in GnuPG, but my hardware token key isn't
+	laptopFingerprint := exampledata.ExampleFingerprint2
+	tokenFingerprint := exampledata.ExampleFingerprint3
+
+	me := team.Person{
+		Email:                  "me@example.com",
+		Fingerprint:            tokenFingerprint,
+		AdditionalFingerprints: []fingerprint.Fingerprint{laptopFingerprint},
+		IsAdmin:                true,
+	}
+	team1 := team.Team{
+		Name:   "Team 1",
+		UUID:   uuid.Must(uuid.NewV4()),
+		People: []team.Person{me},
+	}
+	saveTeam(t, &team1, fluidkeysDir)
+	assert.NoError(t, db.RecordFingerprintImportedIntoGnuPG(laptopFingerprint))
+
+	got, err := user.Memberships()
+	assert.NoError(t, err)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 team membership, got %d: %v", len(got), got)
+	}
+
+	t.Run("Me has my key in GnuPG as Fingerprint", func(t *testing.T) {
+		assert.Equal(t, laptopFingerprint, got[0].Me.Fingerprint)
+		assert.Equal(t, []fingerprint.Fingerprint{tokenFingerprint}, got[0].Me.AdditionalFingerprints)
+	})
+
+	t.Run("key isn't orphaned", func(t *testing.T) {
+		orphaned, err := user.OrphanedFingerprints()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(orphaned))
+	})
+}
+
 func TestRequestFunctions(t *testing.T) {
 	fluidkeysDir := testhelpers.Maketemp(t) // fake fluidkeysDirectory
 	db := database.New(fluidkeysDir)
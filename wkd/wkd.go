@@ -0,0 +1,203 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package wkd looks up public keys using Web Key Directory, where people publish their keys
+// on their own email domain.
+// See https://tools.ietf.org/html/draft-koch-openpgp-webkey-service
+package wkd
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/fluidkeys/keylookup"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+)
+
+// Client looks up keys using both the advanced and direct WKD methods.
+type Client struct {
+	// HTTPClient is used for all requests. Replace it to use a different transport, for
+	// example in tests.
+	HTTPClient *http.Client
+}
+
+// New returns a Client with a sensible timeout.
+func New() *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// Name returns "Web Key Directory"
+func (c *Client) Name() string {
+	return "Web Key Directory"
+}
+
+// FindByEmail fetches the keys for the given email address, trying the advanced method first,
+// then falling back to the direct method. Only keys with a user ID matching the email address
+// are returned. If neither method has any keys, it returns keylookup.ErrNotFound.
+func (c *Client) FindByEmail(email string) ([]*pgpkey.PgpKey, error) {
+	advancedURL, err := AdvancedURL(email)
+	if err != nil {
+		return nil, err
+	}
+	directURL, err := DirectURL(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if keys, err := c.fetchKeys(advancedURL, email); err == nil {
+		return keys, nil
+	}
+
+	// The advanced method usually fails because the openpgpkey subdomain doesn't exist, so
+	// ignore its error and let the direct method decide.
+	return c.fetchKeys(directURL, email)
+}
+
+func (c *Client) fetchKeys(lookupURL *url.URL, email string) ([]*pgpkey.PgpKey, error) {
+	response, err := c.HTTPClient.Get(lookupURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, keylookup.ErrNotFound
+
+	case response.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s returned HTTP %d", lookupURL.Host, response.StatusCode)
+	}
+
+	body := bytes.NewBuffer(nil)
+	bytesRead, err := io.CopyN(body, response.Body, maxResponseBytes+1)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("error reading response from %s: %v", lookupURL.Host, err)
+	} else if bytesRead > maxResponseBytes {
+		return nil, fmt.Errorf("response from %s is too large", lookupURL.Host)
+	}
+
+	keys, err := parseKeys(body.Bytes(), email)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key from %s: %v", lookupURL.Host, err)
+	}
+	if len(keys) == 0 {
+		return nil, keylookup.ErrNotFound
+	}
+	return keys, nil
+}
+
+// parseKeys reads binary (or, leniently, armored) keys and returns those with a user ID for the
+// given email.
+func parseKeys(data []byte, email string) (keys []*pgpkey.PgpKey, err error) {
+	var entities openpgp.EntityList
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range entities {
+		keys = append(keys, &pgpkey.PgpKey{Entity: *entity})
+	}
+	return keylookup.FilterByEmail(keys, email), nil
+}
+
+// AdvancedURL returns the URL for the advanced method, for example for joe.doe@example.com:
+// https://openpgpkey.example.com/.well-known/openpgpkey/example.com/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe
+func AdvancedURL(email string) (*url.URL, error) {
+	localPart, domain, err := splitEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &url.URL{
+		Scheme:   "https",
+		Host:     "openpgpkey." + domain,
+		Path:     "/.well-known/openpgpkey/" + domain + "/hu/" + HashLocalPart(localPart),
+		RawQuery: url.Values{"l": []string{localPart}}.Encode(),
+	}, nil
+}
+
+// DirectURL returns the URL for the direct method, for example for joe.doe@example.com:
+// https://example.com/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe
+func DirectURL(email string) (*url.URL, error) {
+	localPart, domain, err := splitEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &url.URL{
+		Scheme:   "https",
+		Host:     domain,
+		Path:     "/.well-known/openpgpkey/hu/" + HashLocalPart(localPart),
+		RawQuery: url.Values{"l": []string{localPart}}.Encode(),
+	}, nil
+}
+
+// HashLocalPart returns the z-base-32 encoded SHA-1 hash of the lowercased local part of an
+// email address.
+func HashLocalPart(localPart string) string {
+	hash := sha1.Sum([]byte(strings.ToLower(localPart)))
+	return zBase32Encode(hash[:])
+}
+
+func splitEmail(email string) (localPart string, domain string, err error) {
+	at := strings.LastIndex(email, "@")
+	if at < 1 || at == len(email)-1 {
+		return "", "", fmt.Errorf("invalid email address: %s", email)
+	}
+	return email[:at], strings.ToLower(email[at+1:]), nil
+}
+
+// zBase32Encode encodes data using the human-oriented base-32 alphabet from
+// http://philzimmermann.com/docs/human-oriented-base-32-encoding.txt
+func zBase32Encode(data []byte) string {
+	var encoded strings.Builder
+	var buffer, bits uint
+
+	for _, b := range data {
+		buffer = buffer<<8 | uint(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			encoded.WriteByte(zBase32Alphabet[(buffer>>bits)&0x1f])
+		}
+	}
+	if bits > 0 {
+		encoded.WriteByte(zBase32Alphabet[(buffer<<(5-bits))&0x1f])
+	}
+	return encoded.String()
+}
+
+const (
+	zBase32Alphabet  = "ybndrfg8ejkmcpqxot1uwisza345h769"
+	defaultTimeout   = 10 * time.Second
+	maxResponseBytes = 1024 * 1024
+)
@@ -0,0 +1,145 @@
+// Copyright 2019 Paul Furley and Ian Drysdale
+//
+// This file is part of Fluidkeys Client which makes it simple to use OpenPGP.
+//
+// Fluidkeys Client is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Fluidkeys Client is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Fluidkeys Client.  If not, see <https://www.gnu.org/licenses/>.
+
+package wkd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fluidkeys/crypto/openpgp"
+	"github.com/fluidkeys/fluidkeys/assert"
+	"github.com/fluidkeys/fluidkeys/exampledata"
+	"github.com/fluidkeys/fluidkeys/keylookup"
+	"github.com/fluidkeys/fluidkeys/pgpkey"
+)
+
+func TestHashLocalPart(t *testing.T) {
+	t.Run("example from the spec", func(t *testing.T) {
+		assert.Equal(t, "iy9q119eutrkn8s1mk4r39qejnbu3n5q", HashLocalPart("Joe.Doe"))
+	})
+
+	t.Run("hash is case insensitive", func(t *testing.T) {
+		assert.Equal(t, HashLocalPart("joe.doe"), HashLocalPart("JOE.DOE"))
+	})
+}
+
+func TestURLs(t *testing.T) {
+	t.Run("advanced method", func(t *testing.T) {
+		gotURL, err := AdvancedURL("Joe.Doe@Example.ORG")
+		assert.NoError(t, err)
+		assert.Equal(t,
+			"https://openpgpkey.example.org/.well-known/openpgpkey/example.org/hu/"+
+				"iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+			gotURL.String(),
+		)
+	})
+
+	t.Run("direct method", func(t *testing.T) {
+		gotURL, err := DirectURL("Joe.Doe@Example.ORG")
+		assert.NoError(t, err)
+		assert.Equal(t,
+			"https://example.org/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+			gotURL.String(),
+		)
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		_, err := DirectURL("joe.example.org")
+		assert.GotError(t, err)
+	})
+}
+
+func TestFindByEmail(t *testing.T) {
+	key2 := binaryKey(t, exampledata.ExamplePublicKey2)
+	key3 := binaryKey(t, exampledata.ExamplePublicKey3)
+
+	// responses maps host + path to the binary key served there
+	responses := map[string][]byte{
+		"openpgpkey.example.com/.well-known/openpgpkey/example.com/hu/" + HashLocalPart("test2"): key2,
+		"example.com/.well-known/openpgpkey/hu/" + HashLocalPart("test3"):                        key3,
+		"example.com/.well-known/openpgpkey/hu/" + HashLocalPart("mismatch"):                     key3,
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			if response, ok := responses[host+r.URL.Path]; ok {
+				w.Header().Set("Content-Type", "application/octet-stream")
+				w.Write(response)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		},
+	))
+	defer server.Close()
+
+	client := &Client{HTTPClient: clientForServer(server)}
+
+	t.Run("advanced method", func(t *testing.T) {
+		keys, err := client.FindByEmail("test2@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(keys))
+		assert.Equal(t, exampledata.ExampleFingerprint2, keys[0].Fingerprint())
+	})
+
+	t.Run("falls back to direct method", func(t *testing.T) {
+		keys, err := client.FindByEmail("test3@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(keys))
+		assert.Equal(t, exampledata.ExampleFingerprint3, keys[0].Fingerprint())
+	})
+
+	t.Run("ignores keys without a matching user ID", func(t *testing.T) {
+		_, err := client.FindByEmail("mismatch@example.com")
+		assert.Equal(t, keylookup.ErrNotFound, err)
+	})
+
+	t.Run("no key published", func(t *testing.T) {
+		_, err := client.FindByEmail("nobody@example.com")
+		assert.Equal(t, keylookup.ErrNotFound, err)
+	})
+}
+
+func binaryKey(t *testing.T, armoredPublicKey string) []byte {
+	t.Helper()
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredPublicKey))
+	assert.NoError(t, err)
+
+	key := pgpkey.PgpKey{Entity: *entities[0]}
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, key.Serialize(buffer))
+	return buffer.Bytes()
+}
+
+// clientForServer returns an HTTP client which sends requests for any host to the test server,
+// trusting its certificate (which is valid for example.com and *.example.com)
+func clientForServer(server *httptest.Server) *http.Client {
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	return &http.Client{Transport: transport}
+}